@@ -0,0 +1,73 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// CatFile is one git cat-file --batch process that lookups of files at
+// revisions go through, rather than spawning git each time; fork and exec
+// are slow on macOS especially.
+type CatFile struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+// ErrNoObject is what Read returns for an object git doesn't have.
+var ErrNoObject = errors.New("not found")
+
+// StartCatFile starts cat-file in the repository at dir.
+func StartCatFile(dir string) (*CatFile, error) {
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = dir
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return &CatFile{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// Read asks for one object; the reply is a header line, then the contents
+// and a newline, or just "<name> missing".
+func (c *CatFile) Read(name string) ([]byte, error) {
+	if _, err := io.WriteString(c.in, name+"\n"); err != nil {
+		return nil, err
+	}
+	header, err := c.out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		// "<name> missing" or "<name> ambiguous"
+		return nil, ErrNoObject
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: bad header %q", header)
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(c.out, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+// Stop ends the process.
+func (c *CatFile) Stop() {
+	c.in.Close()
+	c.cmd.Wait()
+}
@@ -0,0 +1,31 @@
+// Package git runs and reads git for gd: the diff options it parses output
+// under, git status and numstat records, and a cat-file process.
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DiffOpts pins down the diff format against user config that would change
+// it: external diff drivers, color.ui=always, custom or missing a/ b/
+// prefixes, and diff.renames=false. gd parses the output, so it needs git's
+// defaults.
+var DiffOpts = []string{"--no-ext-diff", "--no-color", "--src-prefix=a/", "--dst-prefix=b/", "--find-renames"}
+
+// Debugf logs input the package skipped; gd points it at its --debug log.
+var Debugf = func(format string, args ...any) {}
+
+// StderrError surfaces a failed command's stderr, which explains the problem
+// far better than the exit status.
+func StderrError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// EmptyTree is git's well-known empty tree, for diffing a repository with no
+// commits yet.
+const EmptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
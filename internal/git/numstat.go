@@ -0,0 +1,82 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FileStat is what the tree shows about a file besides its name.
+type FileStat struct {
+	Added      int
+	Deleted    int
+	Binary     bool
+	Similarity int // percent, for renames and copies
+	Leftovers  int // added lines flagged by --leftovers
+}
+
+// NumstatEntry is one file from git diff --numstat: its stat and, for renames and copies,
+// the path it came from.
+type NumstatEntry struct {
+	Path     string
+	OrigPath string
+	Status   byte // git's letter for the change, as in A or R
+	Stat     FileStat
+}
+
+// ParseNumstat reads --raw --numstat -z output: the raw records, which carry
+// the similarity of renames, come first, then the counts in the same order.
+func ParseNumstat(out string) ([]NumstatEntry, error) {
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	var entries []NumstatEntry
+	var byPath map[string]*NumstatEntry // built once the raw records are all in
+	for i := 0; i < len(fields) && fields[i] != ""; i++ {
+		f := fields[i]
+		if strings.HasPrefix(f, ":") {
+			// :100644 100644 abc123 def456 R087 NUL old NUL new
+			meta := strings.Fields(f)
+			if len(meta) < 5 || i+1 >= len(fields) {
+				return nil, fmt.Errorf("git diff --raw: malformed record %q", f)
+			}
+			e := NumstatEntry{Path: fields[i+1], Status: meta[4][0]}
+			i++
+			if status := meta[4]; status[0] == 'R' || status[0] == 'C' {
+				if i+1 >= len(fields) {
+					return nil, fmt.Errorf("git diff --raw: rename without a new path")
+				}
+				e.OrigPath, e.Path = e.Path, fields[i+1]
+				i++
+				e.Stat.Similarity, _ = strconv.Atoi(status[1:])
+			}
+			entries = append(entries, e)
+			continue
+		}
+		// added TAB deleted TAB path, or an empty path then old NUL new
+		parts := strings.SplitN(f, "\t", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("git diff --numstat: malformed record %q", f)
+		}
+		path := parts[2]
+		if path == "" && i+2 < len(fields) {
+			path = fields[i+2]
+			i += 2
+		}
+		if byPath == nil {
+			byPath = make(map[string]*NumstatEntry, len(entries))
+			for j := range entries {
+				byPath[entries[j].Path] = &entries[j]
+			}
+		}
+		e, ok := byPath[path]
+		if !ok {
+			continue
+		}
+		if parts[0] == "-" {
+			e.Stat.Binary = true
+			continue
+		}
+		e.Stat.Added, _ = strconv.Atoi(parts[0])
+		e.Stat.Deleted, _ = strconv.Atoi(parts[1])
+	}
+	return entries, nil
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// FileStatus is a changed file and how it changed.
+type FileStatus struct {
+	Path       string
+	OrigPath   string
+	Staged     bool
+	Unstaged   bool
+	Untracked  bool
+	Conflicted bool     // unmerged in a merge, rebase, or cherry-pick
+	Diff       string   // preloaded diff, e.g. read from stdin
+	Stat       FileStat // line counts, from one numstat over every file
+	Code       string   // git's letters for the change in the index and the worktree, as in "AM"
+}
+
+// StatusLabel is the letters the tree shows for f.
+func (f FileStatus) StatusLabel() string {
+	if f.Untracked {
+		return "?"
+	}
+	if f.Conflicted {
+		return "U"
+	}
+	var s string
+	if f.Staged {
+		s += "S"
+	}
+	if f.Unstaged {
+		s += "M"
+	}
+	if s == "" {
+		// --main and ranges have no index or worktree side, just git's letter
+		s = strings.TrimSpace(f.Code)
+	}
+	return s
+}
+
+// ParseStatus reads git status --porcelain -z output, from git or go-git, for
+// the repository at root.
+func ParseStatus(root, out string) ([]FileStatus, error) {
+	seen := map[string]*FileStatus{}
+	var order []string
+	entries := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			Debugf("git status: skipped entry %q", entry)
+			continue
+		}
+		x, y := entry[0], entry[1]
+		path := entry[3:]
+		var origPath string
+		// a rename or copy is followed by the path it came from
+		if (x == 'R' || x == 'C' || y == 'R' || y == 'C') && i+1 < len(entries) {
+			origPath = entries[i+1]
+			i++
+		}
+		if x == '?' && strings.HasSuffix(path, "/") {
+			// a directory with nothing tracked in it stands for its files
+			paths, err := UntrackedFiles(root, path)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range paths {
+				if _, ok := seen[p]; !ok && p != "" {
+					seen[p] = &FileStatus{Path: p, Untracked: true}
+					order = append(order, p)
+				}
+			}
+			continue
+		}
+		fs, ok := seen[path]
+		if !ok {
+			fs = &FileStatus{Path: path, OrigPath: origPath}
+			seen[path] = fs
+			order = append(order, path)
+		}
+		switch {
+		case x == '?' && y == '?':
+			fs.Untracked = true
+		case x == 'U' || y == 'U' || x == 'A' && y == 'A' || x == 'D' && y == 'D':
+			fs.Conflicted = true
+		default:
+			fs.Code = string([]byte{x, y})
+			if x != ' ' && x != '?' {
+				fs.Staged = true
+			}
+			if y != ' ' && y != '?' {
+				fs.Unstaged = true
+			}
+		}
+	}
+	files := make([]FileStatus, 0, len(order))
+	for _, p := range order {
+		files = append(files, *seen[p])
+	}
+	return files, nil
+}
+
+// UntrackedFiles lists the untracked files under dir in the repository at
+// root, which git status reports as the directory alone when nothing in it is
+// tracked.
+func UntrackedFiles(root, dir string) ([]string, error) {
+	out, err := exec.Command("git", "-C", root, "ls-files", "--others", "--exclude-standard", "-z", "--", dir).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files: %w", StderrError(err))
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00"), nil
+}
@@ -0,0 +1,95 @@
+// Package tree lays changed files out as the directory tree gd lists them in.
+package tree
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// Node is a directory, a group, or a file of the tree.
+type Node struct {
+	Name     string
+	File     *git.FileStatus
+	Children []*Node
+	Group    bool // a heading such as an owner rather than a directory
+}
+
+// Line is one row of the tree as listed: a file, or a directory or group
+// holding the lines below it.
+type Line struct {
+	File   *git.FileStatus
+	Indent int
+	Name   string
+	Path   string // a directory's or group's path, for collapsing it
+}
+
+// Build nests files in their directories, directories first and each level
+// in name order.
+func Build(files []git.FileStatus) []*Node {
+	root := &Node{}
+	for i := range files {
+		f := &files[i]
+		// git paths use /, but --patch files written on Windows may not
+		parts := strings.Split(filepath.ToSlash(f.Path), "/")
+		cur := root
+		for j, part := range parts {
+			if j == len(parts)-1 {
+				cur.Children = append(cur.Children, &Node{Name: part, File: f})
+			} else {
+				var found *Node
+				for _, ch := range cur.Children {
+					if ch.File == nil && ch.Name == part {
+						found = ch
+						break
+					}
+				}
+				if found == nil {
+					found = &Node{Name: part}
+					cur.Children = append(cur.Children, found)
+				}
+				cur = found
+			}
+		}
+	}
+	sortTree(root.Children)
+	return root.Children
+}
+
+func sortTree(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		iDir := nodes[i].File == nil
+		jDir := nodes[j].File == nil
+		if iDir != jDir {
+			return iDir
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, n := range nodes {
+		if n.File == nil {
+			sortTree(n.Children)
+		}
+	}
+}
+
+// Flatten lists every line of the tree under parent, the path of the
+// directory holding nodes. Lines in collapsed directories are listed too;
+// the caller leaves them out.
+func Flatten(nodes []*Node, parent string, indent int) []Line {
+	var lines []Line
+	for _, n := range nodes {
+		if n.File != nil {
+			lines = append(lines, Line{File: n.File, Indent: indent, Name: n.Name})
+		} else if n.Group {
+			lines = append(lines, Line{Indent: indent, Name: n.Name, Path: n.Name})
+			lines = append(lines, Flatten(n.Children, n.Name+"/", indent+1)...)
+		} else {
+			path := parent + n.Name
+			lines = append(lines, Line{Indent: indent, Name: n.Name + "/", Path: path})
+			lines = append(lines, Flatten(n.Children, path+"/", indent+1)...)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Accessible Mode ====================
+
+// With --accessible nothing is told by color or layout alone, for screen
+// readers and braille displays: diffs are one column of plain text with each
+// change labeled in words, and the browser shows one file at a time, named
+// and described on its own line, in the normal screen rather than the
+// alternate one.
+
+// describeFile says in words what changed about f.
+func describeFile(f git.FileStatus) string {
+	var states []string
+	switch {
+	case f.Untracked:
+		states = append(states, tr("untracked"))
+	case f.Staged && f.Unstaged:
+		states = append(states, tr("staged and modified"))
+	case f.Staged:
+		states = append(states, tr("staged"))
+	case f.Unstaged:
+		states = append(states, tr("modified"))
+	}
+	if f.OrigPath != "" {
+		states = append(states, trf("renamed from %s", f.OrigPath))
+	}
+	if f.Stat.Binary {
+		states = append(states, tr("binary"))
+	} else if f.Stat.Added > 0 || f.Stat.Deleted > 0 {
+		states = append(states, trf("%d added, %d removed", f.Stat.Added, f.Stat.Deleted))
+	}
+	if len(states) == 0 {
+		return f.Path
+	}
+	return f.Path + ", " + strings.Join(states, ", ")
+}
+
+// accessibleView is View in one column: the status line, which file is
+// selected out of how many, its diff, and the footer.
+func (m model) accessibleView() string {
+	var b strings.Builder
+	b.WriteString(m.renderStatusBar())
+	b.WriteByte('\n')
+
+	files, at := 0, 0
+	for i, idx := range m.filtered {
+		if m.allLines[idx].File != nil {
+			files++
+			if i == m.cursor {
+				at = files
+			}
+		}
+	}
+	switch f := m.selectedFile(); {
+	case f != nil:
+		b.WriteString(trf("file %d of %d: %s", at, files, describeFile(*f)))
+	case m.cursorDir() != nil && m.collapsed[m.cursorDir().Path]:
+		b.WriteString(trf("folder %s, collapsed", m.cursorDir().Name))
+	case m.cursor < len(m.filtered):
+		b.WriteString(trf("folder %s", m.allLines[m.filtered[m.cursor]].Name))
+	default:
+		b.WriteString(tr("no files"))
+	}
+	b.WriteByte('\n')
+
+	for _, row := range m.popup.overlay(m.viewport.rows(), m.viewport.width) {
+		b.WriteString(row)
+		b.WriteByte('\n')
+	}
+
+	b.WriteString(m.renderFooter(m.width))
+	return b.String()
+}
@@ -0,0 +1,447 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Base Branch ====================
+
+// refExists reports whether ref names a commit.
+func refExists(ref string) bool {
+	return exec.Command("git", "rev-parse", "--verify", "-q", ref+"^{commit}").Run() == nil
+}
+
+// rebaseOnto returns the commit an interrupted rebase is replaying commits
+// onto, or "" when no rebase is in progress.
+func rebaseOnto() string {
+	for _, p := range []string{"rebase-merge/onto", "rebase-apply/onto"} {
+		out, err := exec.Command("git", "rev-parse", "--git-path", p).Output()
+		if err != nil {
+			continue
+		}
+		if data, err := os.ReadFile(strings.TrimSpace(string(out))); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// defaultBranch guesses the branch the repository's work lands on: origin's
+// HEAD, preferring the local branch of the same name, then the usual names.
+// It's "" when none of them exist.
+func defaultBranch() string {
+	out, err := exec.Command("git", "symbolic-ref", "-q", "--short", "refs/remotes/origin/HEAD").Output()
+	if b := strings.TrimSpace(string(out)); err == nil && refExists(b) {
+		if local := strings.TrimPrefix(b, "origin/"); refExists("refs/heads/" + local) {
+			return local
+		}
+		return b
+	}
+	for _, b := range []string{"main", "master", "trunk", "origin/main", "origin/master"} {
+		if refExists(b) {
+			return b
+		}
+	}
+	return ""
+}
+
+// errNoBase means --main has nothing to compare against.
+var errNoBase = errors.New("no base branch")
+
+// resolveBase sets baseRef for --main to the repository's default branch,
+// unless one was asked for by name. With no default branch, it falls back to
+// what an interrupted rebase is replaying onto, since that's where HEAD's new
+// commits start, and says so in note.
+func resolveBase() (note string, err error) {
+	if !hasCommits() {
+		return "", nil
+	}
+	if !baseNamed {
+		if b := defaultBranch(); b != "" {
+			baseRef = b
+			return "", nil
+		}
+		if onto := rebaseOnto(); onto != "" {
+			baseRef = shortSHA(onto)
+			return trf("no default branch; comparing against the rebase's base %s", baseRef), nil
+		}
+	}
+	if baseRef == git.EmptyTree || refExists(baseRef) {
+		return "", nil
+	}
+	return "", errNoBase
+}
+
+// shortSHA abbreviates sha as git would, to at least seven digits.
+func shortSHA(sha string) string {
+	out, err := exec.Command("git", "rev-parse", "--short", sha).Output()
+	if err != nil {
+		return sha
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// listRefs returns the local branches, then remote ones, then tags.
+func listRefs() []string {
+	out, err := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/remotes", "refs/tags").Output()
+	if err != nil {
+		return nil
+	}
+	var refs []string
+	for _, r := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		// origin/HEAD is only an alias for one of the others
+		if r != "" && !strings.HasSuffix(r, "/HEAD") {
+			refs = append(refs, r)
+		}
+	}
+	return refs
+}
+
+// noBaseHelp is shown when there's no base to compare against and no
+// terminal to pick one in.
+func noBaseHelp(refs []string) string {
+	s := trf("gd: %s isn't a branch or commit to compare against.\n\nPick one with --base <ref>", baseRef)
+	if len(refs) == 0 {
+		return s + ".\n"
+	}
+	const maxListed = 10
+	s += tr(", e.g.:") + "\n\n"
+	for i, r := range refs {
+		if i == maxListed {
+			s += "    …\n"
+			break
+		}
+		s += "    gd --base " + r + "\n"
+	}
+	return s
+}
+
+// refPicker asks which ref --main should compare against when the base
+// branch doesn't exist.
+type refPicker struct {
+	missing string
+	refs    []string
+	cursor  int
+	scroll  int
+	height  int
+	width   int
+	picked  bool
+}
+
+func (p refPicker) Init() tea.Cmd { return nil }
+
+func (p refPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.width, p.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return p, tea.Quit
+		case "up", "k":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+		case "down", "j":
+			if p.cursor < len(p.refs)-1 {
+				p.cursor++
+			}
+		case "enter":
+			p.picked = true
+			return p, tea.Quit
+		}
+	}
+	visibleH := max(p.height-2, 1)
+	if p.cursor < p.scroll {
+		p.scroll = p.cursor
+	}
+	if p.cursor >= p.scroll+visibleH {
+		p.scroll = p.cursor - visibleH + 1
+	}
+	return p, nil
+}
+
+func (p refPicker) View() string {
+	var b strings.Builder
+	b.WriteString(titleSty.Render(fitStr(trf("No branch %s: compare against", p.missing), p.width)))
+	b.WriteByte('\n')
+	visibleH := max(p.height-2, 1)
+	for i := p.scroll; i < len(p.refs) && i < p.scroll+visibleH; i++ {
+		line := fitStr(p.refs[i], p.width)
+		if i == p.cursor {
+			b.WriteString(cursorSty.Render(line))
+		} else {
+			b.WriteString(fileSty.Render(line))
+		}
+		b.WriteByte('\n')
+	}
+	for i := len(p.refs) - p.scroll; i < visibleH; i++ {
+		b.WriteByte('\n')
+	}
+	b.WriteString(borderSty.Render(tr("⏎ compare  q quit")))
+	return b.String()
+}
+
+// pickBase resolves baseRef for --main, asking in a picker when nothing
+// fits and there's a terminal to ask in, and exiting with help otherwise.
+// It returns a note for the status bar when it picked a fallback.
+func pickBase(interactive bool) string {
+	note, err := resolveBase()
+	if err == nil {
+		return note
+	}
+	refs := listRefs()
+	if !interactive || len(refs) == 0 {
+		fmt.Fprint(os.Stderr, noBaseHelp(refs))
+		if flagCheck {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+	initTheme()
+	res, err := tea.NewProgram(refPicker{missing: baseRef, refs: refs}, tea.WithAltScreen()).Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	p := res.(refPicker)
+	if !p.picked {
+		os.Exit(1)
+	}
+	baseRef = p.refs[p.cursor]
+	return ""
+}
+
+// ==================== Ref Arguments ====================
+
+// rangeSep joins baseRef and headRef into the range being diffed: "..." diffs
+// headRef against its merge base with baseRef, as --main does, and ".."
+// diffs the two commits directly.
+var rangeSep = "..."
+
+// baseNamed is set when the base was given by name, so it isn't swapped for a
+// fallback when missing.
+var baseNamed bool
+
+// shownCommit is the commit shown with gd <commit>, abbreviated and followed
+// by its subject.
+var shownCommit string
+
+// diffRange is the range the file list and previews diff outside the
+// worktree.
+func diffRange() string {
+	return baseRef + rangeSep + headRef
+}
+
+// isRev reports whether arg names a commit.
+func isRev(arg string) bool {
+	if strings.HasPrefix(arg, "-") {
+		return false
+	}
+	return exec.Command("git", "rev-parse", "--verify", "-q", "--end-of-options", arg+"^{commit}").Run() == nil
+}
+
+// parseRange reads a revision argument: A..B or A...B, with either side
+// defaulting to HEAD as git does, or a single commit, which is compared with
+// its first parent as git show does.
+func parseRange(arg string) (base, head, sep string, ok bool) {
+	for _, sep := range []string{"...", ".."} {
+		if a, b, found := strings.Cut(arg, sep); found {
+			if a == "" {
+				a = "HEAD"
+			}
+			if b == "" {
+				b = "HEAD"
+			}
+			return a, b, sep, isRev(a) && isRev(b)
+		}
+	}
+	if !isRev(arg) {
+		return "", "", "", false
+	}
+	if isRev(arg + "^") {
+		return arg + "^", arg, "..", true
+	}
+	// a root commit adds everything
+	return git.EmptyTree, arg, "..", true
+}
+
+// splitRevArgs separates the commits to compare from the pathspecs in args:
+//
+//	gd v1.2.0..HEAD    gd main...feature    gd a1b2c3d    gd a b
+//
+// Before a --, the leading arguments that name commits, and aren't also
+// files, are revisions; with a --, everything before it must be. The result
+// is the range's two ends and how they're joined, or nothing when args has
+// no revisions.
+func splitRevArgs(args []string) (revs []string, paths []string, err error) {
+	if i := slices.Index(args, "--"); i >= 0 {
+		revs, paths = args[:i], args[i+1:]
+		for _, r := range revs {
+			if _, _, _, ok := parseRange(r); !ok {
+				return nil, nil, fmt.Errorf("%s isn't a commit or range", r)
+			}
+		}
+	} else {
+		n := 0
+		for n < len(args) && n < 2 {
+			if _, err := os.Stat(userPath(args[n])); err == nil {
+				break
+			}
+			if _, _, _, ok := parseRange(args[n]); !ok {
+				if strings.Contains(args[n], "..") {
+					return nil, nil, fmt.Errorf("%s isn't a range of commits", args[n])
+				}
+				break
+			}
+			n++
+		}
+		revs, paths = args[:n], args[n:]
+	}
+	switch {
+	case len(revs) > 2:
+		return nil, nil, fmt.Errorf("compare at most two commits, not %d", len(revs))
+	case len(revs) == 2 && strings.Contains(revs[0]+revs[1], ".."):
+		return nil, nil, fmt.Errorf("give a range or two commits, not %s %s", revs[0], revs[1])
+	}
+	return revs, paths, nil
+}
+
+// setRevs points the comparison at revs from splitRevArgs.
+func setRevs(revs []string) {
+	switch len(revs) {
+	case 1:
+		baseRef, headRef, rangeSep, _ = parseRange(revs[0])
+		if !strings.Contains(revs[0], "..") {
+			out, _ := exec.Command("git", "log", "-1", "--format=%h %s", revs[0]).Output()
+			shownCommit = strings.TrimSpace(string(out))
+		}
+	case 2:
+		baseRef, headRef, rangeSep = revs[0], revs[1], ".."
+	default:
+		return
+	}
+	flagMain = true
+	baseNamed = true
+}
+
+// ==================== Base Picker ====================
+
+// basePick is the list B opens over the preview, of the worktree and then
+// every branch and tag, narrowed as a name is typed, to compare against.
+type basePick struct {
+	refs   []string // refs[0] is the worktree
+	query  string
+	cursor int // in matches
+}
+
+// baseMatch is one of basePick's refs matching its query.
+type baseMatch struct {
+	ref   int
+	score int
+	pos   []int
+}
+
+// matches are the refs matching the query, best first, or all of them in
+// order without one.
+func (p *basePick) matches() []baseMatch {
+	var ms []baseMatch
+	for i, r := range p.refs {
+		if score, pos, ok := fuzzyMatch(p.query, r); ok {
+			ms = append(ms, baseMatch{i, score, pos})
+		}
+	}
+	if p.query != "" {
+		slices.SortStableFunc(ms, func(a, b baseMatch) int { return b.score - a.score })
+	}
+	return ms
+}
+
+// openBasePick opens the base picker, for the worktree's changes or a range.
+func (m *model) openBasePick() {
+	if !m.live() || reviewPR > 0 {
+		m.message = tr("only the worktree or a range can be compared with another branch")
+		return
+	}
+	m.basePick = &basePick{refs: append([]string{tr("(worktree)")}, listRefs()...)}
+}
+
+// basePickKey handles key while the picker is open: typing narrows it, the
+// arrows move in it, enter compares against the ref picked, and esc closes
+// it.
+func (m *model) basePickKey(msg tea.KeyMsg) tea.Cmd {
+	p := m.basePick
+	n := len(p.matches())
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.basePick = nil
+	case "up", "ctrl+p", "ctrl+k":
+		p.cursor = max(p.cursor-1, 0)
+	case "down", "ctrl+n", "ctrl+j":
+		p.cursor = max(min(p.cursor+1, n-1), 0)
+	case "backspace":
+		if r := []rune(p.query); len(r) > 0 {
+			p.query, p.cursor = string(r[:len(r)-1]), 0
+		}
+	case "enter":
+		m.basePick = nil
+		if ms := p.matches(); p.cursor < len(ms) {
+			if i := ms[p.cursor].ref; i > 0 {
+				return m.compareWith(p.refs[i])
+			}
+			return m.compareWith("")
+		}
+	default:
+		if msg.Type == tea.KeyRunes {
+			p.query, p.cursor = p.query+string(msg.Runes), 0
+		}
+	}
+	return nil
+}
+
+// compareWith switches the tree to ref...HEAD, as --base does, or back to
+// the worktree when ref is "".
+func (m *model) compareWith(ref string) tea.Cmd {
+	session.record("base", "", ref)
+	shownCommit = ""
+	if ref == "" {
+		flagMain = false
+		return reloadFiles(tr("comparing the worktree"))
+	}
+	flagMain, baseNamed = true, true
+	baseRef, headRef, rangeSep = ref, "HEAD", "..."
+	return reloadFiles(trf("comparing against %s", ref))
+}
+
+// popup draws the picker as a popup of height rows at most: the query, then
+// as many matches as fit around the cursor.
+func (p *basePick) popup(height int) *popup {
+	ms := p.matches()
+	// the box, its title, and a row above and below take five rows
+	shown := max(height-5, 1)
+	start := max(min(p.cursor-shown/2, len(ms)-shown), 0)
+	var lines []string
+	for i := start; i < len(ms) && i < start+shown; i++ {
+		name := p.refs[ms[i].ref]
+		line := highlightMatch(name, ms[i].pos, fileSty.Render)
+		if ms[i].ref == 0 {
+			line = highlightMatch(name, ms[i].pos, dirSty.Render)
+		}
+		if i == p.cursor {
+			line = cursorSty.Render(name)
+		}
+		lines = append(lines, line)
+	}
+	if len(ms) == 0 {
+		lines = append(lines, borderSty.Render(tr("no matching refs")))
+	}
+	return &popup{title: tr("Compare against: ") + p.query + "█", text: strings.Join(lines, "\n")}
+}
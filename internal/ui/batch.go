@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Batched Diffs ====================
+
+// diffBatch holds every file's diff from one git diff per side, so previews
+// don't spawn git per file. Keys are new paths, or old paths for deletions.
+type diffBatch struct {
+	unstaged map[string]string
+	staged   map[string]string
+	main     map[string]string
+	err      error
+}
+
+var (
+	batchMu sync.Mutex
+	batch   *diffBatch
+)
+
+// maxBatchBytes caps one batched diff. Past it, holding every file's diff
+// costs more than running git diff for the few files looked at.
+const maxBatchBytes = 32 << 20
+
+var errBatchTooBig = errors.New("diff too big to batch")
+
+// loadBatch runs the batched diffs for the current mode on first use after
+// a refresh.
+func loadBatch() *diffBatch {
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	if batch != nil {
+		return batch
+	}
+	batch = &diffBatch{}
+	if flagMain {
+		batch.main, batch.err = batchDiff(diffRange())
+	} else {
+		batch.unstaged, batch.err = batchDiff()
+		if batch.err == nil {
+			batch.staged, batch.err = batchDiff("--staged")
+		}
+	}
+	// previews still work, one git diff per file, but say why they're slower
+	if errors.Is(batch.err, errBatchTooBig) {
+		debugf("%v", batch.err)
+	} else {
+		reportError(batch.err)
+	}
+	return batch
+}
+
+// dropBatch forgets the batched diffs, for when the worktree may have changed.
+func dropBatch() {
+	batchMu.Lock()
+	batch = nil
+	batchMu.Unlock()
+}
+
+// batchDiff runs one git diff over the pathspecs and slices its output into
+// each file's section.
+func batchDiff(args ...string) (map[string]string, error) {
+	cmd := append(append([]string{"diff"}, shownDiffOpts()...), args...)
+	cmd = append(append(cmd, "--"), pathspecs...)
+	name := strings.Join(append([]string{"git diff"}, args...), " ")
+	defer trace("git", strings.TrimSpace("(all files) "+strings.Join(args, " ")))()
+	c := exec.Command("git", cmd...)
+	var stderr strings.Builder
+	c.Stderr = &stderr
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := c.Start(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	out, readErr := io.ReadAll(io.LimitReader(stdout, maxBatchBytes+1))
+	if len(out) > maxBatchBytes {
+		c.Process.Kill()
+		c.Wait()
+		return nil, fmt.Errorf("%s: %w", name, errBatchTooBig)
+	}
+	if err := c.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("%s: %w", name, readErr)
+	}
+	raw := string(out)
+	files, _, err := gitdiff.Parse(strings.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	sections := splitSections(raw)
+	if len(sections) != len(files) {
+		return nil, fmt.Errorf("%s: found %d sections for %d files", name, len(sections), len(files))
+	}
+	diffs := make(map[string]string, len(files))
+	for i, f := range files {
+		name := f.NewName
+		if f.IsDelete {
+			name = f.OldName
+		}
+		diffs[name] = sections[i]
+	}
+	return diffs, nil
+}
+
+// splitSections cuts a multi-file diff at each diff --git header.
+func splitSections(raw string) []string {
+	var sections []string
+	start := -1
+	for i := 0; i < len(raw); {
+		if strings.HasPrefix(raw[i:], "diff --git ") {
+			if start >= 0 {
+				sections = append(sections, raw[start:i])
+			}
+			start = i
+		}
+		nl := strings.IndexByte(raw[i:], '\n')
+		if nl < 0 {
+			break
+		}
+		i += nl + 1
+	}
+	if start >= 0 {
+		sections = append(sections, raw[start:])
+	}
+	return sections
+}
+
+// batchedDiff returns f's diff from the batch, or false when the batch can't
+// answer for it and git has to be asked directly.
+func batchedDiff(f git.FileStatus) (string, bool) {
+	if f.Untracked || f.Conflicted {
+		return "", false
+	}
+	b := loadBatch()
+	if b.err != nil {
+		return "", false
+	}
+	if flagMain {
+		d, ok := b.main[f.Path]
+		return d, ok
+	}
+	var parts []string
+	for _, side := range []struct {
+		changed bool
+		diffs   map[string]string
+	}{{f.Unstaged, b.unstaged}, {f.Staged, b.staged}} {
+		if !side.changed {
+			continue
+		}
+		d, ok := side.diffs[f.Path]
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, d)
+	}
+	return strings.Join(parts, ""), true
+}
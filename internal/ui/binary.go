@@ -0,0 +1,146 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Binary Files ====================
+
+// maxImageBytes bounds the images read to be previewed; bigger ones are only
+// measured.
+const maxImageBytes = 16 << 20
+
+// binarySide is a binary file on one side of its diff.
+type binarySide struct {
+	size   int
+	data   []byte       // the content, for images
+	config image.Config // an image's dimensions
+	format string       // the image format, "" when it isn't one
+}
+
+// isZeroOID reports whether oid is git's all-zero id for a missing file.
+func isZeroOID(oid string) bool { return strings.Trim(oid, "0") == "" }
+
+// loadBinarySide reads one side of the binary file name from the blob oid,
+// or for the new side of a worktree diff, from the file, since git doesn't
+// store what it hashes there. ok is false when the side is missing or can't
+// be read, as in a --patch file.
+func loadBinarySide(oid, name string, newSide bool) (side binarySide, ok bool) {
+	if oid == "" || isZeroOID(oid) {
+		return side, false
+	}
+	var data []byte
+	size := -1
+	if out, err := exec.Command("git", "cat-file", "-s", oid).Output(); err == nil {
+		size, _ = strconv.Atoi(strings.TrimSpace(string(out)))
+		if isImageName(name) && size <= maxImageBytes {
+			data, _ = exec.Command("git", "cat-file", "blob", oid).Output()
+		}
+	} else if newSide {
+		path := filepath.Join(repoRoot(), name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return side, false
+		}
+		size = int(info.Size())
+		if isImageName(name) && size <= maxImageBytes {
+			data, _ = os.ReadFile(path)
+		}
+	}
+	if size < 0 {
+		return side, false
+	}
+	side.size = size
+	if data != nil {
+		if cfg, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			side.data, side.config, side.format = data, cfg, format
+		}
+	}
+	return side, true
+}
+
+// isImageName reports whether name looks like an image gd can decode.
+func isImageName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// binaryPreview describes a binary file's change: its size on each side,
+// an image's dimensions, and the images themselves where the terminal can
+// draw them in the preview.
+func binaryPreview(f *gitdiff.File, name string, width int) []string {
+	oldSide, hasOld := loadBinarySide(f.OldOIDPrefix, name, false)
+	newSide, hasNew := loadBinarySide(f.NewOIDPrefix, name, true)
+	text := "Binary file"
+	switch {
+	case hasOld && hasNew:
+		text += ", " + byteSize(oldSide.size) + " → " + byteSize(newSide.size)
+	case hasNew:
+		text += " added, " + byteSize(newSide.size)
+	case hasOld:
+		text += " deleted, " + byteSize(oldSide.size)
+	}
+	lines := []string{ctxDimSty.Render("  " + text)}
+
+	var dims []string
+	var sides []binarySide
+	for _, s := range []binarySide{oldSide, newSide} {
+		if s.format != "" {
+			dims = append(dims, fmt.Sprintf("%d×%d", s.config.Width, s.config.Height))
+			sides = append(sides, s)
+		}
+	}
+	if len(sides) == 0 {
+		return lines
+	}
+	lines = append(lines, hunkHdrSty.Render("  "+strings.ToUpper(sides[0].format)+" "+strings.Join(dims, " → ")))
+	if graphics() == graphicsKitty {
+		lines = append(lines, kittyPreview(sides, width-2)...)
+	} else if graphics() != graphicsNone {
+		lines = append(lines, ctxDimSty.Render("  "+tr("enter shows the image")))
+	}
+	return lines
+}
+
+// fullImages reads the images in a binary file's diff for imageView, when
+// the terminal can draw them.
+func fullImages(raw, name string) (imageMsg, bool) {
+	var msg imageMsg
+	if graphics() == graphicsNone {
+		return msg, false
+	}
+	files, _, err := gitdiff.Parse(strings.NewReader(raw))
+	if err != nil || len(files) == 0 || !files[0].IsBinary {
+		return msg, false
+	}
+	f := files[0]
+	for i, oid := range []string{f.OldOIDPrefix, f.NewOIDPrefix} {
+		side, ok := loadBinarySide(oid, name, i == 1)
+		if !ok || side.format == "" {
+			continue
+		}
+		label := tr("old")
+		if i == 1 {
+			label = tr("new")
+		}
+		label += fmt.Sprintf("  %s %d×%d, %s", strings.ToUpper(side.format), side.config.Width, side.config.Height, byteSize(side.size))
+		msg.labels = append(msg.labels, label)
+		msg.sides = append(msg.sides, side)
+	}
+	return msg, len(msg.sides) > 0
+}
@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/arnavsurve/gd/pkg/render"
+)
+
+// ==================== Blame ====================
+
+// showBlame is toggled with b: context and removed lines show who last
+// changed them.
+var showBlame atomic.Bool
+
+// blameRev is the commit the diff's old side comes from: HEAD for the
+// worktree, or the base of the range.
+func blameRev() (string, error) {
+	if !flagMain {
+		return "HEAD", nil
+	}
+	if rangeSep == ".." {
+		return baseRef, nil
+	}
+	out, err := exec.Command("git", "merge-base", baseRef, headRef).Output()
+	if err != nil {
+		return "", fmt.Errorf("git merge-base %s %s: %w", baseRef, headRef, git.StderrError(err))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// blameFile blames path as the diff's old side has it, by line number.
+func blameFile(path string) (map[int]render.Blame, error) {
+	rev, err := blameRev()
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("git", "blame", "--porcelain", rev, "--", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame %s: %w", path, git.StderrError(err))
+	}
+	return parseBlame(string(out)), nil
+}
+
+// parseBlame reads git blame --porcelain, which describes each commit only
+// the first time one of its lines appears.
+func parseBlame(out string) map[int]render.Blame {
+	lines := map[int]render.Blame{}
+	commits := map[string]*render.Blame{}
+	var cur *render.Blame
+	line := 0
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			if cur != nil {
+				lines[line] = *cur
+			}
+		case strings.HasPrefix(text, "author "):
+			if cur != nil {
+				cur.Author = text[len("author "):]
+			}
+		case strings.HasPrefix(text, "author-time "):
+			if cur != nil {
+				t, _ := strconv.ParseInt(text[len("author-time "):], 10, 64)
+				cur.When = time.Unix(t, 0)
+			}
+		default:
+			f := strings.Fields(text)
+			if len(f) < 3 || len(f[0]) != 40 {
+				continue
+			}
+			line, _ = strconv.Atoi(f[2])
+			if commits[f[0]] == nil {
+				commits[f[0]] = &render.Blame{SHA: f[0][:7]}
+			}
+			cur = commits[f[0]]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Object Reader ====================
+
+// catFileProc is the one cat-file process every lookup goes through, rather
+// than spawning git each time; fork and exec are slow on macOS especially.
+var (
+	catFileMu   sync.Mutex
+	catFileProc *git.CatFile
+)
+
+// readObject returns path's contents at rev, starting the cat-file process on
+// first use and again if it died.
+func readObject(rev, path string) ([]byte, error) {
+	if strings.ContainsAny(rev+path, "\n") {
+		return nil, fmt.Errorf("%s:%s: newline in name", rev, path)
+	}
+	catFileMu.Lock()
+	defer catFileMu.Unlock()
+	if catFileProc == nil {
+		c, err := git.StartCatFile(repoRoot())
+		if err != nil {
+			return nil, err
+		}
+		catFileProc = c
+	}
+	data, err := catFileProc.Read(rev + ":" + path)
+	if errors.Is(err, git.ErrNoObject) {
+		return nil, fmt.Errorf("%s:%s: %w", rev, path, err)
+	}
+	if err != nil {
+		// the stream is out of step now; start over next time
+		catFileProc.Stop()
+		catFileProc = nil
+		return nil, fmt.Errorf("git cat-file %s:%s: %w", rev, path, err)
+	}
+	return data, nil
+}
+
+// stopCatFile ends the cat-file process, if one was started.
+func stopCatFile() {
+	catFileMu.Lock()
+	defer catFileMu.Unlock()
+	if catFileProc != nil {
+		catFileProc.Stop()
+		catFileProc = nil
+	}
+}
+
+// newSideText returns path as the diff's new side has it: from the worktree,
+// or from headRef when comparing commits, whose copy may not be checked out.
+func newSideText(path string) ([]byte, error) {
+	if flagMain {
+		return readObject(headRef, path)
+	}
+	return os.ReadFile(filepath.Join(repoRoot(), path))
+}
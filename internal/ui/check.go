@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+)
+
+// ==================== Check ====================
+
+// runCheck prints a compact summary of the changeset and returns the process
+// exit code: 0 when clean, 1 when there are changes, 2 on error.
+func runCheck() int {
+	files, err := loadFiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	if len(files) == 0 {
+		fmt.Println("clean")
+		return 0
+	}
+
+	var staged, unstaged, untracked int
+	for _, f := range files {
+		if f.Staged {
+			staged++
+		}
+		if f.Unstaged {
+			unstaged++
+		}
+		if f.Untracked {
+			untracked++
+		}
+	}
+
+	noun := "files"
+	if len(files) == 1 {
+		noun = "file"
+	}
+	if flagMain {
+		fmt.Printf("%d %s changed vs %s\n", len(files), noun, baseRef)
+	} else {
+		fmt.Printf("%d %s changed (%d staged, %d unstaged, %d untracked)\n", len(files), noun, staged, unstaged, untracked)
+	}
+	for _, f := range files {
+		label := f.StatusLabel()
+		if label == "" {
+			label = "M"
+		}
+		fmt.Printf("  %-2s %s\n", label, f.Path)
+	}
+	return 1
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== CI Status ====================
+
+type ciRun struct {
+	Name         string    `json:"name"`
+	WorkflowName string    `json:"workflowName"`
+	Status       string    `json:"status"`
+	Conclusion   string    `json:"conclusion"`
+	HeadSha      string    `json:"headSha"`
+	URL          string    `json:"url"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type ciMsg struct {
+	runs []ciRun
+	err  error
+	poll bool // sent by the poll timer
+}
+
+// ciPollInterval is how often runs are rechecked while some are unfinished.
+const ciPollInterval = 30 * time.Second
+
+// fetchCI lists the workflow runs for the newest commit pushed on the current
+// branch, one per workflow.
+func fetchCI() ([]ciRun, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return nil, err
+	}
+	if f, err := originForge(); err != nil || f.kind != forgeGitHub {
+		return nil, fmt.Errorf("origin is not on GitHub")
+	}
+	out, err := exec.Command("gh", "run", "list", "--branch", currentBranch(), "--limit", "20",
+		"--json", "name,workflowName,status,conclusion,headSha,url,createdAt").Output()
+	if err != nil {
+		return nil, fmt.Errorf("gh run list: %w", git.StderrError(err))
+	}
+	var all []ciRun
+	if err := json.Unmarshal(out, &all); err != nil {
+		return nil, fmt.Errorf("gh run list: %w", err)
+	}
+	var runs []ciRun
+	seen := map[string]bool{}
+	for _, r := range all {
+		if r.HeadSha != all[0].HeadSha || seen[r.WorkflowName] {
+			continue
+		}
+		seen[r.WorkflowName] = true
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+func loadCI() tea.Msg {
+	runs, err := fetchCI()
+	return ciMsg{runs: runs, err: err}
+}
+
+func pollCI() tea.Cmd {
+	return tea.Tick(ciPollInterval, func(time.Time) tea.Msg {
+		runs, err := fetchCI()
+		return ciMsg{runs: runs, err: err, poll: true}
+	})
+}
+
+func ciPending(runs []ciRun) bool {
+	for _, r := range runs {
+		if r.Status != "completed" {
+			return true
+		}
+	}
+	return false
+}
+
+func ciFailed(r ciRun) bool {
+	switch r.Conclusion {
+	case "failure", "cancelled", "timed_out", "action_required", "startup_failure":
+		return true
+	}
+	return false
+}
+
+// ciMark is the one-character state of a run.
+func ciMark(r ciRun) string {
+	switch {
+	case r.Status != "completed":
+		return noteSty.Render("●")
+	case ciFailed(r):
+		return delIndSty.Render("✗")
+	default:
+		return addIndSty.Render("✓")
+	}
+}
+
+// ciIndicator summarizes runs for the status bar: failed beats running beats
+// passed.
+func ciIndicator(runs []ciRun) string {
+	if len(runs) == 0 {
+		return ""
+	}
+	for _, r := range runs {
+		if r.Status == "completed" && ciFailed(r) {
+			return "CI " + ciMark(r)
+		}
+	}
+	for _, r := range runs {
+		if r.Status != "completed" {
+			return "CI " + ciMark(r)
+		}
+	}
+	return "CI " + ciMark(runs[0])
+}
+
+// showCI replaces the preview with the state of each workflow run.
+func (m model) showCI() tea.Cmd {
+	runs := m.ci
+	return func() tea.Msg {
+		var b strings.Builder
+		if len(runs) == 0 {
+			b.WriteString(titleSty.Render(tr("CI")))
+			b.WriteString("\n\n")
+			b.WriteString(ctxDimSty.Render(trf("No workflow runs for %s", currentBranch())))
+			return diffLoadedMsg{content: b.String()}
+		}
+		b.WriteString(titleSty.Render(fmt.Sprintf("CI for %s @ %.7s", currentBranch(), runs[0].HeadSha)))
+		b.WriteString("\n\n")
+		for _, r := range runs {
+			state := r.Status
+			if r.Status == "completed" {
+				state = r.Conclusion
+			}
+			fmt.Fprintf(&b, "%s %s %s\n", ciMark(r), hyperlink(r.URL, fileHdrSty.Render(r.WorkflowName)), ctxDimSty.Render(state))
+			if r.Name != r.WorkflowName {
+				b.WriteString("  " + r.Name + "\n")
+			}
+			b.WriteString("  " + ctxDimSty.Render(r.URL) + "\n")
+		}
+		return diffLoadedMsg{content: b.String()}
+	}
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+// ==================== Clipboard ====================
+
+// copyToClipboard sends text to the terminal via OSC 52, which also works over
+// SSH, and additionally pipes it to a local clipboard tool when running on the
+// same machine as the terminal.
+func copyToClipboard(text string) error {
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+	if _, err := seq.WriteTo(os.Stderr); err != nil {
+		return err
+	}
+
+	if os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+		return nil
+	}
+	name, args := localClipboardCmd()
+	if name == "" {
+		return nil
+	}
+	c := exec.Command(name, args...)
+	c.Stdin = strings.NewReader(text)
+	return c.Run()
+}
+
+func localClipboardCmd() (string, []string) {
+	candidates := [][]string{
+		{"pbcopy"},
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+		{"clip.exe"},
+	}
+	for _, c := range candidates {
+		if c[0] == "wl-copy" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			continue
+		}
+		if (c[0] == "xclip" || c[0] == "xsel") && os.Getenv("DISPLAY") == "" {
+			continue
+		}
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c[0], c[1:]
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,191 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ==================== User Commands ====================
+
+// userCommand is a [[command]] from the config file: a shell command bound
+// to a key, with its output shown in a popup or in place of the preview.
+//
+//	[[command]]
+//	name = "blame"
+//	key = "ctrl+b"
+//	run = "git blame {file}"
+//	show = "preview"
+type userCommand struct {
+	name string
+	key  string
+	run  string
+	show string // "popup" or "preview"
+}
+
+var userCommands []userCommand
+
+func parseUserCommand(t *configTable) (userCommand, error) {
+	if err := t.checkKeys("name", "key", "run", "show"); err != nil {
+		return userCommand{}, err
+	}
+	var c userCommand
+	for key, dst := range map[string]*string{"name": &c.name, "key": &c.key, "run": &c.run, "show": &c.show} {
+		s, err := t.str(key)
+		if err != nil {
+			return c, err
+		}
+		*dst = s
+	}
+	if c.key == "" || c.run == "" {
+		return c, fmt.Errorf("line %d: a command needs a key and run", t.line)
+	}
+	if c.name == "" {
+		c.name = c.run
+	}
+	switch c.show {
+	case "":
+		c.show = "popup"
+	case "popup", "preview":
+	default:
+		return c, fmt.Errorf("line %d: show should be popup or preview, not %q", t.lines["show"], c.show)
+	}
+	return c, nil
+}
+
+// userCommandFor returns the command bound to key, if any.
+func userCommandFor(key string) (userCommand, bool) {
+	for _, c := range userCommands {
+		if c.key == key {
+			return c, true
+		}
+	}
+	return userCommand{}, false
+}
+
+type userCommandMsg struct {
+	cmd userCommand
+	out string
+	err error
+}
+
+// diffRef is the revision the diff being browsed is against: HEAD for the
+// worktree, the base with --main, or the selected commit of a patch.
+func (m model) diffRef() string {
+	switch {
+	case len(m.commits) > 0:
+		return m.commits[m.commitIdx].sha
+	case flagMain:
+		return baseRef
+	}
+	return "HEAD"
+}
+
+// runUserCommand fills in c's placeholders and runs it: {file} is the
+// selected file, {hunk_patch} a temporary file holding the current hunk as a
+// patch, and {ref} the revision the diff is against.
+func (m model) runUserCommand(c userCommand) tea.Cmd {
+	f := m.selectedFile()
+	script := c.run
+	if f == nil && (strings.Contains(script, "{file}") || strings.Contains(script, "{hunk_patch}")) {
+		return func() tea.Msg { return statusMsg{text: c.name + ": " + tr("no file selected")} }
+	}
+	var path, patchFile string
+	if f != nil {
+		path = f.Path
+	}
+	if strings.Contains(script, "{hunk_patch}") {
+		i := m.currentHunkIdx()
+		if i < 0 {
+			return func() tea.Msg { return statusMsg{text: c.name + ": " + tr("no hunk to pass")} }
+		}
+		patch := buildHunkPatch([]markedHunk{{path: path, file: m.hunks[i].File, frag: m.hunks[i].Frag}})
+		tmp, err := os.CreateTemp("", "gd-hunk-*.patch")
+		if err != nil {
+			return func() tea.Msg { return errorMsg{err: fmt.Errorf("%s: %w", c.name, err)} }
+		}
+		tmp.WriteString(patch)
+		tmp.Close()
+		patchFile = tmp.Name()
+		script = strings.ReplaceAll(script, "{hunk_patch}", scriptQuote(patchFile))
+	}
+	script = strings.ReplaceAll(script, "{file}", scriptQuote(path))
+	script = strings.ReplaceAll(script, "{ref}", scriptQuote(m.diffRef()))
+	cmd := shellCommand(script)
+	cmd.Dir = repoRoot()
+	session.record("command", path, c.name)
+	return func() tea.Msg {
+		out, err := cmd.CombinedOutput()
+		if patchFile != "" {
+			os.Remove(patchFile)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", c.name, err)
+		}
+		return userCommandMsg{cmd: c, out: ansi.Strip(string(out)), err: err}
+	}
+}
+
+// showUserCommand puts a finished command's output where it asked to go.
+func (m *model) showUserCommand(msg userCommandMsg) tea.Cmd {
+	reportError(msg.err)
+	text := expandTabs(strings.TrimRight(msg.out, "\n"))
+	if text == "" {
+		text = tr("(no output)")
+	}
+	if msg.cmd.show == "preview" {
+		content := titleSty.Render(msg.cmd.name) + "\n\n" + text
+		return func() tea.Msg { return diffLoadedMsg{content: content} }
+	}
+	m.popup = &popup{title: msg.cmd.name, text: text}
+	return nil
+}
+
+// popup is text shown over the preview until the next key.
+type popup struct {
+	title string
+	text  string
+}
+
+// overlay draws p over the preview's rows, which are width wide.
+func (p *popup) overlay(rows []string, width int) []string {
+	if p == nil || len(rows) < 3 {
+		return rows
+	}
+	rows = append([]string(nil), rows...)
+	lines := strings.Split(p.text, "\n")
+	if flagAccessible {
+		// plain text from the top, without a box
+		lines = append([]string{p.title + ":"}, lines...)
+		for i := range rows {
+			rows[i] = ""
+			if i < len(lines) {
+				rows[i] = ansi.Truncate(lines[i], width, "…")
+			}
+		}
+		return rows
+	}
+	// border and padding take two rows and four columns
+	innerW := max(width-6, 10)
+	if maxLines := len(rows) - 4; len(lines) > maxLines {
+		lines = append(lines[:maxLines-1], "…")
+	}
+	for i, l := range lines {
+		lines[i] = ansi.Truncate(l, innerW, "…")
+	}
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(pal.border)).
+		Padding(0, 1).
+		Render(titleSty.Render(p.title) + "\n" + strings.Join(lines, "\n"))
+	for i, l := range strings.Split(box, "\n") {
+		if i+1 < len(rows) {
+			rows[i+1] = " " + l
+		}
+	}
+	return rows
+}
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Commit ====================
+
+// committedMsg reports a git commit run in the terminal finishing.
+type committedMsg struct{ err error }
+
+// commitCommand runs git commit with args in the repository.
+func commitCommand(args ...string) *exec.Cmd {
+	c := exec.Command("git", append([]string{"commit"}, args...)...)
+	c.Dir = repoRoot()
+	return c
+}
+
+// execCommit hands the terminal to git commit, which opens the user's editor
+// for the message.
+func execCommit(args ...string) tea.Cmd {
+	return tea.ExecProcess(commitCommand(args...), func(err error) tea.Msg {
+		return committedMsg{err: err}
+	})
+}
+
+// promptCommit asks for a one-line message and commits what's staged, or
+// opens the editor for a longer one when the message is left empty. With
+// amend it goes straight to the editor to reword HEAD, adding anything
+// staged.
+func (m model) promptCommit(amend bool) tea.Cmd {
+	if flagMain || !m.live() {
+		return func() tea.Msg { return statusMsg{text: tr("commits are made from the worktree")} }
+	}
+	if amend {
+		if !hasCommits() {
+			return func() tea.Msg { return statusMsg{text: tr("no HEAD commit")} }
+		}
+		session.record("commit_amend", "", "")
+		return execCommit("--amend")
+	}
+	staged := false
+	for _, f := range m.files {
+		staged = staged || f.Staged
+	}
+	if !staged {
+		return func() tea.Msg { return statusMsg{text: tr("nothing staged to commit (s stages a hunk)")} }
+	}
+	return func() tea.Msg {
+		return promptMsg{prompt: &prompt{
+			label: tr("commit message (empty for editor): "),
+			submit: func(msg string) tea.Cmd {
+				msg = strings.TrimSpace(msg)
+				if msg == "" {
+					session.record("commit", "", "")
+					return execCommit()
+				}
+				session.record("commit", "", msg)
+				return func() tea.Msg {
+					if out, err := commitCommand("--quiet", "-m", msg).CombinedOutput(); err != nil {
+						return errorMsg{err: fmt.Errorf("git commit: %s", strings.TrimSpace(string(out)))}
+					}
+					files, err := loadFiles()
+					if err != nil {
+						return errorMsg{err: err}
+					}
+					return filesLoadedMsg{files: files, text: trf("committed %s", msg)}
+				}
+			},
+		}}
+	}
+}
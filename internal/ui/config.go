@@ -0,0 +1,461 @@
+package ui
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/styles"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Config File ====================
+
+// The config file is TOML, read by a small parser that covers what gd's
+// settings use: tables, arrays of tables, comments, and string, integer, and
+// boolean values on one line each.
+
+// configPath is $XDG_CONFIG_HOME/gd/config.toml, falling back to
+// ~/.config/gd/config.toml.
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gd", "config.toml")
+}
+
+// configTable is one [table] or [[array]] entry of the config file, or its
+// top level when name is empty.
+type configTable struct {
+	name   string
+	line   int
+	values map[string]any
+	lines  map[string]int // where each key was set, for errors
+}
+
+// parseConfig reads src into its tables in order.
+func parseConfig(src string) ([]*configTable, error) {
+	tables := []*configTable{{values: map[string]any{}, lines: map[string]int{}}}
+	for i, line := range strings.Split(src, "\n") {
+		n := i + 1
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name, ok := strings.CutPrefix(line, "[[")
+			if ok {
+				name, ok = strings.CutSuffix(name, "]]")
+			} else {
+				name, ok = strings.CutSuffix(line[1:], "]")
+			}
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("line %d: bad table header %q", n, line)
+			}
+			tables = append(tables, &configTable{name: strings.TrimSpace(name), line: n, values: map[string]any{}, lines: map[string]int{}})
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.TrimSpace(key)
+		if k, err := strconv.Unquote(key); err == nil {
+			key = k // "ctrl+n" = ... needs quotes, as + isn't allowed in a bare key
+		}
+		val, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		t := tables[len(tables)-1]
+		if _, dup := t.values[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", n, key)
+		}
+		t.values[key] = val
+		t.lines[key] = n
+	}
+	return tables, nil
+}
+
+// stripComment drops a # comment, leaving any # inside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseConfigValue(raw string) (any, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return nil, errors.New("unterminated string")
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+	}
+	if n, err := strconv.Atoi(strings.ReplaceAll(raw, "_", "")); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("can't read value %q", raw)
+}
+
+// str returns key's string value, erroring when it's set to something else.
+func (t *configTable) str(key string) (string, error) {
+	v, ok := t.values[key]
+	if !ok {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("line %d: %s should be a string", t.lines[key], key)
+	}
+	return s, nil
+}
+
+// num returns key's integer value, or 0 when it isn't set.
+func (t *configTable) num(key string) (int, error) {
+	v, ok := t.values[key]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := v.(int)
+	if !ok || n <= 0 {
+		return 0, fmt.Errorf("line %d: %s should be a positive number", t.lines[key], key)
+	}
+	return n, nil
+}
+
+// boolean returns key's true or false, or false when it isn't set.
+func (t *configTable) boolean(key string) (bool, error) {
+	v, ok := t.values[key]
+	if !ok {
+		return false, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("line %d: %s should be true or false", t.lines[key], key)
+	}
+	return b, nil
+}
+
+// flagGiven reports whether the flag name was on the command line, where it
+// wins over the config file.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) { given = given || f.Name == name })
+	return given
+}
+
+// checkKeys errors on the first key that isn't one of known, catching typos.
+func (t *configTable) checkKeys(known ...string) error {
+	for key := range t.values {
+		found := false
+		for _, k := range known {
+			found = found || k == key
+		}
+		if !found {
+			return fmt.Errorf("line %d: unknown setting %s", t.lines[key], key)
+		}
+	}
+	return nil
+}
+
+// Settings from the top of the config file. Flags given on the command line
+// win over them.
+//
+//	lang = "de"
+//	base = "develop"            # what --main compares against
+//	theme = "light"
+//	chroma_style = "dracula"    # syntax colors, any chroma style
+//	tab_width = 8
+//	show_whitespace = true      # as --show-whitespace
+//	tree_width = 40             # columns, as ( and ) leave it; by default 30% of the terminal
+//	side_by_side_width = 160    # narrowest preview shown side by side
+//	mouse = false               # leave the mouse to the terminal, to select text
+//	leftovers = true            # as --leftovers
+//	icons = "nerd"              # file-type icons in the tree, for a Nerd Font
+//	max_preview_lines = 50000   # as --max-preview-lines
+//	git_backend = "go-git"      # as --git-backend
+var (
+	configLang      string
+	configBase      string
+	configChroma    string
+	configTreeWidth int
+)
+
+// applySettings reads the config file's top-level settings.
+func applySettings(t *configTable) error {
+	if err := t.checkKeys("lang", "base", "theme", "chroma_style", "tab_width", "show_whitespace", "tree_width", "side_by_side_width", "mouse", "leftovers", "icons", "max_preview_lines", "git_backend"); err != nil {
+		return err
+	}
+	var theme string
+	for key, dst := range map[string]*string{"lang": &configLang, "base": &configBase, "theme": &theme, "chroma_style": &configChroma} {
+		s, err := t.str(key)
+		if err != nil {
+			return err
+		}
+		*dst = s
+	}
+	if flagTheme == "" {
+		flagTheme = theme
+	}
+	if configChroma != "" && styles.Registry[configChroma] == nil {
+		return fmt.Errorf("line %d: unknown chroma_style %q", t.lines["chroma_style"], configChroma)
+	}
+	tab, err := t.num("tab_width")
+	if err != nil {
+		return err
+	}
+	if tab > 0 {
+		tabSpaces = strings.Repeat(" ", tab)
+	}
+	ws, err := t.boolean("show_whitespace")
+	if err != nil {
+		return err
+	}
+	flagShowWhitespace = flagShowWhitespace || ws
+	if configIcons, err = t.str("icons"); err != nil {
+		return err
+	}
+	if configIcons != "" && !slices.Contains(iconSets, configIcons) {
+		return fmt.Errorf("line %d: icons should be one of %s, not %q", t.lines["icons"], strings.Join(iconSets, ", "), configIcons)
+	}
+	backend, err := t.str("git_backend")
+	if err != nil {
+		return err
+	}
+	if backend != "" && !slices.Contains(gitBackends, backend) {
+		return fmt.Errorf("line %d: git_backend should be one of %s, not %q", t.lines["git_backend"], strings.Join(gitBackends, ", "), backend)
+	}
+	if backend != "" && !flagGiven("git-backend") {
+		flagGitBackend = backend
+	}
+	lo, err := t.boolean("leftovers")
+	if err != nil {
+		return err
+	}
+	flagLeftovers = flagLeftovers || lo
+	if configTreeWidth, err = t.num("tree_width"); err != nil {
+		return err
+	}
+	if _, ok := t.values["mouse"]; ok {
+		if configMouse, err = t.boolean("mouse"); err != nil {
+			return err
+		}
+	}
+	lines, err := t.num("max_preview_lines")
+	if err != nil {
+		return err
+	}
+	if lines > 0 && !flagGiven("max-preview-lines") {
+		flagMaxPreviewLines = lines
+	}
+	sbs, err := t.num("side_by_side_width")
+	if sbs > 0 {
+		sideBySideMinWidth = sbs
+	}
+	return err
+}
+
+// setConfigValue sets a top-level setting in the config file to value,
+// written as TOML, replacing the line that sets it or adding one at the top.
+func setConfigValue(key, value string) error {
+	path := configPath()
+	if path == "" {
+		return errors.New("no config directory")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	set, found := key+" = "+value, false
+	for i, line := range lines {
+		setting := stripComment(line)
+		if strings.HasPrefix(strings.TrimSpace(setting), "[") {
+			break // the top level ends at the first table
+		}
+		if k, _, ok := strings.Cut(setting, "="); ok && strings.TrimSpace(k) == key {
+			if comment := line[len(setting):]; comment != "" {
+				set += " " + comment
+			}
+			lines[i], found = set, true
+			break
+		}
+	}
+	if !found {
+		lines = append([]string{set}, lines...)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("saving %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		return fmt.Errorf("saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// keyAliases maps keys the config file's [keys] table sets to other keys to
+// the keys they press instead.
+var keyAliases = map[string]tea.KeyMsg{}
+
+// loadConfig reads the config file, if there is one, and applies it.
+func loadConfig() error {
+	path := configPath()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tables, err := parseConfig(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, t := range tables {
+		switch t.name {
+		case "":
+			err = applySettings(t)
+		case "keys":
+			err = parseKeys(t)
+		case "theme":
+			err = fmt.Errorf("line %d: name the theme, as in [theme.mine]", t.line)
+		case "syntax":
+			var r syntaxRule
+			r, err = parseSyntaxRule(t)
+			syntaxRules = append(syntaxRules, r)
+		case "command":
+			var c userCommand
+			c, err = parseUserCommand(t)
+			userCommands = append(userCommands, c)
+		default:
+			if name, ok := strings.CutPrefix(t.name, "theme."); ok {
+				err = parseTheme(name, t)
+				break
+			}
+			err = fmt.Errorf("line %d: unknown table [%s]", t.line, t.name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := checkBindings(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// paletteKeys names a palette's colors in a [theme.name] table.
+func paletteKeys(p *palette) map[string]*string {
+	return map[string]*string{
+		"bg_add":        &p.bgAdd,
+		"bg_del":        &p.bgDel,
+		"bg_add_word":   &p.bgAddWord,
+		"bg_del_word":   &p.bgDelWord,
+		"line_number":   &p.lineNum,
+		"hunk_header":   &p.hunkHdr,
+		"file_header":   &p.fileHdr,
+		"gutter":        &p.gutter,
+		"add_indicator": &p.addInd,
+		"del_indicator": &p.delInd,
+		"context":       &p.ctxDim,
+		"truncate":      &p.truncate,
+		"dir":           &p.dir,
+		"file":          &p.file,
+		"cursor_fg":     &p.cursorFg,
+		"cursor_bg":     &p.cursorBg,
+		"staged":        &p.staged,
+		"unstaged":      &p.unstaged,
+		"untracked":     &p.untracked,
+		"border":        &p.border,
+		"search":        &p.search,
+		"title":         &p.title,
+		"background":    &p.background,
+		"chroma_style":  &p.chromaStyle,
+	}
+}
+
+// colorValue matches the colors a theme can use: #rrggbb, or an ANSI color
+// number.
+var colorValue = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|[0-9]{1,3})$`)
+
+// parseTheme adds a theme from a [theme.name] table. It starts from the
+// theme named by from, dark unless set, and replaces the colors given:
+//
+//	[theme.mine]
+//	from = "gruvbox"
+//	bg_add = "#203020"
+//	strong = true
+func parseTheme(name string, t *configTable) error {
+	keys := paletteKeys(&palette{})
+	known := []string{"from", "strong"}
+	for k := range keys {
+		known = append(known, k)
+	}
+	if err := t.checkKeys(known...); err != nil {
+		return err
+	}
+	from, err := t.str("from")
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		from = "dark"
+	}
+	p, ok := themes[from]
+	if !ok {
+		return fmt.Errorf("line %d: unknown theme %q to start from", t.lines["from"], from)
+	}
+	for key, dst := range paletteKeys(&p) {
+		s, err := t.str(key)
+		if err != nil {
+			return err
+		}
+		switch {
+		case s == "":
+			continue
+		case key == "chroma_style" && styles.Registry[s] == nil:
+			return fmt.Errorf("line %d: unknown chroma_style %q", t.lines[key], s)
+		case key != "chroma_style" && !colorValue.MatchString(s):
+			return fmt.Errorf("line %d: %s should be a color like #1f2328, not %q", t.lines[key], key, s)
+		}
+		*dst = s
+	}
+	if p.strong, err = t.boolean("strong"); err != nil {
+		return err
+	}
+	themes[name] = p
+	return nil
+}
@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Merge Conflicts ====================
+
+// conflict is one <<<<<<< ... >>>>>>> block of a conflicted file.
+type conflict struct {
+	start, end  int // the marker lines opening and closing it, 0-based
+	ours        []string
+	theirs      []string
+	oursLabel   string
+	theirsLabel string
+	oursAt      int // its first line in the file resolved to ours, 1-based
+}
+
+// parseConflicts finds the conflict blocks in lines, each ending in its
+// newline. The base section of diff3-style conflicts is left out.
+func parseConflicts(lines []string) []conflict {
+	var cs []conflict
+	var cur *conflict
+	section := 0 // 1 ours, 2 base, 3 theirs
+	oursLine := 1
+	for i, line := range lines {
+		marker := strings.TrimRight(line, "\r\n")
+		switch {
+		case cur == nil && strings.HasPrefix(marker, "<<<<<<<"):
+			cur = &conflict{start: i, oursLabel: strings.TrimSpace(marker[7:]), oursAt: oursLine}
+			section = 1
+		case cur == nil:
+			oursLine++
+		case strings.HasPrefix(marker, "|||||||") && section == 1:
+			section = 2
+		case marker == "=======" && section < 3:
+			section = 3
+		case strings.HasPrefix(marker, ">>>>>>>") && section == 3:
+			cur.end = i
+			cur.theirsLabel = strings.TrimSpace(marker[7:])
+			oursLine += len(cur.ours)
+			cs = append(cs, *cur)
+			cur = nil
+		case section == 1:
+			cur.ours = append(cur.ours, line)
+		case section == 3:
+			cur.theirs = append(cur.theirs, line)
+		}
+	}
+	return cs
+}
+
+// inHunk reports whether c starts among frag's old lines, which are the file
+// resolved to ours.
+func (c conflict) inHunk(frag *gitdiff.TextFragment) bool {
+	lo, hi := int(frag.OldPosition), int(frag.OldPosition+frag.OldLines)
+	return c.oursAt >= lo && c.oursAt < hi
+}
+
+// resolveConflicts returns lines with the conflicts for which pick returns a
+// side replaced by it: "ours" or "theirs". The others are left as they are.
+func resolveConflicts(lines []string, cs []conflict, pick func(conflict) string) []string {
+	var out []string
+	next := 0
+	for _, c := range cs {
+		var side []string
+		switch pick(c) {
+		case "ours":
+			side = c.ours
+		case "theirs":
+			side = c.theirs
+		default:
+			continue
+		}
+		out = append(out, lines[next:c.start]...)
+		out = append(out, side...)
+		next = c.end + 1
+	}
+	return append(out, lines[next:]...)
+}
+
+// readConflicts reads path from the worktree with its conflicts.
+func readConflicts(path string) ([]string, []conflict, error) {
+	data, err := os.ReadFile(filepath.Join(repoRoot(), path))
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	return lines, parseConflicts(lines), nil
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^(@@ -\d+(?:,\d+)? \+\d+(?:,\d+)? @@).*`)
+
+// conflictDiff diffs path resolved to ours against it resolved to theirs,
+// so each conflict is a hunk with ours on the left and theirs on the right.
+// It returns "" when the file has no conflict markers, as when one side
+// deleted it.
+func conflictDiff(ctx context.Context, path string) (string, error) {
+	lines, cs, err := readConflicts(path)
+	if err != nil || len(cs) == 0 {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "gd-conflict")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	ours, theirs := filepath.Join(dir, "ours"), filepath.Join(dir, "theirs")
+	for file, side := range map[string]string{ours: "ours", theirs: "theirs"} {
+		text := strings.Join(resolveConflicts(lines, cs, func(conflict) string { return side }), "")
+		if err := os.WriteFile(file, []byte(text), 0o600); err != nil {
+			return "", err
+		}
+	}
+	args := append(append([]string{"diff"}, git.DiffOpts...), "--no-index", "--", ours, theirs)
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+		err = nil // the sides differ
+	}
+	if err != nil {
+		return "", fmt.Errorf("git diff %s: %w", path, git.StderrError(err))
+	}
+
+	// name the file rather than the temporary copies, and label the sides
+	label := trf("ours %s ◀ ▶ theirs %s", cs[0].oursLabel, cs[0].theirsLabel)
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", path, path, path, path)
+	body := false
+	for _, line := range strings.SplitAfter(string(out), "\n") {
+		if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+			body = true
+			line = m[1] + " " + label + "\n"
+		}
+		if body {
+			b.WriteString(line)
+		}
+	}
+	return b.String(), nil
+}
+
+// takeConflictSide resolves the conflicts in the current hunk of a conflicted
+// file to ours or theirs, rewriting the file in the worktree.
+func (m model) takeConflictSide(side string) tea.Cmd {
+	f := m.selectedFile()
+	i := m.currentHunkIdx()
+	if f == nil || !f.Conflicted || i < 0 {
+		return func() tea.Msg { return statusMsg{text: tr("no conflict here")} }
+	}
+	frag := m.hunks[i].Frag
+	path := f.Path
+	session.record("take_"+side, path, frag.Header())
+	return func() tea.Msg {
+		lines, cs, err := readConflicts(path)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		taken := 0
+		lines = resolveConflicts(lines, cs, func(c conflict) string {
+			if c.inHunk(frag) {
+				taken++
+				return side
+			}
+			return ""
+		})
+		if taken == 0 {
+			return statusMsg{text: tr("no conflict here")}
+		}
+		full := filepath.Join(repoRoot(), path)
+		info, err := os.Stat(full)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		if err := os.WriteFile(full, []byte(strings.Join(lines, "")), info.Mode().Perm()); err != nil {
+			return errorMsg{err: err}
+		}
+		text := trf("took %s in %s, %d conflicts left", side, path, len(cs)-taken)
+		if len(cs) == taken {
+			text = trf("took %s in %s; no conflicts left, git add it to mark it resolved", side, path)
+		}
+		files, err := loadFiles()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return filesLoadedMsg{files: files, text: text}
+	}
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// fileLines splits text the way readConflicts does, each line ending in its
+// newline.
+func fileLines(text string) []string {
+	return strings.SplitAfter(text, "\n")
+}
+
+func TestParseConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		want []conflict
+	}{
+		{"none", "a\nb\n", nil},
+		{
+			"one",
+			"a\n<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> feat\nb\n",
+			[]conflict{{start: 1, end: 5, ours: []string{"ours\n"}, theirs: []string{"theirs\n"}, oursLabel: "HEAD", theirsLabel: "feat", oursAt: 2}},
+		},
+		{
+			"diff3 base left out",
+			"<<<<<<< HEAD\nours\n||||||| base\nbase\n=======\ntheirs\n>>>>>>> feat\n",
+			[]conflict{{start: 0, end: 6, ours: []string{"ours\n"}, theirs: []string{"theirs\n"}, oursLabel: "HEAD", theirsLabel: "feat", oursAt: 1}},
+		},
+		{
+			"second counts the first's ours",
+			"<<<<<<< a\no1\no2\n=======\nt\n>>>>>>> b\nmid\n<<<<<<< a\n=======\ny\n>>>>>>> b\n",
+			[]conflict{
+				{start: 0, end: 5, ours: []string{"o1\n", "o2\n"}, theirs: []string{"t\n"}, oursLabel: "a", theirsLabel: "b", oursAt: 1},
+				{start: 7, end: 10, theirs: []string{"y\n"}, oursLabel: "a", theirsLabel: "b", oursAt: 4},
+			},
+		},
+		{"unclosed", "<<<<<<< HEAD\nours\n=======\ntheirs\n", nil},
+	} {
+		if got := parseConflicts(fileLines(tc.text)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: parseConflicts = %+v; want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	text := "<<<<<<< a\no1\n=======\nt1\n>>>>>>> b\nmid\n<<<<<<< a\no2\n=======\nt2\n>>>>>>> b\nend\n"
+	for _, tc := range []struct {
+		name  string
+		sides []string // picked for each conflict in turn
+		want  string
+	}{
+		{"ours", []string{"ours", "ours"}, "o1\nmid\no2\nend\n"},
+		{"theirs", []string{"theirs", "theirs"}, "t1\nmid\nt2\nend\n"},
+		{"mixed", []string{"theirs", "ours"}, "t1\nmid\no2\nend\n"},
+		{"one left", []string{"", "theirs"}, "<<<<<<< a\no1\n=======\nt1\n>>>>>>> b\nmid\nt2\nend\n"},
+		{"none", []string{"", ""}, text},
+	} {
+		lines := fileLines(text)
+		cs := parseConflicts(lines)
+		got := strings.Join(resolveConflicts(lines, cs, func(c conflict) string {
+			for i := range cs {
+				if cs[i].start == c.start {
+					return tc.sides[i]
+				}
+			}
+			return ""
+		}), "")
+		if got != tc.want {
+			t.Errorf("%s: resolveConflicts = %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestConflictInHunk(t *testing.T) {
+	// the hunk covers lines 2 to 4 of the file resolved to ours
+	frag := &gitdiff.TextFragment{OldPosition: 2, OldLines: 3}
+	for at, want := range map[int]bool{1: false, 2: true, 4: true, 5: false} {
+		if got := (conflict{oursAt: at}).inHunk(frag); got != want {
+			t.Errorf("conflict at line %d: inHunk = %t; want %t", at, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Context Lines ====================
+
+// contextLines is one more than the number of unchanged lines the previews
+// show around each change, so that its zero value leaves git's default. -U
+// sets it and + and - step it.
+var contextLines atomic.Int32
+
+// shownContext is the context the previews ask git for, or -1 for its default.
+func shownContext() int { return int(contextLines.Load()) - 1 }
+
+// maxContext bounds + well short of -U99999's whole-file view.
+const maxContext = 999
+
+// setContextFlag reads -U and --context.
+func setContextFlag(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > maxContext {
+		return fmt.Errorf("want a number of lines from 0 to %d", maxContext)
+	}
+	contextLines.Store(int32(n + 1))
+	return nil
+}
+
+// gitContext is the number of context lines git diff shows by default.
+func gitContext() int {
+	out, err := exec.Command("git", "config", "--int", "diff.context").Output()
+	if n, perr := strconv.Atoi(strings.TrimSpace(string(out))); err == nil && perr == nil && n >= 0 {
+		return n
+	}
+	return 3
+}
+
+// stepContext shows delta more lines of context around each change, or fewer,
+// running the diffs again.
+func (m model) stepContext(delta int) tea.Cmd {
+	n := shownContext()
+	if n < 0 {
+		n = gitContext()
+	}
+	next := max(min(n+delta, maxContext), 0)
+	if next == shownContext() {
+		return nil
+	}
+	contextLines.Store(int32(next + 1))
+	text := trf("%d lines of context", next)
+	if next == 1 {
+		text = tr("1 line of context")
+	}
+	return tea.Batch(m.reloadPreview(), func() tea.Msg { return statusMsg{text: text} })
+}
+
+// withUnidiffZero adds --unidiff-zero to git apply's args when the previews'
+// hunks have no context, which git apply refuses without it.
+func withUnidiffZero(args []string) []string {
+	if shownContext() == 0 {
+		return append(args, "--unidiff-zero")
+	}
+	return args
+}
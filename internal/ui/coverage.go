@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ==================== Coverage ====================
+
+// coverage maps repo-relative paths to line coverage: true when some block on
+// the line ran, false when its blocks never did. Lines without statements are
+// absent.
+var coverage map[string]map[int]bool
+
+// coverProfiles are the names looked for at the repo root when --cover isn't
+// given.
+var coverProfiles = []string{"coverage.out", "cover.out", "coverage.txt", "c.out"}
+
+var coverBlockRe = regexp.MustCompile(`^(.+\.go):(\d+)\.\d+,(\d+)\.\d+ \d+ (\d+)$`)
+
+// findCoverProfile returns --cover, taken from where gd was started, or the
+// first Go coverprofile at the repo root.
+func findCoverProfile() string {
+	if flagCover != "" {
+		return userPath(flagCover)
+	}
+	for _, name := range coverProfiles {
+		p := filepath.Join(repoRoot(), name)
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		line, _ := bufio.NewReader(f).ReadString('\n')
+		f.Close()
+		if strings.HasPrefix(line, "mode: ") {
+			return p
+		}
+	}
+	return ""
+}
+
+// modulePath reads the module path from the repo's root go.mod.
+func modulePath() string {
+	data, err := os.ReadFile(filepath.Join(repoRoot(), "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+// loadCoverage reads a coverprofile, mapping its import paths back to files
+// in the repo.
+func loadCoverage(path string) (map[string]map[int]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefix := modulePath() + "/"
+	cov := map[string]map[int]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := coverBlockRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		file := strings.TrimPrefix(m[1], prefix)
+		start, _ := strconv.Atoi(m[2])
+		end, _ := strconv.Atoi(m[3])
+		ran := m[4] != "0"
+		lines := cov[file]
+		if lines == nil {
+			lines = map[int]bool{}
+			cov[file] = lines
+		}
+		for n := start; n <= end; n++ {
+			lines[n] = lines[n] || ran
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cov, nil
+}
@@ -0,0 +1,71 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Debug Log ====================
+
+// debugFlag is --debug, which takes an optional file: --debug alone logs to
+// gd-debug.log in the temp directory, --debug=path to path.
+type debugFlag struct{ path string }
+
+func (f *debugFlag) String() string { return f.path }
+
+func (f *debugFlag) Set(v string) error {
+	switch v {
+	case "false":
+		f.path = ""
+	case "true":
+		f.path = filepath.Join(os.TempDir(), "gd-debug.log")
+	default:
+		f.path = v
+	}
+	return nil
+}
+
+func (f *debugFlag) IsBoolFlag() bool { return true }
+
+var flagDebug debugFlag
+
+// debugLog receives --debug lines; nil when debugging is off.
+var debugLog *log.Logger
+
+// startDebug opens the --debug log. The git commands gd runs are logged by
+// git itself, through GIT_TRACE2, with their arguments, how long they took,
+// and how they exited; gd adds key presses, errors, and input it skipped.
+func startDebug() (stop func(), err error) {
+	if flagDebug.path == "" {
+		return func() {}, nil
+	}
+	path, err := filepath.Abs(userPath(flagDebug.path))
+	if err != nil {
+		return func() {}, err
+	}
+	// git appends to the same file, so gd must too
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return func() {}, fmt.Errorf("debug log: %w", err)
+	}
+	os.Setenv("GIT_TRACE2", path)
+	debugLog = log.New(f, "gd ", log.Ltime|log.Lmicroseconds)
+	git.Debugf = debugLog.Printf
+	debugLog.Printf("start %q", os.Args)
+	return func() {
+		debugLog.Printf("exit")
+		f.Close()
+		fmt.Fprintf(os.Stderr, "debug log written to %s\n", path)
+	}, nil
+}
+
+// debugf logs to the --debug file, if there is one.
+func debugf(format string, args ...any) {
+	if debugLog != nil {
+		debugLog.Printf(format, args...)
+	}
+}
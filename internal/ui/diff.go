@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/arnavsurve/gd/pkg/render"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Diff Rendering ====================
+
+// diffTheme is what diffs are drawn in, made by initTheme.
+var diffTheme *render.Theme
+
+// hScroll is how many columns the preview's lines are scrolled left by, with
+// h and l in the focused preview, their numbers staying put. Wrapped lines
+// aren't. Renders off the UI goroutine read it.
+var hScroll atomic.Int32
+
+// hScrollCols is how far h and l scroll the preview sideways.
+const hScrollCols = 8
+
+// wrapLines soft-wraps long diff lines onto more rows instead of cutting them
+// short, toggled with w. Renders off the UI goroutine read it.
+var wrapLines atomic.Bool
+
+// tabSpaces is what a tab is drawn as, four columns unless the config file
+// sets tab_width.
+var tabSpaces = "    "
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", tabSpaces)
+}
+
+// syntaxRule picks the lexer, the chroma style, or both for the files glob
+// matches, from a [[syntax]] table in the config file:
+//
+//	[[syntax]]
+//	files = "*.tmpl"
+//	lexer = "go-html-template"
+//	style = "github-dark"
+type syntaxRule struct {
+	files string
+	lexer string
+	style string
+}
+
+var syntaxRules []syntaxRule
+
+func parseSyntaxRule(t *configTable) (syntaxRule, error) {
+	if err := t.checkKeys("files", "lexer", "style"); err != nil {
+		return syntaxRule{}, err
+	}
+	var r syntaxRule
+	for key, dst := range map[string]*string{"files": &r.files, "lexer": &r.lexer, "style": &r.style} {
+		s, err := t.str(key)
+		if err != nil {
+			return r, err
+		}
+		*dst = s
+	}
+	switch {
+	case r.files == "" || (r.lexer == "" && r.style == ""):
+		return r, fmt.Errorf("line %d: syntax needs files, and a lexer or a style", t.line)
+	case r.lexer != "" && lexers.Get(r.lexer) == nil:
+		return r, fmt.Errorf("line %d: unknown lexer %q", t.lines["lexer"], r.lexer)
+	case r.style != "" && styles.Registry[r.style] == nil:
+		return r, fmt.Errorf("line %d: unknown chroma style %q", t.lines["style"], r.style)
+	}
+	if _, err := path.Match(r.files, ""); err != nil {
+		return r, fmt.Errorf("line %d: bad pattern %q", t.lines["files"], r.files)
+	}
+	return r, nil
+}
+
+// syntaxFor is the lexer and style the first [[syntax]] rules to set them
+// give filename, "" where none do. A pattern without a slash matches the
+// file's name in any directory, as in .gitignore.
+func syntaxFor(filename string) (lexer, style string) {
+	for _, r := range syntaxRules {
+		name := filename
+		if !strings.Contains(r.files, "/") {
+			name = path.Base(filename)
+		}
+		if ok, _ := path.Match(r.files, name); !ok {
+			continue
+		}
+		if lexer == "" {
+			lexer = r.lexer
+		}
+		if style == "" {
+			style = r.style
+		}
+	}
+	return lexer, style
+}
+
+func renderDiff(raw string, width int, filename string) (string, []render.Hunk) {
+	return renderDiffOpts(raw, width, filename, renderOpts{})
+}
+
+// renderOpts tune renderDiff for previews.
+type renderOpts struct {
+	// progress gets the output so far every streamChunk lines or so;
+	// rendering stops when it returns false
+	progress func(string, []render.Hunk) bool
+	// limit renders only about this many bytes of diff lines, 0 for all
+	limit int
+	// lineLimit renders only this many diff lines, 0 for all
+	lineLimit int
+	// sections labels the parts of a file's diff, by path, in order
+	sections map[string][]render.Summary
+}
+
+// renderDiffOpts draws raw with the settings, toggles, and annotations in
+// effect.
+func renderDiffOpts(raw string, width int, filename string, opts renderOpts) (string, []render.Hunk) {
+	o := render.Options{
+		Width:          width,
+		Filename:       filename,
+		Theme:          diffTheme,
+		SideBySide:     sideBySide(width),
+		Accessible:     flagAccessible,
+		Wrap:           wrapLines.Load(),
+		HScroll:        int(hScroll.Load()),
+		TabWidth:       len(tabSpaces),
+		ShowWhitespace: flagShowWhitespace,
+		ExpandThreads:  threadsExpanded.Load(),
+		Syntax:         syntaxFor,
+		Annotate:       annotate,
+		Sections:       opts.sections,
+		Binary:         binaryPreview,
+		SubmoduleLog:   submoduleLog,
+		Progress:       opts.progress,
+		Limit:          opts.limit,
+		LineLimit:      opts.lineLimit,
+		More: func(lines int) string {
+			return trf("── … %s more lines, %s in all — press L to load them", thousands(lines), byteSize(len(raw)))
+		},
+		Translate: tr,
+		Trace:     trace,
+	}
+	if flagLinks {
+		o.LinkText = linkIssues
+	}
+	rendered, hunks, err := render.Diff(raw, o)
+	if err != nil {
+		reportError(fmt.Errorf("parsing diff of %s: %w", filename, err))
+	}
+	return rendered, hunks
+}
+
+// annotate gathers what path's diff is drawn with: lint and leftover notes,
+// coverage, review threads, blame when b shows it, owners, and links.
+func annotate(path string, f *gitdiff.File) render.Annotations {
+	notes := lintNotes(path)
+	if flagLeftovers {
+		notes = withLeftovers(notes, f)
+	}
+	ann := render.Annotations{Notes: notes, Cover: coverage[path], Threads: pathThreads(path), Owners: ownersFor(path), URL: fileURL(path)}
+	if web := webURL(path, 0); web != "" {
+		ann.URL = web
+		ann.LineURL = func(line int) string { return webURL(path, line) }
+	}
+	if showBlame.Load() && !f.IsNew && !f.IsBinary && !flagAccessible {
+		blame, err := blameFile(f.OldName)
+		if err != nil {
+			debugf("blame: %v", err)
+		}
+		ann.Blame = blame
+	}
+	return ann
+}
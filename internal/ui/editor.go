@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Editor ====================
+
+// editorCommand builds the command that opens path at line in the user's
+// editor, using each editor's own syntax for jumping to a line.
+func editorCommand(path string, line int) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	name, args := fields[0], fields[1:]
+	path = filepath.FromSlash(path)
+
+	if line > 0 {
+		switch filepath.Base(name) {
+		case "code", "code-insiders", "codium", "cursor":
+			args = append(args, "--goto", fmt.Sprintf("%s:%d", path, line))
+		case "subl", "hx", "helix", "zed":
+			args = append(args, fmt.Sprintf("%s:%d", path, line))
+		default:
+			// vi, vim, nvim, emacs, nano, micro, kak all accept +N
+			args = append(args, fmt.Sprintf("+%d", line), path)
+		}
+	} else {
+		args = append(args, path)
+	}
+
+	c := exec.Command(name, args...)
+	c.Dir = repoRoot()
+	return c
+}
+
+// hunkLine returns the new-file line of the first added line in frag, or the
+// line where its deletions happened when nothing was added.
+func hunkLine(frag *gitdiff.TextFragment) int {
+	n := int(frag.NewPosition)
+	lead := -1
+	for i, l := range frag.Lines {
+		switch l.Op {
+		case gitdiff.OpAdd:
+			return n
+		case gitdiff.OpDelete:
+			if lead == -1 {
+				lead = i
+			}
+		case gitdiff.OpContext:
+			n++
+		}
+	}
+	if lead == -1 {
+		lead = 0
+	}
+	return int(frag.NewPosition) + lead
+}
+
+func (m model) openEditor() tea.Cmd {
+	f := m.selectedFile()
+	if f == nil {
+		return nil
+	}
+	line := 0
+	if hunk := m.currentHunk(); hunk != nil {
+		line = hunkLine(hunk)
+	}
+	session.record("open_editor", f.Path, fmt.Sprint(line))
+	if inTmux() {
+		return runInTmux(editorCommand(f.Path, line))
+	}
+	return tea.ExecProcess(editorCommand(f.Path, line), func(err error) tea.Msg {
+		return execFinishedMsg{err: err}
+	})
+}
+
+// ==================== Difftool ====================
+
+// difftoolCommand runs git difftool for f with the diff arguments that pick
+// its two sides. The tool comes from --difftool, or git's own diff.tool
+// configuration.
+func difftoolCommand(f git.FileStatus, sides []string) *exec.Cmd {
+	args := []string{"difftool", "--no-prompt"}
+	if flagDifftool != "" {
+		args = append(args, "--tool="+flagDifftool)
+	}
+	args = append(args, sides...)
+	if f.Untracked {
+		// a new file against nothing, as git diff --no-index has it
+		args = append(args, "--", os.DevNull, f.Path)
+	} else if f.OrigPath != "" {
+		args = append(args, "--", f.OrigPath, f.Path)
+	} else {
+		args = append(args, "--", f.Path)
+	}
+	c := exec.Command("git", args...)
+	c.Dir = repoRoot()
+	return c
+}
+
+// difftoolSides are the diff arguments for the selected file's two sides as
+// the preview shows them: the range, the selected commit, or the worktree
+// against the index, or the index against HEAD for a staged change or the
+// staged section of one that's both.
+func (m model) difftoolSides(f git.FileStatus) ([]string, bool) {
+	switch {
+	case len(m.commits) > 0:
+		sha := m.commits[m.commitIdx].sha
+		if sha == "" || !isRev(sha) {
+			return nil, false
+		}
+		return []string{sha + "^", sha}, true
+	case f.Diff != "":
+		return nil, false
+	case f.Untracked:
+		return []string{"--no-index"}, true
+	case flagMain:
+		return []string{diffRange()}, true
+	case f.Staged && (!f.Unstaged || m.inStagedSection()):
+		return []string{"--cached"}, true
+	}
+	return nil, true
+}
+
+// inStagedSection reports whether the current hunk is in the second of the
+// preview's sections, which is the staged one for a file changed both ways.
+func (m model) inStagedSection() bool {
+	i := m.currentHunkIdx()
+	return i > 0 && m.hunks[i].File != m.hunks[0].File
+}
+
+func (m model) openDifftool() tea.Cmd {
+	f := m.selectedFile()
+	if f == nil {
+		return nil
+	}
+	sides, ok := m.difftoolSides(*f)
+	if !ok {
+		return func() tea.Msg { return statusMsg{text: tr("difftool needs a file in the repository")} }
+	}
+	session.record("difftool", f.Path, strings.Join(sides, " "))
+	return tea.ExecProcess(difftoolCommand(*f, sides), func(err error) tea.Msg {
+		return execFinishedMsg{err: err}
+	})
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ==================== Errors ====================
+
+// errorMsg reports a failure to the user: it's shown in the footer until the
+// next key, and kept in the log that ! opens.
+type errorMsg struct{ err error }
+
+type loggedError struct {
+	at  time.Time
+	err error
+}
+
+// The error log is global so that background work with no message of its own
+// to return, like a batch falling back to per-file diffs, can still report.
+var (
+	errorsMu   sync.Mutex
+	errorLog   []loggedError
+	errorsSeen int // errors already shown in the footer
+)
+
+const maxLoggedErrors = 100
+
+func reportError(err error) {
+	if err == nil {
+		return
+	}
+	debugf("error: %v", err)
+	errorsMu.Lock()
+	defer errorsMu.Unlock()
+	errorLog = append(errorLog, loggedError{at: time.Now(), err: err})
+	if n := len(errorLog) - maxLoggedErrors; n > 0 {
+		errorLog = errorLog[n:]
+		errorsSeen = max(errorsSeen-n, 0)
+	}
+}
+
+// unseenError returns the newest error not yet dismissed from the footer,
+// and how many more arrived with it.
+func unseenError() (error, int) {
+	errorsMu.Lock()
+	defer errorsMu.Unlock()
+	if errorsSeen >= len(errorLog) {
+		return nil, 0
+	}
+	return errorLog[len(errorLog)-1].err, len(errorLog) - errorsSeen - 1
+}
+
+func dismissErrors() {
+	errorsMu.Lock()
+	errorsSeen = len(errorLog)
+	errorsMu.Unlock()
+}
+
+// errorToast is the footer line for err within width: its first line, since
+// git's stderr often runs to several.
+func errorToast(err error, more, width int) string {
+	text, _, _ := strings.Cut(err.Error(), "\n")
+	hint := "  ! details"
+	if more > 0 {
+		hint = fmt.Sprintf("  +%d ! details", more)
+	}
+	text = "✗ " + text
+	if avail := width - ansi.StringWidth(hint); ansi.StringWidth(text) > avail {
+		text = ansi.Truncate(text, max(avail, 1), "…")
+	}
+	return delIndSty.Render(text) + borderSty.Render(hint)
+}
+
+// showErrors replaces the preview with every error logged this session,
+// newest first, in full.
+func (m model) showErrors() tea.Cmd {
+	errorsMu.Lock()
+	logged := append([]loggedError(nil), errorLog...)
+	errorsSeen = len(errorLog)
+	errorsMu.Unlock()
+	return func() tea.Msg {
+		var b strings.Builder
+		b.WriteString(titleSty.Render(fmt.Sprintf("Errors (%d)", len(logged))))
+		b.WriteString("\n\n")
+		for i := len(logged) - 1; i >= 0; i-- {
+			e := logged[i]
+			b.WriteString(ctxDimSty.Render(e.at.Format("15:04:05")))
+			b.WriteByte('\n')
+			for _, l := range strings.Split(strings.TrimRight(e.err.Error(), "\n"), "\n") {
+				b.WriteString("  " + delIndSty.Render(l) + "\n")
+			}
+			b.WriteByte('\n')
+		}
+		if len(logged) == 0 {
+			b.WriteString(ctxDimSty.Render(tr("Nothing has failed.")))
+		}
+		return diffLoadedMsg{content: b.String()}
+	}
+}
@@ -0,0 +1,445 @@
+package ui
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// ==================== Export ====================
+
+type fileDiff struct {
+	file    git.FileStatus
+	raw     string
+	added   int
+	deleted int
+	binary  bool
+}
+
+func diffStat(raw string) (added, deleted int, binary bool) {
+	files, _, err := gitdiff.Parse(strings.NewReader(raw))
+	if err != nil {
+		return 0, 0, false
+	}
+	for _, f := range files {
+		binary = binary || f.IsBinary
+		for _, frag := range f.TextFragments {
+			added += int(frag.LinesAdded)
+			deleted += int(frag.LinesDeleted)
+		}
+	}
+	return added, deleted, binary
+}
+
+// collectDiffs gathers one combined diff per file, as getPatch produces.
+func collectDiffs(files []git.FileStatus) []fileDiff {
+	diffs := make([]fileDiff, len(files))
+	inOrder(len(files), func(i int) fileDiff {
+		raw, _ := getPatch(files[i], false)
+		add, del, bin := diffStat(raw)
+		return fileDiff{file: files[i], raw: raw, added: add, deleted: del, binary: bin}
+	}, func(i int, d fileDiff) {
+		diffs[i] = d
+	})
+	return diffs
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.BoolVar(&flagMain, "main", false, "diff against main branch")
+	markdown := fs.Bool("markdown", false, "emit a Markdown summary for PR descriptions")
+	patch := fs.Bool("patch", false, "emit a patch usable with git apply")
+	svg := fs.Bool("svg", false, "render the styled diff as an SVG image")
+	checklist := fs.Bool("checklist", false, "emit a Markdown review checklist grouped by directory")
+	quickfix := fs.Bool("quickfix", false, "emit file:line:col: hunk locations for vim's quickfix list")
+	sarif := fs.Bool("sarif", false, "emit hunk locations as a SARIF log")
+	width := fs.Int("width", 120, "render width in columns for --svg")
+	output := fs.String("o", "", "write to file instead of stdout")
+	fs.Parse(args)
+	pathspecs = rootPathspecs(fs.Args())
+
+	formats := 0
+	for _, f := range []bool{*markdown, *patch, *svg, *checklist, *quickfix, *sarif} {
+		if f {
+			formats++
+		}
+	}
+	if formats != 1 {
+		fmt.Fprintln(os.Stderr, "error: choose one export format (--markdown, --patch, --svg, --checklist, --quickfix, or --sarif)")
+		os.Exit(2)
+	}
+
+	files, err := loadFiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		out, err := os.Create(userPath(*output))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+		w = out
+	}
+
+	switch {
+	case *patch:
+		var patch string
+		if patch, err = buildPatch(files); err == nil {
+			err = writeString(w, patch)
+		}
+	case *svg:
+		err = writeSVG(w, renderSnapshot(files, *width))
+	case *checklist:
+		writeChecklist(w, collectDiffs(files))
+	case *quickfix:
+		err = writeQuickfix(w, collectDiffs(files))
+	case *sarif:
+		err = writeSARIF(w, collectDiffs(files))
+	default:
+		writeMarkdown(w, collectDiffs(files))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeString(w io.Writer, s string) error {
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// renderSnapshot renders files with full truecolor styling regardless of
+// whether the output is a terminal.
+func renderSnapshot(files []git.FileStatus, width int) string {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	initTheme()
+	var b strings.Builder
+	for i, f := range files {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		raw, err := getDiffOutput(f, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		rendered, _ := renderDiff(raw, width, f.Path)
+		b.WriteString(rendered)
+	}
+	return b.String()
+}
+
+// ==================== Patch ====================
+
+// getPatch returns a single diff per file, of the kind of change the tree
+// shows, so the result applies cleanly with git apply, unlike getDiffOutput
+// which may emit staged and unstaged halves separately. binary includes binary files' contents, which git apply needs
+// to recreate them but which are no use to read.
+func getPatch(f git.FileStatus, binary bool) (string, error) {
+	return kindPatch(f, binary, showKind.Load())
+}
+
+// kindPatch is getPatch for a kind of worktree change.
+func kindPatch(f git.FileStatus, binary bool, kind int32) (string, error) {
+	if f.Diff != "" {
+		return f.Diff, nil
+	}
+	if d, ok := goGitPatchOf(f, binary, kind); ok {
+		return d, nil
+	}
+	var args []string
+	switch {
+	case flagMain && f.OrigPath != "":
+		args = []string{"diff", "-M", diffRange(), "--", f.OrigPath, f.Path}
+	case flagMain:
+		args = []string{"diff", diffRange(), "--", f.Path}
+	case f.Untracked:
+		args = []string{"diff", "--no-index", "--", "/dev/null", f.Path}
+	case f.OrigPath != "":
+		args = append(append([]string{"diff", "-M"}, kindDiffArgs(kind)...), "--", f.OrigPath, f.Path)
+	default:
+		args = append(append([]string{"diff"}, kindDiffArgs(kind)...), "--", f.Path)
+	}
+	opts := git.DiffOpts
+	if binary {
+		opts = append(slices.Clip(opts), "--binary")
+	}
+	args = append(args[:1], append(opts, args[1:]...)...)
+	out, err := exec.Command("git", args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && f.Untracked && exitErr.ExitCode() == 1 {
+		// --no-index exits 1 when the inputs differ
+		err = nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), git.StderrError(err))
+	}
+	return string(out), nil
+}
+
+// buildPatch is every file's patch, binary contents included, failing if
+// any can't be had rather than leaving it out.
+func buildPatch(files []git.FileStatus) (string, error) {
+	var b strings.Builder
+	for _, f := range files {
+		p, err := getPatch(f, true)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(p)
+	}
+	return b.String(), nil
+}
+
+func writePatchFile(path string, files []git.FileStatus) error {
+	patch, err := buildPatch(files)
+	if err != nil {
+		return err
+	}
+	if patch == "" {
+		return fmt.Errorf("nothing to export")
+	}
+	return os.WriteFile(userPath(path), []byte(patch), 0o644)
+}
+
+// buildHunkPatch writes the marked hunks as a patch, grouping hunks of the
+// same file under one header in position order.
+func buildHunkPatch(marked []markedHunk) string {
+	var order []string
+	byPath := map[string][]markedHunk{}
+	for _, h := range marked {
+		if _, ok := byPath[h.path]; !ok {
+			order = append(order, h.path)
+		}
+		byPath[h.path] = append(byPath[h.path], h)
+	}
+	var b strings.Builder
+	for _, p := range order {
+		hunks := byPath[p]
+		sort.SliceStable(hunks, func(i, j int) bool {
+			return hunks[i].frag.OldPosition < hunks[j].frag.OldPosition
+		})
+		f := *hunks[0].file
+		f.TextFragments = nil
+		for _, h := range hunks {
+			f.TextFragments = append(f.TextFragments, h.frag)
+		}
+		b.WriteString(f.String())
+	}
+	return b.String()
+}
+
+// ==================== Format Patch ====================
+
+// formatPatch runs git format-patch into dir for revs and returns the
+// number of files written.
+func formatPatch(dir string, revs []string, coverLetter bool) (int, error) {
+	args := []string{"format-patch", "-o", userPath(dir)}
+	if coverLetter {
+		args = append(args, "--cover-letter")
+	}
+	args = append(args, revs...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return len(strings.Fields(string(out))), nil
+}
+
+// ==================== Markdown ====================
+
+func writeMarkdown(w io.Writer, diffs []fileDiff) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	var totalAdd, totalDel int
+	fmt.Fprintln(w, "| File | + | − |")
+	fmt.Fprintln(w, "|------|--:|--:|")
+	for _, d := range diffs {
+		fmt.Fprintf(w, "| [%s](%s) | %d | %d |\n", mdEscape(d.file.Path), mdLinkPath(d.file.Path), d.added, d.deleted)
+		totalAdd += d.added
+		totalDel += d.deleted
+	}
+	fmt.Fprintf(w, "| **%d files** | **%d** | **%d** |\n", len(diffs), totalAdd, totalDel)
+
+	for _, d := range diffs {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "<details>")
+		fmt.Fprintf(w, "<summary><code>%s</code> (+%d −%d)</summary>\n\n", htmlEscape(d.file.Path), d.added, d.deleted)
+		fence := codeFence(d.raw)
+		fmt.Fprintln(w, fence+"diff")
+		fmt.Fprint(w, d.raw)
+		if !strings.HasSuffix(d.raw, "\n") {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, fence)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "</details>")
+	}
+}
+
+func writeChecklist(w io.Writer, diffs []fileDiff) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	groups := map[string][]fileDiff{}
+	var dirs []string
+	for _, d := range diffs {
+		dir := path.Dir(d.file.Path)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], d)
+	}
+	sort.Strings(dirs)
+
+	for i, dir := range dirs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if dir == "." {
+			fmt.Fprintln(w, "### (root)")
+		} else {
+			fmt.Fprintf(w, "### `%s/`\n", dir)
+		}
+		fmt.Fprintln(w)
+		for _, d := range groups[dir] {
+			name := path.Base(d.file.Path)
+			fmt.Fprintf(w, "- [ ] [%s](%s) (+%d −%d)\n", mdEscape(name), mdLinkPath(d.file.Path), d.added, d.deleted)
+		}
+	}
+}
+
+// codeFence returns a backtick fence longer than any backtick run in s.
+func codeFence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+func mdEscape(s string) string {
+	r := strings.NewReplacer(`|`, `\|`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+func mdLinkPath(s string) string {
+	r := strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+	return r.Replace(s)
+}
+
+func htmlEscape(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+	return r.Replace(s)
+}
+
+func patchName(path string) string {
+	return filepath.Base(path) + ".patch"
+}
+
+// ==================== JSON ====================
+
+type jsonChangeset struct {
+	Mode  string     `json:"mode"`
+	Files []jsonFile `json:"files"`
+}
+
+type jsonFile struct {
+	Path      string     `json:"path"`
+	OldPath   string     `json:"old_path,omitempty"`
+	Status    string     `json:"status"`
+	Staged    bool       `json:"staged"`
+	Unstaged  bool       `json:"unstaged"`
+	Untracked bool       `json:"untracked"`
+	Binary    bool       `json:"binary"`
+	Added     int        `json:"added"`
+	Deleted   int        `json:"deleted"`
+	Hunks     []jsonHunk `json:"hunks"`
+}
+
+type jsonHunk struct {
+	OldStart int    `json:"old_start"`
+	OldLines int    `json:"old_lines"`
+	NewStart int    `json:"new_start"`
+	NewLines int    `json:"new_lines"`
+	Header   string `json:"header,omitempty"`
+}
+
+func writeJSON(w io.Writer, files []git.FileStatus) error {
+	cs := jsonChangeset{Mode: "worktree", Files: []jsonFile{}}
+	if flagMain {
+		cs.Mode = "main"
+	}
+	for _, f := range files {
+		jf := jsonFile{
+			Path:      f.Path,
+			OldPath:   f.OrigPath,
+			Status:    f.StatusLabel(),
+			Staged:    f.Staged,
+			Unstaged:  f.Unstaged,
+			Untracked: f.Untracked,
+			Hunks:     []jsonHunk{},
+		}
+		raw, err := getPatch(f, false)
+		if err != nil {
+			return err
+		}
+		parsed, _, err := gitdiff.Parse(strings.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("parse diff for %s: %w", f.Path, err)
+		}
+		for _, pf := range parsed {
+			if pf.IsRename && jf.OldPath == "" {
+				jf.OldPath = pf.OldName
+			}
+			jf.Binary = jf.Binary || pf.IsBinary
+			for _, frag := range pf.TextFragments {
+				jf.Added += int(frag.LinesAdded)
+				jf.Deleted += int(frag.LinesDeleted)
+				jf.Hunks = append(jf.Hunks, jsonHunk{
+					OldStart: int(frag.OldPosition),
+					OldLines: int(frag.OldLines),
+					NewStart: int(frag.NewPosition),
+					NewLines: int(frag.NewLines),
+					Header:   frag.Comment,
+				})
+			}
+		}
+		cs.Files = append(cs.Files, jf)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cs)
+}
@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Fixup ====================
+
+// fixupLines returns the HEAD line numbers a hunk rewrites: its removed lines,
+// or the lines on either side of a pure insertion.
+func fixupLines(frag *gitdiff.TextFragment) []int {
+	var lines []int
+	n := int(frag.OldPosition)
+	for _, l := range frag.Lines {
+		switch l.Op {
+		case gitdiff.OpDelete:
+			lines = append(lines, n)
+			n++
+		case gitdiff.OpContext:
+			n++
+		}
+	}
+	if len(lines) > 0 {
+		return lines
+	}
+	n = int(frag.OldPosition)
+	for i, l := range frag.Lines {
+		if l.Op == gitdiff.OpAdd {
+			if n > 1 {
+				lines = append(lines, n-1)
+			}
+			for _, l := range frag.Lines[i:] {
+				if l.Op == gitdiff.OpContext {
+					return append(lines, n)
+				}
+			}
+			return lines
+		}
+		n++
+	}
+	return lines
+}
+
+// stackCommits lists the commits that are safe to rewrite, newest first:
+// those not on any remote, or not on the base branch when there are none.
+func stackCommits() ([]string, error) {
+	args := []string{"rev-list", "--max-count=50", "HEAD", "--not"}
+	if out, _ := exec.Command("git", "remote").Output(); len(strings.TrimSpace(string(out))) > 0 {
+		args = append(args, "--remotes")
+	} else {
+		args = append(args, baseRef)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-list: %w", err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// fixupTarget finds the newest unpublished commit that last touched the lines
+// frag changes in path. An unstaged hunk's line numbers are the index's, so
+// inIndex blames the staged version of the file.
+func fixupTarget(path string, frag *gitdiff.TextFragment, inIndex bool) (sha, title string, err error) {
+	lines := fixupLines(frag)
+	if len(lines) == 0 {
+		return "", "", fmt.Errorf("hunk doesn't touch existing lines")
+	}
+	stack, err := stackCommits()
+	if err != nil {
+		return "", "", err
+	}
+	if len(stack) == 0 {
+		return "", "", fmt.Errorf("no unpublished commit touched these lines")
+	}
+
+	args := []string{"blame", "--porcelain"}
+	var staged []byte
+	if inIndex {
+		if staged, err = exec.Command("git", "show", ":"+path).Output(); err != nil {
+			return "", "", fmt.Errorf("git show :%s: %w", path, git.StderrError(err))
+		}
+		// --contents blames from HEAD, and refuses it named
+		args = append(args, "--contents", "-")
+	} else {
+		args = append(args, "HEAD")
+	}
+	for _, n := range lines {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", n, n))
+	}
+	blame := exec.Command("git", append(append(args, "--"), path)...)
+	if inIndex {
+		blame.Stdin = bytes.NewReader(staged)
+	}
+	out, err := blame.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git blame %s: %w", path, git.StderrError(err))
+	}
+	// header lines start with the commit's id, as long as the stack's are
+	blamed := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if strings.HasPrefix(line, "\t") || len(f) < 3 || len(f[0]) != len(stack[0]) {
+			continue
+		}
+		if _, err := hex.DecodeString(f[0]); err == nil {
+			blamed[f[0]] = true
+		}
+	}
+
+	for _, c := range stack {
+		if blamed[c] {
+			out, err := exec.Command("git", "log", "-1", "--format=%s", c).Output()
+			if err != nil {
+				return "", "", fmt.Errorf("git log %s: %w", c, err)
+			}
+			return c, strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", "", fmt.Errorf("no unpublished commit touched these lines")
+}
+
+// commitFixup commits just hunk as "fixup! <target>". The commit is built in
+// a scratch index so other staged changes stay out of it, then the hunk is
+// staged in the real index to match the new HEAD.
+func commitFixup(h markedHunk, sha string) error {
+	patch := buildHunkPatch([]markedHunk{h})
+	apply := func(env []string, args ...string) error {
+		c := exec.Command("git", withUnidiffZero(append([]string{"apply", "--cached"}, args...))...)
+		c.Env = env
+		c.Stdin = strings.NewReader(patch)
+		if out, err := c.CombinedOutput(); err != nil {
+			return fmt.Errorf("git apply: %s", strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	// the hunk is either still unstaged or already in the index
+	stage := apply(nil, "--check") == nil
+	if !stage && apply(nil, "--check", "--reverse") != nil {
+		return fmt.Errorf("hunk no longer matches the index")
+	}
+
+	dir, err := gitDir()
+	if err != nil {
+		return err
+	}
+	index := filepath.Join(dir, "gd-fixup-index")
+	defer os.Remove(index)
+	env := append(os.Environ(), "GIT_INDEX_FILE="+index)
+	read := exec.Command("git", "read-tree", "HEAD")
+	read.Env = env
+	if out, err := read.CombinedOutput(); err != nil {
+		return fmt.Errorf("git read-tree: %s", strings.TrimSpace(string(out)))
+	}
+	if err := apply(env); err != nil {
+		return err
+	}
+	commit := exec.Command("git", "commit", "--quiet", "--fixup="+sha)
+	commit.Env = env
+	if out, err := commit.CombinedOutput(); err != nil {
+		return fmt.Errorf("git commit: %s", strings.TrimSpace(string(out)))
+	}
+	if stage {
+		return apply(nil)
+	}
+	return nil
+}
+
+// promptFixup looks up the commit the current hunk fixes and asks before
+// committing it as a fixup.
+func (m model) promptFixup() tea.Cmd {
+	f := m.selectedFile()
+	i := m.currentHunkIdx()
+	if f == nil || i < 0 {
+		return nil
+	}
+	if flagMain || f.Untracked || f.Diff != "" {
+		return func() tea.Msg { return statusMsg{text: tr("fixups need a change to a tracked file in the worktree")} }
+	}
+	if ignoreSpace.Load() {
+		return func() tea.Msg { return statusMsg{text: tr("can't stage with whitespace hidden; I shows it")} }
+	}
+	h := markedHunk{path: f.Path, file: m.hunks[i].File, frag: m.hunks[i].Frag}
+	inIndex := !(f.Staged && (!f.Unstaged || m.inStagedSection()))
+	return func() tea.Msg {
+		sha, title, err := fixupTarget(h.path, h.frag, inIndex)
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("fixup: %w", err)}
+		}
+		return promptMsg{prompt: &prompt{
+			label: trf("fixup! %s %s? (y/N): ", sha[:7], title),
+			submit: func(answer string) tea.Cmd {
+				if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+					return nil
+				}
+				session.record("fixup", h.path, sha)
+				return func() tea.Msg {
+					if err := commitFixup(h, sha); err != nil {
+						return errorMsg{err: fmt.Errorf("fixup failed: %w", err)}
+					}
+					files, err := loadFiles()
+					if err != nil {
+						return errorMsg{err: err}
+					}
+					return filesLoadedMsg{files: files, text: trf("committed fixup! %s", title)}
+				}
+			},
+		}}
+	}
+}
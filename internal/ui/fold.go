@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arnavsurve/gd/pkg/render"
+)
+
+// ==================== Hunk Folding ====================
+
+// hunkKey names a hunk across reloads of the same preview.
+func hunkKey(h render.Hunk) string {
+	return h.File.OldName + "\x00" + h.File.NewName + "\x00" + h.Frag.Header()
+}
+
+// isFolded reports whether a hunk is shown as its header alone: in the folded
+// view unless it was opened, and otherwise only when it was folded.
+func (m model) isFolded(h render.Hunk) bool {
+	return m.foldView != m.foldToggled[hunkKey(h)]
+}
+
+// foldHunks replaces each folded hunk of a rendered diff with one line naming
+// it and counting its changes, returning the content and the hunks'
+// positions in it.
+func foldHunks(content string, hunks []render.Hunk, folded func(render.Hunk) bool, width int) (string, []render.Hunk) {
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	moved := make([]render.Hunk, len(hunks))
+	next := 0
+	for i, h := range hunks {
+		start, end := h.Line, h.End
+		if end <= start {
+			// still streaming in
+			end = len(lines)
+			if i+1 < len(hunks) {
+				end = hunks[i+1].Line
+			}
+		}
+		out = append(out, lines[next:start]...)
+		h.Line = len(out)
+		if folded(h) {
+			label := fmt.Sprintf("▸ %s  +%d −%d", h.Frag.Header(), h.Frag.LinesAdded, h.Frag.LinesDeleted)
+			out = append(out, hunkHdrSty.Render(fitStr(label, width)))
+		} else {
+			out = append(out, lines[start:end]...)
+		}
+		h.End = len(out)
+		moved[i] = h
+		next = end
+	}
+	out = append(out, lines[next:]...)
+	return strings.Join(out, "\n"), moved
+}
+
+// showFolds puts the rendered preview in the viewport with its folded hunks
+// closed.
+func (m *model) showFolds() {
+	content, hunks := m.unfolded, m.unfoldedHunks
+	if m.foldView || len(m.foldToggled) > 0 {
+		content, hunks = foldHunks(content, hunks, m.isFolded, m.viewport.width)
+	}
+	m.hunks = hunks
+	m.viewport.setContent(content)
+}
+
+// toggleFold folds the current hunk, or opens it when it's folded.
+func (m *model) toggleFold() {
+	i := m.currentHunkIdx()
+	if m.showTests || m.paging || i < 0 {
+		return
+	}
+	key := hunkKey(m.hunks[i])
+	if m.foldToggled == nil {
+		m.foldToggled = map[string]bool{}
+	}
+	if m.foldToggled[key] {
+		delete(m.foldToggled, key)
+	} else {
+		m.foldToggled[key] = true
+	}
+	m.showFolds()
+	m.hunkIdx = i
+	m.viewport.setYOffset(m.hunks[i].Line)
+}
+
+// toggleFoldView switches between showing every hunk and showing only their
+// headers, staying on the current hunk.
+func (m *model) toggleFoldView() {
+	if m.showTests || m.paging {
+		return
+	}
+	m.foldView = !m.foldView
+	m.foldToggled = nil
+	i := m.currentHunkIdx()
+	m.showFolds()
+	if i >= 0 {
+		m.hunkIdx = i
+		m.viewport.setYOffset(m.hunks[i].Line)
+	}
+	if m.foldView {
+		m.message = tr("folded: space opens a hunk, Z shows all")
+	} else {
+		m.message = ""
+	}
+}
@@ -0,0 +1,357 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Forge ====================
+
+type forgeKind int
+
+const (
+	forgeGitHub forgeKind = iota
+	forgeGitLab
+	forgeBitbucket
+	forgeGitea
+)
+
+// forge identifies the web UI hosting a repository.
+type forge struct {
+	kind forgeKind
+	host string
+	repo string // owner/name
+}
+
+// parseRemote understands scp-style (git@host:owner/repo.git), ssh:// and
+// http(s):// remote URLs.
+func parseRemote(remote string) (forge, error) {
+	remote = strings.TrimSpace(remote)
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(remote, "@"); at != -1 && strings.Contains(remote[at:], ":") {
+		rest := remote[at+1:]
+		colon := strings.Index(rest, ":")
+		host, path = rest[:colon], rest[colon+1:]
+	} else {
+		return forge{}, fmt.Errorf("unrecognized remote URL %q", remote)
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return forge{}, fmt.Errorf("unrecognized remote URL %q", remote)
+	}
+
+	f := forge{kind: forgeGitHub, host: host, repo: path}
+	switch {
+	case strings.Contains(host, "gitlab"):
+		f.kind = forgeGitLab
+	case strings.Contains(host, "bitbucket"):
+		f.kind = forgeBitbucket
+	case strings.Contains(host, "gitea"), host == "codeberg.org":
+		f.kind = forgeGitea
+	}
+	return f, nil
+}
+
+var forgeNames = map[string]forgeKind{
+	"github":    forgeGitHub,
+	"gitlab":    forgeGitLab,
+	"bitbucket": forgeBitbucket,
+	"gitea":     forgeGitea,
+	"forgejo":   forgeGitea,
+}
+
+// originForge identifies origin's forge from its URL. Self-hosted instances
+// whose host doesn't say what they run can set git config gd.forge.
+func originForge() (forge, error) {
+	out, err := exec.Command("git", "config", "--get", "remote.origin.url").Output()
+	if err != nil {
+		return forge{}, fmt.Errorf("no remote.origin.url configured")
+	}
+	f, err := parseRemote(string(out))
+	if err != nil {
+		return f, err
+	}
+	if name, err := exec.Command("git", "config", "--get", "gd.forge").Output(); err == nil {
+		kind, ok := forgeNames[strings.ToLower(strings.TrimSpace(string(name)))]
+		if !ok {
+			return f, fmt.Errorf("gd.forge: unknown forge %q", strings.TrimSpace(string(name)))
+		}
+		f.kind = kind
+	}
+	return f, nil
+}
+
+// blobURL links to path at ref, optionally anchored to line.
+func (f forge) blobURL(ref, path string, line int) string {
+	base := "https://" + f.host + "/" + f.repo
+	escaped := (&url.URL{Path: path}).EscapedPath()
+	var u, anchor string
+	switch f.kind {
+	case forgeGitLab:
+		u = base + "/-/blob/" + ref + "/" + escaped
+		anchor = fmt.Sprintf("#L%d", line)
+	case forgeBitbucket:
+		u = base + "/src/" + ref + "/" + escaped
+		anchor = fmt.Sprintf("#lines-%d", line)
+	case forgeGitea:
+		kind := "branch"
+		if shaRe.MatchString(ref) {
+			kind = "commit"
+		}
+		u = base + "/src/" + kind + "/" + ref + "/" + escaped
+		anchor = fmt.Sprintf("#L%d", line)
+	default:
+		u = base + "/blob/" + ref + "/" + escaped
+		anchor = fmt.Sprintf("#L%d", line)
+	}
+	if line > 0 {
+		u += anchor
+	}
+	return u
+}
+
+var shaRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// currentBranch returns the checked-out branch, or the HEAD commit when
+// detached.
+func currentBranch() string {
+	if b, ok := goGitBranch(); ok {
+		return b
+	}
+	out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err == nil {
+		if b := strings.TrimSpace(string(out)); b != "HEAD" {
+			return b
+		}
+	}
+	out, _ = exec.Command("git", "rev-parse", "HEAD").Output()
+	return strings.TrimSpace(string(out))
+}
+
+func openBrowser(u string) error {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		c = exec.Command("open", u)
+	case "windows":
+		c = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		c = exec.Command("xdg-open", u)
+	}
+	return c.Start()
+}
+
+func (m model) openInBrowser() tea.Cmd {
+	f := m.selectedFile()
+	if f == nil {
+		return nil
+	}
+	line := 0
+	if hunk := m.currentHunk(); hunk != nil {
+		line = hunkLine(hunk)
+	}
+	path := f.Path
+	return func() tea.Msg {
+		fg, err := originForge()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		u := fg.blobURL(currentBranch(), path, line)
+		if err := openBrowser(u); err != nil {
+			return errorMsg{err: fmt.Errorf("open failed: %w", err)}
+		}
+		return statusMsg{text: trf("opened %s", u)}
+	}
+}
+
+// copyPermalink copies a URL pinned to the HEAD commit, so the link keeps
+// pointing at the same content after the branch moves.
+func (m model) copyPermalink() tea.Cmd {
+	f := m.selectedFile()
+	if f == nil {
+		return nil
+	}
+	line := 0
+	if hunk := m.currentHunk(); hunk != nil {
+		line = hunkLine(hunk)
+	}
+	path := f.Path
+	session.record("copy_permalink", path, fmt.Sprint(line))
+	return func() tea.Msg {
+		fg, err := originForge()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+		if err != nil {
+			return statusMsg{text: tr("no HEAD commit")}
+		}
+		u := fg.blobURL(strings.TrimSpace(string(out)), path, line)
+		if err := copyToClipboard(u); err != nil {
+			return errorMsg{err: fmt.Errorf("copy failed: %w", err)}
+		}
+		return statusMsg{text: tr("copied permalink")}
+	}
+}
+
+// ==================== Providers ====================
+
+// provider is a forge's pull request API. GitHub goes through the gh CLI;
+// GitLab and Gitea use their REST APIs with a token from the environment.
+type provider interface {
+	listPRs() ([]pullRequest, error)
+	prDiff(pr pullRequest) (string, error)
+	checkoutPR(pr pullRequest) error
+	currentPR() (int, error)
+	fetchPR(n int) (base, head string, err error)
+	reviewThreads(pr int) ([]prThread, error)
+	submitReview(pr int, r review) error
+}
+
+// review is a batch of line comments with an overall verdict.
+type review struct {
+	commitID string
+	event    string // COMMENT, APPROVE, or REQUEST_CHANGES
+	body     string
+	comments []reviewComment
+}
+
+func originProvider() (provider, error) {
+	f, err := originForge()
+	if err != nil {
+		return nil, err
+	}
+	switch f.kind {
+	case forgeGitHub:
+		return githubProvider{f}, nil
+	case forgeGitLab:
+		return gitlabProvider{f}, nil
+	case forgeGitea:
+		return giteaProvider{f}, nil
+	}
+	return nil, fmt.Errorf("pull requests on %s aren't supported", f.host)
+}
+
+// restJSON sends a JSON request to a forge API and decodes the response into
+// out, when given.
+func restJSON(method, u string, header http.Header, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, u, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// tokenHeader returns header set to the token in env, if there is one.
+func tokenHeader(env, header, prefix string) http.Header {
+	h := http.Header{}
+	if tok := os.Getenv(env); tok != "" {
+		h.Set(header, prefix+tok)
+	}
+	return h
+}
+
+// fetchPRHead fetches a pull request's head ref from origin and returns its
+// commit.
+func fetchPRHead(ref string) (string, error) {
+	if out, err := exec.Command("git", "fetch", "--quiet", "origin", ref).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git fetch origin %s: %s", ref, strings.TrimSpace(string(out)))
+	}
+	out, err := exec.Command("git", "rev-parse", "FETCH_HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse FETCH_HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fetchPRRefs fetches a pull request's head ref and its base branch from
+// origin, returning the base's remote branch and the head's commit.
+func fetchPRRefs(ref, base string) (string, string, error) {
+	sha, err := fetchPRHead(ref)
+	if err != nil {
+		return "", "", err
+	}
+	exec.Command("git", "fetch", "--quiet", "origin", base).Run()
+	return "origin/" + base, sha, nil
+}
+
+// diffPRHead diffs a fetched pull request head against its merge base with
+// the up-to-date base branch.
+func diffPRHead(ref, base string) (string, error) {
+	_, sha, err := fetchPRRefs(ref, base)
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"diff"}, git.DiffOpts...)
+	out, err := exec.Command("git", append(args, "origin/"+base+"..."+sha)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git diff origin/%s...%s: %w", base, sha[:7], err)
+	}
+	return string(out), nil
+}
+
+// checkoutPRHead checks out a fetched pull request head on a local branch.
+func checkoutPRHead(ref, branch string) error {
+	sha, err := fetchPRHead(ref)
+	if err != nil {
+		return err
+	}
+	c := exec.Command("git", "checkout", "-B", branch, sha)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("git checkout: %w", err)
+	}
+	return nil
+}
+
+var prBranchRe = regexp.MustCompile(`^(?:mr|pr)/(\d+)$`)
+
+// checkedOutPR returns the number from a branch made by checkoutPRHead.
+func checkedOutPR(branch string) (int, bool) {
+	m := prBranchRe.FindStringSubmatch(branch)
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n, true
+}
@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ==================== Fuzzy Matching ====================
+
+// Scores for fuzzyMatch, after fzf's: every matched character scores, more
+// when it starts a word or follows the one before, and gaps cost.
+const (
+	fuzzyMatchScore   = 16
+	fuzzyBoundary     = 8
+	fuzzyCamel        = 7
+	fuzzyConsecutive  = 4
+	fuzzyGapStart     = -3
+	fuzzyGapExtension = -1
+)
+
+// fuzzyMatch reports whether pattern's characters appear in text in order,
+// ignoring case, and if so how well and at which runes of text. Of the ways
+// to match, it scores the shortest stretch of text holding them all, so
+// mdupd finds model_update.go at its m, d, u, p, and d.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	pat := []rune(strings.ToLower(pattern))
+	orig := []rune(text)
+	low := []rune(strings.ToLower(text))
+	if len(pat) == 0 {
+		return 0, nil, true
+	}
+	if len(low) != len(orig) {
+		low = make([]rune, len(orig))
+		for i, r := range orig {
+			low[i] = unicode.ToLower(r)
+		}
+	}
+	// find where the first match ends, then walk back to its latest start
+	p, end := 0, -1
+	for i, r := range low {
+		if r == pat[p] {
+			p++
+			if p == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	start := end
+	for p = len(pat) - 1; start >= 0; start-- {
+		if low[start] == pat[p] {
+			if p--; p < 0 {
+				break
+			}
+		}
+	}
+
+	pos := make([]int, 0, len(pat))
+	score, prev := 0, -2
+	p = 0
+	for i := start; i <= end && p < len(pat); i++ {
+		if low[i] != pat[p] {
+			continue
+		}
+		score += fuzzyMatchScore + fuzzyBonus(orig, i)
+		switch {
+		case prev == i-1:
+			score += fuzzyConsecutive
+		case prev >= 0:
+			score += fuzzyGapStart + fuzzyGapExtension*(i-prev-2)
+		}
+		pos = append(pos, i)
+		prev = i
+		p++
+	}
+	// the same match in the file's name beats one across its directories,
+	// and in a shorter path beats a longer one
+	base := len(orig) - 1
+	for base >= 0 && orig[base] != '/' {
+		base--
+	}
+	if base < start {
+		score += fuzzyBoundary
+	}
+	return score - len(orig)/8, pos, true
+}
+
+// fuzzyBonus is what starting a word earns the character at i: after a
+// separator, or a capital after a lowercase letter.
+func fuzzyBonus(text []rune, i int) int {
+	if i == 0 {
+		return fuzzyBoundary
+	}
+	prev, cur := text[i-1], text[i]
+	switch {
+	case strings.ContainsRune("/_-. ", prev):
+		return fuzzyBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return fuzzyCamel
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev):
+		return fuzzyBoundary / 2
+	}
+	return 0
+}
+
+// highlightMatch renders text in style, with the runes at pos picked out in
+// the search color.
+func highlightMatch(text string, pos []int, style func(...string) string) string {
+	if len(pos) == 0 {
+		return style(text)
+	}
+	hl := searchSty.Bold(true)
+	var b strings.Builder
+	runes := []rune(text)
+	at := 0
+	for _, p := range pos {
+		if p > at {
+			b.WriteString(style(string(runes[at:p])))
+		}
+		b.WriteString(hl.Render(string(runes[p])))
+		at = p + 1
+	}
+	if at < len(runes) {
+		b.WriteString(style(string(runes[at:])))
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ==================== Gitea ====================
+
+// giteaProvider uses the Gitea (and Forgejo) v1 API, authenticated with
+// $GITEA_TOKEN.
+type giteaProvider struct{ forge }
+
+func (g giteaProvider) api(path string) string {
+	return "https://" + g.host + "/api/v1/repos/" + g.repo + path
+}
+
+func (giteaProvider) header() http.Header {
+	return tokenHeader("GITEA_TOKEN", "Authorization", "token ")
+}
+
+type giteaPull struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	User   struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (g giteaProvider) pulls() ([]pullRequest, error) {
+	var pulls []giteaPull
+	if err := restJSON("GET", g.api("/pulls?state=open&limit=50"), g.header(), nil, &pulls); err != nil {
+		return nil, err
+	}
+	prs := make([]pullRequest, len(pulls))
+	for i, p := range pulls {
+		prs[i] = pullRequest{Number: p.Number, Title: p.Title, HeadRefName: p.Head.Ref, BaseRefName: p.Base.Ref}
+		prs[i].Author.Login = p.User.Login
+	}
+	return prs, nil
+}
+
+func (g giteaProvider) listPRs() ([]pullRequest, error) { return g.pulls() }
+
+func (giteaProvider) prDiff(pr pullRequest) (string, error) {
+	return diffPRHead(fmt.Sprintf("refs/pull/%d/head", pr.Number), pr.BaseRefName)
+}
+
+func (giteaProvider) checkoutPR(pr pullRequest) error {
+	return checkoutPRHead(fmt.Sprintf("refs/pull/%d/head", pr.Number), fmt.Sprintf("pr/%d", pr.Number))
+}
+
+func (g giteaProvider) currentPR() (int, error) {
+	branch := currentBranch()
+	if n, ok := checkedOutPR(branch); ok {
+		return n, nil
+	}
+	prs, err := g.pulls()
+	if err != nil {
+		return 0, err
+	}
+	for _, pr := range prs {
+		if pr.HeadRefName == branch {
+			return pr.Number, nil
+		}
+	}
+	return 0, fmt.Errorf("no open pull request for %s", branch)
+}
+
+func (g giteaProvider) fetchPR(n int) (string, string, error) {
+	var pull giteaPull
+	if err := restJSON("GET", g.api(fmt.Sprintf("/pulls/%d", n)), g.header(), nil, &pull); err != nil {
+		return "", "", err
+	}
+	return fetchPRRefs(fmt.Sprintf("refs/pull/%d/head", n), pull.Base.Ref)
+}
+
+// reviewThreads collects the line comments of every review. Gitea doesn't
+// link replies, so each comment stands alone.
+func (g giteaProvider) reviewThreads(pr int) ([]prThread, error) {
+	var reviews []struct {
+		ID int64 `json:"id"`
+	}
+	if err := restJSON("GET", g.api(fmt.Sprintf("/pulls/%d/reviews", pr)), g.header(), nil, &reviews); err != nil {
+		return nil, err
+	}
+	var threads []prThread
+	for _, r := range reviews {
+		var comments []struct {
+			ID        int64     `json:"id"`
+			Path      string    `json:"path"`
+			Body      string    `json:"body"`
+			Position  int       `json:"position"`
+			Original  int       `json:"original_position"`
+			CreatedAt time.Time `json:"created_at"`
+			User      struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		}
+		u := g.api(fmt.Sprintf("/pulls/%d/reviews/%d/comments", pr, r.ID))
+		if err := restJSON("GET", u, g.header(), nil, &comments); err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			pc := prComment{ID: c.ID, Path: c.Path, Body: c.Body, CreatedAt: c.CreatedAt, Side: "RIGHT"}
+			line := c.Position
+			if line == 0 {
+				line, pc.Side = c.Original, "LEFT"
+			}
+			if line == 0 {
+				continue
+			}
+			pc.Line = &line
+			pc.User.Login = c.User.Login
+			threads = append(threads, prThread{pc})
+		}
+	}
+	return threads, nil
+}
+
+var giteaEvents = map[string]string{
+	"COMMENT":         "COMMENT",
+	"APPROVE":         "APPROVED",
+	"REQUEST_CHANGES": "REQUEST_CHANGES",
+}
+
+func (g giteaProvider) submitReview(pr int, r review) error {
+	type apiComment struct {
+		Path        string `json:"path"`
+		Body        string `json:"body"`
+		NewPosition int    `json:"new_position,omitempty"`
+		OldPosition int    `json:"old_position,omitempty"`
+	}
+	in := struct {
+		CommitID string       `json:"commit_id"`
+		Event    string       `json:"event"`
+		Body     string       `json:"body,omitempty"`
+		Comments []apiComment `json:"comments"`
+	}{CommitID: r.commitID, Event: giteaEvents[r.event], Body: r.body, Comments: []apiComment{}}
+	for _, c := range r.comments {
+		ac := apiComment{Path: c.Path, Body: c.Body, NewPosition: c.Line}
+		if c.Side == "LEFT" {
+			ac.NewPosition, ac.OldPosition = 0, c.Line
+		}
+		in.Comments = append(in.Comments, ac)
+	}
+	return restJSON("POST", g.api(fmt.Sprintf("/pulls/%d/reviews", pr)), g.header(), in, nil)
+}
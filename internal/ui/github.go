@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== GitHub ====================
+
+// githubProvider talks to GitHub through the gh CLI, which handles auth and
+// GitHub Enterprise hosts.
+type githubProvider struct{ forge }
+
+func (githubProvider) listPRs() ([]pullRequest, error) {
+	out, err := exec.Command("gh", "pr", "list", "--limit", "100",
+		"--json", "number,title,author,headRefName,baseRefName").Output()
+	if err != nil {
+		return nil, fmt.Errorf("gh pr list: %w", git.StderrError(err))
+	}
+	var prs []pullRequest
+	if err := json.Unmarshal(out, &prs); err != nil {
+		return nil, fmt.Errorf("gh pr list: %w", err)
+	}
+	return prs, nil
+}
+
+func (githubProvider) prDiff(pr pullRequest) (string, error) {
+	out, err := exec.Command("gh", "pr", "diff", fmt.Sprint(pr.Number)).Output()
+	if err != nil {
+		return "", fmt.Errorf("gh pr diff: %w", git.StderrError(err))
+	}
+	return string(out), nil
+}
+
+func (githubProvider) checkoutPR(pr pullRequest) error {
+	c := exec.Command("gh", "pr", "checkout", fmt.Sprint(pr.Number))
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("gh pr checkout: %w", err)
+	}
+	return nil
+}
+
+func (githubProvider) currentPR() (int, error) {
+	out, err := exec.Command("gh", "pr", "view", "--json", "number", "-q", ".number").Output()
+	if err != nil {
+		return 0, fmt.Errorf("gh pr view: %w", git.StderrError(err))
+	}
+	var n int
+	if _, err := fmt.Sscan(strings.TrimSpace(string(out)), &n); err != nil {
+		return 0, fmt.Errorf("gh pr view: unexpected output %q", out)
+	}
+	return n, nil
+}
+
+func (githubProvider) fetchPR(n int) (string, string, error) {
+	out, err := exec.Command("gh", "pr", "view", fmt.Sprint(n), "--json", "baseRefName", "-q", ".baseRefName").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("gh pr view: %w", git.StderrError(err))
+	}
+	return fetchPRRefs(fmt.Sprintf("refs/pull/%d/head", n), strings.TrimSpace(string(out)))
+}
+
+// reviewThreads groups the PR's review comments under the comment each
+// reply answers.
+func (githubProvider) reviewThreads(pr int) ([]prThread, error) {
+	out, err := exec.Command("gh", "api", "--paginate",
+		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/comments", pr)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("gh api: %w", git.StderrError(err))
+	}
+	var comments []prComment
+	// --paginate concatenates one JSON array per page
+	dec := json.NewDecoder(strings.NewReader(string(out)))
+	for dec.More() {
+		var page []prComment
+		if err := dec.Decode(&page); err != nil {
+			return nil, fmt.Errorf("gh api: %w", err)
+		}
+		comments = append(comments, page...)
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+
+	roots := map[int64]int{}
+	var threads []prThread
+	for _, c := range comments {
+		if c.ReplyTo != 0 {
+			if i, ok := roots[c.ReplyTo]; ok {
+				threads[i] = append(threads[i], c)
+			}
+			continue
+		}
+		roots[c.ID] = len(threads)
+		threads = append(threads, prThread{c})
+	}
+	return threads, nil
+}
+
+// submitReview posts the comments as one review through the pulls API.
+func (githubProvider) submitReview(pr int, r review) error {
+	type apiComment struct {
+		Path string `json:"path"`
+		Line int    `json:"line"`
+		Side string `json:"side"`
+		Body string `json:"body"`
+	}
+	req := struct {
+		CommitID string       `json:"commit_id"`
+		Event    string       `json:"event"`
+		Body     string       `json:"body,omitempty"`
+		Comments []apiComment `json:"comments"`
+	}{CommitID: r.commitID, Event: r.event, Body: r.body, Comments: []apiComment{}}
+	for _, c := range r.comments {
+		req.Comments = append(req.Comments, apiComment{Path: c.Path, Line: c.Line, Side: c.Side, Body: c.Body})
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	c := exec.Command("gh", "api", "--method", "POST",
+		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/reviews", pr), "--input", "-")
+	c.Stdin = strings.NewReader(string(data))
+	if out, err := c.Output(); err != nil {
+		return fmt.Errorf("gh api: %w %s", git.StderrError(err), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
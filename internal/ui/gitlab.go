@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ==================== GitLab ====================
+
+// gitlabProvider uses the GitLab v4 API, authenticated with $GITLAB_TOKEN.
+type gitlabProvider struct{ forge }
+
+func (g gitlabProvider) api(path string) string {
+	return "https://" + g.host + "/api/v4/projects/" + url.PathEscape(g.repo) + path
+}
+
+func (gitlabProvider) header() http.Header {
+	return tokenHeader("GITLAB_TOKEN", "PRIVATE-TOKEN", "")
+}
+
+type gitlabMR struct {
+	IID    int    `json:"iid"`
+	Title  string `json:"title"`
+	Source string `json:"source_branch"`
+	Target string `json:"target_branch"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+func (mr gitlabMR) pullRequest() pullRequest {
+	pr := pullRequest{Number: mr.IID, Title: mr.Title, HeadRefName: mr.Source, BaseRefName: mr.Target}
+	pr.Author.Login = mr.Author.Username
+	return pr
+}
+
+func (g gitlabProvider) listPRs() ([]pullRequest, error) {
+	var mrs []gitlabMR
+	if err := restJSON("GET", g.api("/merge_requests?state=opened&per_page=100"), g.header(), nil, &mrs); err != nil {
+		return nil, err
+	}
+	prs := make([]pullRequest, len(mrs))
+	for i, mr := range mrs {
+		prs[i] = mr.pullRequest()
+	}
+	return prs, nil
+}
+
+func (gitlabProvider) prDiff(pr pullRequest) (string, error) {
+	return diffPRHead(fmt.Sprintf("refs/merge-requests/%d/head", pr.Number), pr.BaseRefName)
+}
+
+func (gitlabProvider) checkoutPR(pr pullRequest) error {
+	return checkoutPRHead(fmt.Sprintf("refs/merge-requests/%d/head", pr.Number), fmt.Sprintf("mr/%d", pr.Number))
+}
+
+func (g gitlabProvider) currentPR() (int, error) {
+	branch := currentBranch()
+	if n, ok := checkedOutPR(branch); ok {
+		return n, nil
+	}
+	var mrs []gitlabMR
+	q := "/merge_requests?state=opened&source_branch=" + url.QueryEscape(branch)
+	if err := restJSON("GET", g.api(q), g.header(), nil, &mrs); err != nil {
+		return 0, err
+	}
+	if len(mrs) == 0 {
+		return 0, fmt.Errorf("no open merge request for %s", branch)
+	}
+	return mrs[0].IID, nil
+}
+
+func (g gitlabProvider) fetchPR(n int) (string, string, error) {
+	var mr gitlabMR
+	if err := restJSON("GET", g.api(fmt.Sprintf("/merge_requests/%d", n)), g.header(), nil, &mr); err != nil {
+		return "", "", err
+	}
+	return fetchPRRefs(fmt.Sprintf("refs/merge-requests/%d/head", n), mr.Target)
+}
+
+// reviewThreads turns the MR's diff discussions into threads; notes that
+// aren't on a line are skipped.
+func (g gitlabProvider) reviewThreads(pr int) ([]prThread, error) {
+	var discussions []struct {
+		Notes []struct {
+			ID        int64     `json:"id"`
+			Body      string    `json:"body"`
+			System    bool      `json:"system"`
+			CreatedAt time.Time `json:"created_at"`
+			Author    struct {
+				Username string `json:"username"`
+			} `json:"author"`
+			Position *struct {
+				NewPath string `json:"new_path"`
+				NewLine *int   `json:"new_line"`
+				OldLine *int   `json:"old_line"`
+			} `json:"position"`
+		} `json:"notes"`
+	}
+	u := g.api(fmt.Sprintf("/merge_requests/%d/discussions?per_page=100", pr))
+	if err := restJSON("GET", u, g.header(), nil, &discussions); err != nil {
+		return nil, err
+	}
+	var threads []prThread
+	for _, d := range discussions {
+		if len(d.Notes) == 0 || d.Notes[0].System || d.Notes[0].Position == nil {
+			continue
+		}
+		pos := d.Notes[0].Position
+		var t prThread
+		for _, n := range d.Notes {
+			c := prComment{ID: n.ID, Path: pos.NewPath, Body: n.Body, CreatedAt: n.CreatedAt, Side: "RIGHT", Line: pos.NewLine}
+			if pos.NewLine == nil {
+				c.Side, c.Line = "LEFT", pos.OldLine
+			}
+			c.User.Login = n.Author.Username
+			t = append(t, c)
+		}
+		threads = append(threads, t)
+	}
+	return threads, nil
+}
+
+// submitReview starts one discussion per comment, since GitLab has no batched
+// review endpoint, then adds the body as a note and approves if asked.
+func (g gitlabProvider) submitReview(pr int, r review) error {
+	var versions []struct {
+		Base  string `json:"base_commit_sha"`
+		Head  string `json:"head_commit_sha"`
+		Start string `json:"start_commit_sha"`
+	}
+	if err := restJSON("GET", g.api(fmt.Sprintf("/merge_requests/%d/versions", pr)), g.header(), nil, &versions); err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("merge request !%d has no diff versions", pr)
+	}
+	v := versions[0]
+	for _, c := range r.comments {
+		pos := map[string]any{
+			"position_type": "text",
+			"base_sha":      v.Base,
+			"head_sha":      v.Head,
+			"start_sha":     v.Start,
+			"new_path":      c.Path,
+			"old_path":      c.Path,
+		}
+		if c.Side == "LEFT" {
+			pos["old_line"] = c.Line
+		} else {
+			pos["new_line"] = c.Line
+		}
+		in := map[string]any{"body": c.Body, "position": pos}
+		if err := restJSON("POST", g.api(fmt.Sprintf("/merge_requests/%d/discussions", pr)), g.header(), in, nil); err != nil {
+			return err
+		}
+	}
+	if r.body != "" {
+		in := map[string]string{"body": r.body}
+		if err := restJSON("POST", g.api(fmt.Sprintf("/merge_requests/%d/notes", pr)), g.header(), in, nil); err != nil {
+			return err
+		}
+	}
+	if r.event == "APPROVE" {
+		return restJSON("POST", g.api(fmt.Sprintf("/merge_requests/%d/approve", pr)), g.header(), map[string]string{}, nil)
+	}
+	return nil
+}
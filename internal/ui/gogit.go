@@ -0,0 +1,639 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/arnavsurve/gd/internal/git"
+	billy "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	gitconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// ==================== go-git Backend ====================
+
+// With --git-backend go-git, or with no git binary to run, status, the
+// worktree's diffs, and refs are read in process with go-git. It's only used
+// where it answers as git would: a repository whose config converts no line
+// endings or modes and whose files have no attributes. Renames, conflicts,
+// and magic pathspecs still fall back to running git.
+
+// flagGitBackend is "git", the default, or "go-git" to read in process. Set
+// by --git-backend or git_backend in the config file.
+var flagGitBackend = "git"
+
+// gitBackends are the backends --git-backend can name.
+var gitBackends = []string{"git", "go-git"}
+
+// goGitRepo is the repository gd runs in, opened with go-git, or nil when
+// go-git isn't to be used for it.
+var goGitRepo = sync.OnceValue(func() *gogit.Repository {
+	if flagGitBackend == "git" {
+		if _, err := exec.LookPath("git"); err == nil {
+			return nil
+		}
+	}
+	return goGitOpen(".")
+})
+
+// goGitOpen opens the repository at dir with go-git, or returns nil if git
+// would read it differently.
+func goGitOpen(dir string) *gogit.Repository {
+	r, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		debugf("go-git: %v", err)
+		return nil
+	}
+	core, ok := goGitCoreConfig(r, dir)
+	if !ok {
+		debugf("go-git: config includes other files")
+		return nil
+	}
+	for key, unsafe := range map[string]func(string) bool{
+		// line endings converted on the way in or out
+		"autocrlf": func(v string) bool { return !gitFalse(v) },
+		"eol":      func(v string) bool { return v != "" },
+		// mode changes git is told to ignore
+		"filemode": func(v string) bool { return v != "" && gitFalse(v) },
+		"symlinks": func(v string) bool { return v != "" && gitFalse(v) },
+		// attributes from outside the worktree
+		"attributesfile": func(v string) bool { return v != "" },
+	} {
+		if v := core[key]; v != "" && unsafe(v) {
+			debugf("go-git: core.%s = %s", key, v)
+			return nil
+		}
+	}
+	attrs := []string{"/etc/gitattributes"}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		attrs = append(attrs, filepath.Join(xdg, "git", "attributes"))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		attrs = append(attrs, filepath.Join(home, ".config", "git", "attributes"))
+	}
+	if fs, ok := r.Storer.(*filesystem.Storage); ok {
+		attrs = append(attrs, filepath.Join(fs.Filesystem().Root(), "info", "attributes"))
+	}
+	for _, p := range attrs {
+		if _, err := os.Stat(p); err == nil {
+			debugf("go-git: attributes in %s", p)
+			return nil
+		}
+	}
+	// filters, eol conversion, and submodules are left to git
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil
+	}
+	for _, e := range idx.Entries {
+		if base := path.Base(e.Name); base == ".gitattributes" || base == ".gitmodules" {
+			debugf("go-git: %s is tracked", e.Name)
+			return nil
+		}
+	}
+	return r
+}
+
+// goGitCoreKeys are the core settings goGitOpen looks at.
+var goGitCoreKeys = []string{"autocrlf", "eol", "filemode", "symlinks", "attributesfile"}
+
+// goGitCoreConfig reads the core settings goGitOpen looks at for the
+// repository r at dir, as every level of config resolves them. git answers
+// that when it's there; otherwise the files are read here, and false returned
+// for one that includes others.
+func goGitCoreConfig(r *gogit.Repository, dir string) (map[string]string, bool) {
+	core := map[string]string{}
+	if _, err := exec.LookPath("git"); err == nil {
+		c := exec.Command("git", "config", "--get-regexp", `^core\.(`+strings.Join(goGitCoreKeys, "|")+`)$`)
+		c.Dir = dir
+		// exits 1 when none is set
+		out, _ := c.Output()
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if key, v, ok := strings.Cut(line, " "); ok {
+				core[strings.TrimPrefix(key, "core.")] = v
+			}
+		}
+		return core, true
+	}
+	var files []string
+	if os.Getenv("GIT_CONFIG_NOSYSTEM") == "" {
+		files = append(files, cmp.Or(os.Getenv("GIT_CONFIG_SYSTEM"), "/etc/gitconfig"))
+	}
+	if p := os.Getenv("GIT_CONFIG_GLOBAL"); p != "" {
+		files = append(files, p)
+	} else {
+		home, _ := os.UserHomeDir()
+		xdg := cmp.Or(os.Getenv("XDG_CONFIG_HOME"), filepath.Join(home, ".config"))
+		files = append(files, filepath.Join(xdg, "git", "config"), filepath.Join(home, ".gitconfig"))
+	}
+	if fs, ok := r.Storer.(*filesystem.Storage); ok {
+		files = append(files, filepath.Join(fs.Filesystem().Root(), "config"))
+	}
+	// later files override earlier ones, as in git
+	for _, p := range files {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		cfg := gitconfig.New()
+		err = gitconfig.NewDecoder(f).Decode(cfg)
+		f.Close()
+		if err != nil {
+			return nil, false
+		}
+		for _, sec := range cfg.Sections {
+			switch {
+			case sec.IsName("include") || sec.IsName("includeIf"):
+				return nil, false
+			case sec.IsName("core"):
+				for _, o := range sec.Options {
+					core[strings.ToLower(o.Key)] = o.Value
+				}
+			}
+		}
+	}
+	return core, true
+}
+
+// gitFalse reports whether v is one of git's spellings of false.
+func gitFalse(v string) bool {
+	switch strings.ToLower(v) {
+	case "false", "no", "off", "0":
+		return true
+	}
+	return false
+}
+
+// goGitAttributed reports whether a .gitattributes file, tracked or not,
+// sits in p's directory or any above it up to the root.
+func goGitAttributed(p string) bool {
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		attributed, ok := attributedDirs.Load(dir)
+		if !ok {
+			_, err := os.Lstat(filepath.Join(filepath.FromSlash(dir), ".gitattributes"))
+			attributed, _ = attributedDirs.LoadOrStore(dir, err == nil)
+		}
+		if attributed.(bool) {
+			return true
+		}
+		if dir == "." {
+			return false
+		}
+	}
+}
+
+// attributedDirs caches goGitAttributed's look in each directory.
+var attributedDirs sync.Map
+
+// goGitRoot finds the worktree above dir with go-git, for when there's no
+// git binary to ask.
+func goGitRoot(dir string) (string, bool) {
+	r, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return "", false
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return "", false
+	}
+	return wt.Filesystem.Root(), true
+}
+
+// goGitBranch is currentBranch read with go-git.
+func goGitBranch() (string, bool) {
+	r := goGitRepo()
+	if r == nil {
+		return "", false
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", false
+	}
+	if head.Name().IsBranch() {
+		return head.Name().Short(), true
+	}
+	return head.Hash().String(), true
+}
+
+// goGitPathspecs matches paths against the pathspecs when they're all plain
+// paths, which is all go-git is given to match.
+func goGitPathspecs() (func(string) bool, bool) {
+	for _, s := range pathspecs {
+		if strings.HasPrefix(s, ":") || strings.ContainsAny(s, "*?[") {
+			return nil, false
+		}
+	}
+	return func(p string) bool {
+		if len(pathspecs) == 0 {
+			return true
+		}
+		for _, s := range pathspecs {
+			s = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(s)), "/")
+			if s == "." || p == s || strings.HasPrefix(p, s+"/") {
+				return true
+			}
+		}
+		return false
+	}, true
+}
+
+// goGitFiles is loadFiles for the worktree, with status and stats from
+// go-git, or false to run git instead.
+func goGitFiles() ([]git.FileStatus, bool) {
+	r := goGitRepo()
+	match, ok := goGitPathspecs()
+	if r == nil || !ok {
+		return nil, false
+	}
+	defer trace("go-git", "status")()
+	wt, err := r.Worktree()
+	if err != nil {
+		return nil, false
+	}
+	// go-git reads .gitignore and info/exclude; git also reads these
+	if ps, err := gitignore.LoadGlobalPatterns(osfs.New("/")); err == nil {
+		wt.Excludes = append(wt.Excludes, ps...)
+	}
+	if ps, err := gitignore.LoadSystemPatterns(osfs.New("/")); err == nil {
+		wt.Excludes = append(wt.Excludes, ps...)
+	}
+	if fs, ok := r.Storer.(*filesystem.Storage); ok {
+		wt.Excludes = append(wt.Excludes, infoExclude(fs.Filesystem())...)
+	}
+	st, err := wt.Status()
+	if err != nil {
+		debugf("go-git status: %v", err)
+		return nil, false
+	}
+	paths := make([]string, 0, len(st))
+	added, deleted := false, false
+	for p, s := range st {
+		if s.Staging == gogit.Unmodified && s.Worktree == gogit.Unmodified || !match(p) {
+			continue
+		}
+		switch {
+		case goGitAttributed(p):
+			return nil, false
+		case s.Staging == gogit.UpdatedButUnmerged || s.Worktree == gogit.UpdatedButUnmerged:
+			return nil, false
+		case s.Staging == gogit.Added:
+			added = true
+		case s.Staging == gogit.Deleted:
+			deleted = true
+		}
+		paths = append(paths, p)
+	}
+	if added && deleted {
+		// maybe a rename, which git pairs up and go-git doesn't
+		return nil, false
+	}
+	sort.Strings(paths)
+	var porcelain strings.Builder
+	for _, p := range paths {
+		s := st[p]
+		porcelain.WriteString(string([]byte{byte(s.Staging), byte(s.Worktree), ' '}) + p + "\x00")
+	}
+	files, err := git.ParseStatus(repoRoot(), porcelain.String())
+	if err != nil {
+		return nil, false
+	}
+	files = filterKind(files)
+	for i := range files {
+		if files[i].Untracked {
+			files[i].Stat = untrackedStat(files[i].Path)
+			continue
+		}
+		from, to := goGitKindSides(showKind.Load())
+		if d, ok := goGitDiff(files[i].Path, from, to, 0); ok {
+			files[i].Stat = countStat(d)
+		}
+	}
+	return files, true
+}
+
+// infoExclude reads the repository's info/exclude, which go-git doesn't.
+func infoExclude(gitDir billy.Filesystem) []gitignore.Pattern {
+	f, err := gitDir.Open(gitDir.Join("info", "exclude"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var ps []gitignore.Pattern
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimRight(sc.Text(), " \t\r"); line != "" && !strings.HasPrefix(line, "#") {
+			ps = append(ps, gitignore.ParsePattern(line, nil))
+		}
+	}
+	return ps
+}
+
+// goGitKindSides are the sides worktreeStats compares for a kind of change
+// shown: HEAD to worktree for all of them.
+func goGitKindSides(kind int32) (goGitSide, goGitSide) {
+	switch kind {
+	case kindStaged:
+		return sideHEAD, sideIndex
+	case kindUnstaged:
+		return sideIndex, sideWorktree
+	}
+	return sideHEAD, sideWorktree
+}
+
+// countStat counts a unified diff's changed lines, as numstat would.
+func countStat(d string) git.FileStat {
+	added, deleted, binary := diffStat(d)
+	return git.FileStat{Added: added, Deleted: deleted, Binary: binary}
+}
+
+// goGitFileDiff is getDiffOutput for a file changed in the worktree, read
+// with go-git, or false to run git instead.
+func goGitFileDiff(f git.FileStatus, fullFile bool) (string, bool) {
+	if goGitRepo() == nil || flagMain || ignoreSpace.Load() || f.OrigPath != "" || f.Conflicted || f.Untracked {
+		return "", false
+	}
+	defer trace("go-git", f.Path)()
+	context := 3
+	if n := shownContext(); n >= 0 {
+		context = n
+	}
+	if fullFile {
+		context = 99999
+	}
+	var b strings.Builder
+	for _, side := range []struct {
+		changed  bool
+		from, to goGitSide
+	}{{f.Unstaged, sideIndex, sideWorktree}, {f.Staged, sideHEAD, sideIndex}} {
+		if !side.changed {
+			continue
+		}
+		d, ok := goGitDiff(f.Path, side.from, side.to, context)
+		if !ok {
+			return "", false
+		}
+		b.WriteString(d)
+	}
+	return b.String(), true
+}
+
+// goGitPatchOf is kindPatch's one diff, read with go-git, or false to run
+// git instead. binary contents need git's --binary.
+func goGitPatchOf(f git.FileStatus, binary bool, kind int32) (string, bool) {
+	if goGitRepo() == nil || flagMain || f.OrigPath != "" || f.Conflicted {
+		return "", false
+	}
+	from, to := goGitKindSides(kind)
+	if f.Untracked {
+		from, to = sideNone, sideWorktree
+	}
+	d, ok := goGitDiff(f.Path, from, to, 3)
+	if !ok || binary && countStat(d).Binary {
+		return "", false
+	}
+	return d, true
+}
+
+// goGitSide is where goGitDiff reads a version of a file from.
+type goGitSide int
+
+const (
+	sideNone goGitSide = iota // for untracked files
+	sideHEAD
+	sideIndex
+	sideWorktree
+)
+
+// goGitDiff is path's diff from one side to another with context lines of
+// context, in git's format, or false if go-git couldn't read it.
+func goGitDiff(path string, from, to goGitSide, context int) (string, bool) {
+	if goGitAttributed(path) {
+		return "", false
+	}
+	a, err := goGitVersion(path, from)
+	if err != nil {
+		debugf("go-git %s: %v", path, err)
+		return "", false
+	}
+	b, err := goGitVersion(path, to)
+	if err != nil {
+		debugf("go-git %s: %v", path, err)
+		return "", false
+	}
+	if a == nil && b == nil || a != nil && b != nil && a.hash == b.hash && a.mode == b.mode {
+		return "", true
+	}
+	var out bytes.Buffer
+	p := goGitPatch{from: a, to: b}
+	if err := fdiff.NewUnifiedEncoder(&out, context).Encode(p); err != nil {
+		return "", false
+	}
+	if a == nil || p.IsBinary() {
+		return out.String(), true
+	}
+	return withFuncNames(out.String(), a.content), true
+}
+
+// withFuncNames gives d's hunk headers the function names git would: the
+// last line above each hunk in old that starts with a letter, _ or $, cut
+// to 80 bytes. go-git's encoder names whatever line comes just before.
+func withFuncNames(d, old string) string {
+	oldLines := strings.Split(old, "\n")
+	lines := strings.SplitAfter(d, "\n")
+	for i, l := range lines {
+		var from int
+		if _, err := fmt.Sscanf(l, "@@ -%d", &from); err != nil || !strings.HasPrefix(l, "@@ ") {
+			continue
+		}
+		end := strings.Index(l[3:], " @@")
+		if end < 0 {
+			continue
+		}
+		header := l[:3+end+3]
+		for j := min(from-2, len(oldLines)-1); j >= 0; j-- {
+			if name := oldLines[j]; name != "" && (unicode.IsLetter(rune(name[0])) || name[0] == '_' || name[0] == '$') {
+				header += " " + strings.TrimRight(name[:min(len(name), 80)], " \t\r")
+				break
+			}
+		}
+		lines[i] = header + "\n"
+	}
+	return strings.Join(lines, "")
+}
+
+// goGitVersion reads path from side, or nil where it doesn't exist.
+func goGitVersion(path string, side goGitSide) (*goGitFile, error) {
+	r := goGitRepo()
+	switch side {
+	case sideNone:
+		return nil, nil
+	case sideHEAD:
+		head, err := r.Head()
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// no commits yet
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c, err := r.CommitObject(head.Hash())
+		if err != nil {
+			return nil, err
+		}
+		f, err := c.File(path)
+		if errors.Is(err, object.ErrFileNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+		return &goGitFile{path: path, hash: f.Hash, mode: f.Mode, content: content}, nil
+	case sideIndex:
+		idx, err := r.Storer.Index()
+		if err != nil {
+			return nil, err
+		}
+		e, err := idx.Entry(path)
+		if errors.Is(err, index.ErrEntryNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		blob, err := r.BlobObject(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		rd, err := blob.Reader()
+		if err != nil {
+			return nil, err
+		}
+		defer rd.Close()
+		content, err := io.ReadAll(rd)
+		if err != nil {
+			return nil, err
+		}
+		return &goGitFile{path: path, hash: e.Hash, mode: e.Mode, content: string(content)}, nil
+	}
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(filepath.ToSlash(target))
+	} else if data, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil {
+		return nil, err
+	}
+	hash := plumbing.ComputeHash(plumbing.BlobObject, data)
+	return &goGitFile{path: path, hash: hash, mode: mode, content: string(data)}, nil
+}
+
+// goGitFile is one version of a file, as go-git's patch encoder takes it.
+type goGitFile struct {
+	path    string
+	hash    plumbing.Hash
+	mode    filemode.FileMode
+	content string
+}
+
+func (f *goGitFile) Hash() plumbing.Hash     { return f.hash }
+func (f *goGitFile) Mode() filemode.FileMode { return f.mode }
+func (f *goGitFile) Path() string            { return f.path }
+
+// binary is git's test: a NUL in the first 8000 bytes.
+func (f *goGitFile) binary() bool {
+	return f != nil && strings.IndexByte(f.content[:min(len(f.content), 8000)], 0) >= 0
+}
+
+// goGitPatch is the change from one version of a file to another, as a patch
+// of one file for go-git's encoder.
+type goGitPatch struct {
+	from, to *goGitFile
+}
+
+func (p goGitPatch) FilePatches() []fdiff.FilePatch { return []fdiff.FilePatch{p} }
+func (p goGitPatch) Message() string                { return "" }
+func (p goGitPatch) IsBinary() bool                 { return p.from.binary() || p.to.binary() }
+
+// Files leaves a missing side a nil interface, which is how the encoder
+// tells a new or deleted file.
+func (p goGitPatch) Files() (fdiff.File, fdiff.File) {
+	var from, to fdiff.File
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+func (p goGitPatch) Chunks() []fdiff.Chunk {
+	if p.IsBinary() {
+		return nil
+	}
+	var a, b string
+	if p.from != nil {
+		a = p.from.content
+	}
+	if p.to != nil {
+		b = p.to.content
+	}
+	var chunks []fdiff.Chunk
+	for _, d := range diff.Do(a, b) {
+		op := fdiff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		}
+		chunks = append(chunks, goGitChunk{d.Text, op})
+	}
+	return chunks
+}
+
+type goGitChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c goGitChunk) Content() string       { return c.content }
+func (c goGitChunk) Type() fdiff.Operation { return c.op }
@@ -0,0 +1,263 @@
+package ui
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+)
+
+// goGitFixture makes a repository with one commit of files, then writes
+// changed over them and runs the git commands in after. A nil content
+// deletes the file. git's config is kept to the repository's own.
+func goGitFixture(t *testing.T, files, changed map[string]*string, after ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if _, err := os.Stat("/etc/gitattributes"); err == nil {
+		t.Skip("/etc/gitattributes rules go-git out")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	dir := t.TempDir()
+	write := func(fs map[string]*string) {
+		for name, content := range fs {
+			p := filepath.Join(dir, name)
+			if content == nil {
+				os.Remove(p)
+				continue
+			}
+			os.MkdirAll(filepath.Dir(p), 0o755)
+			if err := os.WriteFile(p, []byte(*content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(files)
+	if err := gitIn(dir, "init -q -b main", "config user.email gd@example.com", "config user.name gd", "add -A", "commit -qm init"); err != nil {
+		t.Fatal(err)
+	}
+	write(changed)
+	if err := gitIn(dir, after...); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func str(s string) *string { return &s }
+
+// useGoGit has the go-git backend read r, or run git for a nil r, until the
+// test ends.
+func useGoGit(t *testing.T, r *gogit.Repository) {
+	saved := goGitRepo
+	goGitRepo = func() *gogit.Repository { return r }
+	t.Cleanup(func() { goGitRepo = saved })
+}
+
+// indexLine is a diff's index line, whose ids git abbreviates as much as the
+// repository allows and go-git doesn't.
+var indexLine = regexp.MustCompile(`(?m)^index [0-9a-f]+\.\.[0-9a-f]+`)
+
+func sameDiff(git, goGit string) bool {
+	abbrev := func(s string) string {
+		return indexLine.ReplaceAllStringFunc(s, func(l string) string {
+			a, b, _ := strings.Cut(strings.TrimPrefix(l, "index "), "..")
+			return "index " + a[:7] + ".." + b[:7]
+		})
+	}
+	return abbrev(git) == abbrev(goGit)
+}
+
+// TestGoGitMatchesGit reads one worktree's status and diffs both ways, for
+// each kind of change, and wants the same from go-git as from git.
+func TestGoGitMatchesGit(t *testing.T) {
+	var ten strings.Builder
+	for i := 1; i <= 10; i++ {
+		ten.WriteString(strings.Repeat("line ", i) + "\n")
+	}
+	dir := goGitFixture(t,
+		map[string]*string{
+			"a.txt":     str(ten.String()),
+			"b.txt":     str("one\ntwo\n"),
+			"gone.txt":  str("bye\n"),
+			"run.sh":    str("echo hi\n"),
+			"noeol.txt": str("x\ny"),
+			"bin.dat":   str("a\x00b"),
+			"sub/c.go":  str("package sub\n\nfunc C() int {\n\treturn 1\n}\n"),
+		},
+		map[string]*string{
+			"b.txt":       str("one\ntwo\nthree\n"),
+			"sub/new.txt": str("new\n"),
+			"u.txt":       str("untracked\n"),
+			"ignored.txt": str("ignored\n"),
+			"gone.txt":    nil,
+		},
+		"add b.txt sub/new.txt",
+	)
+	for name, content := range map[string]string{
+		"a.txt":             strings.Replace(ten.String(), "line line line line line \n", "five\n", 1),
+		"b.txt":             "zero\none\ntwo\nthree\n",
+		"noeol.txt":         "x\ny\nz",
+		"bin.dat":           "a\x00c",
+		"sub/c.go":          "package sub\n\nfunc C() int {\n\treturn 2\n}\n",
+		".git/info/exclude": "ignored.txt\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "run.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(dir)
+	repoRootOnce = sync.Once{}
+	attributedDirs = sync.Map{}
+	r := goGitOpen(".")
+	if r == nil {
+		t.Fatal("goGitOpen refused a plain repository")
+	}
+	defer func() {
+		showKind.Store(kindAll)
+		repoRootOnce = sync.Once{}
+		clearPreviews()
+	}()
+	for _, kind := range []int32{kindAll, kindStaged, kindUnstaged} {
+		showKind.Store(kind)
+		clearPreviews()
+		useGoGit(t, nil)
+		want, err := loadFiles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		useGoGit(t, r)
+		got, ok := goGitFiles()
+		if !ok {
+			t.Fatalf("kind %d: goGitFiles fell back to git", kind)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("kind %d: goGitFiles =\n%+v\nwant\n%+v", kind, got, want)
+			continue
+		}
+		for _, f := range want {
+			if f.Untracked {
+				continue
+			}
+			useGoGit(t, nil)
+			wantDiff, err := getDiffOutput(f, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantPatch, err := getPatch(f, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			useGoGit(t, r)
+			gotDiff, ok := goGitFileDiff(f, false)
+			if !ok {
+				t.Errorf("kind %d: goGitFileDiff(%s) fell back to git", kind, f.Path)
+			} else if !sameDiff(wantDiff, gotDiff) {
+				t.Errorf("kind %d: goGitFileDiff(%s) =\n%s\nwant\n%s", kind, f.Path, gotDiff, wantDiff)
+			}
+			if gotPatch, ok := goGitPatchOf(f, false, kind); ok && !sameDiff(wantPatch, gotPatch) {
+				t.Errorf("kind %d: goGitPatchOf(%s) =\n%s\nwant\n%s", kind, f.Path, gotPatch, wantPatch)
+			}
+		}
+	}
+}
+
+// TestGoGitOpen leaves to git the repositories go-git would read differently.
+func TestGoGitOpen(t *testing.T) {
+	files := map[string]*string{"a.txt": str("a\n"), "sub/b.txt": str("b\n")}
+	for _, tc := range []struct {
+		name  string
+		setup func(t *testing.T, dir, home string)
+		want  bool
+	}{
+		{"plain", nil, true},
+		{"autocrlf in the global config", func(t *testing.T, dir, home string) {
+			writeFile(t, filepath.Join(home, ".gitconfig"), "[core]\n\tautocrlf = input\n")
+		}, false},
+		{"autocrlf off", func(t *testing.T, dir, home string) {
+			writeFile(t, filepath.Join(home, ".gitconfig"), "[core]\n\tautocrlf = false\n")
+		}, true},
+		{"eol in an included config", func(t *testing.T, dir, home string) {
+			writeFile(t, filepath.Join(home, "more"), "[core]\n\teol = crlf\n")
+			writeFile(t, filepath.Join(home, ".gitconfig"), "[include]\n\tpath = "+filepath.Join(home, "more")+"\n")
+		}, false},
+		{"file modes ignored", func(t *testing.T, dir, home string) {
+			gitIn(dir, "config core.filemode false")
+		}, false},
+		{"attributes file", func(t *testing.T, dir, home string) {
+			writeFile(t, filepath.Join(home, "attrs"), "*.txt text\n")
+			writeFile(t, filepath.Join(home, ".gitconfig"), "[core]\n\tattributesFile = "+filepath.Join(home, "attrs")+"\n")
+		}, false},
+		{"global attributes", func(t *testing.T, dir, home string) {
+			writeFile(t, filepath.Join(home, ".config", "git", "attributes"), "*.txt text\n")
+		}, false},
+		{"info attributes", func(t *testing.T, dir, home string) {
+			writeFile(t, filepath.Join(dir, ".git", "info", "attributes"), "*.txt text\n")
+		}, false},
+		{"nested .gitattributes", func(t *testing.T, dir, home string) {
+			writeFile(t, filepath.Join(dir, "sub", ".gitattributes"), "*.txt text\n")
+			gitIn(dir, "add sub/.gitattributes", "commit -qm attrs")
+		}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := goGitFixture(t, files, nil)
+			if tc.setup != nil {
+				tc.setup(t, dir, os.Getenv("HOME"))
+			}
+			if got := goGitOpen(dir) != nil; got != tc.want {
+				t.Errorf("goGitOpen = %t; want %t", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("without git", func(t *testing.T) {
+		dir := goGitFixture(t, files, nil)
+		if goGitOpen(dir) == nil {
+			t.Fatal("goGitOpen refused a plain repository")
+		}
+		home := os.Getenv("HOME")
+		writeFile(t, filepath.Join(home, ".gitconfig"), "[include]\n\tpath = more\n")
+		t.Setenv("PATH", t.TempDir())
+		if goGitOpen(dir) != nil {
+			t.Error("goGitOpen read a config that includes another without git")
+		}
+		writeFile(t, filepath.Join(home, ".gitconfig"), "[core]\n\tautocrlf = true\n")
+		if goGitOpen(dir) != nil {
+			t.Error("goGitOpen missed autocrlf without git")
+		}
+	})
+
+	t.Run("untracked .gitattributes", func(t *testing.T) {
+		dir := goGitFixture(t, files, nil)
+		writeFile(t, filepath.Join(dir, "sub", ".gitattributes"), "*.txt text\n")
+		t.Chdir(dir)
+		attributedDirs = sync.Map{}
+		defer func() { attributedDirs = sync.Map{} }()
+		if !goGitAttributed("sub/b.txt") {
+			t.Error("goGitAttributed missed sub/.gitattributes")
+		}
+		if goGitAttributed("a.txt") {
+			t.Error("goGitAttributed took sub/.gitattributes for the root's")
+		}
+	})
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(name), 0o755)
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,346 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// ==================== Terminal Graphics ====================
+
+// Graphics protocols for drawing images, set with --images.
+const (
+	graphicsNone  = "none"
+	graphicsKitty = "kitty"
+	graphicsITerm = "iterm"
+	graphicsSixel = "sixel"
+)
+
+var (
+	graphicsOnce sync.Once
+	graphicsName string
+)
+
+// graphics is the protocol the terminal draws images with: --images, or
+// guessed from the environment. Only kitty's images are drawn in the
+// preview, as they're anchored to text that survives partial redraws; the
+// others are drawn over the whole screen with enter.
+func graphics() string {
+	graphicsOnce.Do(func() {
+		graphicsName = flagImages
+		if graphicsName != "auto" {
+			return
+		}
+		graphicsName = graphicsNone
+		termName, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+		switch {
+		case noColor || flagAccessible || !term.IsTerminal(os.Stdout.Fd()) || os.Getenv("TMUX") != "":
+			// tmux passes images through only when configured to
+		case termName == "xterm-kitty" || os.Getenv("KITTY_WINDOW_ID") != "" || program == "ghostty":
+			graphicsName = graphicsKitty
+		case program == "iTerm.app" || program == "WezTerm" || program == "mintty":
+			graphicsName = graphicsITerm
+		case strings.HasPrefix(termName, "foot") || strings.Contains(termName, "mlterm"):
+			graphicsName = graphicsSixel
+		}
+	})
+	return graphicsName
+}
+
+// Terminal cells are taken to be about twice as tall as they are wide, and
+// this many pixels across, to size images in cells.
+const (
+	cellPixelsW = 10
+	cellPixelsH = 20
+)
+
+// fitCells sizes an image of cfg's dimensions to at most cols by rows cells,
+// keeping its shape.
+func fitCells(cfg image.Config, cols, rows int) (int, int) {
+	w, h := max(cfg.Width, 1), max(cfg.Height, 1)
+	c := min(cols, (w+cellPixelsW-1)/cellPixelsW)
+	r := (c*h*cellPixelsW/w + cellPixelsH - 1) / cellPixelsH
+	if r > rows {
+		r = rows
+		c = r * cellPixelsH * w / (h * cellPixelsW)
+	}
+	return max(c, 1), max(r, 1)
+}
+
+// pngOf returns side's image as PNG, which kitty reads, shrunk to at most
+// maxW pixels across.
+func pngOf(side binarySide, maxW int) ([]byte, error) {
+	if side.format == "png" && side.config.Width <= maxW {
+		return side.data, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(side.data))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, shrink(img, maxW, maxW*4)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// shrink scales img down to fit maxW by maxH pixels, picking the nearest
+// pixel, which is plenty for a preview.
+func shrink(img image.Image, maxW, maxH int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxW && h <= maxH {
+		return img
+	}
+	scale := min(float64(maxW)/float64(w), float64(maxH)/float64(h))
+	nw, nh := max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1)
+	out := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := range nh {
+		for x := range nw {
+			out.Set(x, y, img.At(b.Min.X+x*w/nw, b.Min.Y+y*h/nh))
+		}
+	}
+	return out
+}
+
+// ==================== Kitty ====================
+
+// kittyDiacritics number the rows of kitty's image placeholders, from the
+// start of its table of combining marks.
+var kittyDiacritics = []rune{
+	0x0305, 0x030D, 0x030E, 0x0310, 0x0312, 0x033D, 0x033E, 0x033F,
+	0x0346, 0x034A, 0x034B, 0x034C, 0x0350, 0x0351, 0x0352, 0x0357,
+	0x035B, 0x0363, 0x0364, 0x0365, 0x0366, 0x0367, 0x0368, 0x0369,
+}
+
+// kittyPlaceholder is the character whose cells kitty fills with an image.
+const kittyPlaceholder = '\U0010EEEE'
+
+// maxKittyRows is the tallest image drawn in the preview.
+const maxKittyRows = 16
+
+// kittyImageID picks an image's id from its content, so the same image keeps
+// its id across renders.
+func kittyImageID(data []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(data)
+	return max(h.Sum32()&0xFFFFFF, 1)
+}
+
+// kittySend sends PNG data to kitty with the control keys given, in chunks
+// as it asks for.
+func kittySend(b *strings.Builder, keys string, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	const chunk = 4096
+	for i := 0; i < len(enc); i += chunk {
+		more := 0
+		if i+chunk < len(enc) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(b, "\x1b_Ga=T,f=100,q=2,%s,m=%d;", keys, more)
+		} else {
+			fmt.Fprintf(b, "\x1b_Gm=%d;", more)
+		}
+		b.WriteString(enc[i:min(i+chunk, len(enc))])
+		b.WriteString("\x1b\\")
+	}
+}
+
+// kittyRow writes row of an image's placeholders, cols wide. The first cell
+// carries its row and column; the rest continue from it.
+func kittyRow(b *strings.Builder, id uint32, row, cols int) {
+	fmt.Fprintf(b, "\x1b[38;2;%d;%d;%dm", id>>16&0xFF, id>>8&0xFF, id&0xFF)
+	b.WriteRune(kittyPlaceholder)
+	b.WriteRune(kittyDiacritics[row])
+	b.WriteRune(kittyDiacritics[0])
+	b.WriteString(strings.Repeat(string(kittyPlaceholder), cols-1))
+	b.WriteString("\x1b[39m")
+}
+
+// kittyPreview draws sides' images next to each other in width columns, as
+// preview lines of kitty placeholders.
+func kittyPreview(sides []binarySide, width int) []string {
+	const gap = 2
+	boxW := (width - gap*(len(sides)-1)) / len(sides)
+	rows := min(maxKittyRows, len(kittyDiacritics))
+	type placed struct {
+		id         uint32
+		data       []byte
+		cols, rows int
+	}
+	var imgs []placed
+	height := 0
+	for _, s := range sides {
+		cols, r := fitCells(s.config, boxW, rows)
+		data, err := pngOf(s, cols*cellPixelsW*2)
+		if err != nil {
+			debugf("image preview: %v", err)
+			return nil
+		}
+		imgs = append(imgs, placed{kittyImageID(data), data, cols, r})
+		height = max(height, r)
+	}
+	lines := make([]string, height)
+	for row := range height {
+		var b strings.Builder
+		b.WriteString("  ")
+		for i, img := range imgs {
+			if i > 0 {
+				b.WriteString(strings.Repeat(" ", gap))
+			}
+			if row == 0 {
+				// placed virtually, wherever its placeholders are
+				kittySend(&b, fmt.Sprintf("U=1,i=%d,c=%d,r=%d", img.id, img.cols, img.rows), img.data)
+			}
+			if row < img.rows {
+				kittyRow(&b, img.id, row, img.cols)
+			} else {
+				b.WriteString(strings.Repeat(" ", img.cols))
+			}
+			b.WriteString(strings.Repeat(" ", boxW-img.cols))
+		}
+		lines[row] = b.String()
+	}
+	return lines
+}
+
+// ==================== Full-Screen Images ====================
+
+// imageMsg asks for a binary file's images to be drawn over the whole
+// screen.
+type imageMsg struct {
+	labels []string
+	sides  []binarySide
+}
+
+// imageView draws images with the terminal's graphics protocol while the
+// browser is suspended, and waits for a key.
+type imageView struct {
+	msg    imageMsg
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (v *imageView) SetStdin(r io.Reader)  { v.stdin = r }
+func (v *imageView) SetStdout(w io.Writer) { v.stdout = w }
+func (v *imageView) SetStderr(io.Writer)   {}
+
+func (v *imageView) Run() error {
+	cols, rows := 80, 24
+	if f, ok := v.stdout.(*os.File); ok {
+		if w, h, err := term.GetSize(f.Fd()); err == nil {
+			cols, rows = w, h
+		}
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[2J")
+	// each image gets a label row and an equal share of what's left
+	boxH := max((rows-1)/len(v.msg.sides)-1, 1)
+	for i, s := range v.msg.sides {
+		top := 1 + i*(boxH+1)
+		fmt.Fprintf(&b, "\x1b[%d;1H%s", top, v.msg.labels[i])
+		fmt.Fprintf(&b, "\x1b[%d;1H", top+1)
+		c, r := fitCells(s.config, cols, boxH)
+		if err := drawImage(&b, s, c, r); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H%s", rows, tr("press any key"))
+	if _, err := io.WriteString(v.stdout, b.String()); err != nil {
+		return err
+	}
+	if f, ok := v.stdin.(*os.File); ok {
+		if state, err := term.MakeRaw(f.Fd()); err == nil {
+			defer term.Restore(f.Fd(), state)
+		}
+	}
+	v.stdin.Read(make([]byte, 16))
+	if graphics() == graphicsKitty {
+		io.WriteString(v.stdout, "\x1b_Ga=d,q=2\x1b\\")
+	}
+	return nil
+}
+
+// drawImage writes s's image at the cursor, cols by rows cells.
+func drawImage(b *strings.Builder, s binarySide, cols, rows int) error {
+	switch graphics() {
+	case graphicsKitty:
+		data, err := pngOf(s, cols*cellPixelsW*2)
+		if err != nil {
+			return err
+		}
+		kittySend(b, fmt.Sprintf("c=%d,r=%d", cols, rows), data)
+	case graphicsITerm:
+		fmt.Fprintf(b, "\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a",
+			len(s.data), cols, rows, base64.StdEncoding.EncodeToString(s.data))
+	case graphicsSixel:
+		img, _, err := image.Decode(bytes.NewReader(s.data))
+		if err != nil {
+			return err
+		}
+		writeSixel(b, shrink(img, cols*cellPixelsW, rows*cellPixelsH))
+	}
+	return nil
+}
+
+// writeSixel encodes img as sixels in a 6×6×6 color cube, six rows of pixels
+// to a band. Mostly transparent pixels are left out.
+func writeSixel(b *strings.Builder, img image.Image) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	fmt.Fprintf(b, "\x1bP0;1q\"1;1;%d;%d", w, h)
+	for i := range 216 {
+		fmt.Fprintf(b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+	for y0 := 0; y0 < h; y0 += 6 {
+		// which of the band's six rows each color fills, column by column
+		bands := map[int][]byte{}
+		var order []int
+		for x := range w {
+			for dy := 0; dy < 6 && y0+dy < h; dy++ {
+				r, g, bl, a := img.At(bounds.Min.X+x, bounds.Min.Y+y0+dy).RGBA()
+				if a < 0x8000 {
+					continue
+				}
+				c := int(r*5/0xFFFF)*36 + int(g*5/0xFFFF)*6 + int(bl*5/0xFFFF)
+				if bands[c] == nil {
+					bands[c] = make([]byte, w)
+					order = append(order, c)
+				}
+				bands[c][x] |= 1 << dy
+			}
+		}
+		for i, c := range order {
+			if i > 0 {
+				b.WriteByte('$')
+			}
+			fmt.Fprintf(b, "#%d", c)
+			col := bands[c]
+			for x := 0; x < w; {
+				n := 1
+				for x+n < w && col[x+n] == col[x] {
+					n++
+				}
+				ch := string(rune(63 + col[x]))
+				if n > 3 {
+					fmt.Fprintf(b, "!%d%s", n, ch)
+				} else {
+					b.WriteString(strings.Repeat(ch, n))
+				}
+				x += n
+			}
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+}
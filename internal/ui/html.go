@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== HTML ====================
+
+type htmlLine struct {
+	Class  string
+	OldNum string
+	NewNum string
+	Text   string
+}
+
+type htmlFile struct {
+	ID      string
+	Path    string
+	Added   int
+	Deleted int
+	Binary  bool
+	Lines   []htmlLine
+}
+
+type htmlPage struct {
+	Title   string
+	Files   []htmlFile
+	C       map[string]string
+	Refresh bool
+}
+
+func htmlFiles(diffs []fileDiff) []htmlFile {
+	out := make([]htmlFile, 0, len(diffs))
+	for i, d := range diffs {
+		hf := htmlFile{
+			ID:      fmt.Sprintf("f%d", i),
+			Path:    d.file.Path,
+			Added:   d.added,
+			Deleted: d.deleted,
+			Binary:  d.binary,
+		}
+		parsed, _, err := gitdiff.Parse(strings.NewReader(d.raw))
+		if err != nil {
+			out = append(out, hf)
+			continue
+		}
+		for _, pf := range parsed {
+			for _, frag := range pf.TextFragments {
+				hf.Lines = append(hf.Lines, htmlLine{Class: "hunk", Text: strings.TrimRight(frag.Header(), "\n")})
+				oldNum, newNum := int(frag.OldPosition), int(frag.NewPosition)
+				for _, l := range frag.Lines {
+					hl := htmlLine{Text: expandTabs(trimLine(l.Line))}
+					switch l.Op {
+					case gitdiff.OpContext:
+						hl.OldNum, hl.NewNum = fmt.Sprint(oldNum), fmt.Sprint(newNum)
+						oldNum++
+						newNum++
+					case gitdiff.OpDelete:
+						hl.Class, hl.OldNum = "del", fmt.Sprint(oldNum)
+						oldNum++
+					case gitdiff.OpAdd:
+						hl.Class, hl.NewNum = "add", fmt.Sprint(newNum)
+						newNum++
+					}
+					hf.Lines = append(hf.Lines, hl)
+				}
+			}
+		}
+		out = append(out, hf)
+	}
+	return out
+}
+
+func writeHTML(w io.Writer, title string, diffs []fileDiff, refresh bool) error {
+	colors := map[string]string{
+		"background": pal.background,
+		"file":       pal.file,
+		"title":      pal.title,
+		"fileHdr":    pal.fileHdr,
+		"border":     pal.border,
+		"cursorBg":   pal.cursorBg,
+		"addInd":     pal.addInd,
+		"delInd":     pal.delInd,
+		"lineNum":    pal.lineNum,
+		"bgAdd":      pal.bgAdd,
+		"bgDel":      pal.bgDel,
+		"hunkHdr":    pal.hunkHdr,
+		"ctxDim":     pal.ctxDim,
+	}
+	return htmlTmpl.Execute(w, htmlPage{Title: title, Files: htmlFiles(diffs), C: colors, Refresh: refresh})
+}
+
+var htmlTmpl = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; display: flex; height: 100vh; background: {{.C.background}}; color: {{.C.file}};
+  font: 13px ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+nav { width: 320px; overflow: auto; border-right: 1px solid {{.C.border}}; padding: 8px 0; flex-shrink: 0; }
+nav h1 { font-size: 13px; margin: 0 12px 8px; color: {{.C.title}}; }
+nav a { display: block; padding: 2px 12px; color: {{.C.file}}; text-decoration: none; white-space: nowrap; }
+nav a:hover, nav a.active { background: {{.C.cursorBg}}; }
+main { flex: 1; overflow: auto; }
+section { display: none; }
+section.active { display: block; }
+h2 { font-size: 13px; margin: 0; padding: 8px 12px; color: {{.C.fileHdr}}; border-bottom: 1px solid {{.C.border}}; }
+.add-n { color: {{.C.addInd}}; }
+.del-n { color: {{.C.delInd}}; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 0 8px; white-space: pre; }
+td.n { color: {{.C.lineNum}}; text-align: right; width: 1%; user-select: none; }
+tr.add { background: {{.C.bgAdd}}; }
+tr.del { background: {{.C.bgDel}}; }
+tr.hunk td { color: {{.C.hunkHdr}}; padding-top: 6px; }
+.empty { color: {{.C.ctxDim}}; padding: 8px 12px; }
+</style>
+</head>
+<body>
+<nav>
+<h1>{{.Title}}</h1>
+{{range .Files}}<a href="#{{.ID}}" data-id="{{.ID}}">{{.Path}} <span class="add-n">+{{.Added}}</span> <span class="del-n">−{{.Deleted}}</span></a>
+{{else}}<div class="empty">No changes.</div>
+{{end}}</nav>
+<main>
+{{range .Files}}<section id="{{.ID}}">
+<h2>{{.Path}}</h2>
+{{if .Binary}}<div class="empty">Binary file</div>{{else}}<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="n">{{.OldNum}}</td><td class="n">{{.NewNum}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>{{end}}
+</section>
+{{end}}</main>
+<script>
+function show() {
+  var id = location.hash.slice(1) || (document.querySelector("nav a") || {}).dataset?.id;
+  document.querySelectorAll("section, nav a").forEach(function (el) {
+    el.classList.toggle("active", el.id === id || el.dataset.id === id);
+  });
+}
+window.addEventListener("hashchange", show);
+show();
+{{if .Refresh}}var version = null;
+setInterval(function () {
+  fetch("/version").then(function (r) { return r.text(); }).then(function (v) {
+    if (version !== null && v !== version) location.reload();
+    version = v;
+  });
+}, 2000);{{end}}
+</script>
+</body>
+</html>
+`))
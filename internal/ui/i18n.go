@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ==================== Localization ====================
+
+// UI text is written in English in the code and looked up in the catalog for
+// the user's language when shown, so a string missing from a catalog falls
+// back to English rather than to nothing. Error text from git is passed
+// through as it comes.
+
+// catalogs maps a language code to its translations, keyed by the English.
+var catalogs = map[string]map[string]string{
+	"de": catalogDE,
+}
+
+// catalog holds the translations in use; nil means English.
+var catalog map[string]string
+
+// tr translates s.
+func tr(s string) string {
+	if t, ok := catalog[s]; ok {
+		return t
+	}
+	return s
+}
+
+// trf translates format and fills it in.
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// localeLang picks the language: lang from the config file if set, then the
+// first of LC_ALL, LC_MESSAGES, and LANG that is, as POSIX orders them.
+// "de_DE.UTF-8" gives "de".
+func localeLang(lang string) string {
+	for _, v := range []string{lang, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")} {
+		if v != "" {
+			v, _, _ = strings.Cut(v, ".")
+			v, _, _ = strings.Cut(v, "_")
+			return strings.ToLower(v)
+		}
+	}
+	return ""
+}
+
+// setLanguage switches to lang's catalog, staying in English when there
+// isn't one.
+func setLanguage(lang string) {
+	catalog = catalogs[localeLang(lang)]
+}
@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"path"
+	"strings"
+)
+
+// ==================== Icons ====================
+
+// configIcons is the set of icons drawn before names in the tree, from
+// icons in the config file: "nerd" for a Nerd Font's file-type icons, or ""
+// for none.
+var configIcons string
+
+// iconSets are the icon sets icons can name.
+var iconSets = []string{"none", "nerd"}
+
+// nerdIcons are the Nerd Font icons for file extensions, or for whole names
+// such as Makefile.
+var nerdIcons = map[string]string{
+	".go":        "\ue627",
+	".mod":       "\ue627",
+	".sum":       "\ue627",
+	".js":        "\ue74e",
+	".mjs":       "\ue74e",
+	".jsx":       "\ue7ba",
+	".ts":        "\ue628",
+	".tsx":       "\ue7ba",
+	".py":        "\ue606",
+	".rs":        "\ue7a8",
+	".rb":        "\ue791",
+	".java":      "\ue738",
+	".c":         "\ue61e",
+	".h":         "\ue61e",
+	".cpp":       "\ue61d",
+	".cc":        "\ue61d",
+	".hpp":       "\ue61d",
+	".html":      "\ue736",
+	".css":       "\ue749",
+	".scss":      "\ue749",
+	".md":        "\ue609",
+	".json":      "\ue60b",
+	".yaml":      "\ue6a8",
+	".yml":       "\ue6a8",
+	".toml":      "\ue615",
+	".sh":        "\uf489",
+	".bash":      "\uf489",
+	".zsh":       "\uf489",
+	".txt":       "\uf15c",
+	".lock":      "\uf023",
+	".svg":       "\uf1c5",
+	".png":       "\uf1c5",
+	".jpg":       "\uf1c5",
+	".gif":       "\uf1c5",
+	"Makefile":   "\ue779",
+	"Dockerfile": "\uf308",
+	".gitignore": "\ue702",
+}
+
+// fileIcon is the icon drawn before a file's name, with a space after it,
+// or "" without icons.
+func fileIcon(name string) string {
+	if configIcons != "nerd" {
+		return ""
+	}
+	base := path.Base(name)
+	icon, ok := nerdIcons[base]
+	if !ok {
+		icon, ok = nerdIcons[strings.ToLower(path.Ext(base))]
+	}
+	if !ok {
+		icon = "\uf15b"
+	}
+	return icon + " "
+}
+
+// dirIcon is fileIcon for a directory, open unless collapsed.
+func dirIcon(collapsed bool) string {
+	switch {
+	case configIcons != "nerd":
+		return ""
+	case collapsed:
+		return "\uf07b "
+	}
+	return "\uf07c "
+}
@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Incoming ====================
+
+type fetchTickMsg struct{}
+
+// upstreamRef returns the current branch's upstream, or origin's copy of the
+// base branch when there is none.
+func upstreamRef() string {
+	out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}").Output()
+	if err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return "origin/" + baseRef
+}
+
+// splitRemoteRef splits origin/main into its remote and branch, when the
+// first component names a configured remote.
+func splitRemoteRef(ref string) (remote, branch string, ok bool) {
+	remote, branch, ok = strings.Cut(ref, "/")
+	if !ok {
+		return "", "", false
+	}
+	out, err := exec.Command("git", "remote").Output()
+	if err != nil {
+		return "", "", false
+	}
+	for _, r := range strings.Fields(string(out)) {
+		if r == remote {
+			return remote, branch, true
+		}
+	}
+	return "", "", false
+}
+
+// fetchIncoming fetches ref's branch from its remote, reporting whether the
+// ref moved.
+func fetchIncoming(ref string) (bool, error) {
+	remote, branch, ok := splitRemoteRef(ref)
+	if !ok {
+		return false, nil
+	}
+	before, _ := exec.Command("git", "rev-parse", "--verify", "--quiet", ref).Output()
+	if out, err := exec.Command("git", "fetch", "--quiet", remote, branch).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("git fetch %s %s: %s", remote, branch, strings.TrimSpace(string(out)))
+	}
+	after, _ := exec.Command("git", "rev-parse", "--verify", "--quiet", ref).Output()
+	return string(before) != string(after), nil
+}
+
+func fetchTick(every time.Duration) tea.Cmd {
+	return tea.Tick(every, func(time.Time) tea.Msg { return fetchTickMsg{} })
+}
+
+// refetch fetches the incoming ref and reloads the files when it moved.
+func refetch(ref string) tea.Cmd {
+	return func() tea.Msg {
+		moved, err := fetchIncoming(ref)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		if !moved {
+			return nil
+		}
+		files, err := loadFiles()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return filesLoadedMsg{files: files, text: trf("%s moved at %s", ref, time.Now().Format("15:04"))}
+	}
+}
+
+// runIncoming browses what ref has that HEAD doesn't, the changes a pull
+// would bring in, fetching it again every interval.
+func runIncoming(args []string) {
+	fs := flag.NewFlagSet("incoming", flag.ExitOnError)
+	every := fs.Duration("interval", time.Minute, "how often to fetch; 0 fetches only at start")
+	fs.Parse(args)
+	rest := fs.Args()
+
+	ref := ""
+	if len(rest) > 0 && rest[0] != "--" {
+		ref, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+	pathspecs = rootPathspecs(rest)
+	if ref == "" {
+		ref = upstreamRef()
+	}
+
+	initTheme()
+
+	if _, err := fetchIncoming(ref); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	if err := exec.Command("git", "rev-parse", "--verify", "--quiet", ref).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: unknown ref %q\n", ref)
+		os.Exit(1)
+	}
+
+	// diff the merge base against ref, the reverse of --main
+	flagMain = true
+	baseRef, headRef = "HEAD", ref
+	files, err := loadFiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 && *every == 0 {
+		fmt.Printf("Up to date with %s.\n", ref)
+		return
+	}
+
+	m := initialModel(files)
+	m.fetchEvery, m.fetchRef = *every, ref
+	runProgram(m, files)
+}
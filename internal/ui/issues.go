@@ -0,0 +1,140 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Issue Links ====================
+
+var (
+	issueKeyRe     = regexp.MustCompile(`\b[A-Z][A-Z0-9]{1,9}-[1-9][0-9]*\b`)
+	issueNumRe     = regexp.MustCompile(`(^|[^\w&#])#([1-9][0-9]*)\b`)
+	branchKeyRe    = regexp.MustCompile(`(?i)(^|[/_-])([a-z][a-z0-9]{1,9}-[1-9][0-9]*)`)
+	branchNumRe    = regexp.MustCompile(`(^|/)(?:issue-|issues/|gh-)?([1-9][0-9]*)([-_]|$)`)
+	issueForgeOnce sync.Once
+	issueForge     *forge
+)
+
+// issueURL returns the link for an issue reference: ABC-123 keys go through
+// the --issue-url template and #123 numbers to the origin forge's tracker.
+func issueURL(id string) string {
+	if num, ok := strings.CutPrefix(id, "#"); ok {
+		issueForgeOnce.Do(func() {
+			if f, err := originForge(); err == nil {
+				issueForge = &f
+			}
+		})
+		if issueForge == nil {
+			return ""
+		}
+		return issueForge.issueURL(num)
+	}
+	if flagIssueURL == "" {
+		return ""
+	}
+	return strings.ReplaceAll(flagIssueURL, "{id}", id)
+}
+
+func (f forge) issueURL(num string) string {
+	base := "https://" + f.host + "/" + f.repo
+	if f.kind == forgeGitLab {
+		return base + "/-/issues/" + num
+	}
+	return base + "/issues/" + num
+}
+
+// findIssues returns the issue references in s that have a URL, in order.
+func findIssues(s string) []string {
+	type match struct {
+		at int
+		id string
+	}
+	var ms []match
+	if flagIssueURL != "" {
+		for _, loc := range issueKeyRe.FindAllStringIndex(s, -1) {
+			ms = append(ms, match{loc[0], s[loc[0]:loc[1]]})
+		}
+	}
+	for _, loc := range issueNumRe.FindAllStringSubmatchIndex(s, -1) {
+		ms = append(ms, match{loc[4] - 1, "#" + s[loc[4]:loc[5]]})
+	}
+	var ids []string
+	for len(ms) > 0 {
+		first := 0
+		for i := range ms {
+			if ms[i].at < ms[first].at {
+				first = i
+			}
+		}
+		if issueURL(ms[first].id) != "" {
+			ids = append(ids, ms[first].id)
+		}
+		ms = append(ms[:first], ms[first+1:]...)
+	}
+	return ids
+}
+
+// linkIssues renders s with render, wrapping each issue reference in an OSC 8
+// hyperlink.
+func linkIssues(s string, render func(string) string) string {
+	if !flagLinks || !strings.ContainsAny(s, "#-") {
+		return render(s)
+	}
+	var b strings.Builder
+	rest := s
+	for _, id := range findIssues(s) {
+		i := strings.Index(rest, id)
+		if i < 0 {
+			continue
+		}
+		if i > 0 {
+			b.WriteString(render(rest[:i]))
+		}
+		b.WriteString(hyperlink(issueURL(id), render(id)))
+		rest = rest[i+len(id):]
+	}
+	if rest != "" {
+		b.WriteString(render(rest))
+	}
+	return b.String()
+}
+
+var (
+	branchIssueOnce sync.Once
+	branchIssueID   string
+)
+
+// branchIssue returns the issue named by the current branch, such as
+// ABC-123 in feature/ABC-123-login or #42 in 42-fix-crash.
+func branchIssue() string {
+	branchIssueOnce.Do(func() {
+		branch := currentBranch()
+		if flagIssueURL != "" {
+			if m := branchKeyRe.FindStringSubmatch(branch); m != nil {
+				branchIssueID = strings.ToUpper(m[2])
+				return
+			}
+		}
+		if m := branchNumRe.FindStringSubmatch(branch); m != nil && issueURL("#"+m[2]) != "" {
+			branchIssueID = "#" + m[2]
+		}
+	})
+	return branchIssueID
+}
+
+// hunkIssue returns the first issue referenced on a changed line of frag.
+func hunkIssue(frag *gitdiff.TextFragment) string {
+	for _, l := range frag.Lines {
+		if l.Op == gitdiff.OpContext {
+			continue
+		}
+		if ids := findIssues(l.Line); len(ids) > 0 {
+			return ids[0]
+		}
+	}
+	return ""
+}
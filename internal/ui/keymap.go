@@ -0,0 +1,294 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Key Bindings ====================
+
+// binding is one of the file tree's actions, the keys that do it unless the
+// config file says otherwise, and what ? says about it.
+type binding struct {
+	action string
+	keys   []string
+	help   string
+}
+
+// bindings are in the order ? lists them. Action names can't be key names,
+// so [keys] can tell "ctrl+n" = "down" (a key) from "ctrl+n" = "cursor_down".
+var bindings = []binding{
+	{"cursor_down", []string{"j", "down"}, "next file"},
+	{"cursor_up", []string{"k", "up"}, "previous file"},
+	{"open", []string{"enter"}, "open the full-file diff; a directory's or the marked files' together"},
+	{"focus", []string{"tab"}, "focus the preview / the file tree"},
+	{"expand", []string{"l", "right"}, "expand a directory, or focus the preview"},
+	{"collapse", []string{"h", "left"}, "collapse a directory or go up to one, or focus the tree"},
+	{"search", []string{"/"}, "search files"},
+	{"back", []string{"esc"}, "clear the search or the marks, back to the tree, or quit"},
+	{"quit", []string{"q", "ctrl+c"}, "quit"},
+	{"refresh", []string{"r"}, "reload the changed files and the preview"},
+	{"load_all", []string{"L"}, "load the rest of a truncated preview"},
+	{"next_hunk", []string{"n"}, "next hunk"},
+	{"prev_hunk", []string{"p"}, "previous hunk"},
+	{"next_section", []string{"}"}, "next file section"},
+	{"prev_section", []string{"{"}, "previous file section"},
+	{"fold_hunk", []string{" "}, "fold the current hunk, or open it"},
+	{"fold_all", []string{"Z"}, "fold every hunk, or open them all"},
+	{"less_context", []string{"-"}, "one line less of context"},
+	{"more_context", []string{"+", "="}, "one line more of context"},
+	{"narrow_tree", []string{"("}, "narrow the file tree"},
+	{"widen_tree", []string{")"}, "widen the file tree"},
+	{"toggle_tree", []string{"t"}, "hide or show the file tree"},
+	{"layout", []string{"|"}, "switch between unified and side by side"},
+	{"wrap", []string{"w"}, "wrap long lines, or cut them"},
+	{"blame", []string{"b"}, "show or hide the blame column"},
+	{"ignore_space", []string{"I"}, "hide or show whitespace changes"},
+	{"cycle_kind", []string{"U"}, "narrow the tree to staged, unstaged, or untracked changes"},
+	{"mark_file", []string{"x"}, "mark or unmark the selected file or directory"},
+	{"discard", []string{"delete", "backspace"}, "discard the marked files' changes, or the current hunk"},
+	{"stage", []string{"s"}, "stage the current hunk, or the marked files"},
+	{"unstage", []string{"u"}, "unstage the current hunk, or the marked files"},
+	{"ours", []string{"<"}, "resolve the hunk's conflicts to ours"},
+	{"theirs", []string{">"}, "resolve the hunk's conflicts to theirs"},
+	{"stash", []string{"z"}, "stash the worktree's changes"},
+	{"commit", []string{"c"}, "commit what's staged"},
+	{"amend", []string{"C"}, "amend the last commit"},
+	{"fixup", []string{"f"}, "commit the hunk as a fixup!"},
+	{"mark_hunk", []string{"m"}, "mark or unmark the current hunk"},
+	{"export_hunks", []string{"X"}, "export the marked hunks as a patch"},
+	{"export", []string{"e"}, "export the selected file as a patch"},
+	{"export_all", []string{"E"}, "export the whole changeset as a patch"},
+	{"next_commit", []string{"]"}, "next commit of a patch"},
+	{"prev_commit", []string{"["}, "previous commit of a patch"},
+	{"range", []string{"v"}, "start or clear a commit range"},
+	{"format_patch", []string{"F"}, "git format-patch the selected commits"},
+	{"edit", []string{"o"}, "open the file in the editor"},
+	{"browse", []string{"O"}, "open the file in the browser"},
+	{"difftool", []string{"D"}, "open the file in git difftool"},
+	{"symbols", []string{"K"}, "show symbols on the hunk's added lines"},
+	{"test", []string{"T"}, "run the tests"},
+	{"lint", []string{"W"}, "lint the changed files"},
+	{"stats", []string{"S"}, "show the diffstat"},
+	{"pick_base", []string{"B"}, "compare against another branch or tag, or the worktree"},
+	{"owners", []string{"@"}, "group the tree by owner"},
+	{"ci", []string{"i"}, "show the CI runs"},
+	{"threads", []string{"#"}, "expand or collapse review threads"},
+	{"yank", []string{"y"}, "copy the path, hunk, diff, or issue link"},
+	{"permalink", []string{"Y"}, "copy a permalink"},
+	{"comment", []string{"a"}, "draft a review comment on the hunk"},
+	{"file_note", []string{"M"}, "leave a review note on the file"},
+	{"comments", []string{"A"}, "show pending review comments"},
+	{"viewed", []string{"V"}, "mark the file viewed, or not"},
+	{"submit_review", []string{"R"}, "submit the pending review"},
+	{"errors", []string{"!"}, "show this session's errors"},
+	{"help", []string{"?"}, "show the keys"},
+}
+
+// keymap maps each key to its action: the defaults, changed by the config
+// file's [keys] table.
+var keymap = defaultKeymap()
+
+// boundKeys are the keys [keys] binds, by line, for reporting conflicts.
+var boundKeys = map[string]int{}
+
+func defaultKeymap() map[string]string {
+	km := map[string]string{}
+	for _, b := range bindings {
+		for _, k := range b.keys {
+			km[k] = b.action
+		}
+	}
+	return km
+}
+
+func isAction(name string) bool {
+	return slices.ContainsFunc(bindings, func(b binding) bool { return b.action == name })
+}
+
+// keyName reads a key as [keys] writes it, where space may be spelled out.
+func keyName(k string) string {
+	if k == "space" {
+		return " "
+	}
+	return k
+}
+
+// showKey writes k as ? shows it.
+func showKey(k string) string {
+	if k == " " {
+		return "space"
+	}
+	return k
+}
+
+// parseKeys reads the config file's [keys] table. A key set to an action
+// does that instead of whatever it did; set to another key, it presses that
+// one; set to "none", it does nothing:
+//
+//	[keys]
+//	"ctrl+n" = "j"          # ctrl+n does what j does
+//	enter = "focus"         # swap enter and tab
+//	tab = "open"
+//	q = "none"
+func parseKeys(t *configTable) error {
+	for from := range t.values {
+		to, err := t.str(from)
+		if err != nil {
+			return err
+		}
+		key := keyName(from)
+		if _, ok := parseKey(key); !ok {
+			return fmt.Errorf("line %d: unknown key %q", t.lines[from], from)
+		}
+		switch {
+		case to == "none":
+			delete(keymap, key)
+		case isAction(to):
+			keymap[key] = to
+		default:
+			msg, ok := parseKey(keyName(to))
+			if !ok {
+				return fmt.Errorf("line %d: %q is neither a key nor an action (gd keys lists them)", t.lines[from], to)
+			}
+			keyAliases[key] = msg
+		}
+		boundKeys[key] = t.lines[from]
+	}
+	return nil
+}
+
+// checkBindings fails when two commands share a key, or a command takes a
+// key [keys] bound, as only one of them can have it.
+func checkBindings() error {
+	owner := map[string]string{}
+	for _, c := range userCommands {
+		if other, ok := owner[c.key]; ok {
+			return fmt.Errorf("commands %q and %q are both on %s", other, c.name, c.key)
+		}
+		owner[c.key] = c.name
+		if line, ok := boundKeys[c.key]; ok {
+			return fmt.Errorf("line %d: %s is bound in [keys] and to the command %q", line, c.key, c.name)
+		}
+	}
+	return nil
+}
+
+// actionOf is what pressing k does: nothing when a command has it, or the
+// action of the key it stands for.
+func actionOf(k string) string {
+	if _, ok := userCommandFor(k); ok {
+		return ""
+	}
+	if alias, ok := keyAliases[k]; ok {
+		k = alias.String()
+	}
+	return keymap[k]
+}
+
+// keysFor lists the keys that do action, its defaults first.
+func keysFor(action string) []string {
+	var keys, extra []string
+	for _, b := range bindings {
+		if b.action != action {
+			continue
+		}
+		for _, k := range b.keys {
+			if actionOf(k) == action {
+				keys = append(keys, k)
+			}
+		}
+		for _, km := range []map[string]string{keymap, aliasNames()} {
+			for k := range km {
+				if actionOf(k) == action && !slices.Contains(b.keys, k) && !slices.Contains(extra, k) {
+					extra = append(extra, k)
+				}
+			}
+		}
+	}
+	sort.Strings(extra)
+	return append(keys, extra...)
+}
+
+// aliasNames are the keys [keys] set to other keys, in keymap's shape.
+func aliasNames() map[string]string {
+	names := map[string]string{}
+	for k, alias := range keyAliases {
+		names[k] = alias.String()
+	}
+	return names
+}
+
+// keyWarnings are what might surprise about the bindings: commands hiding
+// actions' keys, and actions left without one.
+func keyWarnings() []string {
+	var warns []string
+	for _, c := range userCommands {
+		if a := keymap[c.key]; a != "" {
+			warns = append(warns, fmt.Sprintf("%s: the command %q takes it from %s", showKey(c.key), c.name, a))
+		}
+	}
+	for _, b := range bindings {
+		if len(keysFor(b.action)) == 0 {
+			warns = append(warns, fmt.Sprintf("%s has no key", b.action))
+		}
+	}
+	return warns
+}
+
+// keyTable lays out the active bindings, one action to a line, for ? and
+// gd keys.
+func keyTable(style func(string) string) string {
+	var b strings.Builder
+	write := func(keys []string, action, help string) {
+		for i, k := range keys {
+			keys[i] = showKey(k)
+		}
+		fmt.Fprintf(&b, "%s %-14s %s\n", style(fitStr(strings.Join(keys, " "), 16)), action, help)
+	}
+	for _, bd := range bindings {
+		if keys := keysFor(bd.action); len(keys) > 0 {
+			write(keys, bd.action, bd.help)
+		}
+	}
+	for _, c := range userCommands {
+		write([]string{c.key}, "command", c.name)
+	}
+	return b.String()
+}
+
+// showKeys lists the active bindings in the preview.
+func (m model) showKeys() tea.Cmd {
+	return func() tea.Msg {
+		var b strings.Builder
+		b.WriteString(titleSty.Render(tr("Keys")))
+		b.WriteString("\n\n")
+		b.WriteString(keyTable(func(s string) string { return hunkHdrSty.Render(s) }))
+		for _, w := range keyWarnings() {
+			b.WriteString("\n" + noteSty.Render(w))
+		}
+		return diffLoadedMsg{content: b.String()}
+	}
+}
+
+// runKeys is gd keys: it prints the bindings the config file leaves, and
+// exits 1 when any of them conflict.
+func runKeys() {
+	if err := loadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: config: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(keyTable(func(s string) string { return s }))
+	warns := keyWarnings()
+	for _, w := range warns {
+		fmt.Fprintln(os.Stderr, "warning: "+w)
+	}
+	if len(warns) > 0 {
+		os.Exit(1)
+	}
+}
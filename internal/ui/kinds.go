@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Change Kinds ====================
+
+// Kinds of worktree change the tree can be narrowed to, with --staged,
+// --unstaged, --untracked, or U, which goes through them in this order.
+const (
+	kindAll int32 = iota
+	kindStaged
+	kindUnstaged
+	kindUntracked
+	kindCount
+)
+
+// showKind is read by loads running off the UI goroutine.
+var showKind atomic.Int32
+
+// setKindFlags narrows the tree as the flags ask.
+func setKindFlags(staged, unstaged, untracked bool) error {
+	n := 0
+	for kind, set := range []bool{kindStaged: staged, kindUnstaged: unstaged, kindUntracked: untracked} {
+		if set {
+			showKind.Store(int32(kind))
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("use only one of --staged, --unstaged, and --untracked")
+	}
+	if n > 0 && flagMain {
+		return fmt.Errorf("--staged, --unstaged, and --untracked are for the worktree, not --main or a range")
+	}
+	return nil
+}
+
+// filterKind keeps the files with the shown kind of change, leaving them only
+// that side of it so their badges and previews show just that.
+func filterKind(files []git.FileStatus) []git.FileStatus {
+	kind := showKind.Load()
+	if kind == kindAll {
+		return files
+	}
+	var kept []git.FileStatus
+	for _, f := range files {
+		switch {
+		case kind == kindStaged && f.Staged:
+			f.Unstaged = false
+		case kind == kindUnstaged && (f.Unstaged || f.Conflicted):
+			f.Staged = false
+		case kind == kindUntracked && f.Untracked:
+		default:
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// kindDiffArgs are git diff's arguments to compare a kind of change, for
+// numstat's counts and for patches.
+func kindDiffArgs(kind int32) []string {
+	switch kind {
+	case kindStaged:
+		return []string{"--cached"}
+	case kindUnstaged:
+		return nil
+	}
+	return []string{"HEAD"}
+}
+
+// cycleKind narrows the tree to the next kind of change, round to all of
+// them.
+func (m model) cycleKind() tea.Cmd {
+	if !m.live() || flagMain {
+		return func() tea.Msg { return statusMsg{text: tr("only the worktree's changes can be narrowed")} }
+	}
+	showKind.Store((showKind.Load() + 1) % kindCount)
+	return reloadFiles(kindTitle())
+}
+
+// kindTitle names the files shown, for the tree's title.
+func kindTitle() string {
+	switch showKind.Load() {
+	case kindStaged:
+		return tr("Staged Changes")
+	case kindUnstaged:
+		return tr("Unstaged Changes")
+	case kindUntracked:
+		return tr("Untracked Files")
+	}
+	return tr("Changed Files")
+}
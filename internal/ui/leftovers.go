@@ -0,0 +1,146 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/arnavsurve/gd/pkg/render"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Leftovers ====================
+
+// flagLeftovers flags added lines that are rarely meant to be committed:
+// TODO markers, debug prints, and merge conflict markers. Set by
+// --leftovers or leftovers = true in the config file.
+var flagLeftovers bool
+
+// leftoverRules are checked in order; a line is flagged by the first that
+// matches it.
+var leftoverRules = []struct {
+	re  *regexp.Regexp
+	msg string // %s is what matched
+}{
+	{regexp.MustCompile(`^(<<<<<<<|=======|>>>>>>>)( |$)`), "merge conflict marker"},
+	{regexp.MustCompile(`\b(fmt\.Print(ln|f)?|console\.(log|debug)|breakpoint)\(|^\s*debugger\b|binding\.pry`), "debug leftover: %s"},
+	{regexp.MustCompile(`\b(TODO|FIXME|XXX)\b`), "%s left in"},
+}
+
+// leftover is the note for an added line holding a leftover, or "".
+func leftover(text string) string {
+	text = trimLine(text)
+	for _, r := range leftoverRules {
+		if m := r.re.FindStringSubmatch(text); m != nil {
+			if strings.Contains(r.msg, "%s") {
+				return trf(r.msg, strings.TrimSuffix(strings.TrimSpace(m[0]), "("))
+			}
+			return tr(r.msg)
+		}
+	}
+	return ""
+}
+
+// withLeftovers is notes with a note added under each of f's added lines
+// that holds a leftover. notes is left as it is.
+func withLeftovers(notes render.Notes, f *gitdiff.File) render.Notes {
+	merged := render.Notes{}
+	for n, msgs := range notes {
+		merged[n] = msgs
+	}
+	for _, frag := range f.TextFragments {
+		num := int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			switch l.Op {
+			case gitdiff.OpAdd:
+				if msg := leftover(l.Line); msg != "" {
+					merged[num] = append(append([]string(nil), merged[num]...), msg)
+				}
+				num++
+			case gitdiff.OpContext:
+				num++
+			}
+		}
+	}
+	return merged
+}
+
+// countLeftovers counts the added lines holding leftovers in f.
+func countLeftovers(f *gitdiff.File) int {
+	n := 0
+	for _, frag := range f.TextFragments {
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpAdd && leftover(l.Line) != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// addLeftovers fills in the leftover counts of the worktree's files from
+// one git diff of the changes the tree shows, or of --main's range, reading
+// untracked files from disk. The tree works without them, so failing leaves
+// them out.
+func addLeftovers(files []git.FileStatus) {
+	args := kindDiffArgs(showKind.Load())
+	if flagMain {
+		args = []string{diffRange()}
+	}
+	out, err := leftoverDiff(args)
+	if err != nil && !flagMain && showKind.Load() == kindAll {
+		// no commits yet
+		out, err = leftoverDiff([]string{git.EmptyTree})
+	}
+	reportError(err)
+	counts := map[string]int{}
+	if parsed, _, err := gitdiff.Parse(bytes.NewReader(out)); err == nil {
+		for _, f := range parsed {
+			if f.NewName != "" {
+				counts[f.NewName] = countLeftovers(f)
+			}
+		}
+	}
+	for i := range files {
+		if files[i].Untracked {
+			files[i].Stat.Leftovers = untrackedLeftovers(files[i].Path)
+		} else {
+			files[i].Stat.Leftovers = counts[files[i].Path]
+		}
+	}
+}
+
+// leftoverDiff is git diff args with no context, all the counting needs.
+func leftoverDiff(args []string) ([]byte, error) {
+	cmd := append([]string{"diff", "--no-ext-diff", "--no-color", "-U0"}, args...)
+	cmd = append(append(cmd, "--"), pathspecs...)
+	out, err := exec.Command("git", cmd...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff -U0 %s: %w", strings.Join(args, " "), git.StderrError(err))
+	}
+	return out, nil
+}
+
+// untrackedLeftovers counts the lines of an untracked file holding
+// leftovers, all of them being added.
+func untrackedLeftovers(path string) int {
+	data, err := os.ReadFile(filepath.Join(repoRoot(), path))
+	if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return 0
+	}
+	n := 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		if leftover(sc.Text()) != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,254 @@
+package ui
+
+import (
+	"github.com/arnavsurve/gd/pkg/render"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Line Staging ====================
+
+// lineSelection is a run of one hunk's lines picked in the focused preview
+// with v, to stage or unstage without the rest of the hunk.
+type lineSelection struct {
+	frag           *gitdiff.TextFragment // the hunk's, so a reloaded preview drops the selection
+	hunk           int                   // in m.hunks
+	anchor, cursor int                   // in the hunk's lines
+}
+
+func (s lineSelection) span() (int, int) {
+	return min(s.anchor, s.cursor), max(s.anchor, s.cursor)
+}
+
+// selection is the line selection, if there is one and the preview it was
+// made in is still shown.
+func (m model) selection() (lineSelection, render.Hunk, bool) {
+	s := m.lineSel
+	if s == nil || s.hunk >= len(m.hunks) || m.hunks[s.hunk].Frag != s.frag {
+		return lineSelection{}, render.Hunk{}, false
+	}
+	return *s, m.hunks[s.hunk], true
+}
+
+// startLineSelection picks the first changed line of the hunk at the top of
+// the preview, on screen if it can.
+func (m *model) startLineSelection() {
+	f, i := m.selectedFile(), m.currentHunkIdx()
+	switch {
+	case f == nil || i < 0:
+		return
+	case flagMain || f.Diff != "" || !m.live():
+		m.message = tr("staging needs a change in the worktree")
+		return
+	case f.Untracked:
+		m.message = tr("an untracked file is staged whole, with s")
+		return
+	case m.isFolded(m.hunks[i]):
+		m.message = tr("open the hunk first, with space")
+		return
+	case len(m.hunks[i].Rows) != len(m.hunks[i].Frag.Lines):
+		m.message = tr("lines are picked in the unified layout; | switches to it")
+		return
+	}
+	h := m.hunks[i]
+	first := -1
+	for j, l := range h.Frag.Lines {
+		if l.Op == gitdiff.OpContext {
+			continue
+		}
+		if first < 0 {
+			first = j
+		}
+		if h.Line+h.Rows[j] >= m.viewport.yOffset {
+			first = j
+			break
+		}
+	}
+	if first < 0 {
+		return
+	}
+	m.lineSel = &lineSelection{frag: h.Frag, hunk: i, anchor: first, cursor: first}
+	m.showLineCursor()
+}
+
+// moveLineCursor moves the selection's end by delta lines, within its hunk.
+func (m *model) moveLineCursor(delta int) {
+	s, h, ok := m.selection()
+	if !ok {
+		return
+	}
+	m.lineSel.cursor = max(0, min(s.cursor+delta, len(h.Frag.Lines)-1))
+	m.showLineCursor()
+}
+
+// showLineCursor scrolls the selection's end into view.
+func (m *model) showLineCursor() {
+	s, h, ok := m.selection()
+	if !ok {
+		return
+	}
+	v := &m.viewport
+	row := h.Line + h.Rows[s.cursor]
+	switch {
+	case row < v.yOffset:
+		v.setYOffset(row)
+	case row >= v.yOffset+v.height:
+		v.setYOffset(row - v.height + 1)
+	}
+	m.followEnd = false
+	m.syncHunk()
+}
+
+// lineSelectionKey handles key while lines are being picked: j and k move
+// the end of the selection, s and u stage or unstage it, and esc or v
+// drops it.
+func (m *model) lineSelectionKey(key string) tea.Cmd {
+	switch key {
+	case "j", "down":
+		m.moveLineCursor(1)
+	case "k", "up":
+		m.moveLineCursor(-1)
+	case "s", "u":
+		cmd := m.stageLines(key == "u")
+		m.lineSel = nil
+		return cmd
+	case "esc", "v", "q":
+		m.lineSel = nil
+	case "ctrl+c":
+		return tea.Quit
+	}
+	return nil
+}
+
+// stageLines stages the selected lines with s, or unstages them with u, as
+// a patch of their hunk with the rest of its changes left out.
+func (m model) stageLines(unstage bool) tea.Cmd {
+	s, h, ok := m.selection()
+	f := m.selectedFile()
+	if !ok || f == nil {
+		return nil
+	}
+	if ignoreSpace.Load() {
+		return func() tea.Msg { return statusMsg{text: tr("can't stage with whitespace hidden; I shows it")} }
+	}
+	lo, hi := s.span()
+	frag := partialFragment(h.Frag, lo, hi, unstage)
+	n := 0
+	for _, l := range h.Frag.Lines[lo : hi+1] {
+		if l.Op != gitdiff.OpContext {
+			n++
+		}
+	}
+	if n == 0 {
+		return func() tea.Msg { return statusMsg{text: tr("no changed lines picked")} }
+	}
+	forward, back := []string(nil), []string{"--reverse"}
+	done, already := trf("staged %d lines", n), tr("lines are already staged")
+	if n == 1 {
+		done = tr("staged 1 line")
+	}
+	if unstage {
+		forward, back = back, forward
+		done, already = trf("unstaged %d lines", n), tr("lines aren't staged")
+		if n == 1 {
+			done = tr("unstaged 1 line")
+		}
+		session.record("unstage_lines", f.Path, frag.Header())
+	} else {
+		session.record("stage_lines", f.Path, frag.Header())
+	}
+	return func() tea.Msg {
+		patch := buildHunkPatch([]markedHunk{{path: f.Path, file: h.File, frag: frag}})
+		if err := applyCached(patch, append(forward, "--check")...); err != nil {
+			// the lines picked the other way applying means they're there already
+			other := buildHunkPatch([]markedHunk{{path: f.Path, file: h.File, frag: partialFragment(h.Frag, lo, hi, !unstage)}})
+			if applyCached(other, append(back, "--check")...) == nil {
+				return statusMsg{text: already}
+			}
+			return errorMsg{err: err}
+		}
+		if err := applyCached(patch, forward...); err != nil {
+			return errorMsg{err: err}
+		}
+		files, err := loadFiles()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return filesLoadedMsg{files: files, text: done}
+	}
+}
+
+// partialFragment is frag with only its changed lines lo to hi left changed.
+// Staging drops the other added lines and keeps the other removed ones as
+// context; unstaging applies in reverse, so it does the opposite.
+func partialFragment(frag *gitdiff.TextFragment, lo, hi int, unstage bool) *gitdiff.TextFragment {
+	p := &gitdiff.TextFragment{
+		Comment:     frag.Comment,
+		OldPosition: frag.OldPosition,
+		NewPosition: frag.NewPosition,
+	}
+	var lines []gitdiff.Line
+	for i, l := range frag.Lines {
+		picked := i >= lo && i <= hi
+		switch {
+		case l.Op == gitdiff.OpContext || picked:
+		case (l.Op == gitdiff.OpAdd) != unstage:
+			continue
+		default:
+			l.Op = gitdiff.OpContext
+		}
+		lines = append(lines, l)
+	}
+	for _, l := range withEOLs(lines) {
+		p.Lines = append(p.Lines, l)
+		switch l.Op {
+		case gitdiff.OpContext:
+			p.OldLines++
+			p.NewLines++
+		case gitdiff.OpAdd:
+			p.NewLines++
+			p.LinesAdded++
+		case gitdiff.OpDelete:
+			p.OldLines++
+			p.LinesDeleted++
+		}
+	}
+	return p
+}
+
+// withEOLs gives back a newline to the lines of a partial hunk that lost
+// theirs only for being last in the file, when leaving other changes out
+// puts more lines after them. A line kept as context that's last on one side
+// only becomes a removal and an addition, with and without its newline.
+func withEOLs(lines []gitdiff.Line) []gitdiff.Line {
+	lastOld, lastNew := -1, -1
+	for i, l := range lines {
+		if l.Old() {
+			lastOld = i
+		}
+		if l.New() {
+			lastNew = i
+		}
+	}
+	var out []gitdiff.Line
+	for i, l := range lines {
+		if !l.NoEOL() {
+			out = append(out, l)
+			continue
+		}
+		withEOL := gitdiff.Line{Op: l.Op, Line: l.Line + "\n"}
+		switch {
+		case l.Op == gitdiff.OpDelete && i != lastOld, l.Op == gitdiff.OpAdd && i != lastNew:
+			out = append(out, withEOL)
+		case l.Op != gitdiff.OpContext || i == lastOld && i == lastNew:
+			out = append(out, l)
+		case i == lastOld:
+			out = append(out, gitdiff.Line{Op: gitdiff.OpDelete, Line: l.Line}, gitdiff.Line{Op: gitdiff.OpAdd, Line: withEOL.Line})
+		case i == lastNew:
+			out = append(out, gitdiff.Line{Op: gitdiff.OpDelete, Line: withEOL.Line}, gitdiff.Line{Op: gitdiff.OpAdd, Line: l.Line})
+		default:
+			out = append(out, withEOL)
+		}
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// parseFragment reads the one hunk of a patch to f.
+func parseFragment(t *testing.T, hunk string) *gitdiff.TextFragment {
+	t.Helper()
+	files, _, err := gitdiff.Parse(strings.NewReader("diff --git a/f b/f\n--- a/f\n+++ b/f\n" + hunk))
+	if err != nil || len(files) != 1 || len(files[0].TextFragments) != 1 {
+		t.Fatalf("want one hunk, got %v (%v)", files, err)
+	}
+	return files[0].TextFragments[0]
+}
+
+func TestPartialFragment(t *testing.T) {
+	mixed := "@@ -1,4 +1,4 @@\n a\n-b\n-c\n+B\n+C\n d\n"
+	noEOL := "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n"
+	for _, tc := range []struct {
+		name    string
+		hunk    string
+		lo, hi  int
+		unstage bool
+		want    string
+	}{
+		{
+			"stage a removal and an addition", mixed, 2, 3, false,
+			"@@ -1,4 +1,4 @@\n a\n b\n-c\n+B\n d\n",
+		},
+		{
+			"unstage a removal and an addition", mixed, 2, 3, true,
+			"@@ -1,4 +1,4 @@\n a\n-c\n+B\n C\n d\n",
+		},
+		{
+			"stage the first line", mixed, 1, 1, false,
+			"@@ -1,4 +1,3 @@\n a\n-b\n c\n d\n",
+		},
+		{
+			"stage the last line", mixed, 4, 5, false,
+			"@@ -1,4 +1,5 @@\n a\n b\n c\n+C\n d\n",
+		},
+		{
+			"stage the whole hunk", mixed, 0, 5, false,
+			mixed,
+		},
+		{
+			"stage a removal without a newline", noEOL, 1, 1, false,
+			"@@ -1,2 +1,1 @@\n a\n-b\n\\ No newline at end of file\n",
+		},
+		{
+			"stage an addition after a line without a newline", noEOL, 2, 2, false,
+			"@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			"unstage a removal before a line without a newline", noEOL, 1, 1, true,
+			"@@ -1,3 +1,2 @@\n a\n-b\n c\n\\ No newline at end of file\n",
+		},
+		{
+			"unstage an addition without a newline", noEOL, 2, 2, true,
+			"@@ -1,1 +1,2 @@\n a\n+c\n\\ No newline at end of file\n",
+		},
+	} {
+		got := partialFragment(parseFragment(t, tc.hunk), tc.lo, tc.hi, tc.unstage).String()
+		if got != tc.want {
+			t.Errorf("%s: partialFragment =\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
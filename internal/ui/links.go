@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/arnavsurve/gd/pkg/render"
+)
+
+// ==================== Hyperlinks ====================
+
+var (
+	repoRootOnce sync.Once
+	repoRootDir  string
+)
+
+func repoRoot() string {
+	repoRootOnce.Do(func() {
+		out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+		if err == nil {
+			repoRootDir = strings.TrimSpace(string(out))
+		} else if wd, err := os.Getwd(); err == nil {
+			repoRootDir = wd
+		}
+	})
+	return repoRootDir
+}
+
+// hostname is looked up once, for every link made.
+var hostname = sync.OnceValue(func() string {
+	host, _ := os.Hostname()
+	return host
+})
+
+// fileURL returns a file:// URL for a repo-relative path, or "" when links
+// are off. The hostname is included so terminals can tell local files from
+// ones on a remote machine.
+func fileURL(path string) string {
+	if !flagLinks {
+		return ""
+	}
+	u := url.URL{Scheme: "file", Host: hostname(), Path: filepath.ToSlash(filepath.Join(repoRoot(), path))}
+	return u.String()
+}
+
+// hyperlink wraps text in an OSC 8 escape so supporting terminals make it
+// clickable, when links are on.
+func hyperlink(target, text string) string {
+	if !flagLinks {
+		return text
+	}
+	return render.Hyperlink(target, text)
+}
+
+// linkWeb has file headers and line numbers link to their pages on origin's
+// forge, on the checked-out branch. It's set when browsing the worktree or a
+// branch, whose lines are on the forge once pushed, rather than commits or a
+// patch.
+var linkWeb bool
+
+var (
+	webOnce   sync.Once
+	webForge  forge
+	webBranch string
+	webOK     bool
+)
+
+// webURL returns the forge page for line of path on the current branch, or
+// for the file itself for line 0. It's "" when links are off or origin isn't
+// a forge gd knows.
+func webURL(path string, line int) string {
+	if !linkWeb || !flagLinks {
+		return ""
+	}
+	webOnce.Do(func() {
+		f, err := originForge()
+		if err != nil {
+			return
+		}
+		webForge, webBranch, webOK = f, currentBranch(), true
+	})
+	if !webOK {
+		return ""
+	}
+	return webForge.blobURL(webBranch, path, line)
+}
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/arnavsurve/gd/pkg/render"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Lint Annotations ====================
+
+var (
+	lintMu      sync.RWMutex
+	lintResults map[string]render.Notes
+)
+
+// lintNotes returns the lint messages for path from the last run.
+func lintNotes(path string) render.Notes {
+	lintMu.RLock()
+	defer lintMu.RUnlock()
+	return lintResults[path]
+}
+
+type lintDoneMsg struct {
+	results map[string]render.Notes
+	count   int
+	err     error
+}
+
+// lintLineRe matches the path:line[:col]: message format that go vet,
+// golangci-lint, and eslint -f unix all print.
+var lintLineRe = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?:\s*(.+)$`)
+
+// lintCommand builds the command for --lint, substituting {files} and
+// {packages}. Without it, Go packages are checked with golangci-lint when
+// installed and go vet otherwise.
+func lintCommand(files []git.FileStatus) (*exec.Cmd, error) {
+	var paths, quoted []string
+	for _, f := range files {
+		paths = append(paths, scriptQuote(f.Path))
+	}
+	pkgs := testPackages(files)
+	for _, p := range pkgs {
+		quoted = append(quoted, scriptQuote(p))
+	}
+	script := flagLint
+	if script == "" {
+		if len(pkgs) == 0 {
+			return nil, fmt.Errorf("no changed Go packages; set --lint to check other files")
+		}
+		script = "go vet {packages}"
+		if _, err := exec.LookPath("golangci-lint"); err == nil {
+			script = "golangci-lint run {packages}"
+		}
+	}
+	script = strings.ReplaceAll(script, "{files}", strings.Join(paths, " "))
+	script = strings.ReplaceAll(script, "{packages}", strings.Join(quoted, " "))
+	c := shellCommand(script)
+	c.Dir = repoRoot()
+	return c, nil
+}
+
+// parseLint collects path:line messages from linter output, keyed by
+// repo-relative path.
+func parseLint(out string) (map[string]render.Notes, int) {
+	results := map[string]render.Notes{}
+	count := 0
+	for _, line := range strings.Split(out, "\n") {
+		m := lintLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		path := m[1]
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(repoRoot(), path)
+			if err != nil {
+				continue
+			}
+			path = rel
+		}
+		path = filepath.ToSlash(filepath.Clean(path))
+		n, _ := strconv.Atoi(m[2])
+		if results[path] == nil {
+			results[path] = render.Notes{}
+		}
+		results[path][n] = append(results[path][n], m[3])
+		count++
+	}
+	return results, count
+}
+
+func (m model) runLint() tea.Cmd {
+	c, err := lintCommand(m.files)
+	if err != nil {
+		return func() tea.Msg { return errorMsg{err: err} }
+	}
+	session.record("lint", "", c.Args[2])
+	return func() tea.Msg {
+		out, err := c.CombinedOutput()
+		results, count := parseLint(string(out))
+		if count > 0 {
+			err = nil
+		} else if err != nil {
+			err = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+		}
+		return lintDoneMsg{results: results, count: count, err: err}
+	}
+}
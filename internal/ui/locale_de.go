@@ -0,0 +1,253 @@
+package ui
+
+// catalogDE is the German translation. Answers to yes/no questions stay y
+// and N, which is what the prompts check for.
+var catalogDE = map[string]string{
+	// tree, footer, and status bar
+	"ours %s ◀ ▶ theirs %s":    "unsere %s ◀ ▶ ihre %s",
+	"Changed Files":            "Geänderte Dateien",
+	"Staged Changes":           "Vorgemerkte Änderungen",
+	"Unstaged Changes":         "Nicht vorgemerkte Änderungen",
+	"Untracked Files":          "Unversionierte Dateien",
+	"Changes vs %s":            "Änderungen gegenüber %s",
+	"Changes in %s":            "Änderungen in %s",
+	"commit %s":                "Commit %s",
+	"Commits (%d/%d)":          "Commits (%d/%d)",
+	"Loading...":               "Wird geladen …",
+	"reading git status…":      "git status wird gelesen …",
+	"/ search  ⏎ view  q quit": "/ suchen  ⏎ ansehen  q beenden",
+	"j/k scroll  h/l sideways  ^d/^u page  tab files": "j/k scrollen  h/l seitwärts  ^d/^u Seite  tab Dateien",
+	"n/N match  esc clear":                            "n/N Treffer  esc löschen",
+	"q back  / search  n/p hunk":                      "q zurück  / suchen  n/p Hunk",
+	"1 file":                                          "1 Datei",
+	"%d files":                                        "%d Dateien",
+	"folded: space opens a hunk, Z shows all":         "zugeklappt: Leertaste öffnet einen Hunk, Z zeigt alle",
+	"%d lines of context":                             "%d Kontextzeilen",
+	"1 line of context":                               "1 Kontextzeile",
+	"tree width %d, saved":                            "Baumbreite %d, gespeichert",
+	"side by side":                                    "nebeneinander",
+	"unified":                                         "einspaltig",
+	"blaming context and removed lines":               "Kontext- und entfernte Zeilen mit Blame",
+	"hiding blame":                                    "Blame ausgeblendet",
+	"blame is for the worktree or a range":            "Blame gibt es nur für den Arbeitsbaum oder einen Bereich",
+	"wrapping long lines":                             "lange Zeilen werden umbrochen",
+	"cutting long lines":                              "lange Zeilen werden abgeschnitten",
+	"enter shows the image":                           "Enter zeigt das Bild",
+	"old":                                             "alt",
+	"new":                                             "neu",
+	"press any key":                                   "beliebige Taste drücken",
+	"ignoring whitespace changes":                     "Leerraum-Änderungen werden ignoriert",
+	"showing whitespace changes":                      "Leerraum-Änderungen werden gezeigt",
+	"esc clear":                                       "esc löschen",
+	"worktree":                                        "Arbeitsverzeichnis",
+	"patch":                                           "Patch",
+	"1 stash":                                         "1 Stash",
+	"%d stashes":                                      "%d Stashes",
+	"Stashes (%d/%d)":                                 "Stashes (%d/%d)",
+	"a apply  P pop  d drop  ] [ stash":               "a anwenden  P pop  d löschen  ] [ Stash",
+	"No stashes.":                                     "Keine Stashes.",
+	"1 reflog entry":                                  "1 Reflog-Eintrag",
+	"%d reflog entries":                               "%d Reflog-Einträge",
+	"Reflog (%d/%d)":                                  "Reflog (%d/%d)",
+	"No reflog for %s.":                               "Kein Reflog für %s.",
+	"%d commits":                                      "%d Commits",
+	"refreshed":                                       "aktualisiert",
+	"No changes.":                                     "Keine Änderungen.",
+	"No changes: there are no commits yet, and no files to show. Add some and run gd again.": "Keine Änderungen: Es gibt noch keine Commits und keine Dateien. Lege welche an und starte gd erneut.",
+
+	// messages
+	"%d files marked":                                      "%d Dateien markiert",
+	"unmarked all files":                                   "alle Dateien entmarkiert",
+	"staged %d files":                                      "%d Dateien vorgemerkt",
+	"unstaged %d files":                                    "%d Dateien aus der Vormerkung genommen",
+	"discard all changes to %d files? (y/N): ":             "alle Änderungen an %d Dateien verwerfen? (y/N): ",
+	"discarded changes to %d files":                        "Änderungen an %d Dateien verworfen",
+	"only the worktree's changes can be discarded":         "nur Änderungen im Arbeitsbaum lassen sich verwerfen",
+	"mark files with x to discard their changes":           "markiere Dateien mit x, um ihre Änderungen zu verwerfen",
+	"an untracked file is discarded whole; mark it with x": "eine unversionierte Datei wird ganz verworfen; markiere sie mit x",
+	"the hunk is staged; unstage it first, with u":         "der Hunk ist vorgemerkt; nimm ihn zuerst mit u heraus",
+	"can't discard with whitespace hidden; I shows it":     "bei ausgeblendeten Leerzeichen lässt sich nichts verwerfen; I zeigt sie",
+	"discard this hunk from the worktree? (y/N): ":         "diesen Hunk im Arbeitsbaum verwerfen? (y/N): ",
+	"discarded hunk":                                       "Hunk verworfen",
+	"only the worktree's changes can be narrowed":          "nur die Änderungen im Arbeitsbaum lassen sich eingrenzen",
+	"wrote %s":                             "%s geschrieben",
+	"wrote %d files to %s":                 "%d Dateien nach %s geschrieben",
+	"wrote %d hunks to %s":                 "%d Hunks nach %s geschrieben",
+	"marked hunk (%d marked, X to export)": "Hunk markiert (%d markiert, X zum Exportieren)",
+	"unmarked hunk (%d marked)":            "Markierung entfernt (%d markiert)",
+	"no matches for %s":                    "keine Treffer für %s",
+	"loading %s":                           "lade %s",
+	"searching…":                           "suche…",
+	"match %d of %d":                       "Treffer %d von %d",
+	"applied %s":                           "%s angewendet",
+	"popped %s":                            "%s angewendet und entfernt",
+	"dropped %s":                           "%s gelöscht",
+	"no stashes left":                      "keine Stashes mehr",
+	"nothing in the worktree to stash":     "nichts im Arbeitsverzeichnis zum Stashen",
+	"stashed changes":                      "Änderungen gestasht",
+	"no conflict here":                     "hier ist kein Konflikt",
+	"took %s in %s, %d conflicts left":     "%s in %s übernommen, %d Konflikte übrig",
+	"took %s in %s; no conflicts left, git add it to mark it resolved": "%s in %s übernommen; keine Konflikte mehr, mit git add als gelöst markieren",
+	"no hunks marked":                            "keine Hunks markiert",
+	"no hunk to copy":                            "kein Hunk zum Kopieren",
+	"no hunk to pass":                            "kein Hunk zum Übergeben",
+	"no hunk to comment on":                      "kein Hunk zum Kommentieren",
+	"no hunk in the worktree to inspect":         "kein Hunk im Arbeitsverzeichnis zum Untersuchen",
+	"no file selected":                           "keine Datei ausgewählt",
+	"no issue reference found":                   "kein Issue-Verweis gefunden",
+	"no symbols on the added lines":              "keine Symbole in den hinzugefügten Zeilen",
+	"no CODEOWNERS file":                         "keine CODEOWNERS-Datei",
+	"no HEAD commit":                             "kein HEAD-Commit",
+	"copied path":                                "Pfad kopiert",
+	"copied hunk":                                "Hunk kopiert",
+	"copied diff":                                "Diff kopiert",
+	"copied link to %s":                          "Link zu %s kopiert",
+	"copied permalink":                           "Permalink kopiert",
+	"copy failed":                                "Kopieren fehlgeschlagen",
+	"opened %s":                                  "%s geöffnet",
+	"opened in tmux %s":                          "in tmux %s geöffnet",
+	"commit has no sha":                          "Commit hat keinen SHA",
+	"format-patch needs a commit list or --main": "format-patch braucht eine Commit-Liste oder --main",
+	"format-patch is for commits, not stashes or the reflog": "format-patch ist für Commits, nicht für Stashes oder das Reflog",
+	"range start set, move with ] [ then F":                  "Bereichsanfang gesetzt, mit ] [ bewegen, dann F",
+	"yank: p path  h hunk  d diff  i issue link":             "kopieren: p Pfad  h Hunk  d Diff  i Issue-Link",
+	"linting…":                 "Lint läuft …",
+	"running %s":               "%s läuft",
+	"tests passed":             "Tests bestanden",
+	"tests failed (T to view)": "Tests fehlgeschlagen (T zum Ansehen)",
+	"difftool needs a file in the repository":                "difftool braucht eine Datei im Repository",
+	"fixups need a change to a tracked file in the worktree": "Fixups brauchen eine Änderung an einer versionierten Datei im Arbeitsverzeichnis",
+	"start gd with --lsp, e.g. --lsp gopls":                  "gd mit --lsp starten, z. B. --lsp gopls",
+	"saved comment (%d pending)":                             "Kommentar gespeichert (%d ausstehend)",
+	"(no output)":                                            "(keine Ausgabe)",
+
+	"staged hunk":                                    "Hunk vorgemerkt",
+	"unstaged hunk":                                  "Hunk nicht mehr vorgemerkt",
+	"hunk is already staged":                         "Hunk ist schon vorgemerkt",
+	"hunk isn't staged":                              "Hunk ist nicht vorgemerkt",
+	"staging needs a change in the worktree":         "Vormerken braucht eine Änderung im Arbeitsverzeichnis",
+	"can't stage with whitespace hidden; I shows it": "Vormerken geht nicht, solange Leerraum verborgen ist; I zeigt ihn",
+	"staged %d lines":                                "%d Zeilen vorgemerkt",
+	"staged 1 line":                                  "1 Zeile vorgemerkt",
+	"unstaged %d lines":                              "%d Zeilen nicht mehr vorgemerkt",
+	"scrolled %d columns":                            "um %d Spalten verschoben",
+	"UNSTAGED":                                       "NICHT VORGEMERKT",
+	"STAGED":                                         "VORGEMERKT",
+	"only the worktree or a range can be compared with another branch": "nur der Arbeitsbaum oder ein Bereich lässt sich mit einem anderen Branch vergleichen",
+	"(worktree)":             "(Arbeitsbaum)",
+	"comparing the worktree": "vergleiche den Arbeitsbaum",
+	"comparing against %s":   "vergleiche mit %s",
+	"no matching refs":       "keine passenden Refs",
+	"Compare against: ":      "Vergleichen mit: ",
+	"type to narrow  ↑/↓ move  ⏎ compare  esc cancel": "tippen zum Filtern  ↑/↓ bewegen  ⏎ vergleichen  esc abbrechen",
+	"Diffstat":                                  "Änderungsstatistik",
+	"merge conflict marker":                     "Konfliktmarkierung",
+	"debug leftover: %s":                        "Debug-Überbleibsel: %s",
+	"%s left in":                                "%s übrig geblieben",
+	"unstaged 1 line":                           "1 Zeile nicht mehr vorgemerkt",
+	"lines are already staged":                  "Zeilen sind schon vorgemerkt",
+	"lines aren't staged":                       "Zeilen sind nicht vorgemerkt",
+	"no changed lines picked":                   "keine geänderten Zeilen ausgewählt",
+	"an untracked file is staged whole, with s": "eine unversionierte Datei wird ganz vorgemerkt, mit s",
+	"open the hunk first, with space":           "erst den Hunk öffnen, mit Leertaste",
+	"lines are picked in the unified layout; | switches to it": "Zeilen werden in der einspaltigen Ansicht ausgewählt; | wechselt dorthin",
+	"j/k lines  s stage  u unstage  esc cancel":                "j/k Zeilen  s vormerken  u zurücknehmen  esc abbrechen",
+
+	"commits are made from the worktree":         "Commits entstehen im Arbeitsverzeichnis",
+	"nothing staged to commit (s stages a hunk)": "nichts zum Committen vorgemerkt (s merkt einen Hunk vor)",
+	"committed":                       "committet",
+	"committed %s":                    "%s committet",
+	"committed fixup! %s":             "fixup! %s committet",
+	"%s moved at %s":                  "%s hat sich um %s bewegt",
+	"%d review threads (# to expand)": "%d Review-Threads (# klappt sie auf)",
+	"%d lint warnings":                "%d Lint-Warnungen",
+
+	// prompts
+	"stash message (empty for default): ": "Stash-Nachricht (leer für Standard): ",
+	"apply %s? (y/N): ":                   "%s anwenden? (y/N): ",
+	"pop %s? (y/N): ":                     "%s anwenden und entfernen? (y/N): ",
+	"drop %s? (y/N): ":                    "%s löschen? (y/N): ",
+	"search diff: ":                       "Diff durchsuchen: ",
+	"commit message (empty for editor): ": "Commit-Nachricht (leer für Editor): ",
+	"export to: ":                         "exportieren nach: ",
+	"export hunks to: ":                   "Hunks exportieren nach: ",
+	"format-patch to: ":                   "format-patch nach: ",
+	"cover letter? (y/N): ":               "Anschreiben? (y/N): ",
+	"fixup! %s %s? (y/N): ":               "fixup! %s %s? (y/N): ",
+	"comment on %s:%d: ":                  "Kommentar zu %s:%d: ",
+
+	// panels
+	"CI":                      "CI",
+	"No workflow runs for %s": "Keine Workflow-Läufe für %s",
+	"Nothing has failed.":     "Nichts ist fehlgeschlagen.",
+	"Keys":                    "Tasten",
+	"Open Pull Requests":      "Offene Pull Requests",
+	"Press a on a hunk or M on a file to add one.": "Drücke a auf einem Hunk oder M auf einer Datei, um einen hinzuzufügen.",
+	"Pull request #%d": "Pull-Request #%d",
+	"submit review: c comment, a approve, r request changes: ": "Review abschicken: c kommentieren, a genehmigen, r Änderungen anfordern: ",
+	"review text: ": "Review-Text: ",
+	"submitted review with %d comments to #%d": "Review mit %d Kommentaren an #%d geschickt",
+	"note on %s: ":        "Notiz zu %s: ",
+	"%s viewed, %d of %d": "%s angesehen, %d von %d",
+	"%s not viewed":       "%s nicht angesehen",
+	"viewed marks are for the worktree or --main": "Angesehen-Markierungen gibt es nur für den Arbeitsbaum oder --main",
+	"Submit with: gd review submit":               "Absenden mit: gd review submit",
+	"Tests: %s":                                   "Tests: %s",
+	"passed":                                      "bestanden",
+	"failed":                                      "fehlgeschlagen",
+
+	// diff preview
+	"── … %s more lines, %s in all — press L to load them": "── … %s weitere Zeilen, %s insgesamt — L lädt sie",
+	"symlink to %s deleted":          "Symlink auf %s gelöscht",
+	"symlink → %s":                   "Symlink → %s",
+	"symlink → %s, was %s":           "Symlink → %s, war %s",
+	"mode %o → %o":                   "Modus %o → %o",
+	"file deleted, %d lines removed": "Datei gelöscht, %d Zeilen entfernt",
+	"file deleted, 1 line removed":   "Datei gelöscht, 1 Zeile entfernt",
+	"empty file deleted":             "leere Datei gelöscht",
+
+	// accessible mode
+	"change at line %d":         "Änderung ab Zeile %d",
+	", in %s":                   ", in %s",
+	"removed: ":                 "entfernt: ",
+	"added: ":                   "hinzugefügt: ",
+	"added, not run by tests: ": "hinzugefügt, nicht von Tests ausgeführt: ",
+	"file %d of %d: %s":         "Datei %d von %d: %s",
+	"folder %s":                 "Ordner %s",
+	"folder %s, collapsed":      "Ordner %s, zugeklappt",
+	"no files":                  "keine Dateien",
+	"untracked":                 "unversioniert",
+	"staged":                    "vorgemerkt",
+	"modified":                  "geändert",
+	"staged and modified":       "vorgemerkt und geändert",
+	"renamed from %s":           "umbenannt von %s",
+	"binary":                    "binär",
+	"%d added, %d removed":      "%d hinzugefügt, %d entfernt",
+
+	// base branch
+	"no default branch; comparing against the rebase's base %s": "kein Standard-Branch; Vergleich mit der Basis des Rebase %s",
+	"No branch %s: compare against":                             "Kein Branch %s: vergleichen mit",
+	"⏎ compare  q quit":                                         "⏎ vergleichen  q beenden",
+	"detached at %s":                                            "losgelöst bei %s",
+	"gd: %s isn't a branch or commit to compare against.\n\nPick one with --base <ref>": "gd: %s ist kein Branch oder Commit zum Vergleichen.\n\nWähle einen mit --base <ref>",
+	", e.g.:": ", z. B.:",
+
+	// startup
+	notRepoHelp: `gd: nicht in einem Git-Repository
+
+gd zeigt die Änderungen in einem Git-Arbeitsverzeichnis. Um dieses
+Verzeichnis zu versionieren:
+
+    git init
+
+Um zwei Verzeichnisse außerhalb von Git zu vergleichen:
+
+    git diff --no-index <verz1> <verz2>
+`,
+	noCommitsHelp: `gd: noch keine Commits
+
+--main vergleicht Branches mit Commits, und dieses Repository hat keine.
+Starte gd ohne --main, um zu sehen, was vorgemerkt und unversioniert ist,
+also bisher alles.
+`,
+}
@@ -0,0 +1,407 @@
+package ui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Language Server ====================
+
+// lspClient speaks just enough JSON-RPC to ask a language server about
+// symbols. Requests are serialized; the server runs until gd exits.
+type lspClient struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Reader
+	nextID int
+	opened map[string]bool
+}
+
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Params json.RawMessage  `json:"params,omitempty"`
+	Result json.RawMessage  `json:"result,omitempty"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspLocation struct {
+	URI   string `json:"uri"`
+	Range struct {
+		Start lspPosition `json:"start"`
+	} `json:"range"`
+}
+
+var (
+	lspMu   sync.Mutex
+	lspConn *lspClient
+)
+
+// languageServer starts the --lsp server on first use.
+func languageServer() (*lspClient, error) {
+	lspMu.Lock()
+	defer lspMu.Unlock()
+	if lspConn != nil {
+		return lspConn, nil
+	}
+	fields := strings.Fields(flagLSP)
+	c := exec.Command(fields[0], fields[1:]...)
+	c.Dir = repoRoot()
+	in, err := c.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", fields[0], err)
+	}
+	l := &lspClient{cmd: c, in: in, out: bufio.NewReader(out), opened: map[string]bool{}}
+
+	root := pathURI(repoRoot())
+	init := map[string]any{
+		"processId":    os.Getpid(),
+		"rootUri":      root,
+		"capabilities": map[string]any{},
+		"workspaceFolders": []map[string]string{
+			{"uri": root, "name": filepath.Base(repoRoot())},
+		},
+	}
+	if _, err := l.call("initialize", init); err != nil {
+		c.Process.Kill()
+		return nil, err
+	}
+	if err := l.send(lspMessage{Method: "initialized", Params: json.RawMessage("{}")}); err != nil {
+		c.Process.Kill()
+		return nil, err
+	}
+	lspConn = l
+	return l, nil
+}
+
+// stopLanguageServer asks a running server to exit.
+func stopLanguageServer() {
+	lspMu.Lock()
+	defer lspMu.Unlock()
+	if lspConn == nil {
+		return
+	}
+	lspConn.call("shutdown", nil)
+	lspConn.send(lspMessage{Method: "exit"})
+	lspConn.in.Close()
+	lspConn.cmd.Wait()
+	lspConn = nil
+}
+
+func pathURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func (l *lspClient) send(msg lspMessage) error {
+	body, err := json.Marshal(struct {
+		JSONRPC string `json:"jsonrpc"`
+		lspMessage
+	}{"2.0", msg})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(l.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (l *lspClient) read() (lspMessage, error) {
+	var msg lspMessage
+	length := -1
+	for {
+		line, err := l.out.ReadString('\n')
+		if err != nil {
+			return msg, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+			length, _ = strconv.Atoi(strings.TrimSpace(v))
+		}
+	}
+	if length < 0 {
+		return msg, fmt.Errorf("language server sent no Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(l.out, body); err != nil {
+		return msg, err
+	}
+	return msg, json.Unmarshal(body, &msg)
+}
+
+// call sends a request and waits for its response, answering any requests
+// the server makes in the meantime.
+func (l *lspClient) call(method string, params any) (json.RawMessage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nextID++
+	id := json.RawMessage(strconv.Itoa(l.nextID))
+	p, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.send(lspMessage{ID: &id, Method: method, Params: p}); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := l.read()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		switch {
+		case msg.ID != nil && msg.Method != "":
+			l.reply(msg)
+		case msg.ID != nil && string(*msg.ID) == string(id):
+			if msg.Error != nil {
+				return nil, fmt.Errorf("%s: %s", method, msg.Error.Message)
+			}
+			return msg.Result, nil
+		}
+	}
+}
+
+// reply answers a server-initiated request. Configuration requests get one
+// empty setting per item; everything else gets null.
+func (l *lspClient) reply(req lspMessage) {
+	result := json.RawMessage("null")
+	if req.Method == "workspace/configuration" {
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(req.Params, &p)
+		nulls := make([]json.RawMessage, len(p.Items))
+		for i := range nulls {
+			nulls[i] = json.RawMessage("null")
+		}
+		result, _ = json.Marshal(nulls)
+	}
+	l.send(lspMessage{ID: req.ID, Result: result})
+}
+
+func (l *lspClient) open(path string) (string, error) {
+	uri := pathURI(filepath.Join(repoRoot(), path))
+	l.mu.Lock()
+	opened := l.opened[uri]
+	l.opened[uri] = true
+	l.mu.Unlock()
+	if opened {
+		return uri, nil
+	}
+	text, err := newSideText(path)
+	if err != nil {
+		return "", err
+	}
+	lang := "plaintext"
+	if lexer := lexers.Match(path); lexer != nil && len(lexer.Config().Aliases) > 0 {
+		lang = lexer.Config().Aliases[0]
+	}
+	params, _ := json.Marshal(map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": lang, "version": 1, "text": string(text)},
+	})
+	return uri, l.send(lspMessage{Method: "textDocument/didOpen", Params: params})
+}
+
+// ==================== Symbol Context ====================
+
+type symbolRef struct {
+	name string
+	pos  lspPosition
+}
+
+// changedSymbols returns the distinct identifiers on the added lines of frag,
+// with their positions in the new file.
+func changedSymbols(path string, frag *gitdiff.TextFragment, limit int) []symbolRef {
+	lexer := lexers.Match(path)
+	if lexer == nil {
+		return nil
+	}
+	var syms []symbolRef
+	seen := map[string]bool{}
+	line := int(frag.NewPosition) - 1
+	for _, l := range frag.Lines {
+		if l.Op == gitdiff.OpDelete {
+			continue
+		}
+		if l.Op == gitdiff.OpAdd {
+			iter, err := lexer.Tokenise(nil, trimLine(l.Line))
+			if err != nil {
+				continue
+			}
+			col := 0
+			for _, tok := range iter.Tokens() {
+				if tok.Type.InCategory(chroma.Name) && !seen[tok.Value] && len(syms) < limit {
+					seen[tok.Value] = true
+					syms = append(syms, symbolRef{name: tok.Value, pos: lspPosition{Line: line, Character: col}})
+				}
+				col += len(utf16.Encode([]rune(tok.Value)))
+			}
+		}
+		line++
+	}
+	return syms
+}
+
+// hoverText pulls the text out of the several shapes a hover result can take.
+func hoverText(raw json.RawMessage) string {
+	var h struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if json.Unmarshal(raw, &h) != nil || len(h.Contents) == 0 {
+		return ""
+	}
+	var markup struct {
+		Value string `json:"value"`
+	}
+	var s string
+	var list []json.RawMessage
+	switch {
+	case json.Unmarshal(h.Contents, &markup) == nil && markup.Value != "":
+		s = markup.Value
+	case json.Unmarshal(h.Contents, &s) == nil:
+	case json.Unmarshal(h.Contents, &list) == nil && len(list) > 0:
+		return hoverText([]byte(`{"contents":` + string(list[0]) + `}`))
+	}
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(line, "```") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// definitionLocation reads a Location, Location[], or LocationLink[] result.
+func definitionLocation(raw json.RawMessage) (lspLocation, bool) {
+	var one lspLocation
+	if json.Unmarshal(raw, &one) == nil && one.URI != "" {
+		return one, true
+	}
+	var many []json.RawMessage
+	if json.Unmarshal(raw, &many) != nil || len(many) == 0 {
+		return one, false
+	}
+	if json.Unmarshal(many[0], &one) == nil && one.URI != "" {
+		return one, true
+	}
+	var link struct {
+		TargetURI   string `json:"targetUri"`
+		TargetRange struct {
+			Start lspPosition `json:"start"`
+		} `json:"targetRange"`
+	}
+	if json.Unmarshal(many[0], &link) == nil && link.TargetURI != "" {
+		one.URI, one.Range.Start = link.TargetURI, link.TargetRange.Start
+		return one, true
+	}
+	return one, false
+}
+
+// displayPath shows a file URI relative to the repo when it's inside it.
+func displayPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	if rel, err := filepath.Rel(repoRoot(), filepath.FromSlash(u.Path)); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return u.Path
+}
+
+// showSymbols replaces the preview with hover text, definition, and
+// reference counts for the symbols on the current hunk's added lines.
+func (m model) showSymbols() tea.Cmd {
+	if flagLSP == "" {
+		return func() tea.Msg { return statusMsg{text: tr("start gd with --lsp, e.g. --lsp gopls")} }
+	}
+	f := m.selectedFile()
+	hunk := m.currentHunk()
+	if f == nil || hunk == nil || f.Diff != "" {
+		return func() tea.Msg { return statusMsg{text: tr("no hunk in the worktree to inspect")} }
+	}
+	path := f.Path
+	session.record("symbols", path, hunk.Header())
+	return func() tea.Msg {
+		syms := changedSymbols(path, hunk, 8)
+		if len(syms) == 0 {
+			return statusMsg{text: tr("no symbols on the added lines")}
+		}
+		l, err := languageServer()
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("lsp: %w", err)}
+		}
+		uri, err := l.open(path)
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("lsp: %w", err)}
+		}
+
+		var b strings.Builder
+		b.WriteString(titleSty.Render(fmt.Sprintf("Symbols in %s %s", path, hunk.Header())))
+		b.WriteString("\n\n")
+		for _, s := range syms {
+			at := map[string]any{"textDocument": map[string]string{"uri": uri}, "position": s.pos}
+			b.WriteString(fileHdrSty.Render(s.name))
+
+			if raw, err := l.call("textDocument/definition", at); err == nil {
+				if loc, ok := definitionLocation(raw); ok {
+					b.WriteString(ctxDimSty.Render(fmt.Sprintf("  defined at %s:%d", displayPath(loc.URI), loc.Range.Start.Line+1)))
+				}
+			}
+			refs := map[string]any{"textDocument": at["textDocument"], "position": s.pos, "context": map[string]bool{"includeDeclaration": false}}
+			if raw, err := l.call("textDocument/references", refs); err == nil {
+				var locs []lspLocation
+				if json.Unmarshal(raw, &locs) == nil {
+					b.WriteString(hunkHdrSty.Render(fmt.Sprintf("  %d references", len(locs))))
+				}
+			}
+			b.WriteByte('\n')
+
+			if raw, err := l.call("textDocument/hover", at); err == nil {
+				lines := strings.Split(hoverText(raw), "\n")
+				if len(lines) > 6 {
+					lines = append(lines[:6], "…")
+				}
+				for _, line := range lines {
+					if line != "" {
+						b.WriteString("  " + line + "\n")
+					}
+				}
+			} else {
+				b.WriteString(ctxDimSty.Render("  "+err.Error()) + "\n")
+			}
+			b.WriteByte('\n')
+		}
+		return diffLoadedMsg{content: b.String()}
+	}
+}
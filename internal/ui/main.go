@@ -0,0 +1,3040 @@
+// Package ui is gd itself: its flags and config, the file tree and diff
+// preview it draws, and the git commands its keys run.
+package ui
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/arnavsurve/gd/internal/tree"
+	"github.com/arnavsurve/gd/pkg/render"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+var (
+	flagMain       bool
+	flagJSON       bool
+	flagCheck      bool
+	flagStat       bool
+	flagLinks      bool
+	flagOutput     string
+	flagPrint      bool
+	flagByCommit   bool
+	flagSessionLog string
+	flagDifftool   string
+	flagAgainst    string
+	flagBase       string
+	flagPatch      string
+	flagNoIndex    bool
+	flagStash      bool
+	flagReflog     bool
+	flagTmux       string
+	flagIssueURL   string
+	flagLSP        string
+	flagTestCmd    string
+	flagLint       string
+	flagCover      string
+	flagCI         bool
+	flagWatch      bool
+	flagMaxPreview int
+	flagStaged     bool
+	flagUnstaged   bool
+	flagUntracked  bool
+
+	flagCPUProfile   string
+	flagMemProfile   string
+	flagTraceTimings string
+	flagScript       string
+	flagCacheMB      int
+	flagCacheEntries int
+	flagPoll         time.Duration
+	flagFPS          int
+	flagAccessible   bool
+	flagTheme        string
+	flagSyntaxTheme  string
+	flagPager        string
+	flagNoColor      bool
+	flagUnified      bool
+	flagSplit        bool
+	flagTabWidth     int
+	flagImages       string
+
+	// flagShowWhitespace marks tabs and trailing spaces, set by the flag or
+	// show_whitespace in the config file
+	flagShowWhitespace bool
+
+	// flagMaxPreviewLines cuts previews longer than it, set by the flag or
+	// max_preview_lines in the config file
+	flagMaxPreviewLines int
+
+	flagIgnoreAllSpace   bool
+	flagIgnoreBlankLines bool
+	flagIgnoreSpaceAtEOL bool
+)
+
+// baseRef is the branch compared against in --main mode: the default branch
+// unless one is named, see resolveBase.
+var baseRef = "main"
+
+// headRef is the side of --main mode being reviewed, compared against its
+// merge base with baseRef.
+var headRef = "HEAD"
+
+// pathspecs limits which files are listed, from arguments after the flags.
+var pathspecs []string
+
+// sideBySideMinWidth is the narrowest preview shown side by side, unless the
+// config file sets side_by_side_width.
+var sideBySideMinWidth = 120
+
+// Diff layouts: by width, or forced with --unified, --split, or |.
+const (
+	layoutAuto int32 = iota
+	layoutUnified
+	layoutSplit
+)
+
+// diffLayout is read by renders running off the UI goroutine.
+var diffLayout atomic.Int32
+
+// sideBySide reports whether a diff width columns wide is drawn side by side.
+func sideBySide(width int) bool {
+	switch diffLayout.Load() {
+	case layoutUnified:
+		return false
+	case layoutSplit:
+		return true
+	}
+	return width >= sideBySideMinWidth
+}
+
+// ==================== Color Palette ====================
+
+type palette struct {
+	bgAdd      string
+	bgDel      string
+	bgAddWord  string // changed words within added and removed lines
+	bgDelWord  string
+	lineNum    string
+	hunkHdr    string
+	fileHdr    string
+	gutter     string
+	addInd     string
+	delInd     string
+	ctxDim     string
+	truncate   string
+	dir        string
+	file       string
+	cursorFg   string
+	cursorBg   string
+	staged     string
+	unstaged   string
+	untracked  string
+	border     string
+	search     string
+	title      string
+	background string
+	chromaStyle string
+	strong     bool // bold indicators and no faint text
+}
+
+var darkPalette = palette{
+	bgAdd:      "#122117",
+	bgDel:      "#2d1117",
+	bgAddWord:  "#1f4a2b",
+	bgDelWord:  "#5c1e26",
+	lineNum:    "#484f58",
+	hunkHdr:    "#79c0ff",
+	fileHdr:    "#e6edf3",
+	gutter:     "#30363d",
+	addInd:     "#3fb950",
+	delInd:     "#f85149",
+	ctxDim:     "#8b949e",
+	truncate:   "#484f58",
+	dir:        "#79c0ff",
+	file:       "#e6edf3",
+	cursorFg:   "#e6edf3",
+	cursorBg:   "#30363d",
+	staged:     "#3fb950",
+	unstaged:   "#d29922",
+	untracked:  "#484f58",
+	border:     "#30363d",
+	search:     "#79c0ff",
+	title:      "#e6edf3",
+	background: "#0d1117",
+	chromaStyle: "monokai",
+}
+
+var lightPalette = palette{
+	bgAdd:      "#dafbe1",
+	bgDel:      "#ffebe9",
+	bgAddWord:  "#aceebb",
+	bgDelWord:  "#ffcecb",
+	lineNum:    "#57606a",
+	hunkHdr:    "#0969da",
+	fileHdr:    "#1f2328",
+	gutter:     "#d0d7de",
+	addInd:     "#1a7f37",
+	delInd:     "#cf222e",
+	ctxDim:     "#656d76",
+	truncate:   "#57606a",
+	dir:        "#0969da",
+	file:       "#1f2328",
+	cursorFg:   "#1f2328",
+	cursorBg:   "#ddf4ff",
+	staged:     "#1a7f37",
+	unstaged:   "#9a6700",
+	untracked:  "#57606a",
+	border:     "#d0d7de",
+	search:     "#0969da",
+	title:      "#1f2328",
+	background: "#ffffff",
+	chromaStyle: "github",
+}
+
+// highContrastPalette is for low vision and washed-out projectors: a black
+// background, white text, and saturated colors that don't rely on shade.
+var highContrastPalette = palette{
+	bgAdd:      "#003800",
+	bgDel:      "#500000",
+	bgAddWord:  "#007a00",
+	bgDelWord:  "#a00000",
+	lineNum:    "#ffffff",
+	hunkHdr:    "#00ffff",
+	fileHdr:    "#ffffff",
+	gutter:     "#ffffff",
+	addInd:     "#00ff00",
+	delInd:     "#ff0000",
+	ctxDim:     "#ffffff",
+	truncate:   "#ffff00",
+	dir:        "#00ffff",
+	file:       "#ffffff",
+	cursorFg:   "#000000",
+	cursorBg:   "#ffff00",
+	staged:     "#00ff00",
+	unstaged:   "#ffff00",
+	untracked:  "#ff80ff",
+	border:     "#ffffff",
+	search:     "#ffff00",
+	title:      "#ffffff",
+	background: "#000000",
+	chromaStyle: "modus-vivendi",
+	strong:     true,
+}
+
+var solarizedPalette = palette{
+	bgAdd:      "#0f3b2c",
+	bgDel:      "#3b1f2b",
+	bgAddWord:  "#1d5a3a",
+	bgDelWord:  "#6a2a35",
+	lineNum:    "#586e75",
+	hunkHdr:    "#268bd2",
+	fileHdr:    "#93a1a1",
+	gutter:     "#073642",
+	addInd:     "#859900",
+	delInd:     "#dc322f",
+	ctxDim:     "#839496",
+	truncate:   "#586e75",
+	dir:        "#268bd2",
+	file:       "#93a1a1",
+	cursorFg:   "#93a1a1",
+	cursorBg:   "#073642",
+	staged:     "#859900",
+	unstaged:   "#b58900",
+	untracked:  "#586e75",
+	border:     "#073642",
+	search:     "#2aa198",
+	title:      "#93a1a1",
+	background: "#002b36",
+	chromaStyle: "solarized-dark",
+}
+
+var gruvboxPalette = palette{
+	bgAdd:      "#32361a",
+	bgDel:      "#3c1f1e",
+	bgAddWord:  "#4a5a1e",
+	bgDelWord:  "#6a2a26",
+	lineNum:    "#7c6f64",
+	hunkHdr:    "#83a598",
+	fileHdr:    "#ebdbb2",
+	gutter:     "#3c3836",
+	addInd:     "#b8bb26",
+	delInd:     "#fb4934",
+	ctxDim:     "#a89984",
+	truncate:   "#7c6f64",
+	dir:        "#83a598",
+	file:       "#ebdbb2",
+	cursorFg:   "#ebdbb2",
+	cursorBg:   "#504945",
+	staged:     "#b8bb26",
+	unstaged:   "#fabd2f",
+	untracked:  "#928374",
+	border:     "#3c3836",
+	search:     "#8ec07c",
+	title:      "#ebdbb2",
+	background: "#282828",
+	chromaStyle: "gruvbox",
+}
+
+// fitBackgrounds swaps the diff backgrounds for ones a 256 or 16 color
+// terminal can show. The faint truecolor shades would otherwise round to
+// grays that make added and removed lines look alike. Colors a theme gives
+// as color numbers are left as they are.
+func (p *palette) fitBackgrounds(profile termenv.Profile) {
+	if !strings.HasPrefix(p.bgAdd, "#") {
+		return
+	}
+	var add, del, addWord, delWord string
+	switch {
+	case profile == termenv.ANSI:
+		// no shade is faint enough for a whole line; changed words still get one
+		add, del, addWord, delWord = "", "", "2", "1"
+	case isDark(p.background):
+		add, del, addWord, delWord = "22", "52", "28", "88"
+	default:
+		add, del, addWord, delWord = "194", "224", "157", "217"
+	}
+	p.bgAdd, p.bgDel, p.bgAddWord, p.bgDelWord = add, del, addWord, delWord
+}
+
+// isDark reports whether the #rrggbb color hex is closer to black than white.
+func isDark(hex string) bool {
+	var r, g, b int
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return true
+	}
+	return r*299+g*587+b*114 < 128*1000
+}
+
+// noColor is set when styles are drawn without color or attributes, from
+// NO_COLOR, --no-color, or output that isn't a terminal.
+var noColor bool
+
+// themes are the palettes --theme can name, along with any [theme.name]
+// tables in the config file; without one, dark or light is picked to match
+// the terminal's background.
+var themes = map[string]palette{
+	"dark":          darkPalette,
+	"light":         lightPalette,
+	"solarized":     solarizedPalette,
+	"gruvbox":       gruvboxPalette,
+	"high-contrast": highContrastPalette,
+}
+
+// themeNames lists the themes for help and errors.
+func themeNames() string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names[:len(names)-1], ", ") + ", or " + names[len(names)-1]
+}
+
+// Active palette and styles, set in init()
+var pal palette
+
+var (
+	lineNumSty lipgloss.Style
+	hunkHdrSty lipgloss.Style
+	fileHdrSty lipgloss.Style
+	gutterSty  lipgloss.Style
+	addIndSty  lipgloss.Style
+	delIndSty  lipgloss.Style
+	ctxDimSty  lipgloss.Style
+	dirSty     lipgloss.Style
+	fileSty    lipgloss.Style
+	cursorSty  lipgloss.Style
+	stagedBadge lipgloss.Style
+	unstBadge  lipgloss.Style
+	untrkBadge lipgloss.Style
+	borderSty  lipgloss.Style
+	searchSty  lipgloss.Style
+	titleSty   lipgloss.Style
+	noteSty    lipgloss.Style
+)
+
+func initTheme() {
+	if p, ok := themes[flagTheme]; ok {
+		pal = p
+	} else if termenv.HasDarkBackground() {
+		pal = darkPalette
+	} else {
+		pal = lightPalette
+	}
+	if configChroma != "" {
+		pal.chromaStyle = configChroma
+	}
+	if flagSyntaxTheme != "" {
+		pal.chromaStyle = flagSyntaxTheme
+	}
+	profile := lipgloss.ColorProfile()
+	if profile == termenv.ANSI256 || profile == termenv.ANSI {
+		pal.fitBackgrounds(profile)
+	}
+	noColor = profile == termenv.Ascii
+
+	lineNumSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.lineNum))
+	hunkHdrSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.hunkHdr)).Faint(!pal.strong)
+	fileHdrSty = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(pal.fileHdr))
+	gutterSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.gutter))
+	addIndSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.addInd)).Bold(pal.strong)
+	delIndSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.delInd)).Bold(pal.strong)
+	ctxDimSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.ctxDim))
+	dirSty = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(pal.dir))
+	fileSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.file))
+	cursorSty = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(pal.cursorFg)).Background(lipgloss.Color(pal.cursorBg))
+	stagedBadge = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.staged))
+	unstBadge = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.unstaged))
+	untrkBadge = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.untracked))
+	borderSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.border))
+	searchSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.search))
+	titleSty = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(pal.title))
+	noteSty = lipgloss.NewStyle().Foreground(lipgloss.Color(pal.unstaged))
+
+	diffTheme = &render.Theme{
+		Chroma:     pal.chromaStyle,
+		Truncate:   pal.truncate,
+		AddBg:      pal.bgAdd,
+		DelBg:      pal.bgDel,
+		AddWordBg:  pal.bgAddWord,
+		DelWordBg:  pal.bgDelWord,
+		LineNum:    lineNumSty,
+		Gutter:     gutterSty,
+		Added:      addIndSty,
+		Removed:    delIndSty,
+		Note:       noteSty,
+		FileHeader: fileHdrSty,
+		HunkHeader: hunkHdrSty,
+		File:       fileSty,
+		Dim:        ctxDimSty,
+	}
+}
+
+// ==================== Git Operations ====================
+
+// spaceOpts are the git diff options that hide whitespace changes, from -w,
+// --ignore-blank-lines, and --ignore-space-at-eol, or -w alone when none was
+// given. ignoreSpace turns them on and off; I flips it while previews render.
+var (
+	spaceOpts   = []string{"--ignore-all-space"}
+	ignoreSpace atomic.Bool
+)
+
+// setSpaceOpts applies the whitespace flags.
+func setSpaceOpts() {
+	var opts []string
+	if flagIgnoreAllSpace {
+		opts = append(opts, "--ignore-all-space")
+	}
+	if flagIgnoreBlankLines {
+		opts = append(opts, "--ignore-blank-lines")
+	}
+	if flagIgnoreSpaceAtEOL {
+		opts = append(opts, "--ignore-space-at-eol")
+	}
+	if len(opts) > 0 {
+		spaceOpts = opts
+		ignoreSpace.Store(true)
+	}
+}
+
+// shownDiffOpts is git.DiffOpts plus spaceOpts when whitespace is ignored and the
+// context asked for. It's for diffs that are only read: with whitespace
+// ignored, hunks from them may not apply.
+func shownDiffOpts() []string {
+	opts := git.DiffOpts[:len(git.DiffOpts):len(git.DiffOpts)]
+	if ignoreSpace.Load() {
+		opts = append(opts, spaceOpts...)
+	}
+	if n := shownContext(); n >= 0 {
+		opts = append(opts, fmt.Sprintf("-U%d", n))
+	}
+	return opts
+}
+
+func getChangedFiles() ([]git.FileStatus, error) {
+	// -z gives paths as they are, where plain --porcelain quotes and escapes
+	// any that aren't ASCII under the default core.quotepath
+	args := append([]string{"status", "--porcelain", "-z", "--"}, pathspecs...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status: %w", git.StderrError(err))
+	}
+	return git.ParseStatus(repoRoot(), string(out))
+}
+
+func getMainFiles() ([]git.FileStatus, error) {
+	entries, err := numstat(diffRange())
+	if err != nil {
+		return nil, err
+	}
+	files := make([]git.FileStatus, 0, len(entries))
+	for _, e := range entries {
+		files = append(files, git.FileStatus{Path: e.Path, OrigPath: e.OrigPath, Stat: e.Stat, Code: string(e.Status) + " "})
+	}
+	return files, nil
+}
+
+// diffSections labels the two diffs getDiffOutput puts together for each of
+// files changed both in the worktree and in the index, unstaged first.
+func diffSections(files []git.FileStatus) map[string][]render.Summary {
+	var sections map[string][]render.Summary
+	for _, f := range files {
+		if f.Diff != "" || flagMain || f.Conflicted || f.Untracked || !f.Staged || !f.Unstaged {
+			continue
+		}
+		if sections == nil {
+			sections = map[string][]render.Summary{}
+		}
+		sections[f.Path] = []render.Summary{{Text: tr("UNSTAGED"), Style: unstBadge}, {Text: tr("STAGED"), Style: stagedBadge}}
+	}
+	return sections
+}
+
+// getDiffOutput returns f's diff, running one git diff per side that changed:
+// unstaged, then staged, then the whole file when untracked.
+func getDiffOutput(f git.FileStatus, fullFile bool) (string, error) {
+	return getDiffOutputContext(context.Background(), f, fullFile)
+}
+
+// getDiffOutputContext is getDiffOutput, killing git if ctx is cancelled.
+func getDiffOutputContext(ctx context.Context, f git.FileStatus, fullFile bool) (string, error) {
+	if f.Diff != "" {
+		return f.Diff, nil
+	}
+	if f.Conflicted && !flagMain {
+		if d, err := conflictDiff(ctx, f.Path); d != "" || err != nil {
+			return d, err
+		}
+	}
+	if f.Untracked {
+		if d, ok, err := untrackedDiff(f.Path); ok || err != nil {
+			return d, err
+		}
+	}
+	if d, ok := goGitFileDiff(f, fullFile); ok {
+		return d, nil
+	}
+	if !fullFile {
+		if d, ok := batchedDiff(f); ok {
+			return d, nil
+		}
+	}
+	defer trace("git", f.Path)()
+	opts := append([]string{"diff"}, shownDiffOpts()...)
+	if fullFile {
+		opts = append(opts, "-U99999")
+	}
+	var runs [][]string
+	// a renamed file's diff needs both paths to pair them up
+	paths := []string{"--", f.Path}
+	if f.OrigPath != "" {
+		paths = []string{"--", f.OrigPath, f.Path}
+	}
+	if flagMain {
+		runs = append(runs, append([]string{diffRange()}, paths...))
+	} else {
+		if f.Conflicted {
+			// no conflict markers, as when one side deleted the file
+			runs = append(runs, []string{"HEAD", "--", f.Path})
+		}
+		if f.Unstaged {
+			runs = append(runs, []string{"--", f.Path})
+		}
+		if f.Staged {
+			runs = append(runs, append([]string{"--staged"}, paths...))
+		}
+		if f.Untracked {
+			runs = append(runs, []string{"--no-index", "--", "/dev/null", f.Path})
+		}
+	}
+	var b strings.Builder
+	for _, r := range runs {
+		args := append(opts[:len(opts):len(opts)], r...)
+		out, err := exec.CommandContext(ctx, "git", args...).Output()
+		if exitErr, ok := err.(*exec.ExitError); ok && r[0] == "--no-index" && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+			// --no-index exits 1 when the inputs differ, and also when it
+			// can't read them, but then it says so
+			err = nil
+		}
+		if err != nil {
+			return b.String(), fmt.Errorf("git diff %s: %w", f.Path, git.StderrError(err))
+		}
+		b.Write(out)
+	}
+	return b.String(), nil
+}
+
+// ==================== TUI Model ====================
+
+type diffLoadedMsg struct {
+	content string
+	hunks   []render.Hunk
+	path    string // the file previewed, when it's a file's diff
+	seq     int64  // the preview request this answers; stale ones are dropped
+	more    chan diffLoadedMsg // the rest of a preview still streaming in
+}
+type execFinishedMsg struct{ err error }
+type statusMsg struct{ text string }
+type promptMsg struct{ prompt *prompt }
+
+// filesLoadedMsg replaces the file list after git state changed under us.
+type filesLoadedMsg struct {
+	files []git.FileStatus
+	text  string
+}
+
+// prompt is a single-line text input shown in place of the tree footer.
+type prompt struct {
+	label  string
+	input  string
+	submit func(value string) tea.Cmd
+}
+
+type model struct {
+	allLines  []tree.Line
+	files     []git.FileStatus
+	filtered  []int
+	cursor    int
+	scroll    int
+	collapsed map[string]bool // directories folded shut, by path; kept across refreshes
+	matches   map[int][]int   // while searching, the matched runes of each file's path
+
+	commits      []commit
+	commitIdx    int
+	commitAnchor int // start of the selected commit range, or -1
+
+	searching bool
+	query     string
+	byOwner   bool
+
+	prompt   *prompt
+	popup    *popup    // a command's output, until the next key
+	basePick *basePick // the refs B offers to compare against
+	message  string
+	yanking  bool
+
+	tests     *testRun
+	showTests bool
+	ci        []ciRun
+	ciPolling bool
+	repo      repoInfo // for the status bar
+
+	stashes    bool          // the commit list is git stash list, in gd stash
+	reflog     bool          // the commit list is a reflog, in gd --reflog
+	fetchEvery time.Duration // refetch fetchRef this often in gd incoming
+	fetchRef   string
+	watch      *watcher        // set with --watch
+	poll       time.Duration   // check the status this often, with --poll
+	polledSum  uint64          // the status fingerprint last seen
+	shownPath  string          // the file whose diff is in the viewport
+	fullPaths  map[string]bool // files loaded past --max-preview with L
+
+	starting  <-chan filesResult // the first file list, while git is still at it
+	startErr  error              // loading the first file list failed
+	noChanges bool               // the first file list was empty
+
+	saved         *uiState // where gd was last left, until the files are in
+	restorePath   string   // the file whose preview goes back to restoreOffset
+	restoreOffset int
+
+	viewport     pane
+	previewFocus bool // j, k, and the paging keys scroll the preview
+	paging       bool // the full-file diff fills the screen, until q or esc
+	matchLine    int  // the preview line of the search match last jumped to
+	followEnd    bool // G keeps the preview at its end while it streams in
+	streaming    bool // more of the preview is still being rendered
+	matchWaiting bool // the search looks again when more of the preview arrives
+	spin         spinner
+	loadedSeq    int64 // the preview load last shown, which stops the spinner
+	hunks        []render.Hunk
+	hunkIdx      int
+	marked       []markedHunk
+	lineSel      *lineSelection // lines picked in the preview with v, to stage alone
+	viewed       map[string]bool // files marked viewed, with V
+	fileMarks    map[string]bool // files marked with x for s, u, e, enter, and delete
+	width        int
+	height       int
+	treeW        int
+	treeHidden   bool // the preview takes the whole width, with t
+	ready        bool
+
+	unfolded      string          // the preview before hunks are folded
+	unfoldedHunks []render.Hunk   // and its hunks
+	foldView      bool            // hunks show their headers alone, with Z
+	foldToggled   map[string]bool // hunks opened or folded against foldView, by hunkKey
+}
+
+func initialModel(files []git.FileStatus) model {
+	m := model{commitAnchor: -1}
+	m.setFiles(files)
+	return m
+}
+
+func commitsModel(commits []commit) model {
+	m := initialModel(commits[0].files)
+	m.commits = commits
+	return m
+}
+
+func (m *model) setFiles(files []git.FileStatus) {
+	m.files = files
+	nodes := tree.Build(files)
+	if m.byOwner {
+		nodes = buildOwnerTree(files)
+	}
+	m.allLines = tree.Flatten(nodes, "", 0)
+	m.cursor = 0
+	m.scroll = 0
+	m.updateFilter()
+
+	for i, idx := range m.filtered {
+		if m.allLines[idx].File != nil {
+			m.cursor = i
+			break
+		}
+	}
+}
+
+// selectPath moves the cursor to path when it's still in the tree.
+func (m *model) selectPath(path string) {
+	for i, idx := range m.filtered {
+		if f := m.allLines[idx].File; f != nil && f.Path == path {
+			m.cursor = i
+			if m.cursor >= m.scroll+m.treeHeight() {
+				m.scroll = m.cursor - m.treeHeight() + 1
+			}
+			return
+		}
+	}
+}
+
+func (m *model) selectCommit(idx int) bool {
+	if idx < 0 || idx >= len(m.commits) || idx == m.commitIdx {
+		return false
+	}
+	m.commitIdx = idx
+	m.setFiles(m.commits[idx].files)
+	return true
+}
+
+// commitRows is the number of commit entries shown above the file tree.
+func (m model) commitRows() int {
+	// a lone stash is still listed, to show which it is
+	if len(m.commits) < 2 && !((m.stashes || m.reflog) && len(m.commits) == 1) {
+		return 0
+	}
+	n := (m.height - 2) / 3
+	if n < 3 {
+		n = 3
+	}
+	if n > len(m.commits) {
+		n = len(m.commits)
+	}
+	return n
+}
+
+// treeHeight is the number of rows available for file entries.
+func (m model) treeHeight() int {
+	h := m.height - 2
+	if rows := m.commitRows(); rows > 0 {
+		h -= rows + 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+func (m *model) updateFilter() {
+	m.filtered, m.matches = nil, nil
+	if m.query != "" {
+		// a search lists the matching files flat, best match first
+		scores := map[int]int{}
+		m.matches = map[int][]int{}
+		for i, line := range m.allLines {
+			if line.File == nil {
+				continue
+			}
+			if score, pos, ok := fuzzyMatch(m.query, line.File.Path); ok {
+				m.filtered = append(m.filtered, i)
+				scores[i], m.matches[i] = score, pos
+			}
+		}
+		sort.SliceStable(m.filtered, func(a, b int) bool {
+			return scores[m.filtered[a]] > scores[m.filtered[b]]
+		})
+	} else {
+		for i := range m.allLines {
+			m.filtered = append(m.filtered, i)
+		}
+	}
+	if len(m.collapsed) > 0 && m.query == "" {
+		// leave out what's under collapsed directories, matching or not
+		shown := m.filtered[:0]
+		hideBelow := -1
+		for _, idx := range m.filtered {
+			line := m.allLines[idx]
+			if hideBelow >= 0 && line.Indent > hideBelow {
+				continue
+			}
+			hideBelow = -1
+			if line.File == nil && m.collapsed[line.Path] {
+				hideBelow = line.Indent
+			}
+			shown = append(shown, idx)
+		}
+		m.filtered = shown
+	}
+	if m.cursor >= len(m.filtered) {
+		m.cursor = len(m.filtered) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+// cursorDir is the directory line under the cursor, or nil on a file.
+func (m model) cursorDir() *tree.Line {
+	if m.cursor < 0 || m.cursor >= len(m.filtered) {
+		return nil
+	}
+	if line := &m.allLines[m.filtered[m.cursor]]; line.File == nil {
+		return line
+	}
+	return nil
+}
+
+// setCollapsed folds the directory at path shut, or opens it.
+func (m *model) setCollapsed(path string, shut bool) {
+	if m.collapsed == nil {
+		m.collapsed = map[string]bool{}
+	}
+	if shut {
+		m.collapsed[path] = true
+	} else {
+		delete(m.collapsed, path)
+	}
+	m.updateFilter()
+	m.moveCursor(0)
+}
+
+// selectParent moves the cursor to the directory holding the line under it.
+// It reports whether there was one.
+func (m *model) selectParent() bool {
+	if m.cursor < 0 || m.cursor >= len(m.filtered) {
+		return false
+	}
+	indent := m.allLines[m.filtered[m.cursor]].Indent
+	for i := m.cursor - 1; i >= 0; i-- {
+		if line := m.allLines[m.filtered[i]]; line.File == nil && line.Indent < indent {
+			m.moveCursor(i - m.cursor)
+			return true
+		}
+	}
+	return false
+}
+
+// dirFiles are the files under the directory at the cursor, collapsed or not.
+func (m model) dirFiles() []git.FileStatus {
+	d := m.cursorDir()
+	if d == nil {
+		return nil
+	}
+	var files []git.FileStatus
+	for _, line := range m.allLines[m.filtered[m.cursor]+1:] {
+		if line.Indent <= d.Indent {
+			break
+		}
+		if line.File != nil {
+			files = append(files, *line.File)
+		}
+	}
+	return files
+}
+
+// filesUnder counts the files under the directory line at idx of allLines.
+func (m model) filesUnder(idx int) int {
+	n := 0
+	for _, line := range m.allLines[idx+1:] {
+		if line.Indent <= m.allLines[idx].Indent {
+			break
+		}
+		if line.File != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (m model) Init() tea.Cmd {
+	cmds := []tea.Cmd{loadRepoInfo}
+	if flagCI && len(m.commits) == 0 {
+		cmds = append(cmds, loadCI)
+	}
+	if len(m.commits) == 0 {
+		cmds = append(cmds, loadThreads)
+	}
+	if m.fetchEvery > 0 {
+		cmds = append(cmds, fetchTick(m.fetchEvery))
+	}
+	if m.watch != nil {
+		cmds = append(cmds, m.watch.wait())
+	}
+	if m.poll > 0 {
+		cmds = append(cmds, pollStatus(0))
+	}
+	if m.starting != nil {
+		cmds = append(cmds, awaitStartupFiles(m.starting))
+	}
+	if cmd := m.loadViewed(m.files); cmd != nil {
+		cmds = append(cmds, cmd)
+	}
+	if flagOutput == "" {
+		return tea.Batch(cmds...)
+	}
+	files := m.files
+	if len(m.commits) > 0 {
+		files = allCommitFiles(m.commits)
+	}
+	return tea.Batch(append(cmds, func() tea.Msg {
+		if err := writePatchFile(flagOutput, files); err != nil {
+			return errorMsg{err: fmt.Errorf("output failed: %w", err)}
+		}
+		return statusMsg{text: trf("wrote %s", flagOutput)}
+	})...)
+}
+
+func (m model) selectedFile() *git.FileStatus {
+	if m.cursor >= 0 && m.cursor < len(m.filtered) {
+		return m.allLines[m.filtered[m.cursor]].File
+	}
+	return nil
+}
+
+// previewWidth is the width previews are rendered at.
+func (m model) previewWidth() int {
+	if flagAccessible {
+		return m.width
+	}
+	if m.treeHidden {
+		return m.width - 1 - scrollbarW
+	}
+	vpW := m.width - m.treeW - 2 - scrollbarW
+	if vpW < 40 {
+		vpW = 40
+	}
+	return vpW
+}
+
+// Tree widths, in columns: the narrowest it's resized to, the least it
+// leaves the preview, and how far ( and ) move it.
+const (
+	minTreeW    = 16
+	minPreviewW = 40
+	treeStep    = 4
+)
+
+// layout sizes the tree and the preview for the window: 30% of it for the
+// tree, between 30 and 50 columns, unless tree_width or ( and ) set a width.
+func (m *model) layout() {
+	m.treeW = min(max(m.width*30/100, 30), 50)
+	if configTreeWidth > 0 {
+		m.treeW = max(min(configTreeWidth, m.width-minPreviewW), minTreeW)
+	}
+	vpW := m.width - m.treeW - 2 - scrollbarW
+	if m.treeHidden {
+		vpW = m.width - 1 - scrollbarW
+	}
+	m.viewport.width = max(vpW, 20)
+	m.viewport.height = m.height
+	if flagAccessible {
+		// the file line and the footer take a row each
+		m.viewport.width = m.width
+		m.viewport.height = max(m.height-2, 1)
+	}
+}
+
+// resizeTree widens the tree by delta columns, or narrows it, and saves the
+// width as tree_width in the config file.
+func (m *model) resizeTree(delta int) tea.Cmd {
+	w := max(min(m.treeW+delta, m.width-minPreviewW), minTreeW)
+	if m.treeHidden || w == m.treeW {
+		return nil
+	}
+	configTreeWidth = w
+	m.layout()
+	save := func() tea.Msg {
+		if err := setConfigValue("tree_width", fmt.Sprint(w)); err != nil {
+			return errorMsg{err: err}
+		}
+		return statusMsg{text: trf("tree width %d, saved", w)}
+	}
+	return tea.Batch(m.loadPreview(), save)
+}
+
+func (m model) loadPreview() tea.Cmd {
+	vpW := m.previewWidth()
+	// a directory shows the diffs of every file under it, one after another
+	var files []git.FileStatus
+	var path, name string
+	var key previewKey
+	if f := m.selectedFile(); f != nil {
+		files, path, name = []git.FileStatus{*f}, f.Path, f.Path
+		key = m.previewKey(*f, vpW)
+	} else if d := m.cursorDir(); d != nil {
+		files, path = m.dirFiles(), d.Path+"/"
+		key = m.dirPreviewKey(path, files, vpW)
+	}
+	if len(files) == 0 {
+		session.view("")
+		return func() tea.Msg { return diffLoadedMsg{content: ""} }
+	}
+	session.view(path)
+	full := m.fullPaths[path]
+	want := streamAhead + m.viewport.height
+	if path == m.shownPath {
+		want += m.viewport.yOffset // a refresh keeps its place
+	}
+	if path == m.restorePath {
+		want += m.restoreOffset
+	}
+	gen := previewGeneration()
+	seq := previewSeq.Add(1)
+	if p, ok := cachedPreview(key); ok {
+		stopPreview()
+		return func() tea.Msg {
+			return diffLoadedMsg{content: p.content, hunks: p.hunks, path: path, seq: seq}
+		}
+	}
+	// wait out key repeat so only where the cursor stops gets rendered
+	return tea.Batch(startSpinner(seq, path), tea.Tick(previewDelay, func(time.Time) tea.Msg {
+		if previewSeq.Load() != seq {
+			return nil
+		}
+		ctx := beginPreview()
+		var b strings.Builder
+		for _, file := range files {
+			raw, err := getDiffOutputContext(ctx, file, false)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				reportError(err)
+				return diffLoadedMsg{content: delIndSty.Render(err.Error()), seq: seq}
+			}
+			b.WriteString(raw)
+		}
+		raw := b.String()
+		opts := renderOpts{sections: diffSections(files)}
+		if !full {
+			opts.limit, opts.lineLimit = flagMaxPreview<<10, flagMaxPreviewLines
+		}
+		if len(raw) > streamThreshold {
+			return streamPreview(ctx, raw, vpW, path, name, opts, key, gen, seq, want)
+		}
+		rendered, hunks := renderDiffOpts(raw, vpW, name, opts)
+		storePreview(key, gen, preview{content: rendered, hunks: hunks})
+		if ctx.Err() != nil {
+			return nil
+		}
+		return diffLoadedMsg{content: rendered, hunks: hunks, path: path, seq: seq}
+	}))
+}
+
+// pageMsg carries a rendered full-file diff to show in the pager.
+type pageMsg struct {
+	rendered string
+	hunks    []render.Hunk
+	external bool   // for an external pager, rather than gd's own
+	raw      string // the diff itself, for external pagers that color diffs
+	path     string
+}
+
+// openFullDiff renders the whole file off the UI goroutine, then pages it:
+// full screen in gd itself, or in an external pager with --pager or --tmux.
+func (m model) openFullDiff() tea.Cmd {
+	f := m.selectedFile()
+	if f == nil {
+		return nil
+	}
+	file := *f
+	width, vpW := m.width, m.viewport.width
+	external := (flagPager != "" || inTmux()) && hasPager()
+	return func() tea.Msg {
+		raw, err := getDiffOutput(file, true)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		if msg, ok := fullImages(raw, file.Path); ok {
+			return msg
+		}
+		switch {
+		case external:
+			msg := pageMsg{raw: raw, path: file.Path, external: true}
+			if !pagerWantsDiff(pagerLine()) {
+				msg.rendered, _ = renderDiff(raw, width, file.Path)
+			}
+			return msg
+		case flagAccessible:
+			// the accessible view has no full screen; the preview will do
+			rendered, hunks := renderDiff(raw, vpW, file.Path)
+			return diffLoadedMsg{content: rendered, hunks: hunks}
+		}
+		rendered, hunks := renderDiff(raw, width-pagerGutterW, file.Path)
+		return pageMsg{rendered: rendered, hunks: hunks}
+	}
+}
+
+// pagerGutterW is the columns beside the pager's text: the hunk gutter left
+// of it and the scrollbar right.
+const pagerGutterW = 1 + scrollbarW
+
+// openPager shows msg's full-file diff over the whole screen, scrolled and
+// searched with the preview's keys.
+func (m *model) openPager(msg pageMsg) {
+	previewSeq.Add(1) // a preview still loading would replace it
+	m.paging = true
+	m.previewFocus = true
+	m.viewport.width = m.width - pagerGutterW
+	m.viewport.height = m.height
+	m.viewport.setContent(msg.rendered)
+	m.viewport.gotoTop()
+	m.hunks = msg.hunks
+	m.hunkIdx = 0
+	m.matchLine = -1
+	m.message = tr("q back  / search  n/p hunk")
+}
+
+// closePager goes back to the tree and the file's preview.
+func (m *model) closePager() tea.Cmd {
+	m.paging = false
+	m.previewFocus = false
+	m.viewport.width = m.previewWidth()
+	m.shownPath = "" // the preview starts again from the top
+	return m.loadPreview()
+}
+
+// overlay is what's drawn over the preview: the base picker, or a popup.
+func (m model) overlay() *popup {
+	if m.basePick != nil {
+		return m.basePick.popup(m.viewport.height)
+	}
+	return m.popup
+}
+
+// fullView is the preview filling the screen, as the full-file pager or with
+// the tree hidden, with the hunk gutter and the status bar, or the footer
+// while a prompt or search is open.
+func (m model) fullView() string {
+	var b strings.Builder
+	gutter, diff, bar := m.renderHunkGutter(), m.overlay().overlay(m.previewRows(), m.viewport.width), m.renderScrollbar()
+	for i := range m.height {
+		b.WriteString(gutter[i])
+		b.WriteString(diff[i])
+		if bar != nil {
+			b.WriteString(spaces(m.viewport.width - ansi.StringWidth(diff[i])))
+			b.WriteString(bar[i])
+		}
+		b.WriteByte('\n')
+	}
+	if m.prompt != nil || m.searching {
+		b.WriteString(m.renderFooter(m.width))
+	} else {
+		b.WriteString(m.renderStatusBar())
+	}
+	return b.String()
+}
+
+func (m *model) promptExport(files []git.FileStatus, def string) {
+	m.prompt = &prompt{
+		label: tr("export to: "),
+		input: def,
+		submit: func(path string) tea.Cmd {
+			if path == "" {
+				return nil
+			}
+			session.record("export_patch", path, fmt.Sprintf("%d files", len(files)))
+			return func() tea.Msg {
+				if err := writePatchFile(path, files); err != nil {
+					return errorMsg{err: fmt.Errorf("export failed: %w", err)}
+				}
+				return statusMsg{text: trf("wrote %s", path)}
+			}
+		},
+	}
+}
+
+// currentHunk returns the hunk at the top of the preview viewport.
+func (m model) currentHunk() *gitdiff.TextFragment {
+	if i := m.currentHunkIdx(); i >= 0 {
+		return m.hunks[i].Frag
+	}
+	return nil
+}
+
+func (m model) currentHunkIdx() int {
+	if m.hunkIdx >= len(m.hunks) {
+		return len(m.hunks) - 1
+	}
+	return m.hunkIdx
+}
+
+// scrollPreview scrolls the focused preview for key, keeping the hunk cursor
+// on the hunk at the top. It reports whether key was a scrolling key.
+func (m *model) scrollPreview(key string) bool {
+	v := &m.viewport
+	switch key {
+	case "up", "k":
+		v.setYOffset(v.yOffset - 1)
+	case "down", "j":
+		v.setYOffset(v.yOffset + 1)
+	case "ctrl+u", "pgup":
+		v.setYOffset(v.yOffset - max(v.height/2, 1))
+	case "ctrl+d", "pgdown":
+		v.setYOffset(v.yOffset + max(v.height/2, 1))
+	case "g", "home":
+		v.gotoTop()
+	case "G", "end":
+		streamAll()
+		v.gotoBottom()
+	default:
+		return false
+	}
+	m.followEnd = key == "G" || key == "end"
+	m.syncHunk()
+	return true
+}
+
+// scrollSideways scrolls the preview's lines delta columns sideways, drawing
+// it again with them cut from there.
+func (m *model) scrollSideways(delta int) tea.Cmd {
+	off := max(int(hScroll.Load())+delta, 0)
+	hScroll.Store(int32(off))
+	m.message = trf("scrolled %d columns", off)
+	return m.reloadPreview()
+}
+
+// syncHunk points the hunk cursor at the hunk at the top of the preview.
+func (m *model) syncHunk() {
+	m.hunkIdx = 0
+	for i, h := range m.hunks {
+		if h.Line <= m.viewport.yOffset {
+			m.hunkIdx = i
+		}
+	}
+}
+
+// diffSearchMsg searches the preview's text for query.
+type diffSearchMsg struct{ query string }
+
+// promptDiffSearch asks what to search the preview for.
+func (m *model) promptDiffSearch() {
+	m.prompt = &prompt{
+		label: tr("search diff: "),
+		submit: func(q string) tea.Cmd {
+			return func() tea.Msg { return diffSearchMsg{query: q} }
+		},
+	}
+}
+
+// nextMatch scrolls to the next line of the preview matching its search, or
+// with a negative delta the previous one, wrapping around at the ends.
+func (m *model) nextMatch(delta int) {
+	q := m.viewport.query
+	lines := m.viewport.matches(q)
+	m.matchWaiting = false
+	if m.streaming && delta > 0 && (len(lines) == 0 || lines[len(lines)-1] <= m.matchLine) {
+		// the next match may be in what's still rendering
+		m.matchWaiting = true
+		m.message = tr("searching…")
+		return
+	}
+	if len(lines) == 0 {
+		m.message = trf("no matches for %s", q)
+		return
+	}
+	k := 0
+	if delta > 0 {
+		for k < len(lines) && lines[k] <= m.matchLine {
+			k++
+		}
+		if k == len(lines) {
+			k = 0
+		}
+	} else {
+		k = len(lines) - 1
+		for k >= 0 && lines[k] >= m.matchLine {
+			k--
+		}
+		if k < 0 {
+			k = len(lines) - 1
+		}
+	}
+	m.matchLine = lines[k]
+	v := &m.viewport
+	if m.matchLine < v.yOffset || m.matchLine >= v.yOffset+v.height {
+		v.setYOffset(m.matchLine - v.height/3)
+	}
+	m.syncHunk()
+	m.message = trf("match %d of %d", k+1, len(lines))
+}
+
+// jumpHunk moves the hunk cursor by delta and scrolls it to the top of the
+// preview.
+func (m *model) jumpHunk(delta int) {
+	i := m.currentHunkIdx() + delta
+	if i < 0 || i >= len(m.hunks) {
+		return
+	}
+	m.hunkIdx = i
+	m.viewport.setYOffset(m.hunks[i].Line)
+}
+
+// jumpFile moves the hunk cursor to the first hunk of the next file in the
+// preview, or with a negative delta the previous one, and scrolls that file's
+// header to the top.
+func (m *model) jumpFile(delta int) {
+	i := m.currentHunkIdx()
+	if i < 0 {
+		return
+	}
+	// start of the current file's hunks
+	for i > 0 && m.hunks[i-1].File == m.hunks[i].File {
+		i--
+	}
+	if delta > 0 {
+		f := m.hunks[i].File
+		for i < len(m.hunks) && m.hunks[i].File == f {
+			i++
+		}
+		if i == len(m.hunks) {
+			return
+		}
+	} else {
+		if i == 0 {
+			return
+		}
+		i--
+		for i > 0 && m.hunks[i-1].File == m.hunks[i].File {
+			i--
+		}
+	}
+	m.hunkIdx = i
+	m.viewport.setYOffset(m.hunks[i].Line - 1)
+}
+
+func (m model) isMarked(i int) bool {
+	f := m.selectedFile()
+	if f == nil {
+		return false
+	}
+	h := markedHunk{path: f.Path, frag: m.hunks[i].Frag}
+	for _, mh := range m.marked {
+		if mh.key() == h.key() {
+			return true
+		}
+	}
+	return false
+}
+
+// renderHunkGutter draws the one-column strip left of the preview: a bar
+// beside the current hunk, a dot at the start of marked hunks, and the lines
+// picked with v.
+func (m model) renderHunkGutter() []string {
+	total := m.viewport.lineCount()
+	rows := make([]string, m.viewport.height)
+	for r := range rows {
+		rows[r] = " "
+	}
+	if m.loading() {
+		return rows
+	}
+	top, cur := m.viewport.yOffset, m.currentHunkIdx()
+	for i, h := range m.hunks {
+		end := total
+		if i+1 < len(m.hunks) {
+			end = m.hunks[i+1].Line
+		}
+		// only the rows on screen, however long the hunk
+		for line := max(h.Line, top); line < min(end, top+len(rows)); line++ {
+			r := line - top
+			switch {
+			case line == h.Line && m.isMarked(i):
+				rows[r] = searchSty.Render("●")
+			case i == cur:
+				rows[r] = hunkHdrSty.Render("▎")
+			}
+		}
+	}
+	if s, h, ok := m.selection(); ok {
+		lo, hi := s.span()
+		for j := lo; j <= hi; j++ {
+			if r := h.Line + h.Rows[j] - top; r >= 0 && r < len(rows) {
+				mark := "▌"
+				if j == s.cursor {
+					mark = "▶"
+				}
+				rows[r] = searchSty.Render(mark)
+			}
+		}
+	}
+	return rows
+}
+
+// markedHunk is a hunk picked for export with the m key.
+type markedHunk struct {
+	path string
+	file *gitdiff.File
+	frag *gitdiff.TextFragment
+}
+
+func (h markedHunk) key() string {
+	return h.path + "\x00" + h.frag.Header()
+}
+
+func (m *model) toggleMark() {
+	f := m.selectedFile()
+	i := m.currentHunkIdx()
+	if f == nil || i < 0 {
+		return
+	}
+	h := markedHunk{path: f.Path, file: m.hunks[i].File, frag: m.hunks[i].Frag}
+	for j, mh := range m.marked {
+		if mh.key() == h.key() {
+			m.marked = append(m.marked[:j], m.marked[j+1:]...)
+			session.record("unmark_hunk", f.Path, h.frag.Header())
+			m.message = trf("unmarked hunk (%d marked)", len(m.marked))
+			return
+		}
+	}
+	m.marked = append(m.marked, h)
+	session.record("mark_hunk", f.Path, h.frag.Header())
+	m.message = trf("marked hunk (%d marked, X to export)", len(m.marked))
+}
+
+func (m model) yank(key string) tea.Cmd {
+	f := m.selectedFile()
+	if f == nil {
+		return nil
+	}
+	var text, what, done string
+	switch key {
+	case "p":
+		text, what, done = f.Path, "path", tr("copied path")
+	case "h":
+		hunk := m.currentHunk()
+		if hunk == nil {
+			return func() tea.Msg { return statusMsg{text: tr("no hunk to copy")} }
+		}
+		text, what, done = hunk.String(), "hunk", tr("copied hunk")
+	case "d":
+		diff, err := getDiffOutput(*f, false)
+		if err != nil {
+			return func() tea.Msg { return errorMsg{err: err} }
+		}
+		text, what, done = diff, "diff", tr("copied diff")
+	case "i":
+		id := branchIssue()
+		if hunk := m.currentHunk(); hunk != nil {
+			if hid := hunkIssue(hunk); hid != "" {
+				id = hid
+			}
+		}
+		if id == "" {
+			return func() tea.Msg { return statusMsg{text: tr("no issue reference found")} }
+		}
+		text, what, done = issueURL(id), "link to "+id, trf("copied link to %s", id)
+	default:
+		return nil
+	}
+	session.record("copy_"+what, f.Path, "")
+	return func() tea.Msg {
+		if err := copyToClipboard(text); err != nil {
+			return errorMsg{err: fmt.Errorf("%s: %w", tr("copy failed"), err)}
+		}
+		return statusMsg{text: done}
+	}
+}
+
+// commitRange returns the inclusive list indices between the range anchor and
+// the selected commit.
+func (m model) commitRange() (lo, hi int) {
+	lo, hi = m.commitIdx, m.commitIdx
+	if m.commitAnchor >= 0 {
+		if m.commitAnchor < lo {
+			lo = m.commitAnchor
+		} else {
+			hi = m.commitAnchor
+		}
+	}
+	return lo, hi
+}
+
+// promptFormatPatch asks for an output directory and whether to add a cover
+// letter, then runs git format-patch for the selected commits, or for the
+// whole branch in --main mode.
+func (m *model) promptFormatPatch() {
+	var revs []string
+	switch {
+	case m.stashes || m.reflog:
+		// their entries' diffs aren't the commits' own
+		m.message = tr("format-patch is for commits, not stashes or the reflog")
+		return
+	case len(m.commits) > 0:
+		lo, hi := m.commitRange()
+		// the selected commits themselves, whatever order the list is in
+		revs = append(revs, "--no-walk")
+		for _, c := range m.commits[lo : hi+1] {
+			if c.sha == "" {
+				m.message = tr("commit has no sha")
+				return
+			}
+			if !slices.Contains(revs, c.sha) {
+				revs = append(revs, c.sha)
+			}
+		}
+	case flagMain:
+		revs = []string{baseRef + ".." + headRef}
+	default:
+		m.message = tr("format-patch needs a commit list or --main")
+		return
+	}
+
+	m.prompt = &prompt{
+		label: tr("format-patch to: "),
+		input: "patches",
+		submit: func(dir string) tea.Cmd {
+			if dir == "" {
+				return nil
+			}
+			return func() tea.Msg {
+				return promptMsg{&prompt{
+					label: tr("cover letter? (y/N): "),
+					submit: func(answer string) tea.Cmd {
+						cover := strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+						session.record("format_patch", dir, strings.Join(revs, " "))
+						return func() tea.Msg {
+							n, err := formatPatch(dir, revs, cover)
+							if err != nil {
+								return errorMsg{err: fmt.Errorf("format-patch failed: %w", err)}
+							}
+							return statusMsg{text: trf("wrote %d files to %s", n, dir)}
+						}
+					},
+				}}
+			}
+		},
+	}
+}
+
+func (m *model) moveCursor(delta int) {
+	n := len(m.filtered)
+	if n == 0 {
+		return
+	}
+	m.cursor += delta
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	if m.cursor >= n {
+		m.cursor = n - 1
+	}
+	visibleH := m.treeHeight()
+	if m.cursor < m.scroll {
+		m.scroll = m.cursor
+	}
+	if m.cursor >= m.scroll+visibleH {
+		m.scroll = m.cursor - visibleH + 1
+	}
+}
+
+func (m model) renderCommits(b *strings.Builder) {
+	rows := m.commitRows()
+	title := trf("Commits (%d/%d)", m.commitIdx+1, len(m.commits))
+	if m.stashes {
+		title = trf("Stashes (%d/%d)", m.commitIdx+1, len(m.commits))
+	}
+	if m.reflog {
+		title = trf("Reflog (%d/%d)", m.commitIdx+1, len(m.commits))
+	}
+	b.WriteString(titleSty.Render(title))
+	b.WriteByte('\n')
+	start := m.commitIdx - rows/2
+	if start > len(m.commits)-rows {
+		start = len(m.commits) - rows
+	}
+	if start < 0 {
+		start = 0
+	}
+	contentW := m.treeW - 1
+	lo, hi := m.commitRange()
+	for i := start; i < start+rows; i++ {
+		label := fitStr(m.commits[i].label(), contentW)
+		if i == m.commitIdx {
+			b.WriteString(cursorSty.Render(label))
+		} else if m.commitAnchor >= 0 && i >= lo && i <= hi {
+			b.WriteString(stagedBadge.Render(label))
+		} else {
+			b.WriteString(fileSty.Render(label))
+		}
+		b.WriteByte('\n')
+	}
+}
+
+func (m model) renderTree() string {
+	var b strings.Builder
+	if m.commitRows() > 0 {
+		m.renderCommits(&b)
+	}
+	title := kindTitle()
+	switch {
+	case len(m.commits) > 0:
+	case shownCommit != "":
+		title = trf("Changes in %s", strings.Fields(shownCommit)[0])
+	case reviewPR > 0:
+		title = trf("Pull request #%d", reviewPR)
+	case flagMain:
+		title = trf("Changes vs %s", baseRef)
+	}
+	b.WriteString(titleSty.Render(title))
+	if id := branchIssue(); id != "" {
+		b.WriteString("  " + hyperlink(issueURL(id), hunkHdrSty.Render(id)))
+	}
+	b.WriteByte('\n')
+
+	visibleH := m.treeHeight()
+	end := m.scroll + visibleH
+	if end > len(m.filtered) {
+		end = len(m.filtered)
+	}
+	contentW := m.treeW - 1
+
+	for i := m.scroll; i < end; i++ {
+		lineIdx := m.filtered[i]
+		line := m.allLines[lineIdx]
+		indent := strings.Repeat("  ", line.Indent)
+		name, styledName := line.Name, fileSty.Render(line.Name)
+		if pos, ok := m.matches[lineIdx]; ok {
+			indent = ""
+			name, styledName = line.File.Path, highlightMatch(line.File.Path, pos, fileSty.Render)
+		}
+
+		var plain string
+		var rendered string
+		if line.File == nil {
+			icon := dirIcon(m.collapsed[line.Path])
+			plain = indent + icon + line.Name
+			rendered = indent + dirSty.Render(icon+line.Name)
+			if m.collapsed[line.Path] {
+				count := fmt.Sprintf(" (%d)", m.filesUnder(lineIdx))
+				plain += count
+				rendered += borderSty.Render(count)
+			}
+		} else {
+			badge := ""
+			badgePlain := ""
+			if line.File.Untracked {
+				badge = untrkBadge.Render("?")
+				badgePlain = "?"
+			} else if line.File.Conflicted {
+				badge = delIndSty.Render("U") + " "
+				badgePlain = "U "
+			} else if code := line.File.Code; len(code) == 2 {
+				// what changed in the index, then in the worktree, as git
+				// status -s shows it
+				x, y := code[:1], code[1:]
+				badge = stagedBadge.Render(x) + unstBadge.Render(y)
+				if !line.File.Staged && !line.File.Unstaged {
+					// a range or a commit
+					badge = unstBadge.Render(x) + " "
+				}
+				badgePlain = code
+			}
+			// a file marked with x has a dot between its badge and name
+			sep, sepPlain := " ", " "
+			if m.fileMarks[line.File.Path] {
+				sep, sepPlain = hunkHdrSty.Render("•"), "•"
+			}
+			icon := fileIcon(line.File.Path)
+			plain = indent + badgePlain + sepPlain + icon + name
+			rendered = indent + badge + sep + fileSty.Render(icon) + hyperlink(fileURL(line.File.Path), styledName)
+			if from := renamedFrom(line.File); from != "" {
+				plain = indent + badgePlain + sepPlain + icon + from + " → " + name
+				rendered = indent + badge + sep + fileSty.Render(icon) + borderSty.Render(from+" → ") + hyperlink(fileURL(line.File.Path), styledName)
+			}
+		}
+		if noColor {
+			// without colors the cursor is only this marker
+			marker := " "
+			if i == m.cursor {
+				marker = "›"
+			}
+			plain, rendered = marker+plain, marker+rendered
+		}
+		if line.File != nil && m.viewed[line.File.Path] {
+			plain, rendered = plain+" ✓", rendered+" "+addIndSty.Render("✓")
+		}
+		if line.File != nil {
+			plain, rendered = withTreeStat(plain, rendered, line.File.Stat, contentW)
+		}
+
+		if i == m.cursor {
+			padN := contentW - ansi.StringWidth(plain)
+			if padN < 0 {
+				padN = 0
+			}
+			rendered = cursorSty.Render(rendered + strings.Repeat(" ", padN))
+		}
+
+		// Truncate display to content width
+		if ansi.StringWidth(plain) > contentW {
+			// Re-render truncated
+			if i == m.cursor {
+				rendered = cursorSty.Render(fitStr(plain, contentW))
+			}
+		}
+
+		b.WriteString(rendered)
+		b.WriteByte('\n')
+	}
+
+	for i := end - m.scroll; i < visibleH; i++ {
+		b.WriteByte('\n')
+	}
+
+	b.WriteString(m.renderFooter(contentW))
+	return b.String()
+}
+
+// renamedFrom is what a renamed or copied file's tree row shows it came from:
+// the old name alone when it stayed in the same directory.
+func renamedFrom(f *git.FileStatus) string {
+	if f.OrigPath == "" {
+		return ""
+	}
+	if path.Dir(f.OrigPath) == path.Dir(f.Path) {
+		return path.Base(f.OrigPath)
+	}
+	return f.OrigPath
+}
+
+// withTreeStat right-aligns a file's +N −M counts, and ⚠N for its leftovers,
+// on its tree row of width w, cutting the name short to fit them. Rows too narrow for both go without.
+func withTreeStat(plain, rendered string, st git.FileStat, w int) (string, string) {
+	var statPlain, stat []string
+	switch {
+	case st.Binary:
+		statPlain, stat = []string{"bin"}, []string{borderSty.Render("bin")}
+	default:
+		if st.Added > 0 {
+			s := fmt.Sprintf("+%d", st.Added)
+			statPlain, stat = append(statPlain, s), append(stat, addIndSty.Render(s))
+		}
+		if st.Deleted > 0 {
+			s := fmt.Sprintf("−%d", st.Deleted)
+			statPlain, stat = append(statPlain, s), append(stat, delIndSty.Render(s))
+		}
+	}
+	if st.Leftovers > 0 {
+		s := fmt.Sprintf("⚠%d", st.Leftovers)
+		statPlain, stat = append(statPlain, s), append(stat, noteSty.Render(s))
+	}
+	statW := ansi.StringWidth(strings.Join(statPlain, " "))
+	if statW == 0 || statW > w/3 {
+		return plain, rendered
+	}
+	if nameW := w - statW - 1; ansi.StringWidth(plain) > nameW {
+		plain, rendered = ansi.Truncate(plain, nameW, "…"), ansi.Truncate(rendered, nameW, "…")
+	}
+	gap := spaces(w - ansi.StringWidth(plain) - statW)
+	return plain + gap + strings.Join(statPlain, " "), rendered + gap + strings.Join(stat, " ")
+}
+
+// renderFooter is the tree's last row: the prompt, the latest error, search,
+// or the key hints.
+func (m model) renderFooter(contentW int) string {
+	switch err, more := unseenError(); {
+	case m.basePick != nil:
+		return searchSty.Render(fitStr(tr("type to narrow  ↑/↓ move  ⏎ compare  esc cancel"), contentW))
+	case m.prompt != nil:
+		return searchSty.Render(m.prompt.label + m.prompt.input + "█")
+	case err != nil:
+		return errorToast(err, more, contentW)
+	case m.starting != nil:
+		return borderSty.Render(fitStr(tr("reading git status…"), contentW))
+	case m.searching:
+		return searchSty.Render("/" + m.query + "█")
+	case m.stashes:
+		return borderSty.Render(fitStr(tr("a apply  P pop  d drop  ] [ stash"), contentW))
+	case m.lineSel != nil:
+		return searchSty.Render(fitStr(tr("j/k lines  s stage  u unstage  esc cancel"), contentW))
+	case m.previewFocus && m.viewport.query != "":
+		return searchSty.Render("/"+m.viewport.query) + borderSty.Render("  "+tr("n/N match  esc clear"))
+	case m.previewFocus:
+		return borderSty.Render(fitStr(tr("j/k scroll  h/l sideways  ^d/^u page  tab files"), contentW))
+	case m.query != "":
+		return searchSty.Render("/" + m.query) + borderSty.Render("  "+tr("esc clear"))
+	}
+	return borderSty.Render(tr("/ search  ⏎ view  q quit"))
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		debugf("key %s", msg)
+		m.message = ""
+		dismissErrors()
+		if m.popup != nil {
+			m.popup = nil
+			return m, nil
+		}
+		if m.basePick != nil {
+			return m, m.basePickKey(msg)
+		}
+		if m.prompt != nil {
+			switch msg.String() {
+			case "enter":
+				p := m.prompt
+				m.prompt = nil
+				return m, p.submit(p.input)
+			case "esc":
+				m.prompt = nil
+			case "backspace":
+				if r := []rune(m.prompt.input); len(r) > 0 {
+					m.prompt.input = string(r[:len(r)-1])
+				}
+			default:
+				if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+					m.prompt.input += string(msg.Runes)
+				}
+			}
+			return m, nil
+		}
+
+		if m.yanking {
+			m.yanking = false
+			return m, m.yank(msg.String())
+		}
+
+		if m.searching {
+			switch msg.String() {
+			case "enter":
+				m.searching = false
+				for i, idx := range m.filtered {
+					if m.allLines[idx].File != nil {
+						m.cursor = i
+						break
+					}
+				}
+				return m, m.loadPreview()
+			case "esc":
+				m.searching = false
+				m.query = ""
+				m.updateFilter()
+				return m, m.loadPreview()
+			case "backspace":
+				if len(m.query) > 0 {
+					m.query = m.query[:len(m.query)-1]
+					m.cursor = 0
+					m.updateFilter()
+				}
+				return m, nil
+			default:
+				if len(msg.String()) == 1 {
+					m.query += msg.String()
+					m.cursor = 0 // on the best match
+					m.updateFilter()
+				}
+				return m, nil
+			}
+		}
+
+		if alias, ok := keyAliases[msg.String()]; ok {
+			msg = alias
+		}
+		if c, ok := userCommandFor(msg.String()); ok {
+			return m, m.runUserCommand(c)
+		}
+
+		if m.paging {
+			switch msg.String() {
+			case "q", "esc":
+				if m.viewport.query == "" || msg.String() == "q" {
+					return m, m.closePager()
+				}
+			case "tab", "h", "l", "left", "right", "enter":
+				return m, nil
+			}
+		}
+
+		if m.stashes {
+			switch msg.String() {
+			case "a":
+				return m, m.stashAction("apply")
+			case "P":
+				return m, m.stashAction("pop")
+			case "d":
+				return m, m.stashAction("drop")
+			}
+		}
+
+		if _, _, ok := m.selection(); ok {
+			return m, m.lineSelectionKey(msg.String())
+		}
+		m.lineSel = nil
+
+		if m.previewFocus {
+			switch msg.String() {
+			case "/":
+				m.promptDiffSearch()
+				return m, nil
+			case "v":
+				m.startLineSelection()
+				return m, nil
+			case "l", "right":
+				if !wrapLines.Load() {
+					return m, m.scrollSideways(hScrollCols)
+				}
+			case "h", "left":
+				// back to the tree once scrolled all the way left
+				if !wrapLines.Load() && hScroll.Load() > 0 {
+					return m, m.scrollSideways(-hScrollCols)
+				}
+			case "n", "N":
+				if m.viewport.query != "" {
+					delta := 1
+					if msg.String() == "N" {
+						delta = -1
+					}
+					m.nextMatch(delta)
+					return m, nil
+				}
+			}
+			if m.scrollPreview(msg.String()) {
+				return m, nil
+			}
+		}
+
+		switch keymap[msg.String()] {
+		case "quit":
+			return m, tea.Quit
+		case "focus":
+			m.previewFocus = !m.previewFocus
+			return m, nil
+		case "expand":
+			if d := m.cursorDir(); d != nil && !m.previewFocus {
+				if m.collapsed[d.Path] {
+					m.setCollapsed(d.Path, false)
+				}
+				return m, nil
+			}
+			m.previewFocus = true
+			return m, nil
+		case "collapse":
+			if m.previewFocus {
+				m.previewFocus = false
+				return m, nil
+			}
+			if d := m.cursorDir(); d != nil && !m.collapsed[d.Path] {
+				m.setCollapsed(d.Path, true)
+			} else if m.selectParent() {
+				return m, m.loadPreview()
+			}
+			return m, nil
+		case "back":
+			if m.previewFocus && m.viewport.query != "" {
+				m.viewport.query = ""
+				return m, nil
+			}
+			if m.previewFocus {
+				m.previewFocus = false
+				return m, nil
+			}
+			if m.query != "" {
+				m.query = ""
+				m.updateFilter()
+				return m, m.loadPreview()
+			}
+			if len(m.fileMarks) > 0 {
+				m.fileMarks = nil
+				m.message = tr("unmarked all files")
+				return m, nil
+			}
+			return m, tea.Quit
+		case "cursor_up":
+			prev := m.cursor
+			m.moveCursor(-1)
+			if m.cursor != prev {
+				return m, m.loadPreview()
+			}
+			return m, nil
+		case "cursor_down":
+			prev := m.cursor
+			m.moveCursor(1)
+			if m.cursor != prev {
+				return m, m.loadPreview()
+			}
+			return m, nil
+		case "open":
+			if files := m.dirFiles(); len(files) > 0 {
+				return m, m.openCombinedDiff(files)
+			}
+			if files := m.markedFiles(); len(files) > 0 {
+				return m, m.openCombinedDiff(files)
+			}
+			return m, m.openFullDiff()
+		case "mark_file":
+			m.toggleFileMark()
+			return m, nil
+		case "discard":
+			if files := m.markedFiles(); len(files) > 0 {
+				m.promptDiscard(files)
+			} else {
+				m.promptRevertHunk()
+			}
+			return m, nil
+		case "fold_hunk":
+			m.toggleFold()
+			return m, nil
+		case "fold_all":
+			m.toggleFoldView()
+			return m, nil
+		case "layout":
+			layout, text := layoutSplit, tr("side by side")
+			if sideBySide(m.previewWidth()) {
+				layout, text = layoutUnified, tr("unified")
+			}
+			diffLayout.Store(layout)
+			m.message = text
+			return m, m.reloadPreview()
+		case "wrap":
+			wrapLines.Store(!wrapLines.Load())
+			m.message = tr("cutting long lines")
+			if wrapLines.Load() {
+				m.message = tr("wrapping long lines")
+			}
+			return m, m.reloadPreview()
+		case "cycle_kind":
+			return m, m.cycleKind()
+		case "blame":
+			if len(m.commits) > 0 {
+				m.message = tr("blame is for the worktree or a range")
+				return m, nil
+			}
+			showBlame.Store(!showBlame.Load())
+			m.message = tr("hiding blame")
+			if showBlame.Load() {
+				m.message = tr("blaming context and removed lines")
+			}
+			return m, m.reloadPreview()
+		case "ignore_space":
+			ignoreSpace.Store(!ignoreSpace.Load())
+			m.message = tr("showing whitespace changes")
+			if ignoreSpace.Load() {
+				m.message = tr("ignoring whitespace changes")
+			}
+			return m, m.reloadPreview()
+		case "toggle_tree":
+			m.treeHidden = !m.treeHidden
+			m.layout()
+			return m, m.loadPreview()
+		case "narrow_tree":
+			return m, m.resizeTree(-treeStep)
+		case "widen_tree":
+			return m, m.resizeTree(treeStep)
+		case "less_context":
+			return m, m.stepContext(-1)
+		case "more_context":
+			return m, m.stepContext(1)
+		case "load_all":
+			f := m.selectedFile()
+			if f == nil || m.fullPaths[f.Path] {
+				return m, nil
+			}
+			if m.fullPaths == nil {
+				m.fullPaths = map[string]bool{}
+			}
+			m.fullPaths[f.Path] = true
+			return m, m.loadPreview()
+		case "refresh":
+			if !m.live() {
+				return m, nil
+			}
+			return m, reloadFiles(tr("refreshed"))
+		case "search":
+			m.searching = true
+			m.query = ""
+			return m, nil
+		case "export":
+			if files := m.markedFiles(); len(files) > 0 {
+				m.promptExport(files, "changes.patch")
+			} else if f := m.selectedFile(); f != nil {
+				m.promptExport([]git.FileStatus{*f}, patchName(f.Path))
+			}
+			return m, nil
+		case "export_all":
+			m.promptExport(m.files, "changes.patch")
+			return m, nil
+		case "next_commit", "prev_commit":
+			delta := 1
+			if keymap[msg.String()] == "prev_commit" {
+				delta = -1
+			}
+			if m.selectCommit(m.commitIdx + delta) {
+				return m, m.loadPreview()
+			}
+			return m, nil
+		case "edit":
+			return m, m.openEditor()
+		case "browse":
+			return m, m.openInBrowser()
+		case "difftool":
+			return m, m.openDifftool()
+		case "pick_base":
+			m.openBasePick()
+			return m, nil
+		case "stats":
+			m.showStats()
+			return m, nil
+		case "fixup":
+			return m, m.promptFixup()
+		case "stage":
+			if files := m.markedFiles(); len(files) > 0 {
+				return m, m.stageMarked(files, false)
+			}
+			return m, m.stageHunk(false)
+		case "unstage":
+			if files := m.markedFiles(); len(files) > 0 {
+				return m, m.stageMarked(files, true)
+			}
+			return m, m.stageHunk(true)
+		case "stash":
+			return m, m.promptStash()
+		case "ours":
+			return m, m.takeConflictSide("ours")
+		case "theirs":
+			return m, m.takeConflictSide("theirs")
+		case "commit":
+			return m, m.promptCommit(false)
+		case "amend":
+			return m, m.promptCommit(true)
+		case "symbols":
+			return m, m.showSymbols()
+		case "threads":
+			threadsExpanded.Store(!threadsExpanded.Load())
+			return m, m.reloadPreview()
+		case "ci":
+			if !flagCI || len(m.commits) > 0 {
+				return m, nil
+			}
+			return m, tea.Batch(m.showCI(), loadCI)
+		case "owners":
+			var path string
+			if f := m.selectedFile(); f != nil {
+				path = f.Path
+			}
+			m.byOwner = !m.byOwner
+			if m.byOwner && len(loadOwnerRules()) == 0 {
+				m.byOwner = false
+				m.message = tr("no CODEOWNERS file")
+				return m, nil
+			}
+			m.setFiles(m.files)
+			m.selectPath(path)
+			return m, nil
+		case "lint":
+			m.message = tr("linting…")
+			return m, m.runLint()
+		case "test":
+			if m.tests == nil || m.tests.done && m.showTests {
+				run, err := startTests(m.files)
+				if err != nil {
+					reportError(err)
+					return m, nil
+				}
+				session.record("run_tests", "", run.title)
+				m.tests = run
+				m.message = trf("running %s", run.title)
+				m.showTests = true
+				m.hunks = nil
+				m.viewport.setContent(run.render())
+				return m, run.wait()
+			}
+			m.showTests = true
+			m.hunks = nil
+			m.viewport.setContent(m.tests.render())
+			m.viewport.gotoBottom()
+			return m, nil
+		case "permalink":
+			return m, m.copyPermalink()
+		case "comment":
+			m.promptComment()
+			return m, nil
+		case "file_note":
+			m.promptFileNote()
+			return m, nil
+		case "viewed":
+			return m, m.toggleViewed()
+		case "submit_review":
+			m.promptSubmitReview()
+			return m, nil
+		case "comments":
+			return m, m.showComments()
+		case "errors":
+			return m, m.showErrors()
+		case "help":
+			return m, m.showKeys()
+		case "range":
+			if len(m.commits) > 0 {
+				if m.commitAnchor >= 0 {
+					m.commitAnchor = -1
+				} else {
+					m.commitAnchor = m.commitIdx
+					m.message = tr("range start set, move with ] [ then F")
+				}
+			}
+			return m, nil
+		case "format_patch":
+			m.promptFormatPatch()
+			return m, nil
+		case "next_hunk":
+			m.jumpHunk(1)
+			return m, nil
+		case "prev_hunk":
+			m.jumpHunk(-1)
+			return m, nil
+		case "next_section":
+			m.jumpFile(1)
+			return m, nil
+		case "prev_section":
+			m.jumpFile(-1)
+			return m, nil
+		case "mark_hunk":
+			m.toggleMark()
+			return m, nil
+		case "export_hunks":
+			if len(m.marked) == 0 {
+				m.message = tr("no hunks marked")
+				return m, nil
+			}
+			marked := m.marked
+			m.prompt = &prompt{
+				label: tr("export hunks to: "),
+				input: "hunks.patch",
+				submit: func(path string) tea.Cmd {
+					if path == "" {
+						return nil
+					}
+					session.record("export_hunks", path, fmt.Sprintf("%d hunks", len(marked)))
+					return func() tea.Msg {
+						if err := os.WriteFile(userPath(path), []byte(buildHunkPatch(marked)), 0o644); err != nil {
+							return errorMsg{err: fmt.Errorf("export failed: %w", err)}
+						}
+						return statusMsg{text: trf("wrote %d hunks to %s", len(marked), path)}
+					}
+				},
+			}
+			return m, nil
+		case "yank":
+			if m.selectedFile() != nil {
+				m.yanking = true
+				m.message = tr("yank: p path  h hunk  d diff  i issue link")
+			}
+			return m, nil
+		}
+
+	case tea.MouseMsg:
+		return m.mouse(msg)
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height - statusBarH
+		m.layout()
+		if m.paging {
+			// rendered again for the new width
+			m.viewport.width = m.width - pagerGutterW
+			return m, m.openFullDiff()
+		}
+		if !m.ready {
+			m.ready = true
+			m.moveCursor(0)
+			return m, m.loadPreview()
+		}
+		return m, m.loadPreview()
+
+	case diffSearchMsg:
+		m.viewport.query = msg.query
+		if msg.query != "" {
+			streamAll()
+			m.previewFocus = true
+			m.matchLine = m.viewport.yOffset - 1
+			m.nextMatch(1)
+		}
+		return m, nil
+
+	case diffLoadedMsg:
+		if m.paging {
+			return m, nil
+		}
+		if msg.seq == 0 {
+			// other content replaces the preview, so drop loads in flight
+			previewSeq.Add(1)
+		} else if msg.seq != previewSeq.Load() {
+			return m, nil
+		}
+		m.showTests = false
+		// a refreshed or streaming preview of the same file keeps its place
+		if msg.path == "" || msg.path != m.shownPath {
+			m.hunkIdx = 0
+			m.matchLine = -1
+			m.foldToggled = nil
+			m.followEnd = false
+			m.matchWaiting = false
+			m.viewport.gotoTop()
+		}
+		m.unfolded, m.unfoldedHunks = msg.content, msg.hunks
+		m.showFolds()
+		if m.restoreOffset > 0 && msg.path == m.restorePath {
+			// back where gd was left, once enough of the preview is in
+			m.viewport.setYOffset(m.restoreOffset)
+			m.syncHunk()
+			if msg.more == nil {
+				m.restoreOffset = 0
+			}
+		}
+		m.loadedSeq = msg.seq
+		m.streaming = msg.more != nil
+		if m.followEnd {
+			m.viewport.gotoBottom()
+			m.syncHunk()
+		}
+		if m.matchWaiting {
+			m.nextMatch(1)
+		}
+		m.shownPath = msg.path
+		if msg.more != nil {
+			return m, waitStream(msg.more)
+		}
+		return m, m.preloadAdjacent()
+
+	case spinMsg:
+		if msg.seq != previewSeq.Load() || msg.seq == m.loadedSeq {
+			return m, nil
+		}
+		m.spin = spinner{seq: msg.seq, path: msg.path, frame: m.spin.frame + 1}
+		return m, msg.next()
+
+	case pageMsg:
+		if msg.external {
+			return m, page(msg)
+		}
+		m.openPager(msg)
+		return m, nil
+
+	case imageMsg:
+		return m, tea.Exec(&imageView{msg: msg}, func(err error) tea.Msg {
+			return execFinishedMsg{err: err}
+		})
+
+	case execFinishedMsg:
+		reportError(msg.err)
+		return m, m.reloadPreview()
+
+	case committedMsg:
+		if msg.err != nil {
+			reportError(fmt.Errorf("git commit: %w", msg.err))
+			return m, nil
+		}
+		return m, reloadFiles(tr("committed"))
+
+	case statusMsg:
+		m.message = msg.text
+		return m, nil
+
+	case reviewSubmittedMsg:
+		m.message = msg.text
+		return m, loadThreads
+
+	case errorMsg:
+		reportError(msg.err)
+		return m, nil
+
+	case userCommandMsg:
+		return m, m.showUserCommand(msg)
+
+	case promptMsg:
+		m.prompt = msg.prompt
+		return m, nil
+
+	case fetchTickMsg:
+		return m, tea.Batch(refetch(m.fetchRef), fetchTick(m.fetchEvery))
+
+	case ciMsg:
+		if msg.poll {
+			m.ciPolling = false
+		}
+		if msg.err != nil {
+			return m, nil
+		}
+		m.ci = msg.runs
+		if ciPending(m.ci) && !m.ciPolling {
+			m.ciPolling = true
+			return m, pollCI()
+		}
+		return m, nil
+
+	case threadsMsg:
+		threadsMu.Lock()
+		threadsByPath = msg.threads
+		threadsMu.Unlock()
+		if msg.count > 0 {
+			m.message = trf("%d review threads (# to expand)", msg.count)
+			return m, m.reloadPreview()
+		}
+		return m, nil
+
+	case lintDoneMsg:
+		if msg.err != nil {
+			reportError(fmt.Errorf("lint: %w", msg.err))
+			return m, nil
+		}
+		lintMu.Lock()
+		lintResults = msg.results
+		lintMu.Unlock()
+		m.message = trf("%d lint warnings", msg.count)
+		return m, m.reloadPreview()
+
+	case testLineMsg:
+		m.tests.add(msg.line)
+		if m.showTests {
+			follow := m.viewport.atBottom()
+			m.viewport.setContent(m.tests.render())
+			if follow {
+				m.viewport.gotoBottom()
+			}
+		}
+		return m, m.tests.wait()
+
+	case testDoneMsg:
+		m.tests.done, m.tests.err = true, msg.err
+		m.tests.elapsed = time.Since(m.tests.started)
+		if m.showTests {
+			m.viewport.setContent(m.tests.render())
+		}
+		if msg.err != nil {
+			m.message = tr("tests failed (T to view)")
+		} else {
+			m.message = tr("tests passed")
+		}
+		return m, nil
+
+	case stashesLoadedMsg:
+		m.message = msg.text
+		m.commits = msg.stashes
+		if len(m.commits) == 0 {
+			m.setFiles(nil)
+			return m, m.reloadPreview()
+		}
+		m.commitIdx = min(m.commitIdx, len(m.commits)-1)
+		m.setFiles(m.commits[m.commitIdx].files)
+		return m, m.reloadPreview()
+
+	case filesLoadedMsg:
+		var path string
+		if f := m.selectedFile(); f != nil {
+			path = f.Path
+		}
+		m.setFiles(msg.files)
+		m.selectPath(path)
+		if msg.text != "" {
+			m.message = msg.text
+		}
+		return m, tea.Batch(m.reloadPreview(), loadRepoInfo, m.loadViewed(msg.files))
+
+	case viewedMsg:
+		m.viewed = msg.viewed
+		if msg.text != "" {
+			m.message = msg.text
+		}
+		return m, nil
+
+	case repoInfoMsg:
+		m.repo = repoInfo(msg)
+		return m, nil
+
+	case startupFilesMsg:
+		m.starting = nil
+		if msg.err != nil {
+			m.startErr = msg.err
+			return m, tea.Quit
+		}
+		if len(msg.files) == 0 && m.watch == nil && m.poll == 0 {
+			m.noChanges = true
+			return m, tea.Quit
+		}
+		session.track(msg.files)
+		m.setFiles(msg.files)
+		m.applyState()
+		if !m.ready {
+			return m, m.loadViewed(msg.files)
+		}
+		return m, tea.Batch(m.loadPreview(), m.loadViewed(msg.files))
+
+	case worktreeChangedMsg:
+		return m, tea.Batch(reloadFiles(""), m.watch.wait())
+
+	case tea.FocusMsg:
+		// back from the editor or another window, with whatever changed there
+		if !m.live() || m.starting != nil {
+			return m, nil
+		}
+		return m, reloadFiles("")
+
+	case polledMsg:
+		next := pollStatus(m.poll)
+		if msg.err != nil {
+			reportError(msg.err)
+			return m, next
+		}
+		changed := m.polledSum != 0 && msg.sum != m.polledSum
+		m.polledSum = msg.sum
+		if changed {
+			return m, tea.Batch(reloadFiles(""), next)
+		}
+		return m, next
+	}
+
+	return m, nil
+}
+
+func (m model) View() string {
+	if !m.ready {
+		return tr("Loading...")
+	}
+	if flagAccessible {
+		return m.accessibleView()
+	}
+	if m.paging || m.treeHidden {
+		return m.fullView()
+	}
+	tree := strings.Split(m.renderTree(), "\n")
+	treeW := 0
+	for _, l := range tree {
+		treeW = max(treeW, ansi.StringWidth(l))
+	}
+	border := borderSty.Render("│")
+	if m.previewFocus {
+		border = searchSty.Render("│")
+	}
+	gutter, diff, bar := m.renderHunkGutter(), m.overlay().overlay(m.previewRows(), m.viewport.width), m.renderScrollbar()
+
+	// Rows are joined by hand rather than with lipgloss.JoinHorizontal, which
+	// would measure and pad every line of every pane. Only the preview's rows
+	// are padded, out to the scrollbar when it has one. A row only changes when something on
+	// it does, and bubbletea repaints only those.
+	var b strings.Builder
+	for i := range max(len(tree), m.height) {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		var t string
+		if i < len(tree) {
+			t = tree[i]
+		}
+		b.WriteString(t)
+		b.WriteString(spaces(treeW - ansi.StringWidth(t)))
+		if i < m.height {
+			b.WriteString(border)
+			b.WriteString(gutter[i])
+			b.WriteString(diff[i])
+			if bar != nil {
+				b.WriteString(spaces(m.viewport.width - ansi.StringWidth(diff[i])))
+				b.WriteString(bar[i])
+			}
+		}
+	}
+	b.WriteByte('\n')
+	b.WriteString(m.renderStatusBar())
+	return b.String()
+}
+
+func loadFiles() ([]git.FileStatus, error) {
+	defer trace("status", "")()
+	if flagMain {
+		files, err := getMainFiles()
+		if err == nil && flagLeftovers {
+			addLeftovers(files)
+		}
+		return files, err
+	}
+	if files, ok := goGitFiles(); ok {
+		if flagLeftovers {
+			addLeftovers(files)
+		}
+		return files, nil
+	}
+	// numstat doesn't need status's output, so the two run side by side
+	type statsResult struct {
+		stats map[string]git.FileStat
+		err   error
+	}
+	statsc := make(chan statsResult, 1)
+	go func() {
+		stats, err := worktreeStats()
+		statsc <- statsResult{stats, err}
+	}()
+	files, err := getChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	files = filterKind(files)
+	r := <-statsc
+	// the tree still works without counts
+	reportError(r.err)
+	addStats(files, r.stats)
+	if flagLeftovers {
+		addLeftovers(files)
+	}
+	return files, nil
+}
+
+// Main runs gd with the command line it was started with.
+func Main() {
+	// Windows consoles need VT processing switched on for ANSI color; this
+	// is a no-op elsewhere.
+	if restore, err := termenv.EnableVirtualTerminalProcessing(termenv.DefaultOutput()); err == nil {
+		defer restore()
+	}
+	enterRepoRoot()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "prs":
+			runPRs(os.Args[2:])
+			return
+		case "review":
+			runReview(os.Args[2:])
+			return
+		case "keys":
+			runKeys()
+			return
+		case "incoming":
+			runIncoming(os.Args[2:])
+			return
+		case "stash":
+			runStash(os.Args[2:])
+			return
+		}
+	}
+
+	flag.BoolVar(&flagMain, "main", false, "diff against main branch")
+	flag.BoolVar(&flagStaged, "staged", false, "show only the changes staged in the index; U cycles through staged, unstaged, and untracked")
+	flag.BoolVar(&flagUnstaged, "unstaged", false, "show only the changes not yet staged")
+	flag.BoolVar(&flagUntracked, "untracked", false, "show only untracked files")
+	flag.BoolVar(&flagJSON, "json", false, "print the changeset as JSON and exit")
+	flag.BoolVar(&flagCheck, "check", false, "print a summary and exit 1 if there are changes")
+	flag.BoolVar(&flagStat, "stat", false, "print a diffstat and exit")
+	flag.BoolVar(&flagLinks, "links", true, "emit OSC 8 hyperlinks on file names, line numbers, and issue references")
+	flag.StringVar(&flagOutput, "output", "", "also write the raw patch being viewed to `file`")
+	flag.BoolVar(&flagPrint, "print", false, "print the rendered diff instead of opening the browser")
+	flag.BoolVar(&flagByCommit, "by-commit", false, "with --print --main, print one section per commit")
+	flag.StringVar(&flagSessionLog, "session-log", "", "record files viewed and actions taken to a JSON `file`")
+	flag.StringVar(&flagDifftool, "difftool", "", "`tool` for the D key (default: git's diff.tool)")
+	flag.BoolVar(&flagNoIndex, "no-index", false, "compare the two paths given, files or directories, as git diff --no-index; the default for paths outside a repository")
+	flag.StringVar(&flagPatch, "patch", "", "browse the unified diff in `file` instead of the repository; gd - reads one from stdin")
+	flag.BoolVar(&flagStash, "stash", false, "browse the stashes, from stash@{n} when n follows; the same as gd stash")
+	flag.BoolVar(&flagReflog, "reflog", false, "browse the reflog of HEAD, or of the ref that follows, each entry's changes from the one before")
+	flag.Var(&flagPR, "pr", "review pull request `n` from origin without checking it out; --pr alone picks the current branch's")
+	flag.StringVar(&flagBase, "base", "", "compare `ref`...HEAD instead of the worktree, as --main does with main")
+	flag.StringVar(&flagAgainst, "against", "", "with --check, compare `ref`...HEAD instead of the worktree")
+	flag.StringVar(&flagIssueURL, "issue-url", "", "link ABC-123 style issue keys to this URL `template`, with {id} for the key")
+	flag.StringVar(&flagLSP, "lsp", "", "language server `command` for the K key, e.g. gopls")
+	flag.StringVar(&flagTestCmd, "test-cmd", "", "shell `command` for the T key; {files} and {packages} expand to the changed ones (default: go test)")
+	flag.StringVar(&flagLint, "lint", "", "shell `command` for the W key; {files} and {packages} expand to the changed ones (default: golangci-lint or go vet)")
+	flag.StringVar(&flagCover, "cover", "", "Go coverprofile `file` for marking untested added lines (default: coverage.out or cover.out in the repo)")
+	flag.BoolVar(&flagCI, "ci", true, "show GitHub Actions status for the branch, via gh")
+	flag.IntVar(&flagMaxPreview, "max-preview", 1024, "preview only the first `KB` of larger diffs until L is pressed; 0 for no limit")
+	flag.IntVar(&flagMaxPreviewLines, "max-preview-lines", 10000, "preview only the first `lines` of longer diffs until L is pressed; 0 for no limit")
+	flag.IntVar(&flagCacheMB, "cache-mb", 256, "keep at most this many `MB` of rendered previews; 0 for no limit")
+	flag.IntVar(&flagCacheEntries, "cache-entries", 1000, "keep at most this many rendered previews; 0 for no limit")
+	flag.BoolVar(&flagWatch, "watch", false, "refresh when files in the worktree or the index change")
+	flag.DurationVar(&flagPoll, "poll", 0, "check for changes this often, e.g. 2s, where --watch misses them (NFS, some containers)")
+	flag.StringVar(&flagTmux, "tmux", "", "inside tmux, open the full diff and editor in a `split`, vsplit, or window")
+	flag.StringVar(&flagCPUProfile, "cpuprofile", "", "write a CPU profile to `file`")
+	flag.StringVar(&flagMemProfile, "memprofile", "", "write a heap profile to `file` on exit")
+	flag.StringVar(&flagTraceTimings, "trace-timings", "", "log how long git, parsing, and rendering take for each file to `file`")
+	flag.IntVar(&flagFPS, "fps", 0, "redraw at most `n` times a second (default 60, or 30 over ssh)")
+	flag.StringVar(&flagGitBackend, "git-backend", flagGitBackend, "read status, diffs, and refs by running `backend` git, or in process with go-git where it reads them as git would")
+	flag.StringVar(&flagSyntaxTheme, "syntax-theme", "", "chroma `style` for syntax colors, whatever the theme's")
+	flag.StringVar(&flagTheme, "theme", "", "color `theme`: dark, light, solarized, gruvbox, high-contrast, or one from the config file (default: dark or light to match the terminal)")
+	flag.StringVar(&flagPager, "pager", "", "`pager` command for enter's full-file view, e.g. less or delta, or git for git's pager (default: gd's own, full screen)")
+	flag.BoolVar(&flagNoColor, "no-color", false, "draw without colors, as the NO_COLOR environment variable does")
+	flag.BoolVar(&flagUnified, "unified", false, "always show diffs unified, in one column")
+	flag.BoolVar(&flagSplit, "split", false, "always show diffs side by side")
+	flag.IntVar(&flagTabWidth, "tab-width", 0, "`columns` a tab is drawn as (default 4, or tab_width in the config file)")
+	flag.BoolVar(&flagLeftovers, "leftovers", false, "flag added TODOs, debug prints, and conflict markers, with a count per file in the tree")
+	flag.BoolVar(&flagShowWhitespace, "show-whitespace", false, "draw tabs as → and trailing spaces as ·, highlighted on changed lines")
+	flag.StringVar(&flagImages, "images", "auto", "how to draw images: kitty (in the preview), iterm or sixel (full screen with enter), none, or auto to tell from the terminal")
+	flag.BoolVar(&flagIgnoreAllSpace, "w", false, "hide changes in whitespace, as git diff -w does; I toggles it")
+	flag.BoolVar(&flagIgnoreAllSpace, "ignore-all-space", false, "the same as -w")
+	flag.BoolVar(&flagIgnoreBlankLines, "ignore-blank-lines", false, "hide changes whose lines are all blank")
+	flag.BoolVar(&flagIgnoreSpaceAtEOL, "ignore-space-at-eol", false, "hide changes in whitespace at the ends of lines")
+	flag.Func("U", "show `n` lines of context around changes, as git diff -U does; + and - change it", setContextFlag)
+	flag.Func("context", "the same as -U `n`", setContextFlag)
+	flag.BoolVar(&flagAccessible, "accessible", false, "for screen readers: plain one-column text, changes labeled in words, no alternate screen")
+	flag.BoolVar(&flagAccessible, "plain", false, "the same as --accessible")
+	flag.Var(&flagDebug, "debug", "log git commands, key presses, and errors for a bug report, to gd-debug.log in the temp directory or --debug=`file`")
+	flag.StringVar(&flagScript, "script", "", "replay the keys in `file` without a terminal and print the screen; - reads stdin")
+	flag.Parse()
+	parsePRNumber()
+
+	stopProfiling, err := startProfiling()
+	defer stopProfiling()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	stopDebug, err := startDebug()
+	defer stopDebug()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := loadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: config: %v\n", err)
+		os.Exit(1)
+	}
+	setLanguage(configLang)
+	if flagTabWidth > 0 {
+		tabSpaces = strings.Repeat(" ", flagTabWidth)
+	}
+
+	if !term.IsTerminal(os.Stdout.Fd()) {
+		flagLinks = false
+	}
+	if _, ok := themes[flagTheme]; flagTheme != "" && !ok {
+		fmt.Fprintf(os.Stderr, "error: unknown theme %q: use %s\n", flagTheme, themeNames())
+		os.Exit(2)
+	}
+	if !slices.Contains(gitBackends, flagGitBackend) {
+		fmt.Fprintf(os.Stderr, "error: unknown --git-backend %q: use git or go-git\n", flagGitBackend)
+		os.Exit(2)
+	}
+	if flagSyntaxTheme != "" && styles.Registry[flagSyntaxTheme] == nil {
+		fmt.Fprintf(os.Stderr, "error: unknown syntax theme %q: use a chroma style, such as github-dark or dracula\n", flagSyntaxTheme)
+		os.Exit(2)
+	}
+	if flagAccessible {
+		lipgloss.SetColorProfile(termenv.Ascii)
+		flagLinks = false
+	}
+	if flagNoColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	switch {
+	case flagUnified && flagSplit:
+		fmt.Fprintln(os.Stderr, "error: --unified and --split can't be used together")
+		os.Exit(2)
+	case flagUnified:
+		diffLayout.Store(layoutUnified)
+	case flagSplit:
+		diffLayout.Store(layoutSplit)
+	}
+	setSpaceOpts()
+	switch flagImages {
+	case "auto", graphicsNone, graphicsKitty, graphicsITerm, graphicsSixel:
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown --images %q: use auto, kitty, iterm, sixel, or none\n", flagImages)
+		os.Exit(2)
+	}
+
+	if flag.Arg(0) == "-" {
+		flagPatch = "-"
+	}
+	if flagPatch != "" {
+		initTheme()
+		runPatch(flagPatch)
+		return
+	}
+	if flagStash {
+		runStash(flag.Args())
+		return
+	}
+	if flagReflog {
+		runReflog(flag.Args())
+		return
+	}
+	if a, b, ok := noIndexArgs(flag.Args()); ok {
+		initTheme()
+		runNoIndex(a, b)
+		return
+	}
+	if flagPR.set {
+		if err := setPR(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// a leading -- is taken by the flag package, leaving only pathspecs
+	if args := flag.Args(); flagPR.set || len(os.Args) > len(args) && os.Args[len(os.Args)-len(args)-1] == "--" {
+		pathspecs = rootPathspecs(args)
+	} else {
+		revs, paths, err := splitRevArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		setRevs(revs)
+		pathspecs = rootPathspecs(paths)
+	}
+	if flagAgainst != "" {
+		if !flagCheck {
+			fmt.Fprintln(os.Stderr, "error: --against goes with --check; use --base to compare the diff against a ref")
+			os.Exit(2)
+		}
+		flagBase = flagAgainst
+	}
+	if flagBase != "" {
+		flagMain = true
+		baseNamed = true
+		baseRef = flagBase
+	}
+	if flagMain && !baseNamed && configBase != "" {
+		baseNamed = true
+		baseRef = configBase
+	}
+	if err := setKindFlags(flagStaged, flagUnstaged, flagUntracked); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	var baseNote string
+	if flagMain {
+		interactive := term.IsTerminal(os.Stdout.Fd()) && !flagPrint && !flagStat && !flagJSON && !flagCheck && flagScript == ""
+		if baseNote = pickBase(interactive); baseNote != "" && !interactive {
+			fmt.Fprintln(os.Stderr, "gd: "+baseNote)
+		}
+	}
+	var saved *uiState
+	if !flagStat && !flagPrint && !flagJSON && !flagCheck && !flagByCommit && flagScript == "" {
+		saved = savedState(flagStaged || flagUnstaged || flagUntracked)
+	}
+	var loading <-chan filesResult
+	if !flagCheck && !flagByCommit {
+		// git works while the terminal is asked for its background color
+		loading = loadFilesAsync()
+		go branchIssue() // the tree's title needs it for the first frame
+	}
+	initTheme()
+	if flagCheck {
+		code := runCheck()
+		stopProfiling()
+		os.Exit(code)
+	}
+	if p := findCoverProfile(); p != "" {
+		var err error
+		if coverage, err = loadCoverage(p); err != nil && flagCover != "" {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if flagByCommit {
+		if !flagMain || !flagPrint {
+			fmt.Fprintln(os.Stderr, "error: --by-commit requires --print and --main")
+			os.Exit(2)
+		}
+		if err := writeByCommit(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// the browser opens without the files when git is slow, and they fill
+	// in when it's done; everything else needs them up front
+	var wait time.Duration
+	if !flagStat && !flagPrint && !flagJSON && flagScript == "" && flagOutput == "" {
+		wait = startupWait
+	}
+	res, loaded := awaitFiles(loading, wait)
+	files := res.files
+	if res.err != nil {
+		failStartup(res.err)
+	}
+	if flagStat {
+		writeStat(os.Stdout, statDiffs(files))
+		return
+	}
+	if flagPrint {
+		writePrint(os.Stdout, files)
+		return
+	}
+	if flagJSON {
+		if err := writeJSON(os.Stdout, files); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if loaded && len(files) == 0 && !flagWatch && flagPoll == 0 {
+		fmt.Println(noChangesText())
+		return
+	}
+
+	m := initialModel(files)
+	m.message = baseNote
+	if !loaded {
+		m.starting = loading
+	}
+	if flagWatch {
+		w, err := startWatch()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: watch: %v\n", err)
+			os.Exit(1)
+		}
+		m.watch = w
+	}
+	m.poll = flagPoll
+	if flagScript != "" {
+		if err := runScript(flagScript, m); err != nil {
+			fmt.Fprintf(os.Stderr, "error: script: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	linkWeb = true
+	m.saved = saved
+	m.applyState()
+	runProgram(m, files)
+}
+
+// runProgram runs the browser for m, recording a session log of files when
+// requested.
+func runProgram(m model, files []git.FileStatus, opts ...tea.ProgramOption) {
+	if flagSessionLog != "" {
+		startSession(flagSessionLog, files)
+	}
+
+	opts = append([]tea.ProgramOption{tea.WithFPS(frameRate()), tea.WithReportFocus()}, opts...)
+	if !flagAccessible {
+		opts = append(opts, tea.WithAltScreen())
+		if configMouse {
+			opts = append(opts, tea.WithMouseCellMotion())
+		}
+	}
+	p := tea.NewProgram(m, opts...)
+	final, err := p.Run()
+	stopLanguageServer()
+	stopCatFile()
+	if serr := session.save(); serr != nil {
+		fmt.Fprintf(os.Stderr, "error: session log: %v\n", serr)
+	}
+	if fm, ok := final.(model); ok && err == nil {
+		if serr := fm.saveState(); serr != nil {
+			fmt.Fprintf(os.Stderr, "error: saving state: %v\n", serr)
+		}
+		if fm.startErr != nil {
+			failStartup(fm.startErr)
+		}
+		if fm.noChanges {
+			fmt.Println(noChangesText())
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// frameRate is how often the screen may be redrawn. Over ssh every frame
+// crosses the network, so gd redraws less often there.
+func frameRate() int {
+	if flagFPS > 0 {
+		return flagFPS
+	}
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "" {
+		return 30
+	}
+	return 60
+}
+
+func allCommitFiles(commits []commit) []git.FileStatus {
+	var files []git.FileStatus
+	for _, c := range commits {
+		files = append(files, c.files...)
+	}
+	return files
+}
+
+// runPatch browses the patch in path, or on stdin when path is "-", or prints
+// it with --print, --stat, or --json.
+func runPatch(path string) {
+	var r io.Reader = os.Stdin
+	var opts []tea.ProgramOption
+	if path == "-" {
+		// stdin is the patch, so keys are read from the terminal directly
+		opts = append(opts, tea.WithInputTTY())
+	} else {
+		f, err := os.Open(userPath(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+	browsePatch(r, opts...)
+}
+
+// browsePatch browses the patch read from r, or prints it with --print,
+// --stat, or --json.
+func browsePatch(r io.Reader, opts ...tea.ProgramOption) {
+	commits, err := readPatch(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(commits) == 0 {
+		fmt.Println(tr("No changes."))
+		return
+	}
+
+	files := allCommitFiles(commits)
+	switch {
+	case flagStat:
+		writeStat(os.Stdout, statDiffs(files))
+	case flagPrint:
+		writePrint(os.Stdout, files)
+	case flagJSON:
+		if err := writeJSON(os.Stdout, files); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		runProgram(commitsModel(commits), files, opts...)
+	}
+}
@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Comparing Paths ====================
+
+// noIndexArgs reports whether args are two files or directories to compare
+// with each other rather than commits or pathspecs: with --no-index, outside
+// a repository, or when either is outside the one gd started in.
+func noIndexArgs(args []string) (string, string, bool) {
+	if flagNoIndex && len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "error: --no-index compares two paths")
+		os.Exit(2)
+	}
+	if len(args) != 2 {
+		return "", "", false
+	}
+	for _, a := range args {
+		if _, err := os.Stat(userPath(a)); err != nil {
+			if flagNoIndex {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return "", "", false
+		}
+	}
+	if flagNoIndex {
+		return args[0], args[1], true
+	}
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return args[0], args[1], true
+	}
+	top := strings.TrimSpace(string(out))
+	for _, a := range args {
+		abs, err := filepath.Abs(userPath(a))
+		if err != nil {
+			return "", "", false
+		}
+		if rel, err := filepath.Rel(top, abs); err != nil || !filepath.IsLocal(rel) {
+			return args[0], args[1], true
+		}
+	}
+	return "", "", false
+}
+
+// comparedPaths names the paths runNoIndex compares, for the status bar.
+var comparedPaths string
+
+// runNoIndex browses the differences between paths a and b, two files or
+// two directories, as a patch.
+func runNoIndex(a, b string) {
+	patch, err := noIndexDiff(a, b)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	comparedPaths = a + " ↔ " + b
+	browsePatch(strings.NewReader(patch))
+}
+
+// noIndexDiff runs git diff --no-index on a and b, naming the files in it
+// from inside the directories compared, or by their base names, so the same
+// file on both sides reads as changed rather than renamed.
+func noIndexDiff(a, b string) (string, error) {
+	absA, err := filepath.Abs(userPath(a))
+	if err != nil {
+		return "", err
+	}
+	absB, err := filepath.Abs(userPath(b))
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"diff", "--no-index"}, shownDiffOpts()...)
+	out, err := exec.Command("git", append(args, "--", absA, absB)...).Output()
+	// git diff --no-index exits 1 when the paths differ
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", fmt.Errorf("git diff --no-index: %w", git.StderrError(err))
+	}
+	return renameSides(string(out), noIndexName(absA), noIndexName(absB)), nil
+}
+
+// noIndexName is how git diff --no-index names path, an absolute path, after
+// its a/ or b/: without the leading slash.
+func noIndexName(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+// renameSides rewrites the file names in patch's headers that start with
+// the paths compared, a and b: a directory's files go by their path within
+// it, and a file by its base name. git names an added or deleted file from
+// the side it's on for both of its names, and renames by absolute path.
+func renameSides(patch, a, b string) string {
+	// name is prefixed with a/ or b/ in diff --git, ---, and +++ lines
+	short := func(name string, prefixed bool) string {
+		if name == "/dev/null" {
+			return name
+		}
+		keep := ""
+		if prefixed && (strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/")) {
+			keep, name = name[:2], name[2:]
+		}
+		name = strings.TrimPrefix(name, "/")
+		for _, side := range []string{a, b} {
+			if rest, ok := strings.CutPrefix(name, side+"/"); ok {
+				return keep + rest
+			}
+			if name == side {
+				return keep + filepath.Base(side)
+			}
+		}
+		return keep + name
+	}
+	lines := strings.SplitAfter(patch, "\n")
+	header := false
+	for i, l := range lines {
+		text := strings.TrimSuffix(l, "\n")
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			header = true
+			// the names are only known to be split at " b/" when unquoted
+			rest := text[len("diff --git "):]
+			for _, side := range []string{b, a} {
+				if j := strings.LastIndex(rest, " b/"+side); j > 0 {
+					lines[i] = "diff --git " + short(rest[:j], true) + " " + short(rest[j+1:], true) + "\n"
+					break
+				}
+			}
+		case !header:
+		case strings.HasPrefix(l, "@@"):
+			header = false
+		case strings.HasPrefix(l, "--- "):
+			lines[i] = "--- " + short(text[4:], true) + "\n"
+		case strings.HasPrefix(l, "+++ "):
+			lines[i] = "+++ " + short(text[4:], true) + "\n"
+		case strings.HasPrefix(l, "rename from "), strings.HasPrefix(l, "copy from "):
+			k := strings.Index(text, "from ") + len("from ")
+			lines[i] = text[:k] + short(text[k:], false) + "\n"
+		case strings.HasPrefix(l, "rename to "), strings.HasPrefix(l, "copy to "):
+			k := strings.Index(text, "to ") + len("to ")
+			lines[i] = text[:k] + short(text[k:], false) + "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
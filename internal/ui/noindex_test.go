@@ -0,0 +1,44 @@
+package ui
+
+import "testing"
+
+func TestRenameSides(t *testing.T) {
+	for _, tc := range []struct {
+		name, a, b, patch, want string
+	}{
+		{
+			"directories",
+			"tmp/x", "tmp/y",
+			"diff --git a/tmp/x/f.txt b/tmp/y/f.txt\nindex 1..2 100644\n--- a/tmp/x/f.txt\n+++ b/tmp/y/f.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			"diff --git a/f.txt b/f.txt\nindex 1..2 100644\n--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			"added file",
+			"tmp/x", "tmp/y",
+			"diff --git a/tmp/y/new.txt b/tmp/y/new.txt\nnew file mode 100644\nindex 0..1\n--- /dev/null\n+++ b/tmp/y/new.txt\n@@ -0,0 +1 @@\n+n\n",
+			"diff --git a/new.txt b/new.txt\nnew file mode 100644\nindex 0..1\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+n\n",
+		},
+		{
+			"files by base name",
+			"tmp/one.txt", "tmp/two.txt",
+			"diff --git a/tmp/one.txt b/tmp/two.txt\n--- a/tmp/one.txt\n+++ b/tmp/two.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			"diff --git a/one.txt b/two.txt\n--- a/one.txt\n+++ b/two.txt\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			"renamed within",
+			"tmp/x", "tmp/y",
+			"diff --git a/tmp/x/old.txt b/tmp/y/new.txt\nsimilarity index 100%\nrename from tmp/x/old.txt\nrename to tmp/y/new.txt\n",
+			"diff --git a/old.txt b/new.txt\nsimilarity index 100%\nrename from old.txt\nrename to new.txt\n",
+		},
+		{
+			"body left alone",
+			"tmp/x", "tmp/y",
+			"diff --git a/tmp/x/f b/tmp/y/f\n--- a/tmp/x/f\n+++ b/tmp/y/f\n@@ -1 +1 @@\n--- a/tmp/x/f\n+++ b/tmp/y/f\n",
+			"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n--- a/tmp/x/f\n+++ b/tmp/y/f\n",
+		},
+	} {
+		if got := renameSides(tc.patch, tc.a, tc.b); got != tc.want {
+			t.Errorf("%s: renameSides =\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
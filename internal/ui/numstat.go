@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Numstat ====================
+
+// numstat runs a single git diff --raw --numstat -M -z for every file the
+// pathspecs match, rather than one per file.
+func numstat(args ...string) ([]git.NumstatEntry, error) {
+	cmd := append([]string{"diff", "--no-ext-diff", "--raw", "--numstat", "-M", "-z"}, args...)
+	cmd = append(append(cmd, "--"), pathspecs...)
+	out, err := exec.Command("git", cmd...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff --numstat %s: %w", strings.Join(args, " "), git.StderrError(err))
+	}
+	return git.ParseNumstat(string(out))
+}
+
+// worktreeStats runs one git diff against HEAD, which covers staged and
+// unstaged changes together, or the one the tree is narrowed to.
+func worktreeStats() (map[string]git.FileStat, error) {
+	entries, err := numstat(kindDiffArgs(showKind.Load())...)
+	if err != nil && showKind.Load() == kindAll {
+		// no commits yet
+		if entries, err = numstat(git.EmptyTree); err != nil {
+			return nil, err
+		}
+	}
+	stats := make(map[string]git.FileStat, len(entries))
+	for _, e := range entries {
+		stats[e.Path] = e.Stat
+	}
+	return stats, nil
+}
+
+// addStats fills in the stats of files changed in the worktree from
+// worktreeStats, counting untracked files straight from disk.
+func addStats(files []git.FileStatus, stats map[string]git.FileStat) {
+	for i := range files {
+		if files[i].Untracked {
+			files[i].Stat = untrackedStat(files[i].Path)
+		} else {
+			files[i].Stat = stats[files[i].Path]
+		}
+	}
+}
+
+// untrackedStat counts an untracked file's lines as additions, calling it
+// binary the way git does, by a NUL in its first 8000 bytes.
+func untrackedStat(path string) git.FileStat {
+	data, err := os.ReadFile(filepath.Join(repoRoot(), path))
+	if err != nil {
+		return git.FileStat{}
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return git.FileStat{Binary: true}
+	}
+	n := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return git.FileStat{Added: n}
+}
@@ -0,0 +1,132 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/arnavsurve/gd/internal/tree"
+)
+
+// ==================== Code Owners ====================
+
+type ownerRule struct {
+	re     *regexp.Regexp
+	owners []string
+}
+
+var (
+	ownerRulesOnce sync.Once
+	ownerRules     []ownerRule
+)
+
+// codeownersFiles are checked in the order GitHub uses.
+var codeownersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+func loadOwnerRules() []ownerRule {
+	ownerRulesOnce.Do(func() {
+		for _, name := range codeownersFiles {
+			data, err := os.ReadFile(filepath.Join(repoRoot(), name))
+			if err != nil {
+				continue
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+					continue
+				}
+				var owners []string
+				for _, o := range fields[1:] {
+					if strings.HasPrefix(o, "#") {
+						break
+					}
+					owners = append(owners, o)
+				}
+				if re, err := regexp.Compile(ownerPattern(fields[0])); err == nil {
+					ownerRules = append(ownerRules, ownerRule{re: re, owners: owners})
+				}
+			}
+			return
+		}
+	})
+	return ownerRules
+}
+
+// ownerPattern translates a gitignore-style CODEOWNERS pattern to a regexp
+// over repo-relative paths.
+func ownerPattern(p string) string {
+	anchored := strings.Contains(strings.TrimSuffix(p, "/"), "/")
+	p = strings.TrimPrefix(p, "/")
+	if strings.HasSuffix(p, "/") {
+		p += "**"
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	// a name matches everything below it, but dir/* stops at one level
+	if !strings.HasSuffix(p, "*") {
+		b.WriteString("(/.*)?")
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// ownersFor returns the owners of path from the last matching CODEOWNERS rule.
+func ownersFor(path string) []string {
+	rules := loadOwnerRules()
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].re.MatchString(path) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+const unownedGroup = "(no owner)"
+
+// buildOwnerTree groups files under their owners, each group holding the
+// usual directory tree.
+func buildOwnerTree(files []git.FileStatus) []*tree.Node {
+	groups := map[string][]git.FileStatus{}
+	for _, f := range files {
+		key := strings.Join(ownersFor(f.Path), " ")
+		if key == "" {
+			key = unownedGroup
+		}
+		groups[key] = append(groups[key], f)
+	}
+	var nodes []*tree.Node
+	for key, fs := range groups {
+		nodes = append(nodes, &tree.Node{Name: key, Group: true, Children: tree.Build(fs)})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if (nodes[i].Name == unownedGroup) != (nodes[j].Name == unownedGroup) {
+			return nodes[j].Name == unownedGroup
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
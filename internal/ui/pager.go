@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== External Pager ====================
+
+// pagerLine is the command line enter pages full-file diffs through outside
+// gd: --pager's, or the pager git itself uses (GIT_PAGER, core.pager, PAGER)
+// with --pager git or --tmux. It's "" when that turns paging off, as cat
+// does for git.
+func pagerLine() string {
+	line := flagPager
+	if line == "" || line == "git" {
+		gitPagerOnce.Do(func() {
+			gitPagerLine = "less"
+			if out, err := exec.Command("git", "var", "GIT_PAGER").Output(); err == nil {
+				gitPagerLine = string(out)
+			}
+		})
+		line = gitPagerLine
+	}
+	if line = strings.TrimSpace(line); line == "cat" {
+		return ""
+	}
+	return line
+}
+
+var (
+	gitPagerOnce sync.Once
+	gitPagerLine string
+)
+
+// pagerWords are the words of a pager command line after any leading
+// VAR=value assignments.
+func pagerWords(line string) []string {
+	words := strings.Fields(line)
+	for len(words) > 0 && strings.Contains(words[0], "=") {
+		words = words[1:]
+	}
+	return words
+}
+
+// programName is a command's program without its directory or .exe.
+func programName(word string) string {
+	return strings.TrimSuffix(filepath.Base(word), ".exe")
+}
+
+// diffPagers color diffs themselves, so they're given git's plain diff
+// rather than gd's rendering of it.
+var diffPagers = map[string]bool{"delta": true, "bat": true, "batcat": true, "diff-so-fancy": true, "diff-highlight": true}
+
+func pagerWantsDiff(line string) bool {
+	for _, w := range pagerWords(line) {
+		if diffPagers[programName(w)] {
+			return true
+		}
+	}
+	return false
+}
+
+// pagerScript adds what delta and bat need to page a diff on the terminal
+// rather than print it, named for path's syntax.
+func pagerScript(line, path string) string {
+	words := pagerWords(line)
+	if len(words) == 0 {
+		return line
+	}
+	switch programName(words[0]) {
+	case "delta":
+		return line + " --paging=always"
+	case "bat", "batcat":
+		return line + " --paging=always --language=diff --file-name=" + scriptQuote(path)
+	}
+	return line
+}
+
+// page hands the terminal to the pager for a full-file diff, or opens it
+// in a tmux pane with --tmux. Like git, it sets LESS and LV for less and lv
+// unless they're already set, so colors come through.
+func page(msg pageMsg) tea.Cmd {
+	line := pagerLine()
+	text := msg.rendered
+	if pagerWantsDiff(line) {
+		text = msg.raw
+	}
+	script := pagerScript(line, msg.path)
+	if inTmux() {
+		return pageInTmux(text, script)
+	}
+	c := shellCommand(script)
+	c.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		c.Env = append(c.Env, "LESS=FRX")
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		c.Env = append(c.Env, "LV=-c")
+	}
+	c.Stdin = strings.NewReader(text)
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		return execFinishedMsg{err: err}
+	})
+}
@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ==================== Preview Pane ====================
+
+// pane shows a window onto the preview's lines. Only the visible rows are
+// measured and padded when drawing, so a long diff costs one split when it
+// arrives and nothing per frame beyond the screen.
+type pane struct {
+	lines   []string
+	width   int
+	height  int
+	yOffset int
+	query   string // text searched for in the diff, highlighted where it appears
+}
+
+func (p *pane) setContent(s string) {
+	p.lines = strings.Split(s, "\n")
+	if p.yOffset > len(p.lines)-1 {
+		p.gotoBottom()
+	}
+}
+
+func (p pane) lineCount() int { return len(p.lines) }
+
+func (p pane) maxYOffset() int { return max(0, len(p.lines)-p.height) }
+
+// setYOffset scrolls to line n, asking a streaming preview for the lines
+// past it.
+func (p *pane) setYOffset(n int) {
+	p.yOffset = min(max(n, 0), p.maxYOffset())
+	wantStreamed(p.yOffset + p.height + streamAhead)
+}
+
+func (p *pane) gotoTop() { p.yOffset = 0 }
+
+func (p *pane) gotoBottom() { p.yOffset = p.maxYOffset() }
+
+func (p pane) atBottom() bool { return p.yOffset >= p.maxYOffset() }
+
+// rows returns the visible rows, each cut to the pane's width. They aren't
+// padded; View pads them out to the scrollbar.
+func (p pane) rows() []string {
+	rows := make([]string, p.height)
+	for r := range rows {
+		i := p.yOffset + r
+		if i >= len(p.lines) {
+			break
+		}
+		line := p.lines[i]
+		if p.query != "" {
+			line = highlightMatches(line, p.query)
+		}
+		if ansi.StringWidth(line) > p.width {
+			line = ansi.Truncate(line, p.width, "")
+		}
+		rows[r] = line
+	}
+	return rows
+}
+
+// matchIndex returns the byte offset and length of the first appearance of
+// query in plain, ignoring case, or -1.
+func matchIndex(plain, query string) (int, int) {
+	// lowering some runes changes their length, which would throw the
+	// offsets off; those lines are matched as they are
+	if lower := strings.ToLower(plain); len(lower) == len(plain) {
+		q := strings.ToLower(query)
+		return strings.Index(lower, q), len(q)
+	}
+	return strings.Index(plain, query), len(query)
+}
+
+// matches returns the lines that contain query.
+func (p pane) matches(query string) []int {
+	var lines []int
+	for i, line := range p.lines {
+		if at, _ := matchIndex(ansi.Strip(line), query); at >= 0 {
+			lines = append(lines, i)
+		}
+	}
+	return lines
+}
+
+// highlightMatches redraws each appearance of query in line with searchSty,
+// keeping the colors around it.
+func highlightMatches(line, query string) string {
+	plain := ansi.Strip(line)
+	idx, n := matchIndex(plain, query)
+	if idx < 0 {
+		return line
+	}
+	var b strings.Builder
+	col, off := 0, 0
+	for idx >= 0 {
+		start := off + idx
+		end := start + n
+		startCol := col + ansi.StringWidth(plain[off:start])
+		b.WriteString(ansi.Cut(line, col, startCol))
+		b.WriteString(searchSty.Reverse(true).Render(plain[start:end]))
+		col = startCol + ansi.StringWidth(plain[start:end])
+		off = end
+		idx, n = matchIndex(plain[off:], query)
+	}
+	b.WriteString(ansi.Cut(line, col, ansi.StringWidth(plain)))
+	return b.String()
+}
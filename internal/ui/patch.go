@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Patch Input ====================
+
+// commit is one entry of a multi-commit stream such as `git log -p`.
+type commit struct {
+	sha   string
+	title string
+	files []git.FileStatus
+}
+
+var commitStartRe = regexp.MustCompile(`^(commit [0-9a-f]{7,}|From [0-9a-f]{40} )`)
+
+// readPatch parses a unified diff, optionally containing several commits, into
+// per-commit file lists whose diffs are preloaded from the input.
+func readPatch(r io.Reader) ([]commit, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read patch: %w", err)
+	}
+
+	var commits []commit
+	for _, chunk := range splitCommits(string(data)) {
+		files, preamble, err := gitdiff.Parse(strings.NewReader(chunk))
+		if err != nil {
+			return nil, fmt.Errorf("parse patch: %w", err)
+		}
+		c := commit{}
+		if hdr, err := gitdiff.ParsePatchHeader(preamble); err == nil {
+			c.sha = hdr.SHA
+			c.title = hdr.Title
+		} else {
+			debugf("patch: no commit header: %v", err)
+		}
+		for _, f := range files {
+			fs := git.FileStatus{Path: f.NewName, Diff: f.String(), Code: "M "}
+			switch {
+			case f.IsNew:
+				fs.Code = "A "
+			case f.IsDelete:
+				fs.Path, fs.Code = f.OldName, "D "
+			case f.IsRename:
+				fs.Code = "R "
+			case f.IsCopy:
+				fs.Code = "C "
+			}
+			if f.IsRename || f.IsCopy {
+				fs.OrigPath = f.OldName
+			}
+			// counted here, as there's no git to ask for a numstat
+			fs.Stat.Binary = f.IsBinary
+			for _, frag := range f.TextFragments {
+				fs.Stat.Added += int(frag.LinesAdded)
+				fs.Stat.Deleted += int(frag.LinesDeleted)
+			}
+			if flagLeftovers {
+				fs.Stat.Leftovers = countLeftovers(f)
+			}
+			c.files = append(c.files, fs)
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// splitCommits breaks the input at each `commit <sha>` (git log) or
+// `From <sha>` (git format-patch) line.
+func splitCommits(s string) []string {
+	var chunks []string
+	var cur strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if commitStartRe.MatchString(line) && cur.Len() > 0 {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(line)
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
+func (c commit) label() string {
+	sha := c.sha
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	if sha == "" {
+		return c.title
+	}
+	return sha + " " + c.title
+}
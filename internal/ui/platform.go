@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ==================== Platform ====================
+
+// shellCommand runs a user-supplied command line through the platform's
+// shell: sh on Unix, cmd on Windows.
+func shellCommand(script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", script)
+	}
+	return exec.Command("sh", "-c", script)
+}
+
+// scriptQuote quotes s as one argument for shellCommand.
+func scriptQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return shellQuote(s)
+}
+
+// hasPager reports whether the external pager is available for full-file
+// views. Windows rarely has less, so gd shows those itself instead.
+func hasPager() bool {
+	words := pagerWords(pagerLine())
+	if len(words) == 0 {
+		return false
+	}
+	_, err := exec.LookPath(words[0])
+	return err == nil
+}
@@ -0,0 +1,39 @@
+package ui
+
+import "runtime"
+
+// ==================== Worker Pool ====================
+
+// inOrder runs work for 0..n-1 on up to GOMAXPROCS goroutines and hands each
+// result to emit in order, as soon as the ones before it are out. Workers run
+// only so far ahead of emit, so a long export doesn't hold every file at once.
+func inOrder[R any](n int, work func(i int) R, emit func(i int, r R)) {
+	if n == 0 {
+		return
+	}
+	workers := min(runtime.GOMAXPROCS(0), n)
+	results := make([]chan R, n)
+	for i := range results {
+		results[i] = make(chan R, 1)
+	}
+	jobs := make(chan int)
+	ahead := make(chan struct{}, 4*workers)
+	go func() {
+		for i := range n {
+			ahead <- struct{}{}
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	for range workers {
+		go func() {
+			for i := range jobs {
+				results[i] <- work(i)
+			}
+		}()
+	}
+	for i := range n {
+		emit(i, <-results[i])
+		<-ahead
+	}
+}
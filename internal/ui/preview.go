@@ -0,0 +1,422 @@
+package ui
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/arnavsurve/gd/pkg/render"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ==================== Preview Cache ====================
+
+// previewKey identifies a rendered preview: the same file looks different
+// staged or unstaged, in another commit, or at another width.
+type previewKey struct {
+	path  string
+	mode  string
+	width int
+}
+
+type preview struct {
+	content string
+	hunks   []render.Hunk
+}
+
+// previewCache holds rendered previews, least recently shown evicted first
+// once they pass --cache-mb or --cache-entries.
+var (
+	previewMu    sync.Mutex
+	previewCache = map[previewKey]*list.Element{}
+	previewLRU   = list.New() // of *cachedEntry, most recent at the front
+	previewBytes int
+	previewGen   int // bumped by clearPreviews so renders begun before it are dropped
+)
+
+type cachedEntry struct {
+	key  previewKey
+	p    preview
+	size int
+}
+
+// previewSize estimates what p keeps alive: its text and, through the hunks,
+// the parsed diff, which is about as big again.
+func previewSize(p preview) int {
+	return 2 * len(p.content)
+}
+
+// previewSeq numbers preview requests, so that a slow load finishing after the
+// cursor has moved on is ignored.
+var (
+	previewSeq   atomic.Int64
+	previewStop  context.CancelFunc // cancels the load in flight; guarded by previewMu
+	prefetchStop context.CancelFunc // cancels preloadAdjacent's renders; guarded by previewMu
+)
+
+const previewDelay = 30 * time.Millisecond
+
+// stopPreview cancels the preview load in flight, if any.
+func stopPreview() {
+	previewMu.Lock()
+	if previewStop != nil {
+		previewStop()
+		previewStop = nil
+	}
+	previewMu.Unlock()
+}
+
+// beginPreview cancels the preview load in flight, if any, and returns the
+// context for the next one. Prefetching stops too, to leave it the CPU.
+func beginPreview() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	previewMu.Lock()
+	if previewStop != nil {
+		previewStop()
+	}
+	if prefetchStop != nil {
+		prefetchStop()
+		prefetchStop = nil
+	}
+	previewStop = cancel
+	previewMu.Unlock()
+	return ctx
+}
+
+// beginPrefetch cancels the prefetch running, if any, and returns the
+// context for the next one.
+func beginPrefetch() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	previewMu.Lock()
+	if prefetchStop != nil {
+		prefetchStop()
+	}
+	prefetchStop = cancel
+	previewMu.Unlock()
+	return ctx
+}
+
+// dirPreviewKey is the cache key for the diffs of files, which are under the
+// directory path.
+func (m model) dirPreviewKey(path string, files []git.FileStatus, width int) previewKey {
+	var b strings.Builder
+	fmt.Fprintf(&b, "dir %d %s...%s %t", m.commitIdx, baseRef, headRef, m.fullPaths[path])
+	for _, f := range files {
+		b.WriteString(" " + f.StatusLabel() + f.Path)
+	}
+	return previewKey{path: path, mode: b.String(), width: width}
+}
+
+func (m model) previewKey(f git.FileStatus, width int) previewKey {
+	mode := fmt.Sprintf("%s %d %s...%s %t", f.StatusLabel(), m.commitIdx, baseRef, headRef, m.fullPaths[f.Path])
+	return previewKey{path: f.Path, mode: mode, width: width}
+}
+
+func cachedPreview(k previewKey) (preview, bool) {
+	previewMu.Lock()
+	defer previewMu.Unlock()
+	e, ok := previewCache[k]
+	if !ok {
+		return preview{}, false
+	}
+	previewLRU.MoveToFront(e)
+	return e.Value.(*cachedEntry).p, true
+}
+
+func previewGeneration() int {
+	previewMu.Lock()
+	defer previewMu.Unlock()
+	return previewGen
+}
+
+// storePreview caches p unless the cache was cleared since gen, evicting the
+// least recently shown previews to make room.
+func storePreview(k previewKey, gen int, p preview) {
+	previewMu.Lock()
+	defer previewMu.Unlock()
+	if gen != previewGen {
+		return
+	}
+	if e, ok := previewCache[k]; ok {
+		removePreview(e)
+	}
+	entry := &cachedEntry{key: k, p: p, size: previewSize(p)}
+	previewCache[k] = previewLRU.PushFront(entry)
+	previewBytes += entry.size
+	for previewLRU.Len() > 1 && previewOverBudget() {
+		removePreview(previewLRU.Back())
+	}
+}
+
+func previewOverBudget() bool {
+	if flagCacheEntries > 0 && previewLRU.Len() > flagCacheEntries {
+		return true
+	}
+	return flagCacheMB > 0 && previewBytes > flagCacheMB<<20
+}
+
+// removePreview drops one entry; previewMu must be held.
+func removePreview(e *list.Element) {
+	entry := previewLRU.Remove(e).(*cachedEntry)
+	delete(previewCache, entry.key)
+	previewBytes -= entry.size
+}
+
+// clearPreviews drops every cached preview and the batched diffs behind
+// them, for when the files, or anything drawn over them, may have changed.
+func clearPreviews() {
+	previewMu.Lock()
+	previewCache = map[previewKey]*list.Element{}
+	previewLRU.Init()
+	previewBytes = 0
+	previewGen++
+	previewMu.Unlock()
+	dropBatch()
+}
+
+// reloadPreview renders the selected file again rather than from the cache.
+func (m model) reloadPreview() tea.Cmd {
+	clearPreviews()
+	return m.loadPreview()
+}
+
+// prefetchAround is how many files either side of the cursor are rendered
+// before they're selected.
+const prefetchAround = 2
+
+// preloadAdjacent renders the files either side of the cursor into the cache,
+// nearest first, so that moving to them shows their preview at once. It
+// gives way to the selected file's load and to the next prefetch, and skips
+// diffs big enough to stream in.
+func (m model) preloadAdjacent() tea.Cmd {
+	var sides [2][]git.FileStatus
+	for s, step := range []int{1, -1} {
+		for i := m.cursor + step; i >= 0 && i < len(m.filtered) && len(sides[s]) < prefetchAround; i += step {
+			if f := m.allLines[m.filtered[i]].File; f != nil {
+				sides[s] = append(sides[s], *f)
+			}
+		}
+	}
+	var files []git.FileStatus
+	for i := range prefetchAround {
+		for _, side := range sides {
+			if i < len(side) {
+				files = append(files, side[i])
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	width, gen := m.previewWidth(), previewGeneration()
+	keys := make([]previewKey, len(files))
+	full := make([]bool, len(files))
+	for i, f := range files {
+		keys[i], full[i] = m.previewKey(f, width), m.fullPaths[f.Path]
+	}
+	ctx := beginPrefetch()
+	return func() tea.Msg {
+		inOrder(len(files), func(i int) struct{} {
+			if ctx.Err() != nil {
+				return struct{}{}
+			}
+			if _, ok := cachedPreview(keys[i]); ok {
+				return struct{}{}
+			}
+			raw, err := getDiffOutputContext(ctx, files[i], false)
+			if err != nil || len(raw) > streamThreshold {
+				return struct{}{}
+			}
+			opts := renderOpts{sections: diffSections(files[i : i+1])}
+			if !full[i] {
+				opts.limit, opts.lineLimit = flagMaxPreview<<10, flagMaxPreviewLines
+			}
+			rendered, hunks := renderDiffOpts(raw, width, files[i].Path, opts)
+			if ctx.Err() == nil {
+				storePreview(keys[i], gen, preview{content: rendered, hunks: hunks})
+			}
+			return struct{}{}
+		}, func(int, struct{}) {})
+		return nil
+	}
+}
+
+// ==================== Streaming ====================
+
+const (
+	streamThreshold = 256 << 10 // raw diff bytes above which previews stream in
+	streamEvery     = 100 * time.Millisecond
+	streamAhead     = 2000 // lines rendered past the bottom of the pane
+)
+
+// streamWant is how many lines of a streaming preview the pane needs. The
+// render stops there until scrolling asks for more, so a huge diff costs
+// only what's looked at; streamWake tells it to go on.
+var (
+	streamWant atomic.Int64
+	streamWake = make(chan struct{}, 1)
+)
+
+// wantStreamed asks the streaming preview for its first n lines.
+func wantStreamed(n int) {
+	if int64(n) <= streamWant.Load() {
+		return
+	}
+	streamWant.Store(int64(n))
+	select {
+	case streamWake <- struct{}{}:
+	default:
+	}
+}
+
+// streamAll asks for the whole of the streaming preview, for jumps to its
+// end and searches through it.
+func streamAll() { wantStreamed(math.MaxInt) }
+
+// streamPreview renders a large diff in the background, returning the first
+// screenful as soon as it's ready. Later messages carry all the output so far,
+// and each one's more channel yields the next. It renders want lines, then
+// more as the pane scrolls toward them.
+func streamPreview(ctx context.Context, raw string, width int, path, name string, opts renderOpts, key previewKey, gen int, seq int64, want int) tea.Msg {
+	ch := make(chan diffLoadedMsg, 1)
+	streamWant.Store(int64(want))
+	select {
+	case <-streamWake:
+	default:
+	}
+	go func() {
+		defer close(ch)
+		var last time.Time
+		opts.progress = func(content string, hunks []render.Hunk) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			lines := int64(strings.Count(content, "\n"))
+			if time.Since(last) >= streamEvery || lines >= streamWant.Load() {
+				last = time.Now()
+				sendLatest(ch, diffLoadedMsg{content: content, hunks: hunks, path: path, seq: seq, more: ch})
+			}
+			for lines >= streamWant.Load() {
+				select {
+				case <-ctx.Done():
+					return false
+				case <-streamWake:
+				}
+			}
+			return true
+		}
+		rendered, hunks := renderDiffOpts(raw, width, name, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		storePreview(key, gen, preview{content: rendered, hunks: hunks})
+		sendLatest(ch, diffLoadedMsg{content: rendered, hunks: hunks, path: path, seq: seq})
+	}()
+	return waitStream(ch)()
+}
+
+// sendLatest replaces any update still waiting in ch with msg; each carries
+// everything rendered so far, so only the newest matters.
+func sendLatest(ch chan diffLoadedMsg, msg diffLoadedMsg) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- msg
+}
+
+func waitStream(ch chan diffLoadedMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// ==================== Size Limit ====================
+
+func byteSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// thousands writes n with commas between its thousands, as 12,000.
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// ==================== Spinner ====================
+
+// The spinner shows once a preview has been loading for spinnerDelay, so
+// quick loads don't flash it.
+const (
+	spinnerDelay = 150 * time.Millisecond
+	spinnerEvery = 100 * time.Millisecond
+)
+
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// spinner stands in for the preview while the load numbered seq runs.
+type spinner struct {
+	seq   int64
+	path  string
+	frame int
+}
+
+// spinMsg turns the spinner for a preview load while it's the latest.
+type spinMsg struct {
+	seq  int64
+	path string
+}
+
+func startSpinner(seq int64, path string) tea.Cmd {
+	return tea.Tick(spinnerDelay, func(time.Time) tea.Msg { return spinMsg{seq: seq, path: path} })
+}
+
+func (msg spinMsg) next() tea.Cmd {
+	return tea.Tick(spinnerEvery, func(time.Time) tea.Msg { return msg })
+}
+
+// loading reports whether the preview is still loading the selected file. A
+// file being reloaded keeps showing until its new diff arrives.
+func (m model) loading() bool {
+	return m.spin.seq != 0 && m.spin.seq == previewSeq.Load() && m.spin.seq != m.loadedSeq && m.spin.path != m.shownPath
+}
+
+// previewRows returns the preview's visible rows, or the spinner in their
+// place while it loads.
+func (m model) previewRows() []string {
+	if m.loading() {
+		return m.spin.rows(m.viewport.height, m.viewport.width)
+	}
+	return m.viewport.rows()
+}
+
+// rows draws the spinner as the preview's rows.
+func (s spinner) rows(height, width int) []string {
+	rows := make([]string, height)
+	if height > 0 {
+		frame := string(spinnerFrames[s.frame%len(spinnerFrames)])
+		rows[0] = ansi.Truncate(ctxDimSty.Render(frame+" "+trf("loading %s", s.path)), width, "…")
+	}
+	return rows
+}
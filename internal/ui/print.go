@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/charmbracelet/x/term"
+)
+
+// ==================== Static Output ====================
+
+func printWidth() int {
+	if w, _, err := term.GetSize(os.Stdout.Fd()); err == nil && w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 120
+}
+
+// writePrint renders every file's diff to w without the TUI.
+func writePrint(w io.Writer, files []git.FileStatus) {
+	width := printWidth()
+	type result struct {
+		rendered string
+		err      error
+	}
+	inOrder(len(files), func(i int) result {
+		raw, err := getDiffOutput(files[i], false)
+		rendered, _ := renderDiffOpts(raw, width, files[i].Path, renderOpts{sections: diffSections(files[i : i+1])})
+		return result{rendered, err}
+	}, func(i int, r result) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", r.err)
+		}
+		fmt.Fprint(w, r.rendered)
+	})
+}
+
+type logEntry struct {
+	sha     string
+	author  string
+	date    string
+	message string
+}
+
+func getBranchCommits() ([]logEntry, error) {
+	rangeSpec := baseRef + ".." + headRef
+	args := []string{"log", "--reverse", "--date=short", "--format=%H%x00%an%x00%ad%x00%B%x1e", rangeSpec}
+	out, err := exec.Command("git", append(append(args, "--"), pathspecs...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log %s: %w", rangeSpec, err)
+	}
+	var entries []logEntry
+	for _, rec := range strings.Split(string(out), "\x1e") {
+		rec = strings.TrimLeft(rec, "\n")
+		parts := strings.SplitN(rec, "\x00", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		entries = append(entries, logEntry{
+			sha:     parts[0],
+			author:  parts[1],
+			date:    parts[2],
+			message: strings.TrimRight(parts[3], "\n"),
+		})
+	}
+	return entries, nil
+}
+
+// writeByCommit prints one section per commit on the branch: its message,
+// then the diffs it introduced.
+func writeByCommit(w io.Writer) error {
+	entries, err := getBranchCommits()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No commits.")
+		return nil
+	}
+	width := printWidth()
+	type result struct {
+		rendered string
+		err      error
+	}
+	var failed error
+	inOrder(len(entries), func(i int) result {
+		args := append([]string{"show", "--format=", "--patch"}, shownDiffOpts()...)
+		args = append(append(args, entries[i].sha, "--"), pathspecs...)
+		out, err := exec.Command("git", args...).Output()
+		if err != nil {
+			return result{err: fmt.Errorf("git show %s: %w", entries[i].sha, err)}
+		}
+		rendered, _ := renderDiff(string(out), width, "")
+		return result{rendered: rendered}
+	}, func(i int, r result) {
+		if failed != nil {
+			return
+		}
+		if r.err != nil {
+			failed = r.err
+			return
+		}
+		e := entries[i]
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, hunkHdrSty.Render("commit "+e.sha))
+		fmt.Fprintln(w, ctxDimSty.Render(e.author+"  "+e.date))
+		fmt.Fprintln(w)
+		for _, line := range strings.Split(e.message, "\n") {
+			fmt.Fprintln(w, titleSty.Render("    "+line))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprint(w, r.rendered)
+	})
+	return failed
+}
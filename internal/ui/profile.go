@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// ==================== Profiling ====================
+
+// startProfiling starts whatever --cpuprofile, --memprofile, and
+// --trace-timings ask for, returning a func that writes the profiles out.
+func startProfiling() (stop func(), err error) {
+	var stops []func()
+	stop = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+	if flagTraceTimings != "" {
+		f, err := os.Create(userPath(flagTraceTimings))
+		if err != nil {
+			return stop, err
+		}
+		traceLog = log.New(f, "", log.Ltime|log.Lmicroseconds)
+		stops = append(stops, func() { f.Close() })
+	}
+	if flagCPUProfile != "" {
+		f, err := os.Create(userPath(flagCPUProfile))
+		if err != nil {
+			return stop, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return stop, fmt.Errorf("cpu profile: %w", err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if flagMemProfile != "" {
+		stops = append(stops, func() {
+			f, err := os.Create(userPath(flagMemProfile))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: memory profile: %v\n", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				fmt.Fprintf(os.Stderr, "error: memory profile: %v\n", err)
+			}
+		})
+	}
+	return stop, nil
+}
+
+// traceLog receives --trace-timings lines; nil when tracing is off.
+var traceLog *log.Logger
+
+// trace times one step of loading a file, git, parse, or render, and logs it
+// when the returned func is called.
+//
+//	defer trace("git", path)()
+func trace(step, path string) func() {
+	if traceLog == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		traceLog.Printf("%-6s %10s  %s", step, time.Since(start).Round(time.Microsecond), path)
+	}
+}
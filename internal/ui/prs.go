@@ -0,0 +1,250 @@
+package ui
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Pull Requests ====================
+
+type pullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	HeadRefName string `json:"headRefName"`
+	BaseRefName string `json:"baseRefName"`
+}
+
+type prAction int
+
+const (
+	prNone prAction = iota
+	prView
+	prCheckout
+)
+
+type prPicker struct {
+	prs    []pullRequest
+	cursor int
+	scroll int
+	height int
+	width  int
+	action prAction
+}
+
+func (p prPicker) Init() tea.Cmd { return nil }
+
+func (p prPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.width, p.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return p, tea.Quit
+		case "up", "k":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+		case "down", "j":
+			if p.cursor < len(p.prs)-1 {
+				p.cursor++
+			}
+		case "enter":
+			p.action = prView
+			return p, tea.Quit
+		case "c":
+			p.action = prCheckout
+			return p, tea.Quit
+		}
+	}
+	visibleH := p.height - 2
+	if visibleH < 1 {
+		visibleH = 1
+	}
+	if p.cursor < p.scroll {
+		p.scroll = p.cursor
+	}
+	if p.cursor >= p.scroll+visibleH {
+		p.scroll = p.cursor - visibleH + 1
+	}
+	return p, nil
+}
+
+func (p prPicker) View() string {
+	var b strings.Builder
+	b.WriteString(titleSty.Render(tr("Open Pull Requests")))
+	b.WriteByte('\n')
+	visibleH := p.height - 2
+	if visibleH < 1 {
+		visibleH = 1
+	}
+	for i := p.scroll; i < len(p.prs) && i < p.scroll+visibleH; i++ {
+		pr := p.prs[i]
+		line := fitStr(fmt.Sprintf("#%-5d %s  (%s → %s, @%s)", pr.Number, pr.Title, pr.HeadRefName, pr.BaseRefName, pr.Author.Login), p.width)
+		if i == p.cursor {
+			b.WriteString(cursorSty.Render(line))
+		} else {
+			b.WriteString(fileSty.Render(line))
+		}
+		b.WriteByte('\n')
+	}
+	for i := len(p.prs) - p.scroll; i < visibleH; i++ {
+		b.WriteByte('\n')
+	}
+	b.WriteString(borderSty.Render("⏎ view diff  c checkout and review  q quit"))
+	return b.String()
+}
+
+func runPRs(args []string) {
+	fs := flag.NewFlagSet("prs", flag.ExitOnError)
+	fs.Parse(args)
+
+	initTheme()
+
+	p, err := originProvider()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	prs, err := p.listPRs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(prs) == 0 {
+		fmt.Println("No open pull requests.")
+		return
+	}
+
+	res, err := tea.NewProgram(prPicker{prs: prs}, tea.WithAltScreen()).Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	picker := res.(prPicker)
+	pr := picker.prs[picker.cursor]
+
+	switch picker.action {
+	case prView:
+		out, err := p.prDiff(pr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		commits, err := readPatch(strings.NewReader(out))
+		if err != nil || len(commits) == 0 {
+			fmt.Fprintf(os.Stderr, "error: no diff for #%d\n", pr.Number)
+			os.Exit(1)
+		}
+		runProgram(initialModel(commits[0].files), commits[0].files)
+
+	case prCheckout:
+		if err := p.checkoutPR(pr); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		// Review the PR's commits against its base, as --main does
+		flagMain = true
+		baseRef = "origin/" + pr.BaseRefName
+		files, err := loadFiles()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No changes.")
+			return
+		}
+		runProgram(initialModel(files), files)
+	}
+}
+
+// ==================== Reviewing a Pull Request ====================
+
+// prFlag is --pr: a pull request number, or with no number the current
+// branch's pull request.
+type prFlag struct {
+	set bool
+	n   int
+}
+
+func (f *prFlag) String() string {
+	if f.n == 0 {
+		return ""
+	}
+	return strconv.Itoa(f.n)
+}
+
+func (f *prFlag) Set(v string) error {
+	switch v {
+	case "false":
+		*f = prFlag{}
+	case "true":
+		*f = prFlag{set: true}
+	default:
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("not a pull request number: %q", v)
+		}
+		*f = prFlag{set: true, n: n}
+	}
+	return nil
+}
+
+func (f *prFlag) IsBoolFlag() bool { return true }
+
+var flagPR prFlag
+
+// reviewPR is the pull request shown with --pr. Review comments are kept for
+// it rather than for the current branch, and submitted and read from it.
+var reviewPR int
+
+// parsePRNumber takes a number after a bare --pr as its own, as in
+// gd --pr 12, and parses the flags after it.
+func parsePRNumber() {
+	if !flagPR.set || flagPR.n > 0 {
+		return
+	}
+	if n, err := strconv.Atoi(flag.Arg(0)); err == nil && n > 0 {
+		flagPR.n = n
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+}
+
+// setPR fetches the pull request asked for with --pr and diffs its head
+// against its base, leaving the checkout alone.
+func setPR() error {
+	p, err := originProvider()
+	if err != nil {
+		return err
+	}
+	n := flagPR.n
+	if n == 0 {
+		if n, err = p.currentPR(); err != nil {
+			return err
+		}
+	}
+	base, head, err := p.fetchPR(n)
+	if err != nil {
+		return err
+	}
+	reviewPR = n
+	setRevs([]string{base + "..." + head})
+	return nil
+}
+
+// reviewedPR is the pull request reviewed: --pr's, or the current branch's.
+func reviewedPR(p provider) (int, error) {
+	if reviewPR > 0 {
+		return reviewPR, nil
+	}
+	return p.currentPR()
+}
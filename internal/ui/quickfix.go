@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Hunk Locations ====================
+
+// hunkLocation is where a hunk lands in the working tree, for stepping through
+// a changeset in an editor.
+type hunkLocation struct {
+	path    string
+	line    int
+	endLine int
+	text    string
+}
+
+func hunkLocations(diffs []fileDiff) []hunkLocation {
+	var locs []hunkLocation
+	for _, d := range diffs {
+		files, _, err := gitdiff.Parse(strings.NewReader(d.raw))
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			for _, frag := range f.TextFragments {
+				loc := hunkLocation{path: d.file.Path, line: hunkLine(frag)}
+				if f.IsDelete {
+					loc.line = int(frag.OldPosition)
+				}
+				loc.endLine = loc.line
+				if frag.LinesAdded > 0 {
+					loc.endLine = loc.line + int(frag.LinesAdded) - 1
+				}
+				if loc.line < 1 {
+					loc.line, loc.endLine = 1, 1
+				}
+				header := strings.TrimSpace(strings.TrimSuffix(frag.Header(), frag.Comment))
+				loc.text = fmt.Sprintf("%s +%d -%d", header, frag.LinesAdded, frag.LinesDeleted)
+				if frag.Comment != "" {
+					loc.text += " " + frag.Comment
+				}
+				locs = append(locs, loc)
+			}
+		}
+	}
+	return locs
+}
+
+// writeQuickfix writes one file:line:col: text line per hunk, which vim's
+// default errorformat reads with :cfile.
+func writeQuickfix(w io.Writer, diffs []fileDiff) error {
+	for _, loc := range hunkLocations(diffs) {
+		if _, err := fmt.Fprintf(w, "%s:%d:1: %s\n", loc.path, loc.line, loc.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name string `json:"name"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifResult struct {
+	RuleID  string `json:"ruleId"`
+	Level   string `json:"level"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn"`
+			EndLine     int `json:"endLine"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// writeSARIF writes the hunks as note-level results of a minimal SARIF 2.1.0
+// log, which SARIF viewers in VS Code and elsewhere can step through.
+func writeSARIF(w io.Writer, diffs []fileDiff) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "gd"
+	for _, loc := range hunkLocations(diffs) {
+		var r sarifResult
+		r.RuleID, r.Level, r.Message.Text = "hunk", "note", loc.text
+		var l sarifLocation
+		l.PhysicalLocation.ArtifactLocation.URI = loc.path
+		l.PhysicalLocation.Region.StartLine = loc.line
+		l.PhysicalLocation.Region.StartColumn = 1
+		l.PhysicalLocation.Region.EndLine = loc.endLine
+		r.Locations = []sarifLocation{l}
+		run.Results = append(run.Results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
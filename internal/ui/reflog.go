@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Reflog ====================
+
+// reflogMax is how many of the reflog's entries gd --reflog reads.
+const reflogMax = 100
+
+type reflogEntry struct {
+	sha   string
+	title string
+}
+
+// loadReflog reads ref's reflog, newest first, with each entry's changes
+// from the one before it preloaded: what a commit added, a reset threw
+// away, or a checkout switched. The oldest entry read shows its commit.
+// Entries are listed by title alone, as git reflog shows them, since
+// HEAD@{n} is what names them.
+func loadReflog(ref string) ([]commit, error) {
+	out, err := exec.Command("git", "reflog", "show", "-n", strconv.Itoa(reflogMax+1), "--format=%H %gd: %gs", ref, "--").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git reflog: %w", git.StderrError(err))
+	}
+	var entries []reflogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if sha, title, ok := strings.Cut(line, " "); ok {
+			entries = append(entries, reflogEntry{sha, title})
+		}
+	}
+	type diffResult struct {
+		raw []byte
+		err error
+	}
+	n := min(len(entries), reflogMax)
+	commits := make([]commit, 0, n)
+	var firstErr error
+	inOrder(n, func(i int) diffResult {
+		raw, err := reflogDiff(entries, i)
+		return diffResult{raw, err}
+	}, func(i int, r diffResult) {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			return
+		}
+		c := commit{title: entries[i].title}
+		if parsed, err := readPatch(strings.NewReader(string(r.raw))); err == nil && len(parsed) > 0 {
+			c.files = parsed[0].files
+		}
+		commits = append(commits, c)
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return commits, nil
+}
+
+// reflogDiff is the diff entry i of entries made, from the entry before it,
+// or its commit's own for the oldest.
+func reflogDiff(entries []reflogEntry, i int) ([]byte, error) {
+	args := append([]string{"show", "--format="}, shownDiffOpts()...)
+	args = append(args, entries[i].sha)
+	if i+1 < len(entries) {
+		args = append(append([]string{"diff"}, shownDiffOpts()...), entries[i+1].sha, entries[i].sha)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w", args[0], git.StderrError(err))
+	}
+	return out, nil
+}
+
+// runReflog browses the reflog of the ref args name, HEAD by default, one
+// entry per row of the commit list.
+func runReflog(args []string) {
+	ref := "HEAD"
+	switch len(args) {
+	case 0:
+	case 1:
+		ref = args[0]
+	default:
+		fmt.Fprintln(os.Stderr, "error: usage: gd --reflog [ref]")
+		os.Exit(2)
+	}
+	entries, err := loadReflog(ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Println(trf("No reflog for %s.", ref))
+		return
+	}
+	initTheme()
+	m := commitsModel(entries)
+	m.reflog = true
+	runProgram(m, allCommitFiles(entries))
+}
@@ -0,0 +1,396 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Review Comments ====================
+
+// reviewComment is a pending line comment, kept in .git until submitted as a
+// review on the forge. A note on a whole file has no line.
+type reviewComment struct {
+	Branch  string    `json:"branch"`
+	Path    string    `json:"path"`
+	Line    int       `json:"line"`
+	Side    string    `json:"side"` // RIGHT for new lines, LEFT for removed ones, "" for files
+	Body    string    `json:"body"`
+	Created time.Time `json:"created"`
+}
+
+func gitDir() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func commentsPath() (string, error) {
+	dir, err := gitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gd-comments.json"), nil
+}
+
+func loadComments() ([]reviewComment, error) {
+	p, err := commentsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cs []reviewComment
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return cs, nil
+}
+
+func saveComments(cs []reviewComment) error {
+	p, err := commentsPath()
+	if err != nil {
+		return err
+	}
+	if len(cs) == 0 {
+		err := os.Remove(p)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(cs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, append(data, '\n'), 0o644)
+}
+
+// commentBranch is what pending comments are kept under: the current branch,
+// or pr/N for the pull request shown with --pr.
+func commentBranch() string {
+	if reviewPR > 0 {
+		return fmt.Sprintf("pr/%d", reviewPR)
+	}
+	return currentBranch()
+}
+
+// branchComments returns the pending comments for the current branch.
+func branchComments() ([]reviewComment, error) {
+	all, err := loadComments()
+	if err != nil {
+		return nil, err
+	}
+	branch := commentBranch()
+	var cs []reviewComment
+	for _, c := range all {
+		if c.Branch == branch {
+			cs = append(cs, c)
+		}
+	}
+	return cs, nil
+}
+
+// commentAnchor picks the line a comment on frag attaches to: the first added
+// line, or the first removed line for pure deletions.
+func commentAnchor(frag *gitdiff.TextFragment) (line int, side string) {
+	oldN, newN := int(frag.OldPosition), int(frag.NewPosition)
+	firstDel := 0
+	for _, l := range frag.Lines {
+		switch l.Op {
+		case gitdiff.OpAdd:
+			return newN, "RIGHT"
+		case gitdiff.OpDelete:
+			if firstDel == 0 {
+				firstDel = oldN
+			}
+			oldN++
+		case gitdiff.OpContext:
+			oldN++
+			newN++
+		}
+	}
+	return firstDel, "LEFT"
+}
+
+func (m *model) promptComment() {
+	f := m.selectedFile()
+	hunk := m.currentHunk()
+	if f == nil || hunk == nil {
+		m.message = tr("no hunk to comment on")
+		return
+	}
+	line, side := commentAnchor(hunk)
+	m.promptNote(f.Path, line, side, trf("comment on %s:%d: ", filepath.Base(f.Path), line))
+}
+
+// promptFileNote asks for a note on the selected file as a whole.
+func (m *model) promptFileNote() {
+	f := m.selectedFile()
+	if f == nil {
+		return
+	}
+	m.promptNote(f.Path, 0, "", trf("note on %s: ", filepath.Base(f.Path)))
+}
+
+// promptNote asks for a comment on line of path, or on the file for line 0,
+// and saves it with the branch's pending comments.
+func (m *model) promptNote(path string, line int, side, label string) {
+	m.prompt = &prompt{
+		label: label,
+		submit: func(body string) tea.Cmd {
+			if strings.TrimSpace(body) == "" {
+				return nil
+			}
+			session.record("comment", path, fmt.Sprint(line))
+			return func() tea.Msg {
+				cs, err := loadComments()
+				if err != nil {
+					return errorMsg{err: fmt.Errorf("comment failed: %w", err)}
+				}
+				cs = append(cs, reviewComment{
+					Branch:  commentBranch(),
+					Path:    path,
+					Line:    line,
+					Side:    side,
+					Body:    body,
+					Created: time.Now(),
+				})
+				if err := saveComments(cs); err != nil {
+					return errorMsg{err: fmt.Errorf("comment failed: %w", err)}
+				}
+				return statusMsg{text: trf("saved comment (%d pending)", len(cs))}
+			}
+		},
+	}
+}
+
+// showComments replaces the preview with the pending comments for the branch.
+func (m model) showComments() tea.Cmd {
+	return func() tea.Msg {
+		cs, err := branchComments()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		var b strings.Builder
+		b.WriteString(titleSty.Render(fmt.Sprintf("Pending review comments (%d)", len(cs))))
+		b.WriteString("\n\n")
+		for _, c := range cs {
+			b.WriteString(fileHdrSty.Render(c.where()))
+			if c.Side == "LEFT" {
+				b.WriteString(ctxDimSty.Render(" (removed line)"))
+			}
+			b.WriteByte('\n')
+			for _, l := range strings.Split(c.Body, "\n") {
+				b.WriteString("  " + l + "\n")
+			}
+			b.WriteByte('\n')
+		}
+		if len(cs) == 0 {
+			b.WriteString(ctxDimSty.Render(tr("Press a on a hunk or M on a file to add one.")))
+		} else {
+			b.WriteString(ctxDimSty.Render(tr("Submit with: gd review submit")))
+		}
+		return diffLoadedMsg{content: b.String()}
+	}
+}
+
+// where is path:line for a line comment, or the path for a file's note.
+func (c reviewComment) where() string {
+	if c.Line == 0 {
+		return c.Path
+	}
+	return fmt.Sprintf("%s:%d", c.Path, c.Line)
+}
+
+// ==================== Review Command ====================
+
+func runReview(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: gd review list|clear|summary [--main] [-o file]|submit [--pr N] [--event COMMENT|APPROVE|REQUEST_CHANGES] [--body text]")
+		os.Exit(2)
+	}
+	sub, args := args[0], args[1:]
+	switch sub {
+	case "list":
+		cs, err := branchComments()
+		if err != nil {
+			fatal(err)
+		}
+		for _, c := range cs {
+			side := ""
+			if c.Side != "" {
+				side = " [" + c.Side + "]"
+			}
+			fmt.Printf("%s%s %s\n", c.where(), side, strings.ReplaceAll(c.Body, "\n", " "))
+		}
+	case "clear":
+		if err := dropBranchComments(); err != nil {
+			fatal(err)
+		}
+	case "summary":
+		fs := flag.NewFlagSet("review summary", flag.ExitOnError)
+		fs.BoolVar(&flagMain, "main", false, "summarize the review of the branch against main")
+		output := fs.String("o", "", "write to `file` instead of stdout")
+		fs.Parse(args)
+		if err := reviewSummary(*output); err != nil {
+			fatal(err)
+		}
+	case "submit":
+		fs := flag.NewFlagSet("review submit", flag.ExitOnError)
+		pr := fs.Int("pr", 0, "pull request `number` (default: the PR for the current branch)")
+		event := fs.String("event", "COMMENT", "review event: COMMENT, APPROVE, or REQUEST_CHANGES")
+		body := fs.String("body", "", "overall review `text`")
+		fs.Parse(args)
+		n, to, err := submitReview(*pr, strings.ToUpper(*event), *body)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("submitted review with %d comments to #%d\n", n, to)
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown review command %q\n", sub)
+		os.Exit(2)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
+}
+
+// reviewSummary writes the Markdown summary of the review of the worktree,
+// or with --main of the branch, to output or stdout.
+func reviewSummary(output string) error {
+	files, err := loadFiles()
+	if err != nil {
+		return err
+	}
+	viewed, err := viewedFiles(files)
+	if err != nil {
+		return err
+	}
+	notes, err := branchComments()
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		return writeReviewSummary(os.Stdout, files, viewed, notes)
+	}
+	out, err := os.Create(userPath(output))
+	if err != nil {
+		return err
+	}
+	if err := writeReviewSummary(out, files, viewed, notes); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func dropBranchComments() error {
+	all, err := loadComments()
+	if err != nil {
+		return err
+	}
+	branch := commentBranch()
+	var keep []reviewComment
+	for _, c := range all {
+		if c.Branch != branch {
+			keep = append(keep, c)
+		}
+	}
+	return saveComments(keep)
+}
+
+// submitReview posts the branch's pending comments as one review on the
+// forge and clears them on success. It returns how many comments it posted
+// and to which pull request.
+func submitReview(pr int, event, body string) (int, int, error) {
+	cs, err := branchComments()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(cs) == 0 && body == "" && event == "COMMENT" {
+		return 0, 0, fmt.Errorf("no pending comments")
+	}
+	p, err := originProvider()
+	if err != nil {
+		return 0, 0, err
+	}
+	if pr == 0 {
+		if pr, err = reviewedPR(p); err != nil {
+			return 0, 0, err
+		}
+	}
+	head, err := exec.Command("git", "rev-parse", headRef).Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("git rev-parse %s: %w", headRef, err)
+	}
+	// forges take comments on lines, so notes on whole files go in the body
+	var lines []reviewComment
+	for _, c := range cs {
+		if c.Line == 0 {
+			body = strings.TrimSpace(body + "\n\n**" + c.Path + "**: " + c.Body)
+		} else {
+			lines = append(lines, c)
+		}
+	}
+	r := review{commitID: strings.TrimSpace(string(head)), event: event, body: body, comments: lines}
+	if err := p.submitReview(pr, r); err != nil {
+		return 0, 0, err
+	}
+	return len(cs), pr, dropBranchComments()
+}
+
+// reviewEvents are the answers to promptSubmitReview's first question.
+var reviewEvents = map[string]string{"c": "COMMENT", "a": "APPROVE", "r": "REQUEST_CHANGES"}
+
+// promptSubmitReview asks how to submit the pending comments, and for the
+// review's text, then posts them to the pull request.
+func (m *model) promptSubmitReview() {
+	m.prompt = &prompt{
+		label: tr("submit review: c comment, a approve, r request changes: "),
+		input: "c",
+		submit: func(answer string) tea.Cmd {
+			event, ok := reviewEvents[strings.ToLower(strings.TrimSpace(answer))]
+			if !ok {
+				return nil
+			}
+			return func() tea.Msg {
+				return promptMsg{&prompt{
+					label: tr("review text: "),
+					submit: func(body string) tea.Cmd {
+						session.record("submit_review", event, "")
+						return func() tea.Msg {
+							n, pr, err := submitReview(0, event, strings.TrimSpace(body))
+							if err != nil {
+								return errorMsg{err: fmt.Errorf("submitting review: %w", err)}
+							}
+							return reviewSubmittedMsg{text: trf("submitted review with %d comments to #%d", n, pr)}
+						}
+					},
+				}}
+			}
+		},
+	}
+}
+
+// reviewSubmittedMsg reports a review posted from the browser, whose threads
+// are then loaded again.
+type reviewSubmittedMsg struct{ text string }
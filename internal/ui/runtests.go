@@ -0,0 +1,205 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Test Runner ====================
+
+type testLineMsg struct{ line string }
+type testDoneMsg struct{ err error }
+
+// testRun is a test command streaming its output into the preview.
+type testRun struct {
+	title   string
+	ch      chan tea.Msg
+	lines   []string
+	pkgs    []testPkg
+	started time.Time
+	elapsed time.Duration
+	done    bool
+	err     error
+}
+
+type testPkg struct {
+	name   string
+	status string // ok, FAIL, or none
+	detail string
+}
+
+// testPackages maps changed Go files to the packages containing them, as
+// ./dir patterns relative to the repo root.
+func testPackages(files []git.FileStatus) []string {
+	seen := map[string]bool{}
+	var pkgs []string
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path, ".go") {
+			continue
+		}
+		dir := path.Dir(f.Path)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if st, err := os.Stat(filepath.Join(repoRoot(), dir)); err != nil || !st.IsDir() {
+			continue
+		}
+		if dir == "." {
+			pkgs = append(pkgs, ".")
+		} else {
+			pkgs = append(pkgs, "./"+dir)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// testCommand builds the command for --test-cmd, substituting {files} and
+// {packages}, or go test for the affected packages.
+func testCommand(files []git.FileStatus) (*exec.Cmd, string, error) {
+	pkgs := testPackages(files)
+	var c *exec.Cmd
+	if flagTestCmd != "" {
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, scriptQuote(f.Path))
+		}
+		var quoted []string
+		for _, p := range pkgs {
+			quoted = append(quoted, scriptQuote(p))
+		}
+		script := strings.ReplaceAll(flagTestCmd, "{files}", strings.Join(paths, " "))
+		script = strings.ReplaceAll(script, "{packages}", strings.Join(quoted, " "))
+		c = shellCommand(script)
+	} else {
+		if len(pkgs) == 0 {
+			return nil, "", fmt.Errorf("no changed Go packages; set --test-cmd to test other files")
+		}
+		c = exec.Command("go", append([]string{"test"}, pkgs...)...)
+	}
+	c.Dir = repoRoot()
+	title := strings.Join(c.Args, " ")
+	if flagTestCmd != "" {
+		title = c.Args[2]
+	}
+	return c, title, nil
+}
+
+// startTests launches the test command and returns a run that receives its
+// combined output line by line.
+func startTests(files []git.FileStatus) (*testRun, error) {
+	c, title, err := testCommand(files)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	c.Stdout, c.Stderr = pw, pw
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	run := &testRun{title: title, ch: make(chan tea.Msg, 64), started: time.Now()}
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			run.ch <- testLineMsg{line: scanner.Text()}
+		}
+		// a line past the buffer stops the scanner; keep reading so the
+		// command isn't left blocked writing to the pipe
+		if scanner.Err() != nil {
+			io.Copy(io.Discard, pr)
+		}
+	}()
+	go func() {
+		err := c.Wait()
+		pw.Close()
+		<-scanned
+		run.ch <- testDoneMsg{err: err}
+	}()
+	return run, nil
+}
+
+func (r *testRun) wait() tea.Cmd {
+	return func() tea.Msg { return <-r.ch }
+}
+
+// add records an output line, picking up go test's per-package summaries.
+func (r *testRun) add(line string) {
+	r.lines = append(r.lines, line)
+	f := strings.Fields(line)
+	if len(f) < 2 {
+		return
+	}
+	var p testPkg
+	switch f[0] {
+	case "ok":
+		p = testPkg{name: f[1], status: "ok", detail: strings.Join(f[2:], " ")}
+	case "FAIL":
+		p = testPkg{name: f[1], status: "FAIL", detail: strings.Join(f[2:], " ")}
+	case "?":
+		p = testPkg{name: f[1], status: "none", detail: strings.Join(f[2:], " ")}
+	default:
+		return
+	}
+	if strings.HasPrefix(p.name, "[") || p.name == "" {
+		return
+	}
+	r.pkgs = append(r.pkgs, p)
+}
+
+func (r *testRun) render() string {
+	var b strings.Builder
+	status := ctxDimSty.Render(fmt.Sprintf("running… %s", time.Since(r.started).Round(time.Second)))
+	if r.done {
+		switch {
+		case r.err == nil:
+			status = addIndSty.Render(tr("passed"))
+		default:
+			status = delIndSty.Render(tr("failed"))
+		}
+		status += ctxDimSty.Render(" in " + r.elapsed.Round(100*time.Millisecond).String())
+	}
+	b.WriteString(titleSty.Render(trf("Tests: %s", r.title)) + "  " + status + "\n\n")
+
+	for _, p := range r.pkgs {
+		mark := addIndSty.Render("✓")
+		switch p.status {
+		case "FAIL":
+			mark = delIndSty.Render("✗")
+		case "none":
+			mark = ctxDimSty.Render("–")
+		}
+		b.WriteString(fmt.Sprintf("%s %s %s\n", mark, fileHdrSty.Render(p.name), ctxDimSty.Render(p.detail)))
+	}
+	if len(r.pkgs) > 0 {
+		b.WriteByte('\n')
+	}
+
+	for _, line := range r.lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "--- FAIL"), strings.HasPrefix(line, "FAIL"):
+			line = delIndSty.Render(line)
+		case strings.HasPrefix(trimmed, "--- PASS"), strings.HasPrefix(line, "ok "):
+			line = addIndSty.Render(line)
+		case strings.HasPrefix(line, "?"), strings.HasPrefix(trimmed, "=== RUN"):
+			line = ctxDimSty.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Script ====================
+
+// A script replays keys against the browser without a terminal, one step per
+// line:
+//
+//	# comment
+//	size 120 30     resize the screen (120x40 until the first size)
+//	j               a key, named as bubbletea prints it: enter, esc, ctrl+d, ...
+//	type foo        each character of foo as a key
+//	snapshot        print the screen
+//
+// The screen is printed once more at the end.
+
+// settleQuiet is how long a step waits on commands still running before it
+// gives up on them; some, like the --watch loop, never finish.
+const settleQuiet = time.Second
+
+// keyTypes maps key names to types, for the keys that aren't runes.
+var keyTypes = func() map[string]tea.KeyType {
+	types := map[string]tea.KeyType{}
+	for t := tea.KeyType(-200); t < 200; t++ {
+		if name := t.String(); name != "" && t != tea.KeyRunes {
+			types[name] = t
+		}
+	}
+	return types
+}()
+
+type scriptRunner struct {
+	m       tea.Model
+	msgs    chan tea.Msg
+	running int // commands started whose message hasn't been handled
+	quit    bool
+}
+
+func runScript(path string, m model) error {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(userPath(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	return replay(m, in, os.Stdout)
+}
+
+// replay runs the script against m, writing snapshots to out.
+func replay(m tea.Model, script io.Reader, out io.Writer) error {
+	r := &scriptRunner{m: m, msgs: make(chan tea.Msg, 64)}
+	r.run(m.Init())
+	r.update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	r.settle()
+
+	sc := bufio.NewScanner(script)
+	for n := 1; sc.Scan() && !r.quit; n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch cmd {
+		case "size":
+			w, h, ok := parseSize(arg)
+			if !ok {
+				return fmt.Errorf("line %d: size wants a width and height: %q", n, line)
+			}
+			r.update(tea.WindowSizeMsg{Width: w, Height: h})
+		case "type":
+			for _, c := range arg {
+				r.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{c}})
+				r.settle()
+			}
+		case "snapshot":
+			fmt.Fprintln(out, r.m.View())
+			continue
+		default:
+			key, ok := parseKey(line)
+			if !ok {
+				return fmt.Errorf("line %d: unknown key %q", n, line)
+			}
+			r.update(key)
+		}
+		r.settle()
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if !r.quit {
+		fmt.Fprintln(out, r.m.View())
+	}
+	return nil
+}
+
+func parseSize(s string) (w, h int, ok bool) {
+	ws, hs, _ := strings.Cut(strings.TrimSpace(s), " ")
+	w, werr := strconv.Atoi(ws)
+	h, herr := strconv.Atoi(strings.TrimSpace(hs))
+	return w, h, werr == nil && herr == nil && w > 0 && h > 0
+}
+
+func parseKey(name string) (tea.KeyMsg, bool) {
+	alt := false
+	if rest, ok := strings.CutPrefix(name, "alt+"); ok && rest != "" {
+		alt, name = true, rest
+	}
+	if t, ok := keyTypes[name]; ok {
+		return tea.KeyMsg{Type: t, Alt: alt}, true
+	}
+	if runes := []rune(name); len(runes) == 1 {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: runes, Alt: alt}, true
+	}
+	return tea.KeyMsg{}, false
+}
+
+// run starts cmd the way tea.Program would, delivering its message to msgs.
+func (r *scriptRunner) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	r.running++
+	go func() { r.msgs <- cmd() }()
+}
+
+func (r *scriptRunner) update(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case nil:
+		return
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			r.run(cmd)
+		}
+		return
+	case tea.QuitMsg:
+		r.quit = true
+		return
+	}
+	var cmd tea.Cmd
+	r.m, cmd = r.m.Update(msg)
+	r.run(cmd)
+}
+
+// settle handles messages until every command has finished, or the ones left
+// have been silent for settleQuiet.
+func (r *scriptRunner) settle() {
+	for !r.quit && r.running > 0 {
+		select {
+		case msg := <-r.msgs:
+			r.running--
+			r.update(msg)
+		case <-time.After(settleQuiet):
+			return
+		}
+	}
+}
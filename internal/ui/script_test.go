@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestMain(m *testing.M) {
+	// golden files hold plain text, so colors can't make them flaky
+	lipgloss.SetColorProfile(termenv.Ascii)
+	initTheme()
+	code := m.Run()
+	if playDir != "" {
+		os.RemoveAll(filepath.Dir(playDir))
+	}
+	os.Exit(code)
+}
+
+// golden compares got with testdata/name, or rewrites it with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file; run go test -update and check the diff\ngot:\n%s", path, got)
+	}
+}
+
+// The scripts share one repository: repoRoot is looked up once per process.
+var (
+	playOnce sync.Once
+	playDir  string
+	playErr  error
+)
+
+// playRepo returns a repository with a modified, a staged, and an untracked
+// file, made on first use.
+func playRepo(t *testing.T) string {
+	t.Helper()
+	playOnce.Do(func() {
+		// a fixed name, since the status bar shows it
+		tmp, err := os.MkdirTemp("", "gd-test")
+		if err != nil {
+			playErr = err
+			return
+		}
+		playDir = filepath.Join(tmp, "play")
+		if playErr = os.Mkdir(playDir, 0o755); playErr != nil {
+			return
+		}
+		write := func(name, content string) {
+			os.MkdirAll(filepath.Join(playDir, filepath.Dir(name)), 0o755)
+			os.WriteFile(filepath.Join(playDir, name), []byte(content), 0o644)
+		}
+		var nums strings.Builder
+		for i := 1; i <= 40; i++ {
+			nums.WriteString(strings.Repeat("x", i%7) + "\n")
+		}
+		write("main.go", "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n")
+		write("nums.txt", nums.String())
+		write("sub/notes.txt", "one\n")
+		playErr = gitIn(playDir,
+			"init -q -b main",
+			"config user.email gd@example.com",
+			"config user.name gd",
+			"add -A",
+			"commit -qm init",
+		)
+		if playErr != nil {
+			return
+		}
+		write("main.go", "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n")
+		write("nums.txt", strings.Replace(nums.String(), "xxx\n", "three\n", 1))
+		write("sub/notes.txt", "one\ntwo\n")
+		write("sub/new.txt", "new\n")
+		playErr = gitIn(playDir, "add sub/notes.txt")
+	})
+	if playErr != nil {
+		t.Fatal(playErr)
+	}
+	return playDir
+}
+
+func gitIn(dir string, cmds ...string) error {
+	for _, c := range cmds {
+		cmd := exec.Command("git", strings.Fields(c)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %v: %s", c, err, out)
+		}
+	}
+	return nil
+}
+
+// TestScripts replays each testdata/script/*.txt against the play repository
+// and compares the screens with its .golden file.
+func TestScripts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	scripts, _ := filepath.Glob(filepath.Join("testdata", "script", "*.txt"))
+	wd, _ := os.Getwd()
+	for _, s := range scripts {
+		name := strings.TrimSuffix(filepath.Base(s), ".txt")
+		t.Run(name, func(t *testing.T) {
+			script, err := os.ReadFile(filepath.Join(wd, s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Chdir(playRepo(t))
+			// other tests may have looked it up from the package directory
+			repoRootOnce = sync.Once{}
+			clearPreviews()
+			files, err := loadFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			if err := replay(initialModel(files), strings.NewReader(string(script)), &out); err != nil {
+				t.Fatal(err)
+			}
+			checkScreenWidths(t, out.String(), string(script))
+			t.Chdir(wd)
+			golden(t, filepath.Join("script", name+".golden"), out.String())
+		})
+	}
+}
+
+// checkScreenWidths fails when a screen line runs past the width the script
+// set, which is what breaks first when layout math goes wrong.
+func checkScreenWidths(t *testing.T, screens, script string) {
+	t.Helper()
+	width := 120
+	for _, l := range strings.Split(script, "\n") {
+		if arg, ok := strings.CutPrefix(strings.TrimSpace(l), "size "); ok {
+			if w, _, ok := parseSize(arg); ok {
+				width = w
+			}
+		}
+	}
+	for i, l := range strings.Split(screens, "\n") {
+		if w := lipgloss.Width(l); w > width {
+			t.Errorf("screen line %d is %d wide, past %d: %q", i+1, w, width, l)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for name, want := range map[string]string{
+		"j":      "j",
+		"enter":  "enter",
+		"esc":    "esc",
+		"ctrl+d": "ctrl+d",
+		"alt+j":  "alt+j",
+		"!":      "!",
+		"pgdown": "pgdown",
+	} {
+		key, ok := parseKey(name)
+		if !ok || key.String() != want {
+			t.Errorf("parseKey(%q) = %q, %t; want %q", name, key.String(), ok, want)
+		}
+	}
+	if _, ok := parseKey("nosuchkey"); ok {
+		t.Error("parseKey accepted an unknown key")
+	}
+}
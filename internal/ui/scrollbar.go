@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Scrollbar ====================
+
+// scrollbarW is the column right of the preview showing where in it the
+// screen is, and where its changes are.
+const scrollbarW = 1
+
+// wheelLines is how far a turn of the mouse wheel scrolls the preview.
+const wheelLines = 3
+
+// configMouse is whether gd takes the mouse, for the wheel and clicks on the
+// scrollbar. mouse = false in the config file leaves it to the terminal, to
+// select text with.
+var configMouse = true
+
+// The kinds of change on a scrollbar row, as it colors them.
+const (
+	barAdded = 1 << iota
+	barRemoved
+)
+
+// renderScrollbar draws the scrollbar, one row for each of the preview's:
+// the part on screen as a thick bar over a thin one, and the rows holding
+// changes in their colors. A preview that fits has none, and isn't padded
+// out to it.
+func (m model) renderScrollbar() []string {
+	v := m.viewport
+	total := v.lineCount()
+	if m.loading() || total <= v.height {
+		return nil
+	}
+	rows := make([]string, v.height)
+	// each row stands for the lines from r*total/len(rows) up to the next's
+	kinds := make([]int, len(rows))
+	for i, h := range m.hunks {
+		if h.Frag == nil {
+			continue
+		}
+		end := h.End
+		if end <= h.Line {
+			// still streaming in
+			end = total
+			if i+1 < len(m.hunks) {
+				end = m.hunks[i+1].Line
+			}
+		}
+		// the hunk's lines are spread over its rows of the preview, one to
+		// one unless wrapped or side by side
+		n := len(h.Frag.Lines)
+		for j, l := range h.Frag.Lines {
+			kind := 0
+			switch l.Op {
+			case gitdiff.OpAdd:
+				kind = barAdded
+			case gitdiff.OpDelete:
+				kind = barRemoved
+			default:
+				continue
+			}
+			line := h.Line + j*(end-h.Line)/n
+			if r := line * len(rows) / total; r < len(rows) {
+				kinds[r] |= kind
+			}
+		}
+	}
+	top := v.yOffset * len(rows) / total
+	bottom := max(top+1, ((v.yOffset+v.height)*len(rows)+total-1)/total)
+	for r := range rows {
+		ch, sty := "│", borderSty
+		if kinds[r] != 0 {
+			ch = "▐"
+		}
+		if r >= top && r < bottom {
+			ch, sty = "█", fileSty
+		}
+		switch kinds[r] {
+		case barAdded:
+			sty = addIndSty
+		case barRemoved:
+			sty = delIndSty
+		case barAdded | barRemoved:
+			sty = unstBadge
+		}
+		rows[r] = sty.Render(ch)
+	}
+	return rows
+}
+
+// scrollbarTo scrolls the preview to the part the scrollbar's row r stands
+// for, with it in the middle of the screen.
+func (m *model) scrollbarTo(r int) {
+	v := &m.viewport
+	v.setYOffset(r*v.lineCount()/max(v.height, 1) - v.height/2)
+	m.followEnd = false
+	m.syncHunk()
+}
+
+// mouse handles the mouse: the wheel scrolls what it's over, and clicking or
+// dragging on the scrollbar jumps the preview there.
+func (m model) mouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	overPreview := m.paging || m.treeHidden || msg.X > m.treeW
+	switch msg.Button {
+	case tea.MouseButtonWheelUp, tea.MouseButtonWheelDown:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+		key, delta := tea.KeyMsg{Type: tea.KeyDown}, wheelLines
+		if msg.Button == tea.MouseButtonWheelUp {
+			key, delta = tea.KeyMsg{Type: tea.KeyUp}, -wheelLines
+		}
+		// what's open on top takes the wheel as arrow keys, as does the tree
+		if m.prompt != nil || m.popup != nil || m.searching || !overPreview {
+			return m.Update(key)
+		}
+		m.viewport.setYOffset(m.viewport.yOffset + delta)
+		m.followEnd = false
+		m.syncHunk()
+	case tea.MouseButtonLeft:
+		if msg.Action == tea.MouseActionRelease || msg.X < m.width-scrollbarW || msg.Y >= m.viewport.height || m.loading() {
+			return m, nil
+		}
+		m.scrollbarTo(msg.Y)
+	}
+	return m, nil
+}
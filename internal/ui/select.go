@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Marked Files ====================
+
+// toggleFileMark marks the selected file with x, or unmarks it; on a directory,
+// every file under it. Marks are kept by path, so they last through searches
+// and refreshes.
+func (m *model) toggleFileMark() {
+	if m.cursor < 0 || m.cursor >= len(m.filtered) {
+		return
+	}
+	var paths []string
+	if f := m.allLines[m.filtered[m.cursor]].File; f != nil {
+		paths = []string{f.Path}
+	} else {
+		for _, f := range m.dirFiles() {
+			paths = append(paths, f.Path)
+		}
+	}
+	all := true
+	for _, p := range paths {
+		all = all && m.fileMarks[p]
+	}
+	if m.fileMarks == nil {
+		m.fileMarks = map[string]bool{}
+	}
+	for _, p := range paths {
+		if all {
+			delete(m.fileMarks, p)
+		} else {
+			m.fileMarks[p] = true
+		}
+	}
+	m.message = trf("%d files marked", len(m.markedFiles()))
+}
+
+// markedFiles are the files marked with x that are still changed, in the
+// tree's order.
+func (m model) markedFiles() []git.FileStatus {
+	var files []git.FileStatus
+	for _, f := range m.files {
+		if m.fileMarks[f.Path] {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// markedPaths are files' paths, and for renames the paths they came from,
+// as git add and git reset need both.
+func markedPaths(files []git.FileStatus) []string {
+	var paths []string
+	for _, f := range files {
+		if f.OrigPath != "" {
+			paths = append(paths, f.OrigPath)
+		}
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+// stageMarked stages the marked files whole, or unstages them.
+func (m model) stageMarked(files []git.FileStatus, unstage bool) tea.Cmd {
+	if !m.live() || flagMain {
+		return func() tea.Msg { return statusMsg{text: tr("staging needs a change in the worktree")} }
+	}
+	args := append([]string{"add", "-A", "--"}, markedPaths(files)...)
+	text := trf("staged %d files", len(files))
+	if unstage {
+		args = append([]string{"reset", "-q", "--"}, markedPaths(files)...)
+		text = trf("unstaged %d files", len(files))
+	}
+	session.record(args[0]+"_files", strings.Join(markedPaths(files), " "), "")
+	return func() tea.Msg {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			return errorMsg{err: fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))}
+		}
+		files, err := loadFiles()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return filesLoadedMsg{files: files, text: text}
+	}
+}
+
+// promptDiscard asks before throwing away every change to the marked files:
+// tracked ones go back to HEAD, and untracked ones are deleted.
+func (m *model) promptDiscard(files []git.FileStatus) {
+	if !m.live() || flagMain {
+		m.message = tr("only the worktree's changes can be discarded")
+		return
+	}
+	m.prompt = &prompt{
+		label: trf("discard all changes to %d files? (y/N): ", len(files)),
+		submit: func(answer string) tea.Cmd {
+			if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+				return nil
+			}
+			session.record("discard_files", strings.Join(markedPaths(files), " "), "")
+			text := trf("discarded changes to %d files", len(files))
+			return func() tea.Msg {
+				if err := discardFiles(files); err != nil {
+					return errorMsg{err: err}
+				}
+				files, err := loadFiles()
+				if err != nil {
+					return errorMsg{err: err}
+				}
+				return filesLoadedMsg{files: files, text: text}
+			}
+		},
+	}
+}
+
+// discardFiles restores files to HEAD in the index and the worktree, and
+// removes the untracked ones.
+func discardFiles(files []git.FileStatus) error {
+	var tracked []git.FileStatus
+	for _, f := range files {
+		if !f.Untracked {
+			tracked = append(tracked, f)
+			continue
+		}
+		if err := os.Remove(filepath.Join(repoRoot(), f.Path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if len(tracked) == 0 {
+		return nil
+	}
+	args := append([]string{"restore", "--source=HEAD", "--staged", "--worktree", "--"}, markedPaths(tracked)...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git restore: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// openCombinedDiff pages the marked files' diffs one after another, as enter
+// does for one file.
+func (m model) openCombinedDiff(files []git.FileStatus) tea.Cmd {
+	width, vpW := m.width, m.viewport.width
+	external := (flagPager != "" || inTmux()) && hasPager()
+	return func() tea.Msg {
+		var b strings.Builder
+		for _, f := range files {
+			raw, err := getDiffOutput(f, true)
+			if err != nil {
+				return errorMsg{err: err}
+			}
+			b.WriteString(raw)
+		}
+		raw := b.String()
+		switch {
+		case external:
+			msg := pageMsg{raw: raw, external: true}
+			if !pagerWantsDiff(pagerLine()) {
+				msg.rendered, _ = renderDiff(raw, width, "")
+			}
+			return msg
+		case flagAccessible:
+			rendered, hunks := renderDiff(raw, vpW, "")
+			return diffLoadedMsg{content: rendered, hunks: hunks}
+		}
+		rendered, hunks := renderDiff(raw, width-pagerGutterW, "")
+		return pageMsg{rendered: rendered, hunks: hunks}
+	}
+}
@@ -0,0 +1,212 @@
+package ui
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// ==================== Serve ====================
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.BoolVar(&flagMain, "main", false, "diff against main branch")
+	addr := fs.String("addr", "127.0.0.1:7777", "listen `address`")
+	socket := fs.String("socket", "", "answer editors' render requests on the unix socket at `path` instead")
+	fs.Parse(args)
+	pathspecs = rootPathspecs(fs.Args())
+
+	// The page's CSS and editors' terminals take the palette's hex colors,
+	// not ones fitted to the terminal gd was started in.
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	initTheme()
+	if *socket != "" {
+		if err := serveSocket(userPath(*socket)); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		files, err := loadFiles()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := writeHTML(w, serveTitle(), collectDiffs(files), true); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+	})
+
+	// The page polls this and reloads when the changeset's hash moves.
+	http.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		files, err := loadFiles()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		patch, err := buildPatch(files)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%x", sha1.Sum([]byte(patch)))
+	})
+
+	fmt.Printf("serving on http://%s\n", *addr)
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serveTitle() string {
+	if flagMain {
+		return "Changes vs " + baseRef
+	}
+	return "Changed Files"
+}
+
+// ==================== Socket ====================
+
+// renderRequest is one line an editor sends gd serve --socket. File is
+// relative to the repository's root, or absolute; Format is "ansi", the
+// default, or "html".
+type renderRequest struct {
+	File   string `json:"file"`
+	Width  int    `json:"width"`
+	Format string `json:"format"`
+}
+
+// renderReply answers a renderRequest on one line, with either the
+// rendered diff or what went wrong.
+type renderReply struct {
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// renderMu serializes rendering, which shares the theme and the
+// highlighters between connections.
+var renderMu sync.Mutex
+
+// serveSocket answers render requests on a unix socket at path until
+// interrupted, removing the socket on the way out.
+func serveSocket(path string) error {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		// left over from a gd that didn't exit cleanly, unless one still answers
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return fmt.Errorf("%s is already being served", path)
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		l.Close()
+	}()
+	fmt.Printf("serving on %s\n", path)
+	for {
+		c, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go serveConn(c)
+	}
+}
+
+// serveConn answers each line of c in turn until the editor hangs up.
+func serveConn(c net.Conn) {
+	defer c.Close()
+	sc := bufio.NewScanner(c)
+	sc.Buffer(nil, 1<<20)
+	enc := json.NewEncoder(c)
+	for sc.Scan() {
+		var req renderRequest
+		var reply renderReply
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			reply.Error = err.Error()
+		} else if out, err := renderRequested(req); err != nil {
+			reply.Error = err.Error()
+		} else {
+			reply.Output = out
+		}
+		if enc.Encode(reply) != nil {
+			return
+		}
+	}
+}
+
+// renderRequested renders the diff req asks for, as gd would show it now.
+func renderRequested(req renderRequest) (string, error) {
+	name := req.File
+	if filepath.IsAbs(name) {
+		rel, err := filepath.Rel(repoRoot(), name)
+		if err != nil {
+			return "", err
+		}
+		name = rel
+	}
+	name = filepath.ToSlash(filepath.Clean(name))
+	files, err := loadFiles()
+	if err != nil {
+		return "", err
+	}
+	var f *git.FileStatus
+	for i := range files {
+		if files[i].Path == name {
+			f = &files[i]
+		}
+	}
+	if f == nil {
+		return "", fmt.Errorf("no changes to %s", name)
+	}
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	switch req.Format {
+	case "", "ansi":
+		raw, err := getDiffOutput(*f, false)
+		if err != nil {
+			return "", err
+		}
+		width := req.Width
+		if width <= 0 {
+			width = 120
+		}
+		out, _ := renderDiffOpts(raw, width, f.Path, renderOpts{sections: diffSections([]git.FileStatus{*f})})
+		return out, nil
+	case "html":
+		var b strings.Builder
+		err := writeHTML(&b, f.Path, collectDiffs([]git.FileStatus{*f}), false)
+		return b.String(), err
+	}
+	return "", fmt.Errorf("unknown format %q: use ansi or html", req.Format)
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Session Log ====================
+
+// sessionLog records what was looked at during a review so teams can audit
+// coverage. All methods are no-ops on a nil receiver, which is the state when
+// --session-log isn't given.
+type sessionLog struct {
+	path    string
+	current string
+	since   time.Time
+	index   map[string]int
+
+	Started time.Time      `json:"started"`
+	Ended   time.Time      `json:"ended"`
+	Repo    string         `json:"repo"`
+	Head    string         `json:"head,omitempty"`
+	Mode    string         `json:"mode"`
+	Files   []sessionFile  `json:"files"`
+	Events  []sessionEvent `json:"events"`
+}
+
+type sessionFile struct {
+	Path    string  `json:"path"`
+	Views   int     `json:"views"`
+	Seconds float64 `json:"seconds"`
+}
+
+type sessionEvent struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Path   string    `json:"path,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+var session *sessionLog
+
+func startSession(path string, files []git.FileStatus) {
+	s := &sessionLog{
+		path:    userPath(path),
+		index:   map[string]int{},
+		Started: time.Now(),
+		Repo:    repoRoot(),
+		Mode:    diffMode(),
+		Files:   []sessionFile{},
+		Events:  []sessionEvent{},
+	}
+	if out, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+		s.Head = strings.TrimSpace(string(out))
+	}
+	s.track(files)
+	session = s
+}
+
+// track adds files to the log, including ones that arrive after it started.
+func (s *sessionLog) track(files []git.FileStatus) {
+	if s == nil {
+		return
+	}
+	for _, f := range files {
+		s.file(f.Path)
+	}
+}
+
+func (s *sessionLog) file(path string) *sessionFile {
+	i, ok := s.index[path]
+	if !ok {
+		i = len(s.Files)
+		s.index[path] = i
+		s.Files = append(s.Files, sessionFile{Path: path})
+	}
+	return &s.Files[i]
+}
+
+// view notes that path is now shown in the preview, closing out the time
+// spent on the previously viewed file.
+func (s *sessionLog) view(path string) {
+	if s == nil || path == s.current {
+		return
+	}
+	now := time.Now()
+	s.flush(now)
+	s.current, s.since = path, now
+	if path == "" {
+		return
+	}
+	s.file(path).Views++
+	s.Events = append(s.Events, sessionEvent{Time: now, Type: "view", Path: path})
+}
+
+func (s *sessionLog) flush(now time.Time) {
+	if s.current != "" {
+		s.file(s.current).Seconds += now.Sub(s.since).Seconds()
+	}
+}
+
+func (s *sessionLog) record(typ, path, detail string) {
+	if s == nil {
+		return
+	}
+	s.Events = append(s.Events, sessionEvent{Time: time.Now(), Type: typ, Path: path, Detail: detail})
+}
+
+func (s *sessionLog) save() error {
+	if s == nil {
+		return nil
+	}
+	s.Ended = time.Now()
+	s.flush(s.Ended)
+	s.current = ""
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Hunk Staging ====================
+
+// applyCached runs git apply --cached on patch, which changes the index only.
+func applyCached(patch string, args ...string) error {
+	return gitApply(patch, append([]string{"--cached"}, args...)...)
+}
+
+// gitApply runs git apply on patch, which changes the worktree unless args
+// say otherwise.
+func gitApply(patch string, args ...string) error {
+	c := exec.Command("git", withUnidiffZero(append([]string{"apply"}, args...))...)
+	c.Stdin = strings.NewReader(patch)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("git apply: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// stageHunk stages the current hunk with s, or unstages it with u. The
+// preview lists a file's unstaged hunks, then its staged ones; which a hunk
+// is follows from whether it applies to the index forwards or in reverse.
+func (m model) stageHunk(unstage bool) tea.Cmd {
+	f := m.selectedFile()
+	i := m.currentHunkIdx()
+	if f == nil || i < 0 {
+		return nil
+	}
+	if flagMain || f.Diff != "" {
+		return func() tea.Msg { return statusMsg{text: tr("staging needs a change in the worktree")} }
+	}
+	if ignoreSpace.Load() {
+		return func() tea.Msg { return statusMsg{text: tr("can't stage with whitespace hidden; I shows it")} }
+	}
+	h := markedHunk{path: f.Path, file: m.hunks[i].File, frag: m.hunks[i].Frag}
+	forward, back := []string(nil), []string{"--reverse"}
+	done, already := tr("staged hunk"), tr("hunk is already staged")
+	if unstage {
+		forward, back = back, forward
+		done, already = tr("unstaged hunk"), tr("hunk isn't staged")
+		session.record("unstage_hunk", h.path, h.frag.Header())
+	} else {
+		session.record("stage_hunk", h.path, h.frag.Header())
+	}
+	return func() tea.Msg {
+		patch := buildHunkPatch([]markedHunk{h})
+		if err := applyCached(patch, append(forward, "--check")...); err != nil {
+			// applying the other way means it's on the other side already
+			if applyCached(patch, append(back, "--check")...) == nil {
+				return statusMsg{text: already}
+			}
+			return errorMsg{err: err}
+		}
+		if err := applyCached(patch, forward...); err != nil {
+			return errorMsg{err: err}
+		}
+		files, err := loadFiles()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return filesLoadedMsg{files: files, text: done}
+	}
+}
+
+// promptRevertHunk asks before undoing the current hunk in the worktree with
+// git apply --reverse, leaving the file's other changes alone. A staged hunk
+// is unstaged first, with u, so the index and the worktree don't disagree.
+func (m *model) promptRevertHunk() {
+	f := m.selectedFile()
+	i := m.currentHunkIdx()
+	switch {
+	case f == nil || i < 0:
+		m.message = tr("mark files with x to discard their changes")
+		return
+	case !m.live() || flagMain || f.Diff != "":
+		m.message = tr("only the worktree's changes can be discarded")
+		return
+	case f.Untracked:
+		m.message = tr("an untracked file is discarded whole; mark it with x")
+		return
+	case f.Staged && (!f.Unstaged || m.inStagedSection()):
+		m.message = tr("the hunk is staged; unstage it first, with u")
+		return
+	case ignoreSpace.Load():
+		m.message = tr("can't discard with whitespace hidden; I shows it")
+		return
+	}
+	h := markedHunk{path: f.Path, file: m.hunks[i].File, frag: m.hunks[i].Frag}
+	m.prompt = &prompt{
+		label: tr("discard this hunk from the worktree? (y/N): "),
+		submit: func(answer string) tea.Cmd {
+			if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+				return nil
+			}
+			session.record("discard_hunk", h.path, h.frag.Header())
+			return func() tea.Msg {
+				if err := gitApply(buildHunkPatch([]markedHunk{h}), "--reverse"); err != nil {
+					return errorMsg{err: err}
+				}
+				files, err := loadFiles()
+				if err != nil {
+					return errorMsg{err: err}
+				}
+				return filesLoadedMsg{files: files, text: tr("discarded hunk")}
+			}
+		},
+	}
+}
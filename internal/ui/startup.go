@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Startup ====================
+
+// startupWait is how long gd waits for git before opening the browser
+// without the file list. A quick git status still gets to say "No changes."
+// without flashing the screen.
+const startupWait = 50 * time.Millisecond
+
+// filesResult is the first loadFiles, run in the background from startup.
+type filesResult struct {
+	files []git.FileStatus
+	err   error
+}
+
+// startupFilesMsg delivers the first file list to a browser opened before
+// git finished.
+type startupFilesMsg filesResult
+
+// loadFilesAsync starts loadFiles, so git can work while the terminal is
+// asked for its colors and the browser starts.
+func loadFilesAsync() <-chan filesResult {
+	ch := make(chan filesResult, 1)
+	go func() {
+		files, err := loadFiles()
+		ch <- filesResult{files, err}
+	}()
+	return ch
+}
+
+// awaitFiles waits up to wait for the load to finish; 0 waits as long as it
+// takes.
+func awaitFiles(ch <-chan filesResult, wait time.Duration) (filesResult, bool) {
+	if wait == 0 {
+		return <-ch, true
+	}
+	select {
+	case r := <-ch:
+		return r, true
+	case <-time.After(wait):
+		return filesResult{}, false
+	}
+}
+
+// awaitStartupFiles hands the browser the file list once git is done.
+func awaitStartupFiles(ch <-chan filesResult) tea.Cmd {
+	return func() tea.Msg {
+		return startupFilesMsg(<-ch)
+	}
+}
+
+// notRepoHelp is shown in place of git's error when gd is run outside a
+// repository.
+const notRepoHelp = `gd: not inside a git repository
+
+gd shows the changes in a git worktree. To start tracking this directory:
+
+    git init
+
+To compare two directories that aren't in git:
+
+    git diff --no-index <dir1> <dir2>
+`
+
+// noCommitsHelp is shown when --main is asked of a repository with nothing
+// committed to compare against.
+const noCommitsHelp = `gd: no commits yet
+
+--main compares committed branches, and this repository has none. Run gd
+without --main to see what's staged and untracked, which is everything so
+far.
+`
+
+// hasCommits reports whether HEAD points at a commit yet.
+func hasCommits() bool {
+	return exec.Command("git", "rev-parse", "--verify", "-q", "HEAD").Run() == nil
+}
+
+// failStartup explains why the first file list couldn't be loaded and exits.
+// Being outside a repository, or in one with no commits, gets a hint instead
+// of git's error.
+func failStartup(err error) {
+	switch {
+	case strings.Contains(err.Error(), "not a git repository"):
+		fmt.Fprint(os.Stderr, tr(notRepoHelp))
+	case flagMain && !hasCommits():
+		fmt.Fprint(os.Stderr, tr(noCommitsHelp))
+	default:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// noChangesText says there's nothing to show, pointing out that a repository
+// with no commits yet has nothing to diff until files are added.
+func noChangesText() string {
+	if !hasCommits() {
+		return tr("No changes: there are no commits yet, and no files to show. Add some and run gd again.")
+	}
+	return tr("No changes.")
+}
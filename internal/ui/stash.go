@@ -0,0 +1,148 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Stash ====================
+
+// stashesLoadedMsg replaces the stashes being browsed after one is applied,
+// popped, or dropped.
+type stashesLoadedMsg struct {
+	stashes []commit
+	text    string
+}
+
+// loadStashes reads git stash list, newest first, with each stash's diff
+// preloaded. Stashes are listed by title alone, as git stash list shows
+// them, since stash@{n} is what names them.
+func loadStashes() ([]commit, error) {
+	out, err := exec.Command("git", "stash", "list", "--format=%gd: %gs").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git stash list: %w", git.StderrError(err))
+	}
+	var stashes []commit
+	for i, title := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if title == "" {
+			continue
+		}
+		raw, err := exec.Command("git", "stash", "show", "-p", "--no-ext-diff", "--no-color", "--src-prefix=a/", "--dst-prefix=b/", stashRef(i)).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git stash show: %w", git.StderrError(err))
+		}
+		c := commit{title: title}
+		if parsed, err := readPatch(strings.NewReader(string(raw))); err == nil && len(parsed) > 0 {
+			c.files = parsed[0].files
+		}
+		stashes = append(stashes, c)
+	}
+	return stashes, nil
+}
+
+func stashRef(i int) string { return fmt.Sprintf("stash@{%d}", i) }
+
+// runStash browses the stashes, one per entry of the commit list, starting
+// at the one args name, as n or stash@{n}, if any.
+func runStash(args []string) {
+	n := 0
+	if len(args) > 0 {
+		arg := strings.TrimSuffix(strings.TrimPrefix(args[0], "stash@{"), "}")
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n < 0 || len(args) > 1 {
+			fmt.Fprintln(os.Stderr, "error: usage: gd stash [n]")
+			os.Exit(2)
+		}
+	}
+	stashes, err := loadStashes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(stashes) == 0 {
+		fmt.Println(tr("No stashes."))
+		return
+	}
+	if n >= len(stashes) {
+		fmt.Fprintf(os.Stderr, "error: no %s: there are %d stashes\n", stashRef(n), len(stashes))
+		os.Exit(1)
+	}
+	initTheme()
+	m := commitsModel(stashes)
+	m.stashes = true
+	m.selectCommit(n)
+	runProgram(m, allCommitFiles(stashes))
+}
+
+// stashAsk and stashDone are the question before each stash action and the
+// message once it's run.
+var (
+	stashAsk  = map[string]string{"apply": "apply %s? (y/N): ", "pop": "pop %s? (y/N): ", "drop": "drop %s? (y/N): "}
+	stashDone = map[string]string{"apply": "applied %s", "pop": "popped %s", "drop": "dropped %s"}
+)
+
+// stashAction asks before running git stash apply, pop, or drop on the
+// selected stash, then reloads the list.
+func (m model) stashAction(action string) tea.Cmd {
+	if len(m.commits) == 0 {
+		return func() tea.Msg { return statusMsg{text: tr("no stashes left")} }
+	}
+	ref := stashRef(m.commitIdx)
+	return func() tea.Msg {
+		return promptMsg{prompt: &prompt{
+			label: trf(stashAsk[action], ref),
+			submit: func(answer string) tea.Cmd {
+				if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+					return nil
+				}
+				session.record("stash_"+action, "", ref)
+				return func() tea.Msg {
+					if out, err := exec.Command("git", "stash", action, "--quiet", ref).CombinedOutput(); err != nil {
+						return errorMsg{err: fmt.Errorf("git stash %s: %s", action, strings.TrimSpace(string(out)))}
+					}
+					stashes, err := loadStashes()
+					if err != nil {
+						return errorMsg{err: err}
+					}
+					return stashesLoadedMsg{stashes: stashes, text: trf(stashDone[action], ref)}
+				}
+			},
+		}}
+	}
+}
+
+// promptStash stashes the worktree's changes, untracked files included as
+// the tree shows them, with a message when one is given.
+func (m model) promptStash() tea.Cmd {
+	if flagMain || m.stashes || !m.live() || len(m.files) == 0 {
+		return func() tea.Msg { return statusMsg{text: tr("nothing in the worktree to stash")} }
+	}
+	return func() tea.Msg {
+		return promptMsg{prompt: &prompt{
+			label: tr("stash message (empty for default): "),
+			submit: func(msg string) tea.Cmd {
+				args := []string{"stash", "push", "--include-untracked", "--quiet"}
+				if msg = strings.TrimSpace(msg); msg != "" {
+					args = append(args, "-m", msg)
+				}
+				session.record("stash", "", msg)
+				return func() tea.Msg {
+					if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+						return errorMsg{err: fmt.Errorf("git stash: %s", strings.TrimSpace(string(out)))}
+					}
+					files, err := loadFiles()
+					if err != nil {
+						return errorMsg{err: err}
+					}
+					return filesLoadedMsg{files: files, text: tr("stashed changes")}
+				}
+			},
+		}}
+	}
+}
@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ==================== Diffstat ====================
+
+const statBarMax = 50
+
+func writeStat(w io.Writer, diffs []fileDiff) {
+	writeStatWidth(w, diffs, 0)
+}
+
+// writeStatWidth is writeStat with the bars shortened to fit lines width
+// wide, or statBarMax long at most when width is 0.
+func writeStatWidth(w io.Writer, diffs []fileDiff, width int) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	nameW, maxChange := 0, 0
+	for _, d := range diffs {
+		if n := ansi.StringWidth(d.file.Path); n > nameW {
+			nameW = n
+		}
+		if c := d.added + d.deleted; c > maxChange {
+			maxChange = c
+		}
+	}
+	countW := len(fmt.Sprint(maxChange))
+	if countW < 3 {
+		countW = 3
+	}
+	barMax := statBarMax
+	if width > 0 {
+		// " name | count bars"
+		barMax = max(min(barMax, width-nameW-countW-4), 5)
+	}
+
+	var totalAdd, totalDel int
+	for _, d := range diffs {
+		totalAdd += d.added
+		totalDel += d.deleted
+
+		fmt.Fprintf(w, " %s | ", fitStr(d.file.Path, nameW))
+		if d.binary {
+			fmt.Fprintf(w, "%*s\n", countW, "Bin")
+			continue
+		}
+		add, del := d.added, d.deleted
+		if maxChange > barMax {
+			add = scaleStat(add, maxChange, barMax)
+			del = scaleStat(del, maxChange, barMax)
+		}
+		fmt.Fprintf(w, "%*d %s%s\n", countW, d.added+d.deleted,
+			addIndSty.Render(strings.Repeat("+", add)),
+			delIndSty.Render(strings.Repeat("-", del)))
+	}
+
+	noun := "files"
+	if len(diffs) == 1 {
+		noun = "file"
+	}
+	fmt.Fprintf(w, " %d %s changed, %s, %s\n", len(diffs), noun,
+		addIndSty.Render(fmt.Sprintf("%d insertions(+)", totalAdd)),
+		delIndSty.Render(fmt.Sprintf("%d deletions(-)", totalDel)))
+}
+
+// statDiffs takes the counts loaded with the file list, so a diffstat needs
+// no diffs at all.
+func statDiffs(files []git.FileStatus) []fileDiff {
+	diffs := make([]fileDiff, len(files))
+	for i, f := range files {
+		diffs[i] = fileDiff{file: f, added: f.Stat.Added, deleted: f.Stat.Deleted, binary: f.Stat.Binary}
+	}
+	return diffs
+}
+
+// scaleStat shrinks n proportionally so the largest change fits barMax,
+// keeping at least one mark for any nonzero count.
+func scaleStat(n, max, barMax int) int {
+	if n == 0 {
+		return 0
+	}
+	s := n * barMax / max
+	if s == 0 {
+		s = 1
+	}
+	return s
+}
+
+// showStats shows the tree's files as a diffstat over the preview, with
+// bars as long as it has room for, until the next key.
+func (m *model) showStats() {
+	var b strings.Builder
+	// the popup's border and padding take four columns, and the line's
+	// leading space one more
+	writeStatWidth(&b, statDiffs(m.files), max(m.viewport.width-6, 10))
+	m.popup = &popup{title: tr("Diffstat"), text: strings.TrimRight(b.String(), "\n")}
+}
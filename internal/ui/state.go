@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ==================== Saved State ====================
+
+// uiState is where the browser was left: the file selected and how far its
+// preview was scrolled, the tree's scroll, its collapsed directories, and
+// how it was filtered.
+type uiState struct {
+	Path      string   `json:"path,omitempty"`
+	Offset    int      `json:"offset,omitempty"`
+	Scroll    int      `json:"scroll,omitempty"`
+	Collapsed []string `json:"collapsed,omitempty"`
+	Query     string   `json:"query,omitempty"`
+	Kind      int32    `json:"kind,omitempty"`
+}
+
+// savedStates is .git/gd-state.json: the state gd was last quit in, for
+// each range it was showing, so starting it again picks up there.
+type savedStates map[string]uiState
+
+func statePath() (string, error) {
+	dir, err := gitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gd-state.json"), nil
+}
+
+func loadStates() (savedStates, error) {
+	p, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+	st := savedStates{}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return st, nil
+}
+
+// savedState is the state saved for the current range, if any. Its kind of
+// change is restored straight away, before the files are loaded, unless a
+// flag names one.
+func savedState(kindFlag bool) *uiState {
+	st, err := loadStates()
+	if err != nil {
+		debugf("saved state: %v", err)
+		return nil
+	}
+	saved, ok := st[reviewRange()]
+	if !ok {
+		return nil
+	}
+	if !kindFlag && !flagMain && saved.Kind > kindAll && saved.Kind < kindCount {
+		showKind.Store(saved.Kind)
+	}
+	return &saved
+}
+
+// applyState puts the tree and preview back as they were saved, once the
+// files are in.
+func (m *model) applyState() {
+	st := m.saved
+	if st == nil || len(m.files) == 0 {
+		return
+	}
+	m.saved = nil
+	for _, dir := range st.Collapsed {
+		if m.collapsed == nil {
+			m.collapsed = map[string]bool{}
+		}
+		m.collapsed[dir] = true
+	}
+	m.query = st.Query
+	m.updateFilter()
+	for i, idx := range m.filtered {
+		if f := m.allLines[idx].File; f != nil && f.Path == st.Path {
+			m.cursor = i
+		}
+	}
+	// the first window size scrolls the cursor into view if it's below
+	m.scroll = min(st.Scroll, m.cursor)
+	if f := m.selectedFile(); f != nil && f.Path == st.Path {
+		m.restorePath, m.restoreOffset = st.Path, st.Offset
+	}
+}
+
+// saveState records where the browser was left when gd quits, for the
+// worktree or a branch; commits and patches are read once.
+func (m model) saveState() error {
+	if len(m.commits) > 0 || comparedPaths != "" || len(m.files) == 0 {
+		return nil
+	}
+	st, err := loadStates()
+	if err != nil {
+		return err
+	}
+	var now uiState
+	if f := m.selectedFile(); f != nil {
+		now.Path = f.Path
+		if f.Path == m.shownPath {
+			now.Offset = m.viewport.yOffset
+		}
+	}
+	now.Scroll = m.scroll
+	now.Collapsed = slices.Sorted(maps.Keys(m.collapsed))
+	now.Query = m.query
+	now.Kind = showKind.Load()
+	st[reviewRange()] = now
+	p, err := statePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, append(data, '\n'), 0o644)
+}
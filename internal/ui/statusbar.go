@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ==================== Status Bar ====================
+
+// statusBarH is the number of rows the status bar takes from the bottom of
+// the screen.
+const statusBarH = 1
+
+// repoInfo is what the status bar says about the repository.
+type repoInfo struct {
+	name     string
+	worktree string // a linked worktree's name
+	branch   string
+	upstream bool
+	ahead    int
+	behind   int
+}
+
+type repoInfoMsg repoInfo
+
+// loadRepoInfo looks up the branch and where it stands against its upstream.
+func loadRepoInfo() tea.Msg {
+	info := repoInfo{branch: currentBranch()}
+	info.name, info.worktree = repoNames()
+	if info.branch != "" && exec.Command("git", "symbolic-ref", "-q", "HEAD").Run() != nil {
+		// currentBranch gives the full sha of a detached HEAD
+		info.branch = trf("detached at %s", shortSHA(info.branch))
+	}
+	out, err := exec.Command("git", "rev-list", "--left-right", "--count", "@{upstream}...HEAD").Output()
+	if err == nil {
+		if f := strings.Fields(string(out)); len(f) == 2 {
+			info.behind, _ = strconv.Atoi(f[0])
+			info.ahead, _ = strconv.Atoi(f[1])
+			info.upstream = true
+		}
+	}
+	return repoInfoMsg(info)
+}
+
+// diffMode names what the diff compares: the worktree against HEAD, one
+// commit, or a range of commits.
+func diffMode() string {
+	if shownCommit != "" {
+		return trf("commit %s", shownCommit)
+	}
+	if reviewPR > 0 {
+		return fmt.Sprintf("#%d %s%s%.7s", reviewPR, baseRef, rangeSep, headRef)
+	}
+	if flagMain {
+		return diffRange()
+	}
+	return "worktree"
+}
+
+// changeTotals counts the files and lines changed, as "3 files +12 −4".
+func (m model) changeTotals() string {
+	if len(m.files) == 0 {
+		return ""
+	}
+	s := tr("1 file")
+	if len(m.files) > 1 {
+		s = trf("%d files", len(m.files))
+	}
+	s = borderSty.Render(s)
+	var added, deleted int
+	for _, f := range m.files {
+		added += f.Stat.Added
+		deleted += f.Stat.Deleted
+	}
+	if added > 0 {
+		s += " " + addIndSty.Render(fmt.Sprintf("+%d", added))
+	}
+	if deleted > 0 {
+		s += " " + delIndSty.Render(fmt.Sprintf("−%d", deleted))
+	}
+	return s
+}
+
+// renderStatusBar draws the bottom row: the repository, branch, mode, and
+// totals on the left, and the latest message or CI status on the right.
+func (m model) renderStatusBar() string {
+	var left []string
+	if m.repo.name != "" {
+		name := titleSty.Render(m.repo.name)
+		if m.repo.worktree != "" {
+			name += borderSty.Render(" ⊂ ") + titleSty.Render(m.repo.worktree)
+		}
+		left = append(left, name)
+	}
+	if m.repo.branch != "" {
+		branch := dirSty.Render(m.repo.branch)
+		if m.repo.ahead > 0 {
+			branch += " " + stagedBadge.Render(fmt.Sprintf("↑%d", m.repo.ahead))
+		}
+		if m.repo.behind > 0 {
+			branch += " " + unstBadge.Render(fmt.Sprintf("↓%d", m.repo.behind))
+		}
+		left = append(left, branch)
+	}
+	switch {
+	case m.stashes && len(m.commits) == 1:
+		left = append(left, hunkHdrSty.Render(tr("1 stash")))
+	case m.stashes:
+		left = append(left, hunkHdrSty.Render(trf("%d stashes", len(m.commits))))
+	case m.reflog && len(m.commits) == 1:
+		left = append(left, hunkHdrSty.Render(tr("1 reflog entry")))
+	case m.reflog:
+		left = append(left, hunkHdrSty.Render(trf("%d reflog entries", len(m.commits))))
+	case len(m.commits) > 1:
+		left = append(left, hunkHdrSty.Render(trf("%d commits", len(m.commits))))
+	case comparedPaths != "":
+		left = append(left, hunkHdrSty.Render(comparedPaths))
+	case len(m.commits) == 1:
+		left = append(left, hunkHdrSty.Render(tr("patch")))
+	default:
+		left = append(left, hunkHdrSty.Render(tr(diffMode())))
+	}
+	if totals := m.changeTotals(); totals != "" {
+		left = append(left, totals)
+	}
+	sep := " │ "
+	if flagAccessible {
+		sep = ", "
+	}
+	l := " " + strings.Join(left, borderSty.Render(sep))
+
+	var r string
+	if m.message != "" {
+		r = borderSty.Render(m.message)
+	} else if ci := ciIndicator(m.ci); ci != "" {
+		r = ci
+	}
+	// the message gives way to the repository context, then both get cut
+	room := m.width - ansi.StringWidth(l) - 2
+	if w := ansi.StringWidth(r); w > room {
+		r = ansi.Truncate(r, max(room, 0), "…")
+	}
+	l = ansi.Truncate(l, m.width, "…")
+	pad := m.width - ansi.StringWidth(l) - ansi.StringWidth(r) - 1
+	if r == "" || pad < 1 {
+		return l
+	}
+	return l + spaces(pad) + r + " "
+}
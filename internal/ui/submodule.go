@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+)
+
+// ==================== Submodules ====================
+
+// maxSubmoduleLog is how many of a submodule's new commits are listed.
+const maxSubmoduleLog = 20
+
+// submoduleLog lists the commits in old..new of the submodule at path, one
+// line each, or nothing when it isn't checked out or lacks them.
+func submoduleLog(path, old, new string) []string {
+	dir := filepath.Join(repoRoot(), path)
+	out, err := exec.Command("git", "-C", dir, "log", "--oneline", "--no-decorate", "--no-color",
+		fmt.Sprintf("-n%d", maxSubmoduleLog+1), old+".."+new).Output()
+	if err != nil {
+		debugf("git log in submodule %s: %v", path, git.StderrError(err))
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) > maxSubmoduleLog {
+		lines = append(lines[:maxSubmoduleLog], "…")
+	}
+	if lines[0] == "" {
+		// new is behind old: the submodule went back
+		return nil
+	}
+	return lines
+}
@@ -0,0 +1,214 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ==================== SVG Snapshot ====================
+
+const (
+	svgFontSize = 14
+	svgCharW    = 8.4
+	svgLineH    = 18
+	svgPad      = 12
+)
+
+// sgrState is the subset of SGR attributes lipgloss emits for diff rendering.
+type sgrState struct {
+	fg, bg string
+	bold   bool
+	italic bool
+	faint  bool
+}
+
+var ansiBasic = [16]string{
+	"#000000", "#cd3131", "#0dbc79", "#e5e510", "#2472c8", "#bc3fbc", "#11a8cd", "#e5e5e5",
+	"#666666", "#f14c4c", "#23d18b", "#f5f543", "#3b8eea", "#d670d6", "#29b8db", "#ffffff",
+}
+
+func (st *sgrState) apply(params string) {
+	if params == "" {
+		*st = sgrState{}
+		return
+	}
+	p := strings.Split(params, ";")
+	for i := 0; i < len(p); i++ {
+		n, _ := strconv.Atoi(p[i])
+		switch {
+		case n == 0:
+			*st = sgrState{}
+		case n == 1:
+			st.bold = true
+		case n == 2:
+			st.faint = true
+		case n == 3:
+			st.italic = true
+		case n == 22:
+			st.bold, st.faint = false, false
+		case n == 23:
+			st.italic = false
+		case n == 39:
+			st.fg = ""
+		case n == 49:
+			st.bg = ""
+		case n >= 30 && n <= 37:
+			st.fg = ansiBasic[n-30]
+		case n >= 90 && n <= 97:
+			st.fg = ansiBasic[n-90+8]
+		case n >= 40 && n <= 47:
+			st.bg = ansiBasic[n-40]
+		case n >= 100 && n <= 107:
+			st.bg = ansiBasic[n-100+8]
+		case (n == 38 || n == 48) && i+4 < len(p) && p[i+1] == "2":
+			r, _ := strconv.Atoi(p[i+2])
+			g, _ := strconv.Atoi(p[i+3])
+			b, _ := strconv.Atoi(p[i+4])
+			c := fmt.Sprintf("#%02x%02x%02x", r, g, b)
+			if n == 38 {
+				st.fg = c
+			} else {
+				st.bg = c
+			}
+			i += 4
+		case (n == 38 || n == 48) && i+2 < len(p) && p[i+1] == "5":
+			idx, _ := strconv.Atoi(p[i+2])
+			c := ""
+			if idx < 16 {
+				c = ansiBasic[idx]
+			}
+			if n == 38 {
+				st.fg = c
+			} else {
+				st.bg = c
+			}
+			i += 2
+		}
+	}
+}
+
+// writeSVG converts ANSI-styled terminal output into a standalone SVG image.
+func writeSVG(w io.Writer, ansi string) error {
+	lines := strings.Split(strings.TrimRight(ansi, "\n"), "\n")
+	cols := 0
+	for _, l := range lines {
+		if n := len([]rune(stripANSI(l))); n > cols {
+			cols = n
+		}
+	}
+	width := float64(cols)*svgCharW + 2*svgPad
+	height := len(lines)*svgLineH + 2*svgPad
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%d" viewBox="0 0 %.0f %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", pal.background)
+	fmt.Fprintf(&b, `<g font-family="ui-monospace, SFMono-Regular, Menlo, Consolas, monospace" font-size="%d" fill="%s">`+"\n", svgFontSize, pal.file)
+
+	for row, line := range lines {
+		y := svgPad + row*svgLineH
+		var st sgrState
+		col := 0
+		var texts strings.Builder
+		for len(line) > 0 {
+			if strings.HasPrefix(line, "\x1b[") {
+				end := strings.IndexByte(line, 'm')
+				if end == -1 {
+					break
+				}
+				st.apply(line[2:end])
+				line = line[end+1:]
+				continue
+			}
+			if strings.HasPrefix(line, "\x1b]") {
+				line = skipOSC(line)
+				continue
+			}
+			next := strings.IndexByte(line, '\x1b')
+			if next == -1 {
+				next = len(line)
+			}
+			if next == 0 {
+				next = 1 // stray escape, emit it as text
+			}
+			run := line[:next]
+			line = line[next:]
+			n := len([]rune(run))
+			x := svgPad + float64(col)*svgCharW
+			if st.bg != "" {
+				fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`+"\n", x, y, float64(n)*svgCharW, svgLineH, st.bg)
+			}
+			if strings.TrimSpace(run) != "" {
+				fmt.Fprintf(&texts, `<tspan x="%.1f"%s>%s</tspan>`, x, svgAttrs(st), htmlEscape(run))
+			}
+			col += n
+		}
+		if texts.Len() > 0 {
+			fmt.Fprintf(&b, `<text y="%d" xml:space="preserve">%s</text>`+"\n", y+svgLineH-5, texts.String())
+		}
+	}
+
+	b.WriteString("</g>\n</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func svgAttrs(st sgrState) string {
+	var a strings.Builder
+	if st.fg != "" {
+		fmt.Fprintf(&a, ` fill="%s"`, st.fg)
+	}
+	if st.bold {
+		a.WriteString(` font-weight="bold"`)
+	}
+	if st.italic {
+		a.WriteString(` font-style="italic"`)
+	}
+	if st.faint {
+		a.WriteString(` opacity="0.6"`)
+	}
+	return a.String()
+}
+
+// skipOSC drops a leading OSC sequence (such as an OSC 8 hyperlink)
+// terminated by ST or BEL.
+func skipOSC(s string) string {
+	for i := 2; i < len(s); i++ {
+		if s[i] == '\a' {
+			return s[i+1:]
+		}
+		if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+			return s[i+2:]
+		}
+	}
+	return ""
+}
+
+func stripANSI(s string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "\x1b[") {
+			end := strings.IndexByte(s, 'm')
+			if end == -1 {
+				break
+			}
+			s = s[end+1:]
+			continue
+		}
+		if strings.HasPrefix(s, "\x1b]") {
+			s = skipOSC(s)
+			continue
+		}
+		next := strings.IndexByte(s, '\x1b')
+		if next == -1 {
+			next = len(s)
+		}
+		if next == 0 {
+			next = 1 // stray escape, keep it as text
+		}
+		b.WriteString(s[:next])
+		s = s[next:]
+	}
+	return b.String()
+}
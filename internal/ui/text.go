@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// spaceRun is sliced for padding rather than building a run of spaces for
+// every line.
+var spaceRun = strings.Repeat(" ", 256)
+
+// spaces returns n spaces.
+func spaces(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n <= len(spaceRun) {
+		return spaceRun[:n]
+	}
+	return strings.Repeat(" ", n)
+}
+
+func trimLine(s string) string {
+	return strings.TrimRight(s, "\n\r")
+}
+
+// fitStr cuts s short with … or pads it with spaces to fill exactly w
+// cells.
+func fitStr(s string, w int) string {
+	n := ansi.StringWidth(s)
+	if n > w {
+		if w <= 1 {
+			return "…"
+		}
+		s = ansi.Truncate(s, w, "…")
+		n = ansi.StringWidth(s)
+	}
+	return s + spaces(w-n)
+}
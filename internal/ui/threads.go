@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arnavsurve/gd/pkg/render"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== PR Threads ====================
+
+// prComment is a review comment, shaped like GitHub's pulls comments API.
+type prComment struct {
+	ID        int64     `json:"id"`
+	ReplyTo   int64     `json:"in_reply_to_id"`
+	Path      string    `json:"path"`
+	Line      *int      `json:"line"` // nil once the comment is outdated
+	Side      string    `json:"side"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+type prThread []prComment
+
+var (
+	threadsMu       sync.RWMutex
+	threadsByPath   map[string]map[render.ThreadKey][]render.Thread
+	threadsExpanded atomic.Bool
+)
+
+type threadsMsg struct {
+	threads map[string]map[render.ThreadKey][]render.Thread
+	count   int
+}
+
+// pathThreads returns the review threads on path.
+func pathThreads(path string) map[render.ThreadKey][]render.Thread {
+	threadsMu.RLock()
+	defer threadsMu.RUnlock()
+	return threadsByPath[path]
+}
+
+// fetchThreads loads the review threads on the current branch's pull request
+// and places them on the lines they were left on.
+func fetchThreads() (map[string]map[render.ThreadKey][]render.Thread, int, error) {
+	p, err := originProvider()
+	if err != nil {
+		return nil, 0, err
+	}
+	pr, err := reviewedPR(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := p.reviewThreads(pr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	threads := map[string]map[render.ThreadKey][]render.Thread{}
+	count := 0
+	for _, t := range list {
+		root := t[0]
+		if root.Line == nil {
+			continue
+		}
+		side := root.Side
+		if side == "" {
+			side = "RIGHT"
+		}
+		if threads[root.Path] == nil {
+			threads[root.Path] = map[render.ThreadKey][]render.Thread{}
+		}
+		k := render.ThreadKey{Side: side, Line: *root.Line}
+		thread := make(render.Thread, len(t))
+		for i, c := range t {
+			thread[i] = render.Comment{Author: c.User.Login, Body: c.Body, When: c.CreatedAt}
+		}
+		threads[root.Path][k] = append(threads[root.Path][k], thread)
+		count++
+	}
+	return threads, count, nil
+}
+
+func loadThreads() tea.Msg {
+	threads, count, err := fetchThreads()
+	if err != nil {
+		return nil
+	}
+	return threadsMsg{threads: threads, count: count}
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== tmux ====================
+
+// inTmux reports whether --tmux asked for a separate pane and gd is actually
+// running inside a tmux session.
+func inTmux() bool {
+	return flagTmux != "" && flagTmux != "off" && os.Getenv("TMUX") != ""
+}
+
+// tmuxArgs returns the tmux subcommand that opens a new pane or window for
+// the --tmux mode.
+func tmuxArgs() []string {
+	switch flagTmux {
+	case "window":
+		return []string{"new-window"}
+	case "vsplit":
+		return []string{"split-window", "-v"}
+	default:
+		return []string{"split-window", "-h"}
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runInTmux starts c in a new tmux pane, leaving the browser running in this
+// one. tmux runs the command through the shell, so arguments are quoted.
+func runInTmux(c *exec.Cmd) tea.Cmd {
+	words := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		words[i] = shellQuote(a)
+	}
+	args := tmuxArgs()
+	if c.Dir != "" {
+		args = append(args, "-c", c.Dir)
+	}
+	args = append(args, strings.Join(words, " "))
+	return func() tea.Msg {
+		if out, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+			return errorMsg{err: fmt.Errorf("tmux: %w %s", err, strings.TrimSpace(string(out)))}
+		}
+		return statusMsg{text: trf("opened in tmux %s", flagTmux)}
+	}
+}
+
+// pageInTmux pages text with the pager's script in a new tmux pane. The pane
+// can't read our stdin, so the text goes through a temp file that the
+// pager's shell removes. less keeps the pane open however short the text.
+func pageInTmux(text, script string) tea.Cmd {
+	tmp, err := os.CreateTemp("", "gd-*.diff")
+	if err != nil {
+		return func() tea.Msg { return errorMsg{err: fmt.Errorf("tmux: %w", err)} }
+	}
+	_, err = tmp.WriteString(text)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return func() tea.Msg { return errorMsg{err: fmt.Errorf("tmux: %w", err)} }
+	}
+	name := shellQuote(tmp.Name())
+	return runInTmux(exec.Command("sh", "-c", `export LESS="${LESS-R}"; `+script+" <"+name+"; rm -f "+name))
+}
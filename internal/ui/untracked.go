@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/pkg/render"
+)
+
+// ==================== Untracked Files ====================
+
+// untrackedDiff makes an untracked text file's diff from its content, which
+// is much quicker than git diff --no-index. ok is false for binary files,
+// which are left to git.
+func untrackedDiff(path string) (diff string, ok bool, err error) {
+	defer trace("read", path)()
+	full := filepath.Join(repoRoot(), path)
+	info, err := os.Stat(full)
+	if err != nil {
+		return "", false, err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return "", false, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return "", false, nil
+	}
+	mode := "100644"
+	if info.Mode()&0o111 != 0 {
+		mode = "100755"
+	}
+	var b strings.Builder
+	b.Grow(len(data) + len(data)/20 + 200)
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\nnew file mode %s\n", path, path, mode)
+	if len(data) == 0 {
+		return b.String(), true, nil
+	}
+	text := string(data)
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	fmt.Fprintf(&b, "--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@ %s\n", path, len(lines), render.UntrackedComment)
+	for _, l := range lines {
+		b.WriteByte('+')
+		b.WriteString(l)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		b.WriteString("\n\\ No newline at end of file\n")
+	}
+	return b.String(), true, nil
+}
@@ -0,0 +1,218 @@
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==================== Viewed Files ====================
+
+// reviewState is .git/gd-review.json: for each range reviewed, the files
+// marked viewed with a hash of their diff then. A file whose diff has
+// changed since shows as not viewed again.
+type reviewState map[string]map[string]string
+
+func reviewStatePath() (string, error) {
+	dir, err := gitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gd-review.json"), nil
+}
+
+func loadReviewState() (reviewState, error) {
+	p, err := reviewStatePath()
+	if err != nil {
+		return nil, err
+	}
+	st := reviewState{}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return st, nil
+}
+
+func saveReviewState(st reviewState) error {
+	p, err := reviewStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, append(data, '\n'), 0o644)
+}
+
+// reviewRange names what's being reviewed: the --main range, or the
+// worktree on the current branch.
+func reviewRange() string {
+	if flagMain {
+		return diffRange()
+	}
+	return "worktree " + currentBranch()
+}
+
+// diffHash fingerprints a file's patch, which stays the same however its
+// changes are staged or shown.
+func diffHash(f git.FileStatus) (string, error) {
+	raw, err := kindPatch(f, false, kindAll)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// viewedMsg replaces the set of files marked viewed.
+type viewedMsg struct {
+	viewed map[string]bool
+	text   string
+}
+
+// viewedFiles returns which of files are marked viewed in the current range
+// and unchanged since.
+func viewedFiles(files []git.FileStatus) (map[string]bool, error) {
+	st, err := loadReviewState()
+	if err != nil {
+		return nil, err
+	}
+	marks := st[reviewRange()]
+	viewed := map[string]bool{}
+	for _, f := range files {
+		if h, ok := marks[f.Path]; ok {
+			if now, err := diffHash(f); err == nil && now == h {
+				viewed[f.Path] = true
+			}
+		}
+	}
+	return viewed, nil
+}
+
+// loadViewed reads the viewed marks for files, when they're the worktree's
+// or a branch's rather than commits'.
+func (m model) loadViewed(files []git.FileStatus) tea.Cmd {
+	if len(m.commits) > 0 || len(files) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		viewed, err := viewedFiles(files)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return viewedMsg{viewed: viewed}
+	}
+}
+
+// toggleViewed marks the selected file viewed, or not, saving the mark with
+// its diff's hash for the current range.
+func (m model) toggleViewed() tea.Cmd {
+	f := m.selectedFile()
+	if f == nil {
+		return nil
+	}
+	if len(m.commits) > 0 {
+		return func() tea.Msg { return statusMsg{text: tr("viewed marks are for the worktree or --main")} }
+	}
+	file := *f
+	mark := !m.viewed[file.Path]
+	viewed := maps.Clone(m.viewed)
+	if viewed == nil {
+		viewed = map[string]bool{}
+	}
+	if mark {
+		viewed[file.Path] = true
+	} else {
+		delete(viewed, file.Path)
+	}
+	text := trf("%s viewed, %d of %d", file.Path, len(viewed), len(m.files))
+	if !mark {
+		text = trf("%s not viewed", file.Path)
+	}
+	session.record("viewed", file.Path, fmt.Sprint(mark))
+	return func() tea.Msg {
+		st, err := loadReviewState()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		rng := reviewRange()
+		if mark {
+			h, err := diffHash(file)
+			if err != nil {
+				return errorMsg{err: err}
+			}
+			if st[rng] == nil {
+				st[rng] = map[string]string{}
+			}
+			st[rng][file.Path] = h
+		} else {
+			delete(st[rng], file.Path)
+			if len(st[rng]) == 0 {
+				delete(st, rng)
+			}
+		}
+		if err := saveReviewState(st); err != nil {
+			return errorMsg{err: fmt.Errorf("saving viewed marks: %w", err)}
+		}
+		return viewedMsg{viewed: viewed, text: text}
+	}
+}
+
+// ==================== Review Summary ====================
+
+// writeReviewSummary writes a Markdown summary of a self-review: which files
+// were viewed, and the notes left on them and on their hunks.
+func writeReviewSummary(w io.Writer, files []git.FileStatus, viewed map[string]bool, notes []reviewComment) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Review of %s\n\n", reviewRange())
+	n := 0
+	for _, f := range files {
+		if viewed[f.Path] {
+			n++
+		}
+	}
+	fmt.Fprintf(&b, "%d of %d files viewed.\n\n", n, len(files))
+	byPath := map[string][]reviewComment{}
+	for _, c := range notes {
+		byPath[c.Path] = append(byPath[c.Path], c)
+	}
+	for _, f := range files {
+		box := "[ ]"
+		if viewed[f.Path] {
+			box = "[x]"
+		}
+		fmt.Fprintf(&b, "- %s `%s`\n", box, f.Path)
+		for _, c := range byPath[f.Path] {
+			where := ""
+			switch {
+			case c.Line == 0:
+			case c.Side == "LEFT":
+				where = fmt.Sprintf("removed line %d: ", c.Line)
+			default:
+				where = fmt.Sprintf("line %d: ", c.Line)
+			}
+			body := strings.ReplaceAll(strings.TrimSpace(c.Body), "\n", "\n    ")
+			fmt.Fprintf(&b, "  - %s%s\n", where, body)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
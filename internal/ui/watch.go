@@ -0,0 +1,225 @@
+package ui
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gd/internal/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ==================== Watch ====================
+
+type worktreeChangedMsg struct{}
+
+// watcher reports changes to the worktree and to the index and HEAD, batched
+// so that a save or checkout touching many files refreshes once.
+type watcher struct {
+	fsw     *fsnotify.Watcher
+	root    string
+	gitDir  string
+	changes chan struct{}
+}
+
+const watchQuiet = 150 * time.Millisecond
+
+func startWatch() (*watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	gd, err := gitDir()
+	if err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	w := &watcher{fsw: fsw, root: repoRoot(), gitDir: gd, changes: make(chan struct{}, 1)}
+	if err := fsw.Add(gd); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	w.addTree(w.root)
+	// gd's own git status would otherwise refresh the index and wake us up
+	os.Setenv("GIT_OPTIONAL_LOCKS", "0")
+	go w.run()
+	return w, nil
+}
+
+// addTree watches dir and the directories under it that git doesn't ignore;
+// fsnotify isn't recursive.
+func (w *watcher) addTree(dir string) {
+	var dirs []string
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, p)
+		return nil
+	})
+	ignored := ignoredPaths(w.root, dirs)
+	for _, d := range dirs {
+		if ignored[d] || underAny(d, ignored) {
+			continue
+		}
+		w.fsw.Add(d)
+	}
+}
+
+// ignoredPaths returns which of paths git ignores.
+func ignoredPaths(root string, paths []string) map[string]bool {
+	c := exec.Command("git", "check-ignore", "--stdin")
+	c.Dir = root
+	c.Stdin = strings.NewReader(strings.Join(paths, "\n") + "\n")
+	out, _ := c.Output() // exits 1 when nothing is ignored
+	ignored := map[string]bool{}
+	for _, p := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if p != "" {
+			ignored[p] = true
+		}
+	}
+	return ignored
+}
+
+func underAny(p string, dirs map[string]bool) bool {
+	for d := filepath.Dir(p); d != p && d != "."; p, d = d, filepath.Dir(d) {
+		if dirs[d] {
+			return true
+		}
+	}
+	return false
+}
+
+// relevant reports whether an event could change what gd shows. Inside .git
+// only the index and HEAD matter; lock and temp files come and go constantly.
+func (w *watcher) relevant(ev fsnotify.Event) bool {
+	if filepath.Dir(ev.Name) == w.gitDir {
+		name := filepath.Base(ev.Name)
+		return name == "index" || name == "HEAD"
+	}
+	return ev.Op != fsnotify.Chmod
+}
+
+func (w *watcher) run() {
+	var quiet <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if ev.Has(fsnotify.Create) {
+				if st, err := os.Stat(ev.Name); err == nil && st.IsDir() {
+					w.addTree(ev.Name)
+				}
+			}
+			if w.relevant(ev) {
+				quiet = time.After(watchQuiet)
+			}
+		case _, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+		case <-quiet:
+			quiet = nil
+			select {
+			case w.changes <- struct{}{}:
+			default: // a refresh is already pending
+			}
+		}
+	}
+}
+
+// wait blocks until the next batch of changes.
+func (w *watcher) wait() tea.Cmd {
+	return func() tea.Msg {
+		<-w.changes
+		return worktreeChangedMsg{}
+	}
+}
+
+// ==================== Poll ====================
+
+// polledMsg carries a fingerprint of the worktree's status; a change from the
+// last one means something needs reloading.
+type polledMsg struct {
+	sum uint64
+	err error
+}
+
+// pollStatus fingerprints the status after every, or at once when every is 0.
+// It's the fallback for filesystems where fsnotify misses events, like NFS
+// and some container mounts.
+func pollStatus(every time.Duration) tea.Cmd {
+	poll := func() tea.Msg {
+		sum, err := statusSum()
+		return polledMsg{sum: sum, err: err}
+	}
+	if every == 0 {
+		return poll
+	}
+	return tea.Tick(every, func(time.Time) tea.Msg { return poll() })
+}
+
+// statusSum hashes git status along with the size and modification time of
+// each file it lists, so that editing an already modified file counts too.
+func statusSum() (uint64, error) {
+	args := append([]string{"status", "--porcelain", "-z", "--"}, pathspecs...)
+	c := exec.Command("git", args...)
+	c.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+	out, err := c.Output()
+	if err != nil {
+		return 0, fmt.Errorf("git status: %w", git.StderrError(err))
+	}
+	h := fnv.New64a()
+	h.Write(out)
+	root := repoRoot()
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++ // skip the old path
+		}
+		if st, err := os.Stat(filepath.Join(root, entry[3:])); err == nil {
+			fmt.Fprintf(h, "%d %d", st.Size(), st.ModTime().UnixNano())
+		}
+	}
+	return h.Sum64(), nil
+}
+
+// reloadFiles lists the changed files again, keeping the selection.
+func reloadFiles(text string) tea.Cmd {
+	return func() tea.Msg {
+		files, err := loadFiles()
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return filesLoadedMsg{files: files, text: text}
+	}
+}
+
+// live reports whether the files come from the repository, and so can be
+// reloaded, rather than from a patch.
+func (m model) live() bool {
+	if len(m.commits) > 0 {
+		return false
+	}
+	for _, f := range m.files {
+		if f.Diff != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ==================== Repository Root ====================
+
+// gd runs from the top of the worktree it was started in, wherever below it
+// that was, so git takes and gives paths the way the tree shows them: from
+// the root. git apply in a subdirectory would otherwise skip hunks above it.
+
+var (
+	startDir  string // where gd was started
+	cwdPrefix string // startDir below the root, as "sub/dir/"; empty at the root
+)
+
+// enterRepoRoot moves to the root of the worktree gd was started in. Outside
+// a repository, or inside .git, it stays put.
+func enterRepoRoot() {
+	startDir, _ = os.Getwd()
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel", "--show-prefix").Output()
+	top, prefix, _ := strings.Cut(strings.TrimRight(string(out), "\n"), "\n")
+	if err != nil {
+		// no git binary, maybe; go-git can still find the root
+		var ok bool
+		if top, ok = goGitRoot(startDir); !ok {
+			return
+		}
+		if rel, err := filepath.Rel(top, startDir); err == nil && rel != "." {
+			prefix = filepath.ToSlash(rel) + "/"
+		}
+	}
+	if top == "" || os.Chdir(top) != nil {
+		return
+	}
+	cwdPrefix = prefix
+}
+
+// userPath resolves a file named on the command line or in a prompt against
+// the directory gd was started in.
+func userPath(p string) string {
+	if p == "" || p == "-" || filepath.IsAbs(p) || startDir == "" {
+		return p
+	}
+	return filepath.Join(startDir, p)
+}
+
+// rootPathspecs makes pathspecs given relative to where gd was started
+// relative to the root. Magic ones, like :(top)dir or :!vendor, are left to
+// git.
+func rootPathspecs(specs []string) []string {
+	if cwdPrefix == "" {
+		return specs
+	}
+	out := make([]string, len(specs))
+	for i, s := range specs {
+		out[i] = s
+		if !strings.HasPrefix(s, ":") {
+			out[i] = path.Join(cwdPrefix, filepath.ToSlash(s))
+		}
+	}
+	return out
+}
+
+// repoNames names the repository for the status bar and, in a worktree
+// added with git worktree add, that worktree too.
+func repoNames() (repo, worktree string) {
+	repo = filepath.Base(repoRoot())
+	out, err := exec.Command("git", "rev-parse", "--absolute-git-dir", "--git-common-dir").Output()
+	if err != nil {
+		return repo, ""
+	}
+	gitDir, common, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(repoRoot(), common)
+	}
+	if filepath.Clean(gitDir) == filepath.Clean(common) {
+		return repo, ""
+	}
+	// the main repository is the directory holding its .git, or a bare one
+	if filepath.Base(common) == ".git" {
+		return filepath.Base(filepath.Dir(common)), repo
+	}
+	return strings.TrimSuffix(filepath.Base(common), ".git"), repo
+}
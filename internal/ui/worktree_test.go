@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestPathsFromSubdir starts gd below the root: files named on the command
+// line are still taken from there once it has moved to the root.
+func TestPathsFromSubdir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := playRepo(t)
+	sub := filepath.Join(root, "sub")
+	t.Chdir(sub)
+	repoRootOnce = sync.Once{}
+	defer func() {
+		startDir, cwdPrefix, flagCover, session = "", "", "", nil
+		repoRootOnce = sync.Once{}
+	}()
+	enterRepoRoot()
+	if wd, _ := os.Getwd(); wd != root {
+		t.Fatalf("enterRepoRoot moved to %s; want %s", wd, root)
+	}
+
+	flagCover = "c.out"
+	if got, want := findCoverProfile(), filepath.Join(sub, "c.out"); got != want {
+		t.Errorf("findCoverProfile = %s; want %s", got, want)
+	}
+
+	startSession("session.json", nil)
+	if err := session.save(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filepath.Join(sub, "session.json"))
+	if _, err := os.Stat(filepath.Join(sub, "session.json")); err != nil {
+		t.Errorf("session log not written where gd was started: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "session.json")); err == nil {
+		t.Error("session log written at the repo root")
+	}
+}
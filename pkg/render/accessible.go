@@ -0,0 +1,66 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// accessibleHunkHeader says where a hunk starts, and in what, when git found
+// an enclosing function.
+func (r *renderer) accessibleHunkHeader(frag *gitdiff.TextFragment) string {
+	s := r.trf("change at line %d", frag.NewPosition)
+	if frag.Comment != "" {
+		s += r.trf(", in %s", frag.Comment)
+	}
+	return s
+}
+
+// renderAccessible writes frag one line per row, removed and added lines
+// labeled, long ones wrapped rather than cut off.
+func (r *renderer) renderAccessible(b *strings.Builder, frag *gitdiff.TextFragment, ann Annotations) {
+	width := r.Width
+	oldNum := int(frag.OldPosition)
+	newNum := int(frag.NewPosition)
+	for _, line := range frag.Lines {
+		text := r.expandTabs(trimLine(line.Line))
+		switch line.Op {
+		case gitdiff.OpContext:
+			writeWrapped(b, "", text, width)
+			oldNum++
+			newNum++
+		case gitdiff.OpDelete:
+			writeWrapped(b, r.tr("removed: "), text, width)
+			r.renderThreads(b, ann.Threads, "LEFT", oldNum, 0)
+			oldNum++
+		case gitdiff.OpAdd:
+			label := r.tr("added: ")
+			if ann.uncovered(newNum) {
+				label = r.tr("added, not run by tests: ")
+			}
+			writeWrapped(b, label, text, width)
+			r.renderNotes(b, ann.Notes, newNum, 0)
+			r.renderThreads(b, ann.Threads, "RIGHT", newNum, 0)
+			newNum++
+		}
+	}
+}
+
+// writeWrapped writes label and text, continuing onto further rows indented
+// past the label when it's wider than width.
+func writeWrapped(b *strings.Builder, label, text string, width int) {
+	indent := ansi.StringWidth(label)
+	w := max(width-indent, 10)
+	b.WriteString(label)
+	for {
+		row := ansi.Truncate(text, w, "")
+		b.WriteString(row)
+		b.WriteByte('\n')
+		text = text[len(row):]
+		if text == "" {
+			return
+		}
+		b.WriteString(spaces(indent))
+	}
+}
@@ -0,0 +1,124 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// blameW is the width of the blame column: a space, the short sha, the
+// author, and the age.
+const blameW = 22
+
+// Blame is who last changed a line of the old side, and when.
+type Blame struct {
+	SHA    string
+	Author string
+	When   time.Time
+}
+
+// blameCell renders b for the blame column, blameW wide.
+func (r *renderer) blameCell(b Blame) string {
+	if b.SHA == "" {
+		return spaces(blameW)
+	}
+	return r.Theme.Dim.Render(fmt.Sprintf(" %s %s %4s", b.SHA, fitStr(b.Author, 8), shortAge(time.Since(b.When))))
+}
+
+// shortAge gives d in its largest whole unit, as 5m, 3d, or 2y.
+func shortAge(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < day:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d < 14*day:
+		return fmt.Sprintf("%dd", int(d/day))
+	case d < 60*day:
+		return fmt.Sprintf("%dw", int(d/(7*day)))
+	case d < 365*day:
+		return fmt.Sprintf("%dmo", int(d/(30*day)))
+	}
+	return fmt.Sprintf("%dy", int(d/(365*day)))
+}
+
+// newCodeCoverage counts the added lines in f that hold statements, and how
+// many of them ran.
+func newCodeCoverage(f *gitdiff.File, lines map[int]bool) (covered, total int) {
+	for _, frag := range f.TextFragments {
+		n := int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpDelete {
+				continue
+			}
+			if ran, ok := lines[n]; ok && l.Op == gitdiff.OpAdd {
+				total++
+				if ran {
+					covered++
+				}
+			}
+			n++
+		}
+	}
+	return covered, total
+}
+
+// Notes maps new-file line numbers to messages shown under those lines.
+type Notes map[int][]string
+
+// renderNotes writes the messages for line below it, indented by indent.
+func (r *renderer) renderNotes(b *strings.Builder, notes Notes, line, indent int) {
+	for _, msg := range notes[line] {
+		b.WriteString(strings.Repeat(" ", indent))
+		b.WriteString(r.Theme.Note.Render(fitStr("⚠ "+msg, r.Width-indent)))
+		b.WriteByte('\n')
+	}
+}
+
+// ThreadKey places a thread on a line of one side of the diff.
+type ThreadKey struct {
+	Side string // LEFT or RIGHT
+	Line int
+}
+
+// Thread is a review thread's comments, the first starting it.
+type Thread []Comment
+
+// Comment is one comment of a review thread.
+type Comment struct {
+	Author string
+	Body   string
+	When   time.Time
+}
+
+// renderThreads writes the threads anchored at side/line below it: a one-line
+// summary each, or every comment when expanded.
+func (r *renderer) renderThreads(b *strings.Builder, threads map[ThreadKey][]Thread, side string, line, indent int) {
+	t := r.Theme
+	for _, th := range threads[ThreadKey{Side: side, Line: line}] {
+		pad := strings.Repeat(" ", indent)
+		w := r.Width - indent
+		if !r.ExpandThreads {
+			first := strings.SplitN(strings.TrimSpace(th[0].Body), "\n", 2)[0]
+			summary := "▸ " + th[0].Author + ": " + first
+			if len(th) > 1 {
+				summary += fmt.Sprintf(" (+%d)", len(th)-1)
+			}
+			b.WriteString(pad + t.HunkHeader.Render(fitStr(summary, w)) + "\n")
+			continue
+		}
+		for i, c := range th {
+			head := "▾ "
+			if i > 0 {
+				head = "  "
+			}
+			b.WriteString(pad + t.FileHeader.Render(head+c.Author) + t.Dim.Render(" "+c.When.Local().Format("2006-01-02")) + "\n")
+			for _, l := range strings.Split(strings.TrimSpace(c.Body), "\n") {
+				b.WriteString(pad + t.HunkHeader.Render(fitStr("  │ "+strings.TrimRight(l, "\r"), w)) + "\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package render
+
+// Hyperlink wraps text in an OSC 8 escape so supporting terminals make it
+// clickable. Terminals without support print just the text, as it is for an
+// empty target.
+func Hyperlink(target, text string) string {
+	if target == "" {
+		return text
+	}
+	return linkStart(target) + text + linkEnd
+}
+
+// linkStart opens an OSC 8 hyperlink to target, which linkEnd closes.
+func linkStart(target string) string { return "\x1b]8;;" + target + "\x1b\\" }
+
+const linkEnd = "\x1b]8;;\x1b\\"
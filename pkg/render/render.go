@@ -0,0 +1,1212 @@
+// Package render draws git diffs for a terminal: syntax highlighted,
+// unified or side by side, with changed words marked, and with whatever notes,
+// blame, coverage, and review threads the caller annotates files with.
+package render
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ==================== Options ====================
+
+// Options say how Diff draws a patch. The zero value draws it unified, 80
+// columns wide, in plain styles, with nothing but its lines.
+type Options struct {
+	// Width is the columns every row fills, 80 when 0.
+	Width int
+	// Filename names every file in the patch, as for one file's diff read
+	// from elsewhere; "" takes the names the patch gives.
+	Filename string
+	// Theme is what the diff is drawn in; nil draws it in plain styles.
+	Theme *Theme
+	// SideBySide draws removed lines beside the added lines they became.
+	SideBySide bool
+	// Accessible draws one column of plain text with each change labeled
+	// in words, for screen readers and braille displays.
+	Accessible bool
+	// Wrap soft-wraps long lines onto more rows instead of cutting them
+	// short.
+	Wrap bool
+	// HScroll scrolls lines this many columns left, unless they wrap.
+	HScroll int
+	// TabWidth is the columns a tab is drawn as, 4 when 0.
+	TabWidth int
+	// ShowWhitespace draws tabs as → and trailing spaces as ·.
+	ShowWhitespace bool
+	// ExpandThreads shows every comment of review threads rather than a
+	// line each.
+	ExpandThreads bool
+	// Syntax picks the lexer and the chroma style for a file by its path,
+	// "" for either to go by the file's name and the theme.
+	Syntax func(path string) (lexer, style string)
+	// LinkText, when set, draws the text and comments in lines with style,
+	// linking what it finds in them, such as issue numbers.
+	LinkText func(text string, style func(string) string) string
+	// Annotate gives what a file's diff is drawn with besides its lines.
+	Annotate func(path string, f *gitdiff.File) Annotations
+	// Sections labels the parts of a file's diff, by path, in order.
+	Sections map[string][]Summary
+	// Binary describes a binary file's change, a row each; nil says only
+	// that it's binary.
+	Binary func(f *gitdiff.File, path string, width int) []string
+	// SubmoduleLog lists the commits of the submodule at path in
+	// old..new, a line each; nil lists none.
+	SubmoduleLog func(path, old, new string) []string
+	// Progress gets the output so far every 400 diff lines or so;
+	// rendering stops when it returns false.
+	Progress func(string, []Hunk) bool
+	// Limit renders only about this many bytes of diff lines, 0 for all.
+	Limit int
+	// LineLimit renders only this many diff lines, 0 for all.
+	LineLimit int
+	// More is the note below a diff cut short by Limit or LineLimit, given
+	// how many lines were left out; nil for none.
+	More func(lines int) string
+	// Translate gives the words a diff is labeled with in the reader's
+	// language; nil leaves them in English.
+	Translate func(string) string
+	// Trace times a step of the render, returning the func that ends it.
+	Trace func(step, path string) func()
+}
+
+// Annotations are what a file's diff is drawn with besides its lines.
+type Annotations struct {
+	Notes   Notes                  // messages below added lines
+	Cover   map[int]bool           // by new line: whether tests ran its statements
+	Threads map[ThreadKey][]Thread // review threads below the lines they're on
+	Blame   map[int]Blame          // by old line: shown beside context and removals
+	Owners  []string               // named in the file's header
+	URL     string                 // the file name in the header links here
+	// LineURL links new line numbers, when set.
+	LineURL func(line int) string
+}
+
+// Hunk records where a hunk starts in the rendered output.
+type Hunk struct {
+	Line int
+	End  int   // the line after its last, or 0 while it's still streaming in
+	Rows []int // the row each of Frag's lines starts on, from Line; unified only
+	File *gitdiff.File
+	Frag *gitdiff.TextFragment
+}
+
+// Summary is a line said about a file's diff as a whole.
+type Summary struct {
+	Text  string
+	Style lipgloss.Style
+}
+
+// renderer is one Diff's options, and what's worked out from them.
+type renderer struct {
+	Options
+	tab string // what a tab is drawn as
+}
+
+func newRenderer(opts Options) *renderer {
+	if opts.Width <= 0 {
+		opts.Width = 80
+	}
+	if opts.Theme == nil {
+		opts.Theme = plainTheme
+	}
+	if opts.TabWidth <= 0 {
+		opts.TabWidth = 4
+	}
+	return &renderer{Options: opts, tab: strings.Repeat(" ", opts.TabWidth)}
+}
+
+func (r *renderer) tr(s string) string {
+	if r.Translate == nil {
+		return s
+	}
+	return r.Translate(s)
+}
+
+func (r *renderer) trf(format string, args ...any) string {
+	return fmt.Sprintf(r.tr(format), args...)
+}
+
+func (r *renderer) trace(step, path string) func() {
+	if r.Trace == nil {
+		return func() {}
+	}
+	return r.Trace(step, path)
+}
+
+func (r *renderer) annotate(path string, f *gitdiff.File) Annotations {
+	if r.Annotate == nil {
+		return Annotations{}
+	}
+	return r.Annotate(path, f)
+}
+
+// ==================== Syntax Highlighting ====================
+
+type highlighter struct {
+	lexer chroma.Lexer
+	lang  string // the lexer's name, for the token cache
+	style *chroma.Style
+	r     *renderer
+}
+
+// Theme is the colors and styles a diff is drawn in. It keeps what it renders
+// of them, so it mustn't change once drawn with: make another instead.
+type Theme struct {
+	// Chroma names the chroma style for syntax highlighting.
+	Chroma string
+	// Truncate is the color of the … ending a line cut short.
+	Truncate string
+	// The backgrounds of removed and added lines, and of the words that
+	// changed within them; "" for none.
+	AddBg, DelBg, AddWordBg, DelWordBg string
+
+	LineNum    lipgloss.Style
+	Gutter     lipgloss.Style // between the sides of a side-by-side row
+	Added      lipgloss.Style // + and what's said about additions
+	Removed    lipgloss.Style // - and what's said about deletions
+	Note       lipgloss.Style // notes below lines, and ! beside untested ones
+	FileHeader lipgloss.Style
+	HunkHeader lipgloss.Style
+	File       lipgloss.Style
+	Dim        lipgloss.Style
+
+	once sync.Once
+	rows rowSpans
+
+	spansMu sync.RWMutex
+	spans   map[spanKey]spanStyle
+
+	// highlighters holds one highlighter per file extension (or name, for
+	// files like Makefile), since matching a lexer tries every lexer's
+	// patterns.
+	highlightersMu sync.Mutex
+	highlighters   map[string]*highlighter
+}
+
+// plainTheme draws diffs without Options.Theme.
+var plainTheme = &Theme{Chroma: "monokai"}
+
+// rowSpans are the styles every diff row draws besides its text, rendered
+// once per theme instead of through lipgloss on each row.
+type rowSpans struct {
+	lineNum spanStyle
+	gutter  string
+	del     string
+	add     string
+	note    string
+}
+
+func (t *Theme) init() {
+	t.once.Do(func() {
+		t.rows = rowSpans{
+			lineNum: newSpanStyle(t.LineNum),
+			gutter:  t.Gutter.Render(" │"),
+			del:     t.Removed.Render(" -"),
+			add:     t.Added.Render(" +"),
+			note:    t.Note.Render(" !"),
+		}
+		t.spans = map[spanKey]spanStyle{}
+		t.highlighters = map[string]*highlighter{}
+	})
+}
+
+// bg is the background color for lines drawn on bg.
+func (t *Theme) bg(bg diffBg) string {
+	switch bg {
+	case bgAdd:
+		return t.AddBg
+	case bgDel:
+		return t.DelBg
+	case bgAddWord:
+		return t.AddWordBg
+	case bgDelWord:
+		return t.DelWordBg
+	}
+	return ""
+}
+
+func (r *renderer) highlighter(filename string) *highlighter {
+	key := filepath.Ext(filename)
+	if key == "" {
+		key = filepath.Base(filename)
+	}
+	var lexName, styleName string
+	if r.Syntax != nil {
+		lexName, styleName = r.Syntax(filename)
+	}
+	if lexName != "" || styleName != "" {
+		key += "\x00" + lexName + "\x00" + styleName
+	}
+	t := r.Theme
+	t.init()
+	t.highlightersMu.Lock()
+	defer t.highlightersMu.Unlock()
+	h, ok := t.highlighters[key]
+	if !ok {
+		lexer := lexers.Get(lexName)
+		if lexName == "" {
+			lexer = lexers.Match(filename)
+		}
+		if lexer == nil {
+			lexer = lexers.Fallback
+		}
+		lexer = chroma.Coalesce(lexer)
+
+		if styleName == "" {
+			styleName = t.Chroma
+		}
+		style := styles.Get(styleName)
+		if style == nil {
+			style = styles.Fallback
+		}
+
+		h = &highlighter{lexer: lexer, lang: lexer.Config().Name, style: style}
+		t.highlighters[key] = h
+	}
+	hl := *h
+	hl.r = r
+	return &hl
+}
+
+type diffBg int
+
+const (
+	bgNone diffBg = iota
+	bgAdd
+	bgDel
+	bgAddWord // the words that changed within an added line
+	bgDelWord
+)
+
+// wordBg is the background for changed words on a line drawn on bg.
+var wordBg = map[diffBg]diffBg{bgAdd: bgAddWord, bgDel: bgDelWord}
+
+// spanStyle is a style rendered down to the escape codes around its text,
+// which is all lipgloss produces for single-line text with colors and
+// attributes, without building a Style each time.
+type spanStyle struct{ prefix, suffix string }
+
+func (s spanStyle) render(text string) string {
+	return s.prefix + text + s.suffix
+}
+
+// write is render straight into b, without the intermediate string.
+func (s spanStyle) write(b *strings.Builder, text string) {
+	b.WriteString(s.prefix)
+	b.WriteString(text)
+	b.WriteString(s.suffix)
+}
+
+func newSpanStyle(s lipgloss.Style) spanStyle {
+	const mark = "\x00"
+	prefix, suffix, _ := strings.Cut(s.Render(mark), mark)
+	return spanStyle{prefix, suffix}
+}
+
+// spanKey identifies a token style on a background; tokenType is one of the
+// span* pseudo types for the truncation marker and padding.
+type spanKey struct {
+	tokenType chroma.TokenType
+	bg        diffBg
+}
+
+const (
+	spanTruncate chroma.TokenType = -1000 - iota
+	spanPad
+)
+
+func (h *highlighter) span(tt chroma.TokenType, bg diffBg) spanStyle {
+	t := h.r.Theme
+	k := spanKey{tt, bg}
+	t.spansMu.RLock()
+	ss, ok := t.spans[k]
+	t.spansMu.RUnlock()
+	if ok {
+		return ss
+	}
+
+	s := lipgloss.NewStyle()
+	switch tt {
+	case spanTruncate:
+		s = s.Foreground(lipgloss.Color(t.Truncate))
+	case spanPad:
+	default:
+		entry := h.style.Get(tt)
+		if entry.Colour.IsSet() {
+			s = s.Foreground(lipgloss.Color(entry.Colour.String()))
+		}
+		if entry.Bold == chroma.Yes {
+			s = s.Bold(true)
+		}
+		if entry.Italic == chroma.Yes {
+			s = s.Italic(true)
+		}
+	}
+	if bgColor := t.bg(bg); bgColor != "" {
+		s = s.Background(lipgloss.Color(bgColor))
+	}
+	ss = newSpanStyle(s)
+	t.spansMu.Lock()
+	t.spans[k] = ss
+	t.spansMu.Unlock()
+	return ss
+}
+
+// renderLine writes text highlighted on bg into b, cut or padded to exactly
+// w columns. The runes in words, counted after tabs are expanded, are drawn
+// on bg's stronger shade, as are tabs and trailing whitespace with
+// ShowWhitespace.
+func (h *highlighter) renderLine(b *strings.Builder, text string, w int, bg diffBg, words []wordSpan) {
+	r := h.r
+	var shown string // text with whitespace marked, rune for rune
+	if r.ShowWhitespace {
+		var marks []wordSpan
+		shown, marks = r.markWhitespace(text)
+		words = mergeSpans(words, marks)
+	}
+	text = r.expandTabs(text)
+	full := text
+
+	// the bytes of text scrolled off to the left, skipped as it's drawn
+	skip := 0
+	if off := r.HScroll; off > 0 && !r.Wrap {
+		skip = len(ansi.Truncate(text, off, ""))
+	}
+
+	// Truncate plain text first (before adding ANSI codes), by the cells it
+	// takes: two for wide characters, none for combining marks
+	visW := ansi.StringWidth(text[skip:])
+	truncated := false
+	if visW > w-1 && w > 1 {
+		text = text[:skip] + ansi.Truncate(text[skip:], w-1, "")
+		visW = ansi.StringWidth(text[skip:]) + 1
+		truncated = true
+		if shown != "" {
+			shown = shown[:runeOffset(shown, utf8.RuneCountInString(text))]
+		}
+	}
+
+	// lexed whole, and cached, so the line drawn again at another width
+	// isn't lexed again; only what's shown of it is drawn
+	toks, ok := h.tokens(full)
+	if !strings.HasPrefix(full, text) {
+		toks, ok = h.lex(text)
+	}
+	if !ok {
+		// Fallback: plain text with bg
+		if shown != "" {
+			text, skip = shown, runeOffset(shown, utf8.RuneCountInString(text[:skip]))
+		}
+		h.span(spanPad, bg).write(b, fitStr(text[skip:], w))
+		return
+	}
+
+	pos, shownAt, rest := 0, 0, len(text)
+	for _, tok := range toks {
+		val := tok.Value
+		if len(val) > rest {
+			val = val[:rest]
+		}
+		rest -= len(val)
+		if skip > 0 {
+			n := min(skip, len(val))
+			runes := utf8.RuneCountInString(val[:n])
+			pos += runes
+			if shown != "" {
+				shownAt += runeOffset(shown[shownAt:], runes)
+			}
+			skip -= n
+			val = val[n:]
+		}
+		val = strings.TrimRight(val, "\n\r")
+		if val == "" {
+			continue
+		}
+		links := r.LinkText != nil && (tok.Type == chroma.Text || tok.Type.InCategory(chroma.Comment))
+		// a token is split where a changed word starts or ends in it
+		for val != "" {
+			seg, segBg := val, bg
+			if len(words) > 0 {
+				in, n := wordSpanAt(words, pos, utf8.RuneCountInString(val))
+				seg = val[:runeOffset(val, n)]
+				pos += n
+				if in {
+					segBg = wordBg[bg]
+				}
+			}
+			val = val[len(seg):]
+			if shown != "" {
+				// highlighted as text, drawn with the markers
+				end := shownAt + runeOffset(shown[shownAt:], utf8.RuneCountInString(seg))
+				seg, shownAt = shown[shownAt:end], end
+			}
+			s := h.span(tok.Type, segBg)
+			if links {
+				b.WriteString(r.LinkText(seg, s.render))
+			} else {
+				s.write(b, seg)
+			}
+		}
+	}
+
+	if truncated {
+		h.span(spanTruncate, bg).write(b, "…")
+	}
+
+	// Pad remaining width with background
+	if pad := w - visW; pad > 0 {
+		h.span(spanPad, bg).write(b, spaces(pad))
+	}
+}
+
+// wrapLine is renderLine soft-wrapped: text highlighted on bg in as many rows
+// of exactly w columns as it takes.
+func (h *highlighter) wrapLine(text string, w int, bg diffBg, words []wordSpan) []string {
+	var b strings.Builder
+	expanded := h.r.expandTabs(text)
+	n := ansi.StringWidth(expanded)
+	if n <= w || w < 2 {
+		h.renderLine(&b, text, w, bg, words)
+		return []string{b.String()}
+	}
+	// highlighted whole, so tokens cut across rows keep their colors
+	h.renderLine(&b, text, n+1, bg, words)
+	full := b.String()
+	var rows []string
+	cut := func(from, to int) {
+		row := ansi.Cut(full, from, to)
+		rows = append(rows, row+h.span(spanPad, bg).render(spaces(w-(to-from))))
+	}
+	// rows break before a character that would cross the edge, so a wide
+	// one moves to the next row whole
+	start, col := 0, 0
+	for _, r := range expanded {
+		rw := ansi.StringWidth(string(r))
+		if col+rw-start > w {
+			cut(start, col)
+			start = col
+		}
+		col += rw
+	}
+	cut(start, col)
+	return rows
+}
+
+// spaceRun is sliced for padding rather than building a run of spaces for
+// every line.
+var spaceRun = strings.Repeat(" ", 256)
+
+// spaces returns n spaces.
+func spaces(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n <= len(spaceRun) {
+		return spaceRun[:n]
+	}
+	return strings.Repeat(" ", n)
+}
+
+// runeOffset is the byte offset of s's nth rune, or len(s) if it has fewer.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+// writeNum writes n right-aligned in w columns, as %*d would.
+func writeNum(b *strings.Builder, n, w int) {
+	var buf [20]byte
+	digits := strconv.AppendInt(buf[:0], int64(n), 10)
+	b.WriteString(spaces(w - len(digits)))
+	b.Write(digits)
+}
+
+// writeLineNum writes a styled line number column, blank for 0, linked to
+// url when it isn't "".
+func (r *renderer) writeLineNum(b *strings.Builder, n, w int, url string) {
+	if n <= 0 {
+		b.WriteString(spaces(w))
+		return
+	}
+	b.WriteString(r.Theme.rows.lineNum.prefix)
+	writeLinkedNum(b, n, w, url)
+	b.WriteString(r.Theme.rows.lineNum.suffix)
+}
+
+// writeLinkedNum writes n right-aligned in w columns, as a hyperlink to url
+// when it isn't "".
+func writeLinkedNum(b *strings.Builder, n, w int, url string) {
+	if url == "" {
+		writeNum(b, n, w)
+		return
+	}
+	b.WriteString(linkStart(url))
+	writeNum(b, n, w)
+	b.WriteString(linkEnd)
+}
+
+// writeLineNums writes the old and new number columns of a unified row as
+// one span; a side the line isn't on is 0 and left blank. The new number is
+// linked to newURL when it isn't "".
+func (r *renderer) writeLineNums(b *strings.Builder, oldNum, newNum, w int, newURL string) {
+	b.WriteString(r.Theme.rows.lineNum.prefix)
+	if oldNum > 0 {
+		writeNum(b, oldNum, w)
+	} else {
+		b.WriteString(spaces(w))
+	}
+	b.WriteByte(' ')
+	if newNum > 0 {
+		writeLinkedNum(b, newNum, w, newURL)
+	} else {
+		b.WriteString(spaces(w))
+	}
+	b.WriteString(r.Theme.rows.lineNum.suffix)
+}
+
+// ==================== Diff Rendering ====================
+
+func (r *renderer) expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", r.tab)
+}
+
+// markWhitespace returns s with tabs expanded as → and trailing spaces
+// drawn as ·, and where those markers are.
+func (r *renderer) markWhitespace(s string) (string, []wordSpan) {
+	trailing := len(strings.TrimRight(s, " \t"))
+	var b strings.Builder
+	var marks []wordSpan
+	pos := 0
+	mark := func(n int) {
+		if k := len(marks) - 1; k >= 0 && marks[k].end == pos {
+			marks[k].end += n
+		} else {
+			marks = append(marks, wordSpan{pos, pos + n})
+		}
+		pos += n
+	}
+	for i, c := range s {
+		switch {
+		case c == '\t':
+			b.WriteString("→" + r.tab[1:])
+			mark(len(r.tab))
+		case c == ' ' && i >= trailing:
+			b.WriteString("·")
+			mark(1)
+		default:
+			b.WriteRune(c)
+			pos++
+		}
+	}
+	return b.String(), marks
+}
+
+func trimLine(s string) string {
+	return strings.TrimRight(s, "\n\r")
+}
+
+// fitStr cuts s short with … or pads it with spaces to fill exactly w
+// cells.
+func fitStr(s string, w int) string {
+	n := ansi.StringWidth(s)
+	if n > w {
+		if w <= 1 {
+			return "…"
+		}
+		s = ansi.Truncate(s, w, "…")
+		n = ansi.StringWidth(s)
+	}
+	return s + spaces(w-n)
+}
+
+type lineGroup struct {
+	op    gitdiff.LineOp
+	lines []string
+}
+
+func groupLines(lines []gitdiff.Line) []lineGroup {
+	var groups []lineGroup
+	for _, l := range lines {
+		text := trimLine(l.Line)
+		if len(groups) > 0 && groups[len(groups)-1].op == l.Op {
+			groups[len(groups)-1].lines = append(groups[len(groups)-1].lines, text)
+		} else {
+			groups = append(groups, lineGroup{op: l.Op, lines: []string{text}})
+		}
+	}
+	return groups
+}
+
+// Diff draws patch, returning the rows and where each hunk starts in them. A
+// patch that doesn't parse comes back as it is, with the error.
+func Diff(patch string, opts Options) (string, []Hunk, error) {
+	r := newRenderer(opts)
+	parsed := r.trace("parse", r.Filename)
+	files, _, err := gitdiff.Parse(strings.NewReader(patch))
+	parsed()
+	if err != nil {
+		return patch, nil, err
+	}
+	if len(files) == 0 {
+		return patch, nil, nil
+	}
+	r.Theme.init()
+	more := 0
+	if r.Limit > 0 || r.LineLimit > 0 {
+		files, more = truncateDiff(files, r.Limit, r.LineLimit)
+	}
+	defer r.trace("render", r.Filename)()
+	var b strings.Builder
+	// every row is padded out to at least the width, so start there
+	b.Grow(len(patch) + strings.Count(patch, "\n")*r.Width)
+	var hunks []Hunk
+	var flush func() bool
+	if r.Progress != nil {
+		flush = func() bool { return r.Progress(b.String(), append([]Hunk(nil), hunks...)) }
+	}
+	lines := &lineCounter{b: &b}
+	parts := map[string]int{}
+	for i, f := range files {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		path := f.NewName
+		if path == "" {
+			path = f.OldName
+		}
+		var section Summary
+		if labels := r.Sections[path]; parts[path] < len(labels) {
+			section = labels[parts[path]]
+		}
+		parts[path]++
+		if !r.renderFileDiff(&b, lines, f, section, &hunks, flush) {
+			break
+		}
+	}
+	if more > 0 && r.More != nil {
+		b.WriteString(r.Theme.Note.Render(r.More(more)))
+		b.WriteByte('\n')
+	}
+	return b.String(), hunks, nil
+}
+
+// gitSymlink is git's mode for a symbolic link, whose content is its target.
+const gitSymlink = 0o120000
+
+// fileSummaries spells out what a diff's lines show poorly or not at all: a
+// mode change, where a symlink or submodule points, or a file's deletion.
+// link is set for a symlink or submodule, whose summary stands in for its
+// hunks.
+func (r *renderer) fileSummaries(f *gitdiff.File) (sums []Summary, link bool) {
+	mode := f.NewMode
+	if mode == 0 {
+		mode = f.OldMode // from the index line when the mode didn't change
+	}
+	if mode&0o170000 == gitSubmodule {
+		return r.submoduleSummaries(f), true
+	}
+	if mode&0o170000 == gitSymlink {
+		var oldTarget, newTarget string
+		for _, frag := range f.TextFragments {
+			for _, l := range frag.Lines {
+				switch l.Op {
+				case gitdiff.OpDelete:
+					oldTarget = trimLine(l.Line)
+				case gitdiff.OpAdd:
+					newTarget = trimLine(l.Line)
+				}
+			}
+		}
+		switch {
+		case f.IsDelete:
+			return []Summary{{r.trf("symlink to %s deleted", oldTarget), r.Theme.Removed}}, true
+		case f.IsNew || oldTarget == "":
+			return []Summary{{r.trf("symlink → %s", newTarget), r.Theme.File}}, true
+		default:
+			return []Summary{{r.trf("symlink → %s, was %s", newTarget, oldTarget), r.Theme.File}}, true
+		}
+	}
+	if f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode && !f.IsNew && !f.IsDelete {
+		sums = append(sums, Summary{r.trf("mode %o → %o", f.OldMode, f.NewMode), r.Theme.HunkHeader})
+	}
+	if f.IsDelete {
+		n := 0
+		for _, frag := range f.TextFragments {
+			n += int(frag.LinesDeleted)
+		}
+		text := r.trf("file deleted, %d lines removed", n)
+		switch n {
+		case 0:
+			text = r.tr("empty file deleted")
+		case 1:
+			text = r.tr("file deleted, 1 line removed")
+		}
+		sums = append(sums, Summary{text, r.Theme.Removed})
+	}
+	return sums, false
+}
+
+// lineCounter counts the lines written to a builder so far, looking only at
+// what was written since it was last asked.
+type lineCounter struct {
+	b       *strings.Builder
+	n, seen int
+}
+
+func (c *lineCounter) count() int {
+	s := c.b.String()
+	c.n += strings.Count(s[c.seen:], "\n")
+	c.seen = len(s)
+	return c.n
+}
+
+// renderFileDiff writes one file's diff to b, calling flush, when given, after
+// each streamChunk lines. It reports false if flush asked it to stop. A
+// section's text, when there is one, follows the name in the header. lines
+// counts b's lines, for the hunks' positions.
+func (r *renderer) renderFileDiff(b *strings.Builder, lines *lineCounter, f *gitdiff.File, section Summary, hunks *[]Hunk, flush func() bool) bool {
+	name := f.NewName
+	if name == "" {
+		name = f.OldName
+	}
+	if r.Filename != "" {
+		name = r.Filename
+	}
+	width := r.Width
+	t := r.Theme
+
+	ann := r.annotate(name, f)
+	if f.IsNew || f.IsBinary || r.Accessible {
+		ann.Blame = nil
+	}
+	var summary string
+	summarySty := t.Added
+	if ann.Cover != nil {
+		if covered, total := newCodeCoverage(f, ann.Cover); total > 0 {
+			summary = fmt.Sprintf("%d/%d new lines covered", covered, total)
+			if covered < total {
+				summarySty = t.Note
+			}
+		}
+	}
+
+	// a rename or copy names where it came from
+	var from string
+	if (f.IsRename || f.IsCopy) && f.OldName != name {
+		from = f.OldName
+	}
+
+	owners := strings.Join(ann.Owners, " ")
+	if r.Accessible {
+		b.WriteString("file: " + name)
+		if from != "" {
+			b.WriteString(", from " + from)
+		}
+		for _, s := range []string{section.Text, summary, owners} {
+			if s != "" {
+				b.WriteString(", " + s)
+			}
+		}
+	} else {
+		header := "── " + name + " "
+		if from != "" {
+			header = "── " + from + " → " + name + " "
+		}
+		if section.Text != "" {
+			header += section.Text + " "
+		}
+		if summary != "" {
+			header += summary + " "
+		}
+		if owners != "" {
+			header += owners + " "
+		}
+		pad := width - ansi.StringWidth(header)
+		b.WriteString(t.FileHeader.Render("── "))
+		if from != "" {
+			b.WriteString(t.FileHeader.Render(from + " → "))
+		}
+		b.WriteString(Hyperlink(ann.URL, t.FileHeader.Render(name)))
+		if section.Text != "" {
+			b.WriteString(" " + section.Style.Render(section.Text))
+		}
+		if summary != "" {
+			b.WriteString(" " + summarySty.Render(summary))
+		}
+		if owners != "" {
+			b.WriteString(" " + t.HunkHeader.Render(owners))
+		}
+		if pad > 0 {
+			b.WriteString(t.FileHeader.Render(" " + strings.Repeat("─", pad)))
+		} else {
+			b.WriteString(t.FileHeader.Render(" "))
+		}
+	}
+	b.WriteByte('\n')
+
+	summaries, link := r.fileSummaries(f)
+	for _, sum := range summaries {
+		b.WriteString(sum.Style.Render(ansi.Truncate("  "+sum.Text, width, "…")))
+		b.WriteByte('\n')
+	}
+	if link {
+		return true
+	}
+	if f.IsBinary {
+		preview := []string{t.Dim.Render("  Binary file")}
+		if r.Binary != nil {
+			preview = r.Binary(f, name, width)
+		}
+		for _, line := range preview {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+		return true
+	}
+	if from != "" && len(f.TextFragments) == 0 {
+		b.WriteString(t.Dim.Render("  Same content"))
+		b.WriteByte('\n')
+		return true
+	}
+
+	hl := r.highlighter(name)
+	// an untracked file reads better as itself than as all additions
+	untracked := f.IsNew && len(f.TextFragments) == 1 && f.TextFragments[0].Comment == UntrackedComment && !r.Accessible
+
+	for _, frag := range f.TextFragments {
+		*hunks = append(*hunks, Hunk{Line: lines.count(), File: f, Frag: frag})
+		if r.Accessible {
+			b.WriteString(r.accessibleHunkHeader(frag))
+			b.WriteByte('\n')
+		} else if frag.Comment != "" {
+			b.WriteString(t.HunkHeader.Render(frag.Comment))
+			b.WriteByte('\n')
+		}
+		rows := r.SideBySide && !untracked && !r.Accessible
+		parts := []*gitdiff.TextFragment{frag}
+		if flush != nil {
+			parts = splitFragment(frag, streamChunk, rows)
+		}
+		// words pair across the parts, which are slices of frag's lines
+		var words [][]wordSpan
+		if !rows && !untracked && !r.Accessible {
+			words = r.pairWordDiffs(frag.Lines)
+		}
+		at := 0
+		for _, part := range parts {
+			var partWords [][]wordSpan
+			if words != nil {
+				partWords = words[at : at+len(part.Lines)]
+			}
+			at += len(part.Lines)
+			if !r.Accessible {
+				hl.warm(part.Lines)
+			}
+			if untracked {
+				r.renderContent(b, part, hl, ann)
+			} else if r.Accessible {
+				r.renderAccessible(b, part, ann)
+			} else if rows {
+				r.renderSideBySide(b, part, hl, ann)
+			} else {
+				h := &(*hunks)[len(*hunks)-1]
+				base := lines.count() - h.Line
+				for _, row := range r.renderUnified(b, part, hl, ann, partWords) {
+					h.Rows = append(h.Rows, base+row)
+				}
+			}
+			if flush != nil && !flush() {
+				return false
+			}
+		}
+		(*hunks)[len(*hunks)-1].End = lines.count()
+	}
+	return true
+}
+
+// blameWidth is the width of the blame column, 0 when it isn't shown.
+func (a Annotations) blameWidth() int {
+	if a.Blame == nil {
+		return 0
+	}
+	return blameW
+}
+
+// lineURL is the link for line on the new side, or "" when there's none.
+func (a Annotations) lineURL(line int) string {
+	if a.LineURL == nil || line <= 0 {
+		return ""
+	}
+	return a.LineURL(line)
+}
+
+// uncovered reports whether the coverprofile has statements on line that
+// never ran.
+func (a Annotations) uncovered(line int) bool {
+	ran, ok := a.Cover[line]
+	return ok && !ran
+}
+
+// blameAt is the blame column for old line, or "" when it isn't shown.
+func (r *renderer) blameAt(a Annotations, line int) string {
+	if a.Blame == nil {
+		return ""
+	}
+	return r.blameCell(a.Blame[line])
+}
+
+func (r *renderer) renderSideBySide(b *strings.Builder, frag *gitdiff.TextFragment, hl *highlighter, ann Annotations) {
+	const numW = 4
+	width := r.Width
+	// [lnum numW] [space 1] [left colW] [blame] [ │  3] [rnum numW] [space 1] [right colW]
+	bw := ann.blameWidth()
+	colW := (width - numW*2 - 5 - bw) / 2
+	if colW < 10 {
+		colW = 10
+	}
+
+	groups := groupLines(frag.Lines)
+	oldNum := int(frag.OldPosition)
+	newNum := int(frag.NewPosition)
+
+	emitRow := func(lNum int, lText string, lBg diffBg, rNum int, rText string, rBg diffBg) {
+		var lWords, rWords []wordSpan
+		if lBg == bgDel && rBg == bgAdd {
+			lWords, rWords = wordDiff(r.expandTabs(lText), r.expandTabs(rText))
+		}
+		mark := " "
+		if rBg == bgAdd && ann.uncovered(rNum) {
+			mark = r.Theme.Note.Render("!")
+		}
+		blame := spaces(bw)
+		if lNum > 0 {
+			blame = r.blameAt(ann, lNum)
+		}
+		if r.Wrap {
+			left, right := hl.wrapLine(lText, colW, lBg, lWords), hl.wrapLine(rText, colW, rBg, rWords)
+			r.emitWrapped(b, hl, lNum, left, lBg, blame, rNum, right, rBg, mark, colW, numW, ann.lineURL(rNum))
+		} else {
+			r.writeLineNum(b, lNum, numW, "")
+			b.WriteByte(' ')
+			hl.renderLine(b, lText, colW, lBg, lWords)
+			b.WriteString(blame)
+			b.WriteString(r.Theme.rows.gutter)
+			b.WriteString(mark)
+			r.writeLineNum(b, rNum, numW, ann.lineURL(rNum))
+			b.WriteByte(' ')
+			hl.renderLine(b, rText, colW, rBg, rWords)
+			b.WriteByte('\n')
+		}
+		if rBg == bgAdd {
+			r.renderNotes(b, ann.Notes, rNum, numW*2+colW+5+bw)
+		}
+		if lNum > 0 {
+			r.renderThreads(b, ann.Threads, "LEFT", lNum, numW+1)
+		}
+		if rNum > 0 {
+			r.renderThreads(b, ann.Threads, "RIGHT", rNum, numW*2+colW+5+bw)
+		}
+	}
+
+	for i := 0; i < len(groups); i++ {
+		g := groups[i]
+		switch g.op {
+		case gitdiff.OpContext:
+			for _, text := range g.lines {
+				emitRow(oldNum, text, bgNone, newNum, text, bgNone)
+				oldNum++
+				newNum++
+			}
+		case gitdiff.OpDelete:
+			var adds []string
+			if i+1 < len(groups) && groups[i+1].op == gitdiff.OpAdd {
+				adds = groups[i+1].lines
+				i++
+			}
+			// each removed line beside the added line it became, if any
+			for _, p := range alignLines(g.lines, adds) {
+				var lNum, rNum int
+				var lText, rText string
+				lBg, rBg := bgNone, bgNone
+				if p[0] >= 0 {
+					lNum, lText, lBg = oldNum, g.lines[p[0]], bgDel
+					oldNum++
+				}
+				if p[1] >= 0 {
+					rNum, rText, rBg = newNum, adds[p[1]], bgAdd
+					newNum++
+				}
+				emitRow(lNum, lText, lBg, rNum, rText, rBg)
+			}
+		case gitdiff.OpAdd:
+			for _, text := range g.lines {
+				emitRow(0, "", bgNone, newNum, text, bgAdd)
+				newNum++
+			}
+		}
+	}
+}
+
+// emitWrapped writes a side-by-side row whose sides wrap onto more rows,
+// numbering, blaming, and marking only the first and filling the shorter side
+// with its background. The right number is linked to rURL when it isn't "".
+func (r *renderer) emitWrapped(b *strings.Builder, hl *highlighter, lNum int, left []string, lBg diffBg, blame string, rNum int, right []string, rBg diffBg, mark string, colW, numW int, rURL string) {
+	noBlame := spaces(ansi.StringWidth(blame))
+	for i := range max(len(left), len(right)) {
+		if i > 0 {
+			lNum, rNum, mark, blame = 0, 0, " ", noBlame
+		}
+		r.writeLineNum(b, lNum, numW, "")
+		b.WriteByte(' ')
+		if i < len(left) {
+			b.WriteString(left[i])
+		} else {
+			hl.span(spanPad, lBg).write(b, spaces(colW))
+		}
+		b.WriteString(blame)
+		b.WriteString(r.Theme.rows.gutter)
+		b.WriteString(mark)
+		r.writeLineNum(b, rNum, numW, rURL)
+		b.WriteByte(' ')
+		if i < len(right) {
+			b.WriteString(right[i])
+		} else {
+			hl.span(spanPad, rBg).write(b, spaces(colW))
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// renderContent draws an untracked file's lines as they are, numbered, in
+// one column.
+func (r *renderer) renderContent(b *strings.Builder, frag *gitdiff.TextFragment, hl *highlighter, ann Annotations) {
+	const numW = 4
+	// [num numW] [space 2] [text]
+	textW := max(r.Width-numW-2, 10)
+	num := int(frag.NewPosition)
+	for _, line := range frag.Lines {
+		r.writeLineNum(b, num, numW, ann.lineURL(num))
+		b.WriteString("  ")
+		text := trimLine(line.Line)
+		if r.Wrap {
+			for i, row := range hl.wrapLine(text, textW, bgNone, nil) {
+				if i > 0 {
+					b.WriteByte('\n')
+					b.WriteString(spaces(numW + 2))
+				}
+				b.WriteString(row)
+			}
+		} else {
+			hl.renderLine(b, text, textW, bgNone, nil)
+		}
+		b.WriteByte('\n')
+		r.renderNotes(b, ann.Notes, num, numW+2)
+		num++
+	}
+}
+
+// renderUnified writes frag one line above another, with words, indexed like
+// its lines, marking the words that changed. It returns the row each line
+// starts on, from the first, past wrapped rows and notes.
+func (r *renderer) renderUnified(b *strings.Builder, frag *gitdiff.TextFragment, hl *highlighter, ann Annotations, words [][]wordSpan) []int {
+	const numW = 4
+	// [oldnum numW] [space] [newnum numW] [space] [indicator 1] [space] [text] [blame]
+	textW := r.Width - numW*2 - 4 - ann.blameWidth()
+	if textW < 10 {
+		textW = 10
+	}
+
+	oldNum := int(frag.OldPosition)
+	newNum := int(frag.NewPosition)
+	// writeText writes a line's text and then blame, wrapped with Wrap onto
+	// more rows that leave the number and blame columns blank
+	writeText := func(text string, bg diffBg, words []wordSpan, blame string) {
+		if !r.Wrap {
+			hl.renderLine(b, text, textW, bg, words)
+			b.WriteString(blame)
+			return
+		}
+		for i, row := range hl.wrapLine(text, textW, bg, words) {
+			if i > 0 {
+				b.WriteByte('\n')
+				b.WriteString(spaces(numW*2 + 4))
+			}
+			b.WriteString(row)
+			if i == 0 {
+				b.WriteString(blame)
+			}
+		}
+	}
+
+	rows := make([]int, 0, len(frag.Lines))
+	start, row := b.Len(), 0
+	for i, line := range frag.Lines {
+		row += strings.Count(b.String()[start:], "\n")
+		start = b.Len()
+		rows = append(rows, row)
+		text := trimLine(line.Line)
+		var lineWords []wordSpan
+		if words != nil {
+			lineWords = words[i]
+		}
+
+		switch line.Op {
+		case gitdiff.OpContext:
+			r.writeLineNums(b, oldNum, newNum, numW, ann.lineURL(newNum))
+			b.WriteString("   ")
+			writeText(text, bgNone, nil, r.blameAt(ann, oldNum))
+			oldNum++
+			newNum++
+
+		case gitdiff.OpDelete:
+			r.writeLineNums(b, oldNum, 0, numW, "")
+			b.WriteString(r.Theme.rows.del)
+			b.WriteByte(' ')
+			writeText(text, bgDel, lineWords, r.blameAt(ann, oldNum))
+			oldNum++
+
+		case gitdiff.OpAdd:
+			r.writeLineNums(b, 0, newNum, numW, ann.lineURL(newNum))
+			if ann.uncovered(newNum) {
+				b.WriteString(r.Theme.rows.note)
+			} else {
+				b.WriteString(r.Theme.rows.add)
+			}
+			b.WriteByte(' ')
+			writeText(text, bgAdd, lineWords, "")
+			newNum++
+		}
+		b.WriteByte('\n')
+		if line.Op == gitdiff.OpAdd {
+			r.renderNotes(b, ann.Notes, newNum-1, numW*2+4)
+		}
+		if line.Op != gitdiff.OpAdd {
+			r.renderThreads(b, ann.Threads, "LEFT", oldNum-1, numW*2+4)
+		}
+		if line.Op != gitdiff.OpDelete {
+			r.renderThreads(b, ann.Threads, "RIGHT", newNum-1, numW*2+4)
+		}
+	}
+	return rows
+}
+
+// UntrackedComment labels the one hunk of an untracked file's diff, which
+// Diff draws as the file's content rather than as additions.
+const UntrackedComment = "new file (untracked)"
@@ -0,0 +1,150 @@
+package render
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestMain(m *testing.M) {
+	// golden files hold plain text, so colors can't make them flaky
+	lipgloss.SetColorProfile(termenv.Ascii)
+	os.Exit(m.Run())
+}
+
+// golden compares got with testdata/name, or rewrites it with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file; run go test -update and check the diff\ngot:\n%s", path, got)
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	patches, _ := filepath.Glob(filepath.Join("testdata", "render", "*.diff"))
+	if len(patches) == 0 {
+		t.Fatal("no patches in testdata/render")
+	}
+	for _, p := range patches {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, _, err := gitdiff.Parse(strings.NewReader(string(raw)))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("%s: want one file, got %d (%v)", p, len(files), err)
+		}
+		f := files[0]
+		name := f.NewName
+		if f.IsDelete {
+			name = f.OldName
+		}
+		base := strings.TrimSuffix(filepath.Base(p), ".diff")
+
+		for _, layout := range []struct {
+			name   string
+			width  int
+			render func(*renderer, *strings.Builder, *gitdiff.TextFragment, *highlighter)
+		}{
+			{"side", 120, func(r *renderer, b *strings.Builder, frag *gitdiff.TextFragment, hl *highlighter) {
+				r.renderSideBySide(b, frag, hl, Annotations{})
+			}},
+			{"unified", 60, func(r *renderer, b *strings.Builder, frag *gitdiff.TextFragment, hl *highlighter) {
+				r.renderUnified(b, frag, hl, Annotations{}, r.pairWordDiffs(frag.Lines))
+			}},
+			{"accessible", 60, func(r *renderer, b *strings.Builder, frag *gitdiff.TextFragment, _ *highlighter) {
+				r.renderAccessible(b, frag, Annotations{})
+			}},
+		} {
+			t.Run(base+"/"+layout.name, func(t *testing.T) {
+				r := newRenderer(Options{Width: layout.width})
+				hl := r.highlighter(name)
+				var b strings.Builder
+				for _, frag := range f.TextFragments {
+					layout.render(r, &b, frag, hl)
+				}
+				got := b.String()
+				for i, l := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+					if w := lipgloss.Width(l); w > layout.width {
+						t.Errorf("line %d is %d wide, past %d: %q", i+1, w, layout.width, l)
+					}
+				}
+				golden(t, filepath.Join("render", base+"."+layout.name+".golden"), got)
+			})
+		}
+	}
+}
+
+func TestRenderDiffWidths(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "render", "modify.go.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, width := range []int{40, 119, 120, 200} {
+		for _, side := range []bool{false, true} {
+			rendered, hunks, err := Diff(string(raw), Options{Width: width, SideBySide: side})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hunks) != 1 {
+				t.Errorf("width %d: got %d hunks, want 1", width, len(hunks))
+			}
+			for i, l := range strings.Split(strings.TrimSuffix(rendered, "\n"), "\n") {
+				if w := lipgloss.Width(l); w > width {
+					t.Errorf("width %d: line %d is %d wide: %q", width, i+1, w, l)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamedRenderMatches(t *testing.T) {
+	// a rewrite longer than a streamed part, between context lines
+	var b strings.Builder
+	b.WriteString("diff --git a/x.go b/x.go\n--- a/x.go\n+++ b/x.go\n@@ -1,1003 +1,703 @@\n ctx\n")
+	for i := range 1000 {
+		fmt.Fprintf(&b, "-var x%d = %d\n", i, i)
+	}
+	for i := range 700 {
+		fmt.Fprintf(&b, "+var x%d = %d + 1\n", i, i)
+	}
+	b.WriteString(" ctx\n ctx\n")
+	raw := b.String()
+	for _, width := range []int{60, 200} {
+		want, _, _ := Diff(raw, Options{Width: width, SideBySide: width >= 120})
+		updates := 0
+		got, hunks, _ := Diff(raw, Options{Width: width, SideBySide: width >= 120, Progress: func(string, []Hunk) bool {
+			updates++
+			return true
+		}})
+		if updates < 2 {
+			t.Errorf("width %d: rendered in %d parts, want it cut up", width, updates)
+		}
+		if got != want {
+			t.Errorf("width %d: streamed render differs from the whole", width)
+		}
+		if len(hunks) != 1 || hunks[0].End != strings.Count(got, "\n") {
+			t.Errorf("width %d: got hunks %+v", width, hunks)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package render
+
+import "github.com/bluekeyes/go-gitdiff/gitdiff"
+
+const streamChunk = 400 // lines rendered between updates
+
+// splitFragment cuts frag into parts of about n lines that render the same
+// as the whole. Where rows is set, as side by side, removals share rows with
+// the additions that follow them, so a long run of both is cut into parts
+// holding the same rows' removals and additions. Otherwise lines are cut
+// where they fall, each part a slice of frag's lines.
+func splitFragment(frag *gitdiff.TextFragment, n int, rows bool) []*gitdiff.TextFragment {
+	lines := frag.Lines
+	if len(lines) <= n {
+		return []*gitdiff.TextFragment{frag}
+	}
+	var parts []*gitdiff.TextFragment
+	oldPos, newPos := frag.OldPosition, frag.NewPosition
+	cut := func(part []gitdiff.Line) {
+		parts = append(parts, &gitdiff.TextFragment{OldPosition: oldPos, NewPosition: newPos, Lines: part})
+		for _, l := range part {
+			if l.Op != gitdiff.OpAdd {
+				oldPos++
+			}
+			if l.Op != gitdiff.OpDelete {
+				newPos++
+			}
+		}
+	}
+	if !rows {
+		for i := 0; i < len(lines); i += n {
+			cut(lines[i:min(i+n, len(lines))])
+		}
+		return parts
+	}
+
+	start := 0
+	for i := 0; i < len(lines); {
+		// the removals at i and the additions after them, which share rows
+		j := i
+		for j < len(lines) && lines[j].Op == gitdiff.OpDelete {
+			j++
+		}
+		k := j
+		for k < len(lines) && lines[k].Op == gitdiff.OpAdd {
+			k++
+		}
+		if k == i {
+			k++
+		}
+		if dels, adds := lines[i:j], lines[j:k]; max(len(dels), len(adds)) > n {
+			if start < i {
+				cut(lines[start:i])
+			}
+			for r := 0; r < max(len(dels), len(adds)); r += n {
+				part := append([]gitdiff.Line(nil), dels[min(r, len(dels)):min(r+n, len(dels))]...)
+				cut(append(part, adds[min(r, len(adds)):min(r+n, len(adds))]...))
+			}
+			start = k
+		} else if k-start >= n {
+			cut(lines[start:k])
+			start = k
+		}
+		i = k
+	}
+	if start < len(lines) {
+		cut(lines[start:])
+	}
+	return parts
+}
+
+// truncateDiff keeps about limit bytes of diff lines, and at most lineLimit
+// lines, either unlimited when 0. It reports how many lines it cut, 0 when
+// none.
+func truncateDiff(files []*gitdiff.File, limit, lineLimit int) ([]*gitdiff.File, int) {
+	total := 0
+	for _, f := range files {
+		for _, frag := range f.TextFragments {
+			total += len(frag.Lines)
+		}
+	}
+	n, lines := 0, 0
+	for i, f := range files {
+		for j, frag := range f.TextFragments {
+			for k, l := range frag.Lines {
+				n += len(l.Line)
+				if lines++; (limit <= 0 || n <= limit) && (lineLimit <= 0 || lines <= lineLimit) {
+					continue
+				}
+				cut := *frag
+				cut.Lines = frag.Lines[:k]
+				trimmed := *f
+				trimmed.TextFragments = append(f.TextFragments[:j:j], &cut)
+				return append(files[:i:i], &trimmed), total - lines + 1
+			}
+		}
+	}
+	return files, 0
+}
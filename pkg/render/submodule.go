@@ -0,0 +1,72 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// gitSubmodule is git's mode for a submodule, whose content is the commit it
+// points at.
+const gitSubmodule = 0o160000
+
+// submoduleCommits reads the commits a submodule moved between from its diff,
+// "Subproject commit <sha>" lines with -dirty appended when its worktree has
+// changes. Either is "" when the submodule was added or removed.
+func submoduleCommits(f *gitdiff.File) (old, new string, dirty bool) {
+	for _, frag := range f.TextFragments {
+		for _, l := range frag.Lines {
+			sha, ok := strings.CutPrefix(trimLine(l.Line), "Subproject commit ")
+			if !ok {
+				continue
+			}
+			sha, d := strings.CutSuffix(sha, "-dirty")
+			switch l.Op {
+			case gitdiff.OpDelete:
+				old = sha
+			case gitdiff.OpAdd:
+				new, dirty = sha, d
+			case gitdiff.OpContext:
+				// only the submodule's worktree changed
+				old, new, dirty = sha, sha, d
+			}
+		}
+	}
+	return old, new, dirty
+}
+
+// submoduleSummaries says which commits a submodule moved between and lists
+// the commits it gained, when the submodule is checked out to look them up.
+func (r *renderer) submoduleSummaries(f *gitdiff.File) []Summary {
+	old, new, dirty := submoduleCommits(f)
+	var text string
+	switch {
+	case old == "":
+		text = "Subproject added at " + shortCommit(new)
+	case new == "":
+		text = "Subproject removed, was at " + shortCommit(old)
+	case old == new:
+		text = "Subproject commit " + shortCommit(new)
+	default:
+		text = "Subproject commit " + shortCommit(old) + " → " + shortCommit(new)
+	}
+	if dirty {
+		text += ", with uncommitted changes"
+	}
+	sums := []Summary{{text, r.Theme.HunkHeader}}
+	if old == "" || new == "" || old == new || r.SubmoduleLog == nil {
+		return sums
+	}
+	for _, line := range r.SubmoduleLog(f.NewName, old, new) {
+		sums = append(sums, Summary{"  " + line, r.Theme.Dim})
+	}
+	return sums
+}
+
+// shortCommit abbreviates a full SHA to the seven digits git log shows.
+func shortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
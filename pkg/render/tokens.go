@@ -0,0 +1,112 @@
+package render
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Token Cache ====================
+
+// tokenKey is a line as a lexer reads it: tabs expanded, and whole, however
+// much of it is shown.
+type tokenKey struct {
+	lang string
+	text string
+}
+
+// tokenCache holds lexed lines, least recently used evicted first past
+// tokenCacheLines, so a diff rendered again at another width, wrapped, or
+// side by side doesn't lex what it lexed before.
+var (
+	tokenMu    sync.Mutex
+	tokenCache = map[tokenKey]*list.Element{}
+	tokenLRU   = list.New() // of *tokenEntry, most recent at the front
+)
+
+const tokenCacheLines = 50000
+
+type tokenEntry struct {
+	key  tokenKey
+	toks []chroma.Token
+}
+
+// tokens returns text's tokens, from the cache or lexed and cached. They're
+// shared: callers mustn't change them.
+func (h *highlighter) tokens(text string) ([]chroma.Token, bool) {
+	k := tokenKey{h.lang, text}
+	tokenMu.Lock()
+	if el, ok := tokenCache[k]; ok {
+		tokenLRU.MoveToFront(el)
+		tokenMu.Unlock()
+		return el.Value.(*tokenEntry).toks, true
+	}
+	tokenMu.Unlock()
+
+	toks, ok := h.lex(text)
+	if !ok {
+		return nil, false
+	}
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	if _, ok := tokenCache[k]; !ok {
+		tokenCache[k] = tokenLRU.PushFront(&tokenEntry{key: k, toks: toks})
+		for tokenLRU.Len() > tokenCacheLines {
+			old := tokenLRU.Remove(tokenLRU.Back()).(*tokenEntry)
+			delete(tokenCache, old.key)
+		}
+	}
+	return toks, true
+}
+
+// lex tokenises text, uncached.
+func (h *highlighter) lex(text string) ([]chroma.Token, bool) {
+	iter, err := h.lexer.Tokenise(nil, text)
+	if err != nil {
+		return nil, false
+	}
+	return iter.Tokens(), true
+}
+
+// warmMin is the fewest lines worth lexing on the worker pool; fewer are
+// lexed as they're drawn.
+const warmMin = 64
+
+// warm lexes the lines not yet cached on the worker pool, ahead of drawing
+// them one by one.
+func (h *highlighter) warm(lines []gitdiff.Line) {
+	var todo []string
+	tokenMu.Lock()
+	for _, l := range lines {
+		text := h.r.expandTabs(trimLine(l.Line))
+		if _, ok := tokenCache[tokenKey{h.lang, text}]; !ok {
+			todo = append(todo, text)
+		}
+	}
+	tokenMu.Unlock()
+	if len(todo) < warmMin {
+		return
+	}
+	parallel(len(todo), func(i int) { h.tokens(todo[i]) })
+}
+
+// parallel runs work for 0..n-1 on up to GOMAXPROCS goroutines and returns
+// once they've all run.
+func parallel(n int, work func(i int)) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1)) - 1; i < n; i = int(next.Add(1)) - 1 {
+				work(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,294 @@
+package render
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// ==================== Word Diff ====================
+
+// wordSpan is the runes [start, end) of a line that changed within it.
+type wordSpan struct{ start, end int }
+
+// maxWordTokens bounds the token lists compared, as the comparison is
+// quadratic; longer lines are shown without word highlights.
+const maxWordTokens = 300
+
+// minWordSimilarity is the share of a pair of lines that must be unchanged
+// for word highlights to be shown. Lines that are mostly rewritten read better
+// as a plain removal and addition.
+const minWordSimilarity = 0.4
+
+// splitWords cuts s into words, runs of spaces, and single other characters.
+func splitWords(s string) []string {
+	var toks []string
+	for len(s) > 0 {
+		r, n := utf8.DecodeRuneInString(s)
+		switch {
+		case isWordRune(r):
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if !isWordRune(r) {
+					break
+				}
+				n += size
+			}
+		case unicode.IsSpace(r):
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				n += size
+			}
+		}
+		toks = append(toks, s[:n])
+		s = s[n:]
+	}
+	return toks
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordDiff finds the words that differ between a removed line and the added
+// line it became, as spans of each. Both are nil when the lines have too
+// little in common to be worth comparing word by word.
+func wordDiff(oldLine, newLine string) (oldSpans, newSpans []wordSpan) {
+	a, b := splitWords(oldLine), splitWords(newLine)
+	if len(a) > maxWordTokens || len(b) > maxWordTokens {
+		return nil, nil
+	}
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var same, posA, posB int
+	add := func(spans []wordSpan, pos, n int) []wordSpan {
+		if k := len(spans) - 1; k >= 0 && spans[k].end == pos {
+			spans[k].end += n
+			return spans
+		}
+		return append(spans, wordSpan{pos, pos + n})
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			n := utf8.RuneCountInString(a[i])
+			same += n
+			posA += n
+			posB += n
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			n := utf8.RuneCountInString(b[j])
+			newSpans = add(newSpans, posB, n)
+			posB += n
+			j++
+		default:
+			n := utf8.RuneCountInString(a[i])
+			oldSpans = add(oldSpans, posA, n)
+			posA += n
+			i++
+		}
+	}
+	if float64(same) < minWordSimilarity*float64(max(posA, posB)) {
+		return nil, nil
+	}
+	return oldSpans, newSpans
+}
+
+// pairWordDiffs word-diffs each removed line of a hunk against the added line
+// in the same place of the block of additions that follows, returning spans
+// indexed like lines.
+func (r *renderer) pairWordDiffs(lines []gitdiff.Line) [][]wordSpan {
+	var spans [][]wordSpan
+	for i := 0; i < len(lines); {
+		if lines[i].Op != gitdiff.OpDelete {
+			i++
+			continue
+		}
+		dels := i
+		for i < len(lines) && lines[i].Op == gitdiff.OpDelete {
+			i++
+		}
+		adds := i
+		for i < len(lines) && lines[i].Op == gitdiff.OpAdd {
+			i++
+		}
+		for k := 0; dels+k < adds && adds+k < i; k++ {
+			o, n := wordDiff(r.expandTabs(trimLine(lines[dels+k].Line)), r.expandTabs(trimLine(lines[adds+k].Line)))
+			if o == nil && n == nil {
+				continue
+			}
+			if spans == nil {
+				spans = make([][]wordSpan, len(lines))
+			}
+			spans[dels+k], spans[adds+k] = o, n
+		}
+	}
+	return spans
+}
+
+// mergeSpans combines two sorted lists of spans into one, joining spans that
+// overlap or touch.
+func mergeSpans(a, b []wordSpan) []wordSpan {
+	if len(b) == 0 {
+		return a
+	}
+	all := append(append([]wordSpan(nil), a...), b...)
+	slices.SortFunc(all, func(x, y wordSpan) int { return x.start - y.start })
+	merged := all[:1]
+	for _, s := range all[1:] {
+		if last := &merged[len(merged)-1]; s.start <= last.end {
+			last.end = max(last.end, s.end)
+		} else {
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// wordSpanAt reports whether rune pos is in one of spans, and for how many
+// of the next n runes that stays so.
+func wordSpanAt(spans []wordSpan, pos, n int) (in bool, run int) {
+	for _, s := range spans {
+		switch {
+		case pos < s.start:
+			return false, min(s.start-pos, n)
+		case pos < s.end:
+			return true, min(s.end-pos, n)
+		}
+	}
+	return false, n
+}
+
+// ==================== Line Alignment ====================
+
+// minPairSimilarity is how alike a removed and an added line must be to be
+// taken for the same line changed, and drawn on one row side by side.
+const minPairSimilarity = 0.5
+
+// maxAlignCells bounds the removed times added lines compared to align a
+// block; bigger ones are zipped line by line.
+const maxAlignCells = 40000
+
+// alignLines pairs removed lines with the added lines they most likely
+// became, in order, as index pairs with -1 for a side left blank. Lines that
+// match nothing between two pairs are zipped together, so a block rewritten
+// from scratch still fills both sides.
+func alignLines(dels, adds []string) [][2]int {
+	n, m := len(dels), len(adds)
+	if n == 0 || m == 0 || n*m > maxAlignCells {
+		return zipLines(nil, 0, n, 0, m)
+	}
+	db, ab := make([][]uint64, n), make([][]uint64, m)
+	for i, s := range dels {
+		db[i] = bigrams(s)
+	}
+	for j, s := range adds {
+		ab[j] = bigrams(s)
+	}
+	// score[i][j] is the greatest total similarity of pairs of dels[i:] and adds[j:]
+	sim := make([][]float64, n)
+	score := make([][]float64, n+1)
+	for i := range score {
+		score[i] = make([]float64, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		sim[i] = make([]float64, m)
+		for j := m - 1; j >= 0; j-- {
+			sim[i][j] = lineSimilarity(dels[i], adds[j], db[i], ab[j])
+			best := max(score[i+1][j], score[i][j+1])
+			if s := sim[i][j]; s >= minPairSimilarity {
+				best = max(best, s+score[i+1][j+1])
+			}
+			score[i][j] = best
+		}
+	}
+	var pairs [][2]int
+	i, j, gapI, gapJ := 0, 0, 0, 0
+	for i < n && j < m {
+		switch s := sim[i][j]; {
+		case s >= minPairSimilarity && score[i][j] == s+score[i+1][j+1]:
+			pairs = append(zipLines(pairs, gapI, i, gapJ, j), [2]int{i, j})
+			i, j = i+1, j+1
+			gapI, gapJ = i, j
+		case score[i+1][j] >= score[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return zipLines(pairs, gapI, n, gapJ, m)
+}
+
+// zipLines appends removed lines i0 to i1 paired with added lines j0 to j1
+// by position, the shorter run's side left blank past its end.
+func zipLines(pairs [][2]int, i0, i1, j0, j1 int) [][2]int {
+	for k := 0; i0+k < i1 || j0+k < j1; k++ {
+		p := [2]int{-1, -1}
+		if i0+k < i1 {
+			p[0] = i0 + k
+		}
+		if j0+k < j1 {
+			p[1] = j0 + k
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs
+}
+
+// bigrams lists s's pairs of adjacent runes, leading and trailing space
+// aside, sorted.
+func bigrams(s string) []uint64 {
+	rs := []rune(strings.TrimSpace(s))
+	bs := make([]uint64, 0, max(len(rs)-1, 0))
+	for k := 1; k < len(rs); k++ {
+		bs = append(bs, uint64(rs[k-1])<<32|uint64(rs[k]))
+	}
+	slices.Sort(bs)
+	return bs
+}
+
+// lineSimilarity is how much two lines share, from 0 to 1, as the Dice
+// coefficient of their bigrams.
+func lineSimilarity(a, b string, ab, bb []uint64) float64 {
+	if strings.TrimSpace(a) == strings.TrimSpace(b) {
+		return 1
+	}
+	if len(ab) == 0 || len(bb) == 0 {
+		return 0
+	}
+	common := 0
+	for i, j := 0, 0; i < len(ab) && j < len(bb); {
+		switch {
+		case ab[i] == bb[j]:
+			common++
+			i++
+			j++
+		case ab[i] < bb[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return 2 * float64(common) / float64(len(ab)+len(bb))
+}